@@ -0,0 +1,93 @@
+// Copyright (c) 2016 Pulcy.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"github.com/pulcy/quark/providers"
+	"github.com/pulcy/quark/providers/audit"
+)
+
+var (
+	// auditLog records all changes made by the running command.
+	auditLog = audit.New(log)
+)
+
+// newAuditLog creates the audit log that sends all events to the debug log, the audit file and the webhook (if any).
+// During a dry run nothing is changed, so nothing is recorded.
+func newAuditLog() *audit.Log {
+	if dryRun {
+		return audit.New(log)
+	}
+	sinks := []audit.Sink{audit.NewLogSink(log)}
+	if auditFile != "" {
+		sinks = append(sinks, audit.NewFileSink(auditFile))
+	}
+	if auditWebhook != "" {
+		sinks = append(sinks, audit.NewWebhookSink(auditWebhook))
+	}
+	return audit.New(log, sinks...)
+}
+
+// startAuditOperation records the start of the given operation on the given cluster.
+func startAuditOperation(provider providers.CloudProvider, info providers.ClusterInfo, operation string) {
+	if dryRun || auditLog.IsStarted() {
+		return
+	}
+	info.ID = auditClusterID(provider, info)
+	auditLog.Start(operation, info, auditOperator())
+}
+
+// auditOperator returns the identity of the operator running quark.
+func auditOperator() audit.Operator {
+	operator := audit.Operator{
+		User: audit.LocalUser(),
+		Git:  audit.GitUser(),
+	}
+	if vaultCfg.GithubToken != "" {
+		login, err := audit.GithubLogin(ctx, vaultCfg.GithubToken)
+		if err != nil {
+			log.Warningf("Cannot get the GitHub login of the github-token: %v", err)
+		} else {
+			operator.GitHub = login
+		}
+	}
+	return operator
+}
+
+// auditClusterID returns the ID of the given cluster, taken from the given info, the recorded state
+// or the instances of the cluster. It returns an empty string when the ID is unknown.
+func auditClusterID(provider providers.CloudProvider, info providers.ClusterInfo) string {
+	if info.ID != "" {
+		return info.ID
+	}
+	if c := loadClusterState(provider, info); c != nil && c.Cluster.ID != "" {
+		return c.Cluster.ID
+	}
+	instances, err := provider.GetInstances(ctx, info)
+	if err != nil || len(instances) == 0 {
+		return ""
+	}
+	id, err := instances.GetClusterID(ctx, log)
+	if err != nil {
+		log.Debugf("Cannot get ID of %s: %v", info, err)
+		return ""
+	}
+	return id
+}
+
+// auditStep records that the step with given name has finished (on the given instance, if any).
+func auditStep(name, instance string) {
+	auditLog.Emit(audit.Event{Type: audit.EventStepFinished, Name: name, Instance: instance})
+}
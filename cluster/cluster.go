@@ -32,6 +32,12 @@ type Cluster struct {
 	Domain string `mapstructure:"domain"`
 	// SSH tunnel needed to reach the cluster (optional)
 	Tunnel string `mapstructure:"tunnel,omitempty"`
+	// Account name used to SSH into instances (optional)
+	SSHUser string `mapstructure:"ssh-user,omitempty"`
+	// Port of the SSH server on instances (optional)
+	SSHPort int `mapstructure:"ssh-port,omitempty"`
+	// Private key files used to SSH into instances (optional)
+	SSHKeyFiles []string `mapstructure:"ssh-key-files,omitempty"`
 	// Size of the cluster (in instances==machines)
 	InstanceCount int `mapstructure:"instance-count,omitempty"`
 	// Default network type
@@ -63,6 +69,9 @@ func (c Cluster) validate() error {
 	if c.Tunnel == "" {
 		return maskAny(errgo.WithCausef(nil, ValidationError, "Tunnel missing"))
 	}
+	if c.SSHPort < 0 || c.SSHPort > 65535 {
+		return maskAny(errgo.WithCausef(nil, ValidationError, "SSHPort out of range"))
+	}
 	if c.InstanceCount == 0 {
 		return maskAny(errgo.WithCausef(nil, ValidationError, "InstanceCount missing"))
 	} else if c.InstanceCount < 0 {
@@ -96,3 +105,25 @@ func (c Cluster) ResolveProfile(name string) (map[string]interface{}, error) {
 	result["instance-count"] = c.InstanceCount
 	return result, nil
 }
+
+// ProfileInstanceCount returns the number of instances the profile with given name should have.
+// If the profile does not specify an `instance-count` and it is the only profile in the cluster,
+// the instance count of the cluster is used.
+// If neither is the case, false is returned.
+func (c Cluster) ProfileInstanceCount(name string) (int, bool, error) {
+	p, err := c.QuarkOptions.get(name)
+	if err != nil {
+		return 0, false, maskAny(err)
+	}
+	count, ok, err := p.InstanceCount()
+	if err != nil {
+		return 0, false, maskAny(err)
+	}
+	if ok {
+		return count, true, nil
+	}
+	if len(c.QuarkOptions.Profiles) == 1 {
+		return c.InstanceCount, true, nil
+	}
+	return 0, false, nil
+}
@@ -14,9 +14,37 @@
 
 package cluster
 
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/juju/errgo"
+)
+
+const (
+	instanceCountKey = "instance-count"
+)
+
 // Profile contains options that are specific to one profile.
 // settings in a profile overwrite settings in QuarkOptions.
 type Profile struct {
 	Name   string `mapstructure:"-"`
 	Values map[string]interface{}
 }
+
+// InstanceCount returns the `instance-count` value of the profile.
+// If the profile does not specify an instance count, false is returned.
+func (p Profile) InstanceCount() (int, bool, error) {
+	raw, ok := p.Values[instanceCountKey]
+	if !ok {
+		return 0, false, nil
+	}
+	count, err := strconv.Atoi(fmt.Sprintf("%v", raw))
+	if err != nil {
+		return 0, false, maskAny(errgo.WithCausef(nil, ValidationError, "instance-count of profile '%s' is not a number", p.Name))
+	}
+	if count < 0 {
+		return 0, false, maskAny(errgo.WithCausef(nil, ValidationError, "instance-count of profile '%s' is negative", p.Name))
+	}
+	return count, true, nil
+}
@@ -0,0 +1,266 @@
+// Copyright (c) 2016 Pulcy.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/juju/errgo"
+	"github.com/ryanuber/columnize"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	"golang.org/x/sync/errgroup"
+
+	"github.com/pulcy/quark/providers"
+)
+
+var (
+	cmdApplyCluster = &cobra.Command{
+		Short: "Create or destroy instances until the cluster matches its cluster file",
+		Long:  "Create or destroy instances until the number of instances of every profile in the cluster file matches its instance-count",
+		Use:   "apply",
+		Run:   applyCluster,
+		Example: `Scale 'mycluster' to the instance counts of the profiles in its cluster file.
+	./quark cluster apply -c mycluster
+`,
+	}
+
+	applyClusterFlags struct {
+		drainFlags
+		Force bool
+	}
+)
+
+func init() {
+	cmdApplyCluster.Flags().BoolVar(&applyClusterFlags.Force, "force", false, "If set, instances are destroyed even when that breaks etcd quorum or removes the last instance with a role or draining it fails")
+	addDrainFlags(cmdApplyCluster.Flags(), &applyClusterFlags.drainFlags)
+	cmdCluster.AddCommand(cmdApplyCluster)
+}
+
+// applyProfile is the desired state of a single profile in a cluster file.
+type applyProfile struct {
+	Name          string
+	InstanceCount int
+	Options       providers.CreateInstanceOptions
+	Instances     providers.ClusterInstanceList // Existing instances with the roles of this profile
+}
+
+// Roles returns the roles of instances created with this profile.
+func (p *applyProfile) Roles() string {
+	return p.Options.Roles()
+}
+
+// ToCreate returns the number of instances to create for this profile.
+func (p *applyProfile) ToCreate() int {
+	if n := p.InstanceCount - len(p.Instances); n > 0 {
+		return n
+	}
+	return 0
+}
+
+// ToDestroy returns the instances to destroy for this profile.
+func (p *applyProfile) ToDestroy() providers.ClusterInstanceList {
+	n := len(p.Instances) - p.InstanceCount
+	if n <= 0 {
+		return nil
+	}
+	sorted := append(providers.ClusterInstanceList(nil), p.Instances...)
+	sort.Sort(instancesByName(sorted))
+	return sorted[len(sorted)-n:]
+}
+
+type instancesByName providers.ClusterInstanceList
+
+func (l instancesByName) Len() int           { return len(l) }
+func (l instancesByName) Less(i, j int) bool { return l[i].Name < l[j].Name }
+func (l instancesByName) Swap(i, j int)      { l[i], l[j] = l[j], l[i] }
+
+func applyCluster(cmd *cobra.Command, args []string) {
+	if cluster == "" {
+		Exitf("Please specify a cluster (-c cluster)\n")
+	}
+	if strings.Contains(cluster, "@") {
+		Exitf("Apply uses all profiles of the cluster, do not specify a profile\n")
+	}
+	c, _ := loadCluster(cluster)
+	provider := newProvider()
+
+	// Collect the desired state of all profiles
+	var profiles []*applyProfile
+	var skipped []string
+	for _, p := range c.QuarkOptions.Profiles {
+		count, ok, err := c.ProfileInstanceCount(p.Name)
+		if err != nil {
+			Exitf("Invalid profile '%s': %v\n", p.Name, err)
+		}
+		if !ok {
+			skipped = append(skipped, p.Name)
+			continue
+		}
+		values, err := c.ResolveProfile(p.Name)
+		if err != nil {
+			Exitf("Cannot resolve profile '%s': %v\n", p.Name, err)
+		}
+		options := providers.CreateInstanceOptions{}
+		flagSet := pflag.NewFlagSet(p.Name, pflag.ContinueOnError)
+		addCreateInstanceFlags(flagSet, &options)
+		setFlagsFromValues(flagSet, values)
+		options.VaultAddress = vaultCfg.VaultAddr
+		options.VaultCertificatePath = vaultCfg.VaultCACert
+		options.VaultServerKeyPath = vaultCfg.VaultCAKey
+		options.VaultServerKeyCommand = vaultCfg.VaultCAKeyCommand
+		options.ClusterInfo = provider.ClusterDefaults(options.ClusterInfo)
+		applyClusterStateDefaults(flagSet, provider, options.ClusterInfo)
+
+		ap := &applyProfile{
+			Name:          p.Name,
+			InstanceCount: count,
+			Options:       options,
+		}
+		for _, other := range profiles {
+			if other.Roles() == ap.Roles() {
+				Exitf("Profiles '%s' and '%s' have the same roles (%s), cannot tell their instances apart\n", other.Name, ap.Name, ap.Roles())
+			}
+		}
+		profiles = append(profiles, ap)
+	}
+	if len(profiles) == 0 {
+		Exitf("Cluster %s has no profiles with an instance-count\n", cluster)
+	}
+	clusterInfo := profiles[0].Options.ClusterInfo
+	if clusterInfo.Name == "" {
+		Exitf("Please specify a name\n")
+	}
+	for _, p := range profiles[1:] {
+		if info := p.Options.ClusterInfo; info.String() != clusterInfo.String() {
+			Exitf("Profiles '%s' and '%s' belong to different clusters (%s and %s)\n", profiles[0].Name, p.Name, clusterInfo, info)
+		}
+	}
+	lockCluster(provider, clusterInfo, "cluster apply")
+
+	// Match existing instances to profiles by their roles
+	instances, err := provider.GetInstances(ctx, clusterInfo)
+	if err != nil {
+		Exitf("Failed to list instances: %v\n", err)
+	}
+	if len(instances) == 0 {
+		Exitf("Cluster %s does not exist, use `quark cluster create` first.\n", clusterInfo)
+	}
+	roles := make([]string, len(instances))
+	g := errgroup.Group{}
+	for idx, i := range instances {
+		idx, i := idx, i
+		g.Go(func() error {
+			r, err := i.GetRoles(ctx, log)
+			if errgo.Cause(err) == providers.UnknownRolesError {
+				log.Warningf("Leaving %s alone: %v", i.Name, err)
+				return nil
+			} else if err != nil {
+				return maskAny(err)
+			}
+			roles[idx] = r
+			return nil
+		})
+	}
+	if err := g.Wait(); err != nil {
+		Exitf("Failed to fetch instance roles: %v\n", err)
+	}
+	var unmanaged []string
+	for idx, i := range instances {
+		if roles[idx] == "" {
+			unmanaged = append(unmanaged, fmt.Sprintf("%s (unknown roles)", i.Name))
+			continue
+		}
+		matched := false
+		for _, p := range profiles {
+			if p.Roles() == roles[idx] {
+				p.Instances = append(p.Instances, i)
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			unmanaged = append(unmanaged, fmt.Sprintf("%s (%s)", i.Name, roles[idx]))
+		}
+	}
+
+	// Show plan
+	changes := 0
+	lines := []string{"Profile | Roles | Desired | Actual | Action"}
+	for _, p := range profiles {
+		action := "none"
+		if n := p.ToCreate(); n > 0 {
+			action = fmt.Sprintf("create %d", n)
+			changes += n
+		} else if l := p.ToDestroy(); len(l) > 0 {
+			var names []string
+			for _, i := range l {
+				names = append(names, i.Name)
+			}
+			action = fmt.Sprintf("destroy %s", strings.Join(names, ","))
+			changes += len(l)
+		}
+		lines = append(lines, fmt.Sprintf("%s | %s | %d | %d | %s", p.Name, p.Roles(), p.InstanceCount, len(p.Instances), action))
+	}
+	fmt.Println(columnize.SimpleFormat(lines))
+	if len(skipped) > 0 {
+		fmt.Printf("Profiles without instance-count (ignored): %s\n", strings.Join(skipped, ", "))
+	}
+	if len(unmanaged) > 0 {
+		fmt.Printf("Instances not matching any profile (left alone): %s\n", strings.Join(unmanaged, ", "))
+	}
+	if changes == 0 {
+		Infof("Cluster %s is up to date\n", clusterInfo)
+		return
+	}
+
+	// Confirm
+	if err := confirm(fmt.Sprintf("Are you sure you want to apply this plan to %s?", clusterInfo)); err != nil {
+		Exitf("%v\n", err)
+	}
+
+	// Record the roles of matched instances that were derived from their fleet metadata,
+	// so later runs match them the same way
+	for _, p := range profiles {
+		for _, i := range p.Instances {
+			if _, err := i.RecordRoles(ctx, log); err != nil {
+				log.Warningf("Failed to record the roles of %s: %v", i.Name, err)
+			}
+		}
+	}
+
+	// Create new instances first, so the cluster never runs with less capacity than needed
+	for _, p := range profiles {
+		for n := p.ToCreate(); n > 0; n-- {
+			log.Infof("Creating new %s instance on %s", p.Name, clusterInfo)
+			createClusterInstance(provider, p.Options, false)
+		}
+	}
+	for _, p := range profiles {
+		for _, i := range p.ToDestroy() {
+			info := providers.ClusterInstanceInfo{
+				ClusterInfo: clusterInfo,
+				Prefix:      strings.SplitN(i.Name, ".", 2)[0],
+			}
+			log.Infof("Destroying %s instance %s", p.Name, info)
+			checkInstanceRemoval(provider, info, applyClusterFlags.Force)
+			destroyClusterInstance(provider, info, applyClusterFlags.drainFlags, applyClusterFlags.Force)
+		}
+	}
+
+	Successf("Cluster %s applied\n", clusterInfo)
+}
@@ -22,6 +22,8 @@ import (
 	"github.com/spf13/cobra"
 
 	"github.com/pulcy/quark/providers"
+	"github.com/pulcy/quark/providers/journal"
+	"github.com/pulcy/quark/providers/state"
 )
 
 var (
@@ -30,7 +32,8 @@ var (
 		Run: createCluster,
 	}
 
-	createClusterFlags providers.CreateClusterOptions
+	createClusterFlags        providers.CreateClusterOptions
+	createClusterJournalFlags journalFlags
 )
 
 func init() {
@@ -55,6 +58,7 @@ func init() {
 	cmdCreateCluster.Flags().StringVar(&createClusterFlags.WeavePassword, "weave-password", "", "Password of the weave network")
 	cmdCreateCluster.Flags().BoolVar(&createClusterFlags.EnableFleet, "fleet-enabled", true, "If set, Fleet will be installed on the cluster")
 	cmdCreateCluster.Flags().BoolVar(&createClusterFlags.EnableKubernetes, "kubernetes-enabled", true, "If set, Kubernetes will be installed on the cluster")
+	addJournalFlags(cmdCreateCluster.Flags(), &createClusterJournalFlags)
 	cmdCluster.AddCommand(cmdCreateCluster)
 }
 
@@ -68,7 +72,87 @@ func createCluster(cmd *cobra.Command, args []string) {
 	loadArgumentsFromCluster(cmd.Flags(), requireProfile)
 	clusterInfoFromArgs(&createClusterFlags.ClusterInfo, args)
 
+	// A cluster that does not exist yet cannot be locked, only an earlier attempt that failed
 	provider := newProvider()
+	lockCluster(provider, createClusterFlags.ClusterInfo, operationCreateCluster)
+	if createClusterJournalFlags.Rollback {
+		rollbackJournal(openJournal(operationCreateCluster, createClusterFlags.ClusterInfo, createClusterJournalFlags))
+		Successf("Rolled back cluster creation\n")
+		return
+	}
+
+	j := openJournal(operationCreateCluster, createClusterFlags.ClusterInfo, createClusterJournalFlags)
+	if j.HasOptions() {
+		// Secrets are not recorded, the registry password is taken from the flags again.
+		// The weave password is only needed when the cluster has not been created yet.
+		registryPassword, weavePassword := createClusterFlags.PrivateRegistryPassword, createClusterFlags.WeavePassword
+		if err := j.GetOptions(&createClusterFlags); err != nil {
+			Exitf("Failed to load options from journal: %v\n", err)
+		}
+		createClusterFlags.PrivateRegistryPassword, createClusterFlags.WeavePassword = registryPassword, weavePassword
+		if !j.IsDone(journal.KindCluster, createClusterFlags.ClusterInfo.String()) {
+			if createClusterFlags.PrivateRegistryUserName != "" && createClusterFlags.PrivateRegistryPassword == "" {
+				Exitf("Please specify --private-registry-password again, it is not recorded in the journal\n")
+			}
+			if createClusterFlags.WeavePassword == "" {
+				createClusterFlags.WeavePassword = uniuri.NewLen(40)
+			}
+		}
+	} else {
+		prepareCreateClusterOptions(provider)
+		if err := j.SetOptions(createClusterFlags); err != nil {
+			Exitf("Failed to record options in journal: %v\n", err)
+		}
+	}
+	auditLog.SetClusterID(createClusterFlags.ID)
+
+	// Create
+	name := createClusterFlags.ClusterInfo.String()
+	if !j.IsDone(journal.KindCluster, name) {
+		if len(j.Pending(journal.KindCluster)) > 0 {
+			Exitf("Creation of cluster %s was interrupted, it cannot be resumed.\n", name)
+		}
+		if err := journal.NewProvider(j, provider).CreateCluster(ctx, log, createClusterFlags, newDnsProvider()); err != nil {
+			Exitf("Failed to create new cluster: %v\n", err)
+		}
+	}
+
+	// Describe the new instances, while they can still be reached before their reboot
+	c := newClusterState(createClusterFlags.ClusterInfo)
+	c.Options = state.OptionsFromCluster(createClusterFlags)
+	syncClusterState(provider, c)
+
+	// Update all members
+	if !j.IsDone(journal.KindStep, stepUpdateMembers) {
+		reboot := true
+		if err := providers.UpdateClusterMembers(ctx, log, createClusterFlags.ClusterInfo, reboot, nil, provider); err != nil {
+			Exitf("Failed to update cluster members: %v\n", err)
+		}
+		recordStep(j, stepUpdateMembers)
+	}
+
+	finishJournal(j)
+
+	// Record the new cluster
+	if stateStore == stateStoreEtcd && !dryRun {
+		// The state is kept in etcd, which is available once the instances have rebooted
+		instances, err := provider.GetInstances(ctx, createClusterFlags.ClusterInfo)
+		if err != nil {
+			log.Warningf("Failed to list instances: %v", err)
+		} else if len(instances) > 0 {
+			if err := instances[0].WaitUntilHealthy(ctx, log, defaultHealthTimeout); err != nil {
+				log.Warningf("Cluster is not healthy: %v", err)
+			}
+		}
+	}
+	saveClusterState(provider, c)
+
+	Successf("Cluster created with ID: %s\n", createClusterFlags.ID)
+}
+
+// prepareCreateClusterOptions completes and validates the options of the new cluster
+// and asks for confirmation.
+func prepareCreateClusterOptions(provider providers.CloudProvider) {
 	createClusterFlags = provider.CreateClusterDefaults(createClusterFlags)
 
 	// Create cluster ID if needed
@@ -89,7 +173,7 @@ func createCluster(cmd *cobra.Command, args []string) {
 	}
 
 	// See if there are already instances for the given cluster
-	instances, err := provider.GetInstances(createClusterFlags.ClusterInfo)
+	instances, err := provider.GetInstances(ctx, createClusterFlags.ClusterInfo)
 	if err != nil {
 		Exitf("Failed to query existing instances: %v\n", err)
 	}
@@ -101,18 +185,4 @@ func createCluster(cmd *cobra.Command, args []string) {
 	if err := confirm(fmt.Sprintf("Are you sure you want to create a %d instance cluster of %s?", createClusterFlags.InstanceCount, createClusterFlags.InstanceConfig)); err != nil {
 		Exitf("%v\n", err)
 	}
-
-	// Create
-	err = provider.CreateCluster(log, createClusterFlags, newDnsProvider())
-	if err != nil {
-		Exitf("Failed to create new cluster: %v\n", err)
-	}
-
-	// Update all members
-	reboot := true
-	if err := providers.UpdateClusterMembers(log, createClusterFlags.ClusterInfo, reboot, nil, provider); err != nil {
-		Exitf("Failed to update cluster members: %v\n", err)
-	}
-
-	Infof("Cluster created with ID: %s\n", createClusterFlags.ID)
 }
@@ -53,11 +53,13 @@ func destroyCluster(cmd *cobra.Command, args []string) {
 	if destroyClusterFlags.Name == "" {
 		Exitf("Please specify a name\n")
 	}
+	lockCluster(provider, destroyClusterFlags, "cluster destroy")
 	if err := confirm(fmt.Sprintf("Are you sure you want to destroy %s?", destroyClusterFlags.String())); err != nil {
 		Exitf("%v\n", err)
 	}
-	err := provider.DeleteCluster(destroyClusterFlags, newDnsProvider())
+	err := provider.DeleteCluster(ctx, destroyClusterFlags, newDnsProvider())
 	if err != nil {
 		Exitf("Failed to destroy cluster: %v\n", err)
 	}
+	removeClusterState(provider, destroyClusterFlags)
 }
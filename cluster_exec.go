@@ -0,0 +1,240 @@
+// Copyright (c) 2016 Pulcy.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/juju/errgo"
+	"github.com/ryanuber/columnize"
+	"github.com/spf13/cobra"
+	"golang.org/x/sync/errgroup"
+
+	"github.com/pulcy/quark/providers"
+)
+
+var (
+	cmdExecCluster = &cobra.Command{
+		Short: "Run a command on all instances of a cluster",
+		Long:  "Run a command (given after `--`) on all instances of a cluster in parallel, optionally limited to instances with given roles",
+		Use:   "exec",
+		Run:   execCluster,
+	}
+
+	execClusterFlags struct {
+		providers.ClusterInfo
+		Roles    []string
+		Parallel int
+	}
+)
+
+func init() {
+	cmdExecCluster.Flags().StringVar(&execClusterFlags.Domain, "domain", defaultDomain(), "Cluster domain")
+	cmdExecCluster.Flags().StringVar(&execClusterFlags.Name, "name", "", "Cluster name")
+	cmdExecCluster.Flags().StringSliceVar(&execClusterFlags.Roles, "role", nil, "Only run on instances with one of these roles")
+	cmdExecCluster.Flags().IntVar(&execClusterFlags.Parallel, "parallel", defaultExecParallel, "Maximum number of instances to run the command on at the same time")
+	cmdCluster.AddCommand(cmdExecCluster)
+}
+
+// execResult holds the outcome of a command on a single instance.
+type execResult struct {
+	Instance   providers.ClusterInstance
+	ExitStatus int
+	Err        error
+}
+
+func execCluster(cmd *cobra.Command, args []string) {
+	requireProfile := false
+	loadArgumentsFromCluster(cmd.Flags(), requireProfile)
+	args, command := splitArgsAtDash(cmd, args)
+	clusterInfoFromArgs(&execClusterFlags.ClusterInfo, args)
+
+	provider := newProvider()
+	execClusterFlags.ClusterInfo = provider.ClusterDefaults(execClusterFlags.ClusterInfo)
+
+	if execClusterFlags.Domain == "" {
+		Exitf("Please specify a domain\n")
+	}
+	if execClusterFlags.Name == "" {
+		Exitf("Please specify a name\n")
+	}
+	if command == "" {
+		Exitf("Please specify a command after `--`\n")
+	}
+	if execClusterFlags.Parallel < 1 {
+		Exitf("Please specify a parallel of at least 1\n")
+	}
+	instances, err := provider.GetInstances(ctx, execClusterFlags.ClusterInfo)
+	if err != nil {
+		Exitf("Failed to list instances: %v\n", err)
+	}
+	if len(execClusterFlags.Roles) > 0 {
+		instances = filterInstancesByRole(instances, execClusterFlags.Roles)
+	}
+	if len(instances) == 0 {
+		Exitf("No instances found in cluster %s\n", execClusterFlags.ClusterInfo)
+	}
+	sort.Sort(instancesByName(instances))
+
+	// Run the command on all instances in parallel
+	var outputMutex sync.Mutex
+	results := make([]execResult, len(instances))
+	running := make(chan struct{}, execClusterFlags.Parallel)
+	wg := sync.WaitGroup{}
+	for idx, i := range instances {
+		wg.Add(1)
+		go func(idx int, i providers.ClusterInstance) {
+			defer wg.Done()
+			select {
+			case running <- struct{}{}:
+				defer func() { <-running }()
+			case <-ctx.Done():
+				results[idx] = execResult{Instance: i, ExitStatus: -1, Err: ctx.Err()}
+				return
+			}
+			stdout := newPrefixWriter(os.Stdout, i.Name, &outputMutex)
+			stderr := newPrefixWriter(os.Stderr, i.Name, &outputMutex)
+			status, err := i.Stream(ctx, log, command, stdout, stderr)
+			stdout.Flush()
+			stderr.Flush()
+			results[idx] = execResult{Instance: i, ExitStatus: status, Err: err}
+		}(idx, i)
+	}
+	wg.Wait()
+
+	// Show the exit status of every instance
+	failed := 0
+	lines := []string{"Name | Exit code | Result"}
+	for _, r := range results {
+		result := "ok"
+		if r.Err != nil {
+			result = fmt.Sprintf("failed: %v", r.Err)
+			failed++
+		} else if r.ExitStatus != 0 {
+			result = "failed"
+			failed++
+		}
+		exitCode := ""
+		if r.ExitStatus >= 0 {
+			exitCode = fmt.Sprintf("%d", r.ExitStatus)
+		}
+		lines = append(lines, fmt.Sprintf("%s | %s | %s", r.Instance.Name, exitCode, result))
+	}
+	fmt.Println()
+	fmt.Println(columnize.SimpleFormat(lines))
+	if failed > 0 {
+		Exitf("Command failed on %d of %d instances\n", failed, len(results))
+	}
+}
+
+// filterInstancesByRole returns those instances that have at least one of the given roles.
+func filterInstancesByRole(instances providers.ClusterInstanceList, roles []string) providers.ClusterInstanceList {
+	instanceRoles := make([]string, len(instances))
+	g := errgroup.Group{}
+	for idx, i := range instances {
+		idx, i := idx, i
+		g.Go(func() error {
+			r, err := i.GetRoles(ctx, log)
+			if errgo.Cause(err) == providers.UnknownRolesError {
+				log.Warningf("Skipping %s: %v", i.Name, err)
+				return nil
+			} else if err != nil {
+				return maskAny(err)
+			}
+			instanceRoles[idx] = r
+			return nil
+		})
+	}
+	if err := g.Wait(); err != nil {
+		Exitf("Failed to fetch instance roles: %v\n", err)
+	}
+
+	result := providers.ClusterInstanceList{}
+	for idx, i := range instances {
+		if hasAnyRole(instanceRoles[idx], roles) {
+			result = append(result, i)
+		}
+	}
+	return result
+}
+
+// hasAnyRole returns true if the given comma separated list of roles contains one of the given roles.
+func hasAnyRole(instanceRoles string, roles []string) bool {
+	for _, r := range strings.Split(instanceRoles, ",") {
+		r = strings.TrimSpace(r)
+		for _, x := range roles {
+			if r != "" && r == x {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// prefixWriter writes complete lines to an underlying writer, prefixing each line with the name of an instance.
+// Writers that share a mutex never mix their lines.
+type prefixWriter struct {
+	w      io.Writer
+	prefix []byte
+	mutex  *sync.Mutex
+	buf    bytes.Buffer
+}
+
+func newPrefixWriter(w io.Writer, name string, mutex *sync.Mutex) *prefixWriter {
+	return &prefixWriter{
+		w:      w,
+		prefix: []byte(fmt.Sprintf("[%s] ", name)),
+		mutex:  mutex,
+	}
+}
+
+func (pw *prefixWriter) Write(p []byte) (int, error) {
+	pw.buf.Write(p)
+	for {
+		idx := bytes.IndexByte(pw.buf.Bytes(), '\n')
+		if idx < 0 {
+			return len(p), nil
+		}
+		line := pw.buf.Next(idx + 1)
+		if err := pw.writeLine(line); err != nil {
+			return 0, err
+		}
+	}
+}
+
+// Flush writes the last line, even when it is not terminated by a newline.
+func (pw *prefixWriter) Flush() error {
+	if pw.buf.Len() == 0 {
+		return nil
+	}
+	line := append(pw.buf.Bytes(), '\n')
+	pw.buf.Reset()
+	return pw.writeLine(line)
+}
+
+func (pw *prefixWriter) writeLine(line []byte) error {
+	pw.mutex.Lock()
+	defer pw.mutex.Unlock()
+	if _, err := pw.w.Write(append(pw.prefix[:len(pw.prefix):len(pw.prefix)], line...)); err != nil {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,140 @@
+// Copyright (c) 2016 Pulcy.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+	"sync"
+	"testing"
+
+	"github.com/spf13/cobra"
+
+	"github.com/pulcy/quark/providers"
+	"github.com/pulcy/quark/providers/sshtest"
+)
+
+func TestPrefixWriter(t *testing.T) {
+	var out bytes.Buffer
+	var mutex sync.Mutex
+	w1 := newPrefixWriter(&out, "i1", &mutex)
+	w2 := newPrefixWriter(&out, "i2", &mutex)
+
+	// Partial lines are held back until they are complete
+	w1.Write([]byte("hel"))
+	w2.Write([]byte("first\nsec"))
+	w1.Write([]byte("lo\nworld"))
+	w2.Write([]byte("ond\n"))
+	if expected := "[i2] first\n[i1] hello\n[i2] second\n"; out.String() != expected {
+		t.Errorf("Expected %q, got %q", expected, out.String())
+	}
+
+	// Flush writes the last unterminated line
+	w1.Flush()
+	w2.Flush()
+	if expected := "[i2] first\n[i1] hello\n[i2] second\n[i1] world\n"; out.String() != expected {
+		t.Errorf("Expected %q, got %q", expected, out.String())
+	}
+}
+
+func TestSplitArgsAtDash(t *testing.T) {
+	tests := []struct {
+		Args            []string
+		ExpectedArgs    []string
+		ExpectedCommand string
+	}{
+		{[]string{"c1.example.com"}, []string{"c1.example.com"}, ""},
+		{[]string{"c1.example.com", "--", "systemctl", "status", "fleet"}, []string{"c1.example.com"}, "systemctl status fleet"},
+		{[]string{"--", "uptime"}, []string{}, "uptime"},
+		{[]string{"c1.example.com", "--"}, []string{"c1.example.com"}, ""},
+	}
+	for _, test := range tests {
+		cmd := &cobra.Command{}
+		if err := cmd.Flags().Parse(test.Args); err != nil {
+			t.Fatalf("Parse(%v) failed: %v", test.Args, err)
+		}
+		args, command := splitArgsAtDash(cmd, cmd.Flags().Args())
+		if !reflect.DeepEqual(args, test.ExpectedArgs) || command != test.ExpectedCommand {
+			t.Errorf("splitArgsAtDash(%v): expected %v, '%s', got %v, '%s'", test.Args, test.ExpectedArgs, test.ExpectedCommand, args, command)
+		}
+	}
+}
+
+func TestHasAnyRole(t *testing.T) {
+	tests := []struct {
+		InstanceRoles string
+		Roles         []string
+		Expected      bool
+	}{
+		{"core,lb", []string{"lb"}, true},
+		{"core, lb", []string{"worker", "lb"}, true},
+		{"core,lb", []string{"worker"}, false},
+		{"", []string{""}, false},
+		{"worker", nil, false},
+	}
+	for _, test := range tests {
+		if actual := hasAnyRole(test.InstanceRoles, test.Roles); actual != test.Expected {
+			t.Errorf("hasAnyRole(%s, %v): expected %v, got %v", test.InstanceRoles, test.Roles, test.Expected, actual)
+		}
+	}
+}
+
+func TestFilterInstancesByRole(t *testing.T) {
+	roles := []string{"core,lb", "worker", "core,vault", ""}
+	servers := make(map[string]*sshtest.Server)
+	instances := providers.ClusterInstanceList{}
+	for idx, r := range roles {
+		s, err := sshtest.NewServer("")
+		if err != nil {
+			t.Fatalf("NewServer failed: %v", err)
+		}
+		defer s.Close()
+		if r != "" {
+			s.FS.WriteFile("/etc/pulcy/roles", r, 0644, "root")
+		} else {
+			// Instance of which the roles cannot be determined
+			s.Handle("fleetctl", func(cmd sshtest.Command) sshtest.Result {
+				return sshtest.Fail(1, "Error retrieving list of active machines\n")
+			})
+		}
+		i := providers.ClusterInstance{
+			Name:             fmt.Sprintf("i%d.c1.example.com", idx),
+			LoadBalancerIPv4: fmt.Sprintf("10.0.0.%d", idx+1),
+		}
+		servers[i.String()] = s
+		instances = append(instances, i)
+	}
+	defer sshtest.Install(servers)()
+
+	tests := []struct {
+		Roles    []string
+		Expected []string
+	}{
+		{[]string{"lb"}, []string{"i0.c1.example.com"}},
+		{[]string{"core"}, []string{"i0.c1.example.com", "i2.c1.example.com"}},
+		{[]string{"worker", "vault"}, []string{"i1.c1.example.com", "i2.c1.example.com"}},
+		{[]string{"other"}, nil},
+	}
+	for _, test := range tests {
+		var names []string
+		for _, i := range filterInstancesByRole(instances, test.Roles) {
+			names = append(names, i.Name)
+		}
+		if !reflect.DeepEqual(names, test.Expected) {
+			t.Errorf("filterInstancesByRole(%v): expected %v, got %v", test.Roles, test.Expected, names)
+		}
+	}
+}
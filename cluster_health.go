@@ -0,0 +1,102 @@
+// Copyright (c) 2016 Pulcy.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/pulcy/quark/providers"
+)
+
+const (
+	defaultCertExpiryWarning = time.Hour * 24 * 30
+
+	// Exit codes of `cluster health`, 1 is used by Exitf when the check itself could not be done
+	healthExitFail = 2
+	healthExitWarn = 3
+)
+
+var (
+	cmdHealthCluster = &cobra.Command{
+		Short: "Check the health of a cluster",
+		Long:  "Check SSH, cluster ID, etcd, gluon, tinc, vault CA certificates & DNS records of a cluster. Exits with 2 when a check failed, 3 on warnings and 1 when the health could not be checked",
+		Use:   "health",
+		Run:   healthCluster,
+	}
+
+	healthClusterFlags struct {
+		providers.ClusterInfo
+		CertExpiryWarning time.Duration
+		SkipDNS           bool
+	}
+)
+
+func init() {
+	cmdHealthCluster.Flags().StringVar(&healthClusterFlags.Domain, "domain", defaultDomain(), "Cluster domain")
+	cmdHealthCluster.Flags().StringVar(&healthClusterFlags.Name, "name", "", "Cluster name")
+	cmdHealthCluster.Flags().DurationVar(&healthClusterFlags.CertExpiryWarning, "cert-expiry-warning", defaultCertExpiryWarning, "Warn when the vault CA certificate expires within this period")
+	cmdHealthCluster.Flags().BoolVar(&healthClusterFlags.SkipDNS, "skip-dns", false, "If set, the DNS records of the cluster are not checked")
+	cmdCluster.AddCommand(cmdHealthCluster)
+}
+
+func healthCluster(cmd *cobra.Command, args []string) {
+	requireProfile := false
+	loadArgumentsFromCluster(cmd.Flags(), requireProfile)
+	clusterInfoFromArgs(&healthClusterFlags.ClusterInfo, args)
+
+	provider := newProvider()
+	healthClusterFlags.ClusterInfo = provider.ClusterDefaults(healthClusterFlags.ClusterInfo)
+
+	if healthClusterFlags.Domain == "" {
+		Exitf("Please specify a domain\n")
+	}
+	if healthClusterFlags.Name == "" {
+		Exitf("Please specify a name\n")
+	}
+	instances, err := provider.GetInstances(ctx, healthClusterFlags.ClusterInfo)
+	if err != nil {
+		Exitf("Failed to list instances: %v\n", err)
+	}
+	if len(instances) == 0 {
+		Exitf("Cluster %s has no instances\n", healthClusterFlags.ClusterInfo)
+	}
+
+	options := providers.HealthOptions{
+		ClusterInfo:       healthClusterFlags.ClusterInfo,
+		CertExpiryWarning: healthClusterFlags.CertExpiryWarning,
+	}
+	if !healthClusterFlags.SkipDNS {
+		options.DnsProvider = newDnsProvider()
+	}
+	report := instances.CheckHealth(ctx, log, options)
+
+	showOutput(report, func() []string {
+		lines := []string{"Check | Instance | Status | Message"}
+		for _, c := range report {
+			lines = append(lines, fmt.Sprintf("%s | %s | %s | %s", c.Check, c.Instance, c.Status, c.Message))
+		}
+		return lines
+	})
+
+	switch report.Status() {
+	case providers.HealthFail:
+		exitStatus = healthExitFail
+	case providers.HealthWarn:
+		exitStatus = healthExitWarn
+	}
+}
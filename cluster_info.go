@@ -17,7 +17,6 @@ package main
 import (
 	"fmt"
 
-	"github.com/ryanuber/columnize"
 	"github.com/spf13/cobra"
 
 	"github.com/pulcy/quark/providers"
@@ -32,6 +31,12 @@ var (
 	clusterInfoFlags providers.ClusterInfo
 )
 
+// clusterInfoOutput is the machine readable form of `cluster info`.
+type clusterInfoOutput struct {
+	ID        string `json:"id"`
+	Instances int    `json:"instances"`
+}
+
 func init() {
 	cmdClusterInfo.Flags().StringVar(&clusterInfoFlags.Domain, "domain", defaultDomain(), "Cluster domain")
 	cmdClusterInfo.Flags().StringVar(&clusterInfoFlags.Name, "name", "", "Cluster name")
@@ -49,19 +54,23 @@ func showClusterInfo(cmd *cobra.Command, args []string) {
 	if clusterInfoFlags.Name == "" {
 		Exitf("Please specify a name\n")
 	}
-	instances, err := provider.GetInstances(clusterInfoFlags)
+	instances, err := provider.GetInstances(ctx, clusterInfoFlags)
 	if err != nil {
 		Exitf("Failed to list instances: %v\n", err)
 	}
-	clusterMembers, err := instances.AsClusterMemberList(log, nil)
+	clusterMembers, err := instances.AsClusterMemberList(ctx, log, nil)
 	if err != nil {
 		Exitf("Failed to fetch instance member data: %v\n", err)
 	}
 
-	lines := []string{
-		fmt.Sprintf("ID | %s", clusterMembers[0].ClusterID),
-		fmt.Sprintf("#Instances | %d", len(instances)),
+	info := clusterInfoOutput{
+		ID:        clusterMembers[0].ClusterID,
+		Instances: len(instances),
 	}
-	result := columnize.SimpleFormat(lines)
-	fmt.Println(result)
+	showOutput(info, func() []string {
+		return []string{
+			fmt.Sprintf("ID | %s", info.ID),
+			fmt.Sprintf("#Instances | %d", info.Instances),
+		}
+	})
 }
@@ -0,0 +1,85 @@
+// Copyright (c) 2016 Pulcy.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/spf13/cobra"
+
+	"github.com/pulcy/quark/providers"
+)
+
+var (
+	cmdPinHostKeysCluster = &cobra.Command{
+		Short: "Trust the current SSH host keys of the instances of a cluster",
+		Long:  "Trust the current SSH host keys of the instances of a cluster, replacing the known host keys. Use this after instances have been rebuilt",
+		Use:   "pin-host-keys",
+		Run:   pinHostKeysCluster,
+	}
+
+	pinHostKeysFlags struct {
+		providers.ClusterInfo
+		Instance string
+	}
+)
+
+func init() {
+	cmdPinHostKeysCluster.Flags().StringVar(&pinHostKeysFlags.Domain, "domain", defaultDomain(), "Cluster domain")
+	cmdPinHostKeysCluster.Flags().StringVar(&pinHostKeysFlags.Name, "name", "", "Cluster name")
+	cmdPinHostKeysCluster.Flags().StringVar(&pinHostKeysFlags.Instance, "instance", "", "Name of the instance to pin the host key of (defaults to all instances)")
+	cmdCluster.AddCommand(cmdPinHostKeysCluster)
+}
+
+func pinHostKeysCluster(cmd *cobra.Command, args []string) {
+	requireProfile := false
+	loadArgumentsFromCluster(cmd.Flags(), requireProfile)
+	clusterInfoFromArgs(&pinHostKeysFlags.ClusterInfo, args)
+
+	provider := newProvider()
+	pinHostKeysFlags.ClusterInfo = provider.ClusterDefaults(pinHostKeysFlags.ClusterInfo)
+
+	if pinHostKeysFlags.Domain == "" {
+		Exitf("Please specify a domain\n")
+	}
+	if pinHostKeysFlags.Name == "" {
+		Exitf("Please specify a name\n")
+	}
+	instances, err := provider.GetInstances(ctx, pinHostKeysFlags.ClusterInfo)
+	if err != nil {
+		Exitf("Failed to list instances: %v\n", err)
+	}
+	selected := providers.ClusterInstanceList{}
+	for _, i := range instances {
+		if pinHostKeysFlags.Instance == "" || i.Name == pinHostKeysFlags.Instance {
+			selected = append(selected, i)
+		}
+	}
+	if len(selected) == 0 {
+		Exitf("No instances found\n")
+	}
+	sort.Sort(instancesByName(selected))
+
+	if err := confirm(fmt.Sprintf("Are you sure you want to trust the current host keys of %d instances of %s?", len(selected), pinHostKeysFlags.ClusterInfo)); err != nil {
+		Exitf("%v\n", err)
+	}
+	for _, i := range selected {
+		if err := i.PinHostKey(); err != nil {
+			Exitf("Failed to pin host key of %s: %v\n", i.Name, err)
+		}
+		Infof("Pinned host key of %s (%s)\n", i.Name, i)
+	}
+}
@@ -0,0 +1,95 @@
+// Copyright (c) 2016 Pulcy.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/ryanuber/columnize"
+	"github.com/spf13/cobra"
+
+	"github.com/pulcy/quark/providers"
+)
+
+var (
+	cmdStateCluster = &cobra.Command{
+		Short: "Show the recorded state of a cluster",
+		Long:  "Show the options a cluster was created with and its instances, as recorded by quark",
+		Use:   "state",
+		Run:   showClusterState,
+	}
+
+	stateClusterFlags providers.ClusterInfo
+)
+
+func init() {
+	cmdStateCluster.Flags().StringVar(&stateClusterFlags.Domain, "domain", defaultDomain(), "Cluster domain")
+	cmdStateCluster.Flags().StringVar(&stateClusterFlags.Name, "name", "", "Cluster name")
+	cmdCluster.AddCommand(cmdStateCluster)
+}
+
+func showClusterState(cmd *cobra.Command, args []string) {
+	requireProfile := false
+	loadArgumentsFromCluster(cmd.Flags(), requireProfile)
+	clusterInfoFromArgs(&stateClusterFlags, args)
+
+	provider := newProvider()
+	stateClusterFlags = provider.ClusterDefaults(stateClusterFlags)
+
+	if stateClusterFlags.Domain == "" {
+		Exitf("Please specify a domain\n")
+	}
+	if stateClusterFlags.Name == "" {
+		Exitf("Please specify a name\n")
+	}
+	c := loadClusterState(provider, stateClusterFlags)
+	if c == nil {
+		Exitf("No state of %s has been recorded.\nRun `quark cluster update` to record it.\n", stateClusterFlags)
+	}
+
+	if outputFormat != outputTable {
+		showOutput(c, nil)
+		return
+	}
+
+	lines := []string{
+		fmt.Sprintf("Cluster | %s", c.Cluster),
+		fmt.Sprintf("ID | %s", c.Cluster.ID),
+		fmt.Sprintf("Provider | %s", c.Provider),
+		fmt.Sprintf("Updated | %s", c.Updated.Local().Format("2006-01-02 15:04:05")),
+	}
+	values := c.Options.Values()
+	var names []string
+	for name := range values {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		lines = append(lines, fmt.Sprintf("--%s | %s", name, values[name]))
+	}
+	fmt.Println(columnize.SimpleFormat(lines))
+	fmt.Println()
+
+	rows := [][]string{}
+	for _, i := range c.Instances {
+		etcd := "member"
+		if i.EtcdProxy {
+			etcd = "proxy"
+		}
+		rows = append(rows, []string{i.Name, i.ClusterIP, i.PublicIPv4, i.Roles, etcd})
+	}
+	fmt.Println(columnize.SimpleFormat(formatTable([]string{"Instance", "Cluster IP", "Public IP", "Roles", "Etcd"}, rows)))
+}
@@ -0,0 +1,75 @@
+// Copyright (c) 2016 Pulcy.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"github.com/juju/errgo"
+	"github.com/spf13/cobra"
+
+	"github.com/pulcy/quark/providers"
+)
+
+var (
+	cmdUnlockCluster = &cobra.Command{
+		Short: "Remove the operation lock of a cluster",
+		Long:  "Remove the operation lock of a cluster. Without --force, only locks held by the current user are removed.",
+		Use:   "unlock",
+		Run:   unlockCluster,
+	}
+
+	unlockClusterFlags struct {
+		providers.ClusterInfo
+		Force bool
+	}
+)
+
+func init() {
+	cmdUnlockCluster.Flags().StringVar(&unlockClusterFlags.Domain, "domain", defaultDomain(), "Cluster domain")
+	cmdUnlockCluster.Flags().StringVar(&unlockClusterFlags.Name, "name", "", "Cluster name")
+	cmdUnlockCluster.Flags().BoolVar(&unlockClusterFlags.Force, "force", false, "If set, the lock is removed, even when it is held by someone else")
+	cmdCluster.AddCommand(cmdUnlockCluster)
+}
+
+func unlockCluster(cmd *cobra.Command, args []string) {
+	requireProfile := false
+	loadArgumentsFromCluster(cmd.Flags(), requireProfile)
+	clusterInfoFromArgs(&unlockClusterFlags.ClusterInfo, args)
+
+	provider := newProvider()
+	unlockClusterFlags.ClusterInfo = provider.ClusterDefaults(unlockClusterFlags.ClusterInfo)
+
+	if unlockClusterFlags.Domain == "" {
+		Exitf("Please specify a domain\n")
+	}
+	if unlockClusterFlags.Name == "" {
+		Exitf("Please specify a name\n")
+	}
+	info := unlockClusterFlags.ClusterInfo
+
+	lock, err := providers.GetClusterLock(ctx, log, provider, info)
+	if errgo.Cause(err) == providers.NotFoundError {
+		Infof("%s is not locked\n", info)
+		return
+	} else if err != nil {
+		Exitf("Failed to get lock of %s: %v\n", info, err)
+	}
+	if lock.Owner != lockOwner() && !unlockClusterFlags.Force {
+		Exitf("%s is locked by %s.\nUse --force to remove the lock anyway.\n", info, lock)
+	}
+	if err := providers.UnlockCluster(ctx, log, provider, info, lock.ID); err != nil {
+		Exitf("Failed to unlock %s: %v\n", info, err)
+	}
+	Infof("Removed lock of %s held by %s\n", info, lock)
+}
@@ -46,8 +46,10 @@ func updateCluster(cmd *cobra.Command, args []string) {
 	if updateClusterFlags.Name == "" {
 		Exitf("Please specify a name\n")
 	}
-	err := provider.UpdateCluster(log, updateClusterFlags, newDnsProvider())
+	lockCluster(provider, updateClusterFlags, "cluster update")
+	err := provider.UpdateCluster(ctx, log, updateClusterFlags, newDnsProvider())
 	if err != nil {
 		Exitf("Failed to update cluster: %v\n", err)
 	}
+	updateClusterState(provider, updateClusterFlags, nil)
 }
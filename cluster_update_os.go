@@ -0,0 +1,204 @@
+// Copyright (c) 2016 Pulcy.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/coreos/go-semver/semver"
+	"github.com/ryanuber/columnize"
+	"github.com/spf13/cobra"
+
+	"github.com/pulcy/quark/providers"
+	"github.com/pulcy/quark/providers/state"
+)
+
+var (
+	cmdUpdateOSCluster = &cobra.Command{
+		Short: "Update the OS of all outdated instances of a cluster",
+		Long:  "Update the OS of all outdated instances of a cluster. Workers are updated before etcd members, one instance at a time",
+		Use:   "update-os",
+		Run:   updateOSCluster,
+	}
+
+	updateOSFlags struct {
+		providers.ClusterInfo
+		MinOSVersion  string
+		HealthTimeout time.Duration
+	}
+)
+
+func init() {
+	cmdUpdateOSCluster.Flags().StringVar(&updateOSFlags.Domain, "domain", defaultDomain(), "Cluster domain")
+	cmdUpdateOSCluster.Flags().StringVar(&updateOSFlags.Name, "name", "", "Cluster name")
+	cmdUpdateOSCluster.Flags().StringVar(&updateOSFlags.MinOSVersion, "min-os-version", defaultMinOSVersion, "Minimum version of the OS")
+	cmdUpdateOSCluster.Flags().DurationVar(&updateOSFlags.HealthTimeout, "health-timeout", defaultHealthTimeout, "Maximum time to wait for etcd & fleet to become healthy after rebooting an instance")
+	cmdCluster.AddCommand(cmdUpdateOSCluster)
+}
+
+// osUpdateStatus holds the OS update state of a single instance.
+type osUpdateStatus struct {
+	Instance   providers.ClusterInstance
+	EtcdMember bool
+	OldVersion semver.Version
+	NewVersion *semver.Version
+	Result     string
+}
+
+func (s osUpdateStatus) role() string {
+	if s.EtcdMember {
+		return "etcd member"
+	}
+	return "worker"
+}
+
+// osUpdateOrder sorts workers before etcd members, then by name.
+type osUpdateOrder []*osUpdateStatus
+
+func (l osUpdateOrder) Len() int { return len(l) }
+func (l osUpdateOrder) Less(i, j int) bool {
+	if l[i].EtcdMember != l[j].EtcdMember {
+		return !l[i].EtcdMember
+	}
+	return l[i].Instance.Name < l[j].Instance.Name
+}
+func (l osUpdateOrder) Swap(i, j int) { l[i], l[j] = l[j], l[i] }
+
+func updateOSCluster(cmd *cobra.Command, args []string) {
+	requireProfile := false
+	loadArgumentsFromCluster(cmd.Flags(), requireProfile)
+	clusterInfoFromArgs(&updateOSFlags.ClusterInfo, args)
+
+	provider := newProvider()
+	updateOSFlags.ClusterInfo = provider.ClusterDefaults(updateOSFlags.ClusterInfo)
+
+	if updateOSFlags.Domain == "" {
+		Exitf("Please specify a domain\n")
+	}
+	if updateOSFlags.Name == "" {
+		Exitf("Please specify a name\n")
+	}
+	minOSVersion, err := semver.NewVersion(updateOSFlags.MinOSVersion)
+	if err != nil {
+		Exitf("Invalid min-os-version '%s': %v\n", updateOSFlags.MinOSVersion, err)
+	}
+	lockCluster(provider, updateOSFlags.ClusterInfo, "cluster update-os")
+	instances, err := provider.GetInstances(ctx, updateOSFlags.ClusterInfo)
+	if err != nil {
+		Exitf("Failed to list instances: %v\n", err)
+	}
+	if len(instances) == 0 {
+		Exitf("Cluster %s has no instances\n", updateOSFlags.ClusterInfo)
+	}
+
+	// Find outdated instances
+	statuses := osUpdateOrder{}
+	outdated := 0
+	for _, i := range instances {
+		if i.OS != providers.OSNameCoreOS {
+			statuses = append(statuses, &osUpdateStatus{Instance: i, Result: fmt.Sprintf("not supported on %s", i.OS)})
+			continue
+		}
+		v, err := i.GetOSRelease(ctx, log)
+		if err != nil {
+			Exitf("Failed to get OS release of %s: %v\n", i.Name, err)
+		}
+		etcdProxy, err := i.IsEtcdProxy(ctx, log)
+		if err != nil {
+			Exitf("Failed to get etcd status of %s: %v\n", i.Name, err)
+		}
+		s := &osUpdateStatus{
+			Instance:   i,
+			EtcdMember: !etcdProxy,
+			OldVersion: v,
+			Result:     "up to date",
+		}
+		if v.LessThan(*minOSVersion) {
+			s.Result = "update"
+			outdated++
+		}
+		statuses = append(statuses, s)
+	}
+	sort.Sort(statuses)
+	showOSUpdateStatus(statuses)
+	if outdated == 0 {
+		Infof("All instances run OS %s or newer\n", minOSVersion)
+		return
+	}
+
+	if err := confirm(fmt.Sprintf("Are you sure you want to update the OS of %d instances of %s?", outdated, updateOSFlags.ClusterInfo)); err != nil {
+		Exitf("%v\n", err)
+	}
+
+	// Do not start when the cluster is already unhealthy
+	if err := instances.WaitUntilHealthy(ctx, log, updateOSFlags.HealthTimeout); err != nil {
+		Exitf("Cluster is not healthy: %v\n", err)
+	}
+
+	// Update one instance at a time, waiting for etcd quorum to recover after each reboot
+	var failure error
+	for _, s := range statuses {
+		if s.Result != "update" {
+			continue
+		}
+		if failure != nil {
+			s.Result = "skipped"
+			continue
+		}
+		Infof("Updating OS on %s (%s)\n", s.Instance.Name, s.role())
+		if _, err := s.Instance.UpdateOS(ctx, log, *minOSVersion, updateOSFlags.HealthTimeout, provider); err != nil {
+			s.Result = fmt.Sprintf("failed: %v", err)
+			failure = err
+			continue
+		}
+		s.Result = "updated"
+		auditStep("update-os", s.Instance.Name)
+		if v, err := s.Instance.GetOSRelease(ctx, log); err == nil {
+			s.NewVersion = &v
+		}
+		// Do not continue before all instances see a healthy cluster again
+		if err := instances.WaitUntilHealthy(ctx, log, updateOSFlags.HealthTimeout); err != nil {
+			failure = fmt.Errorf("cluster is not healthy after updating %s: %v", s.Instance.Name, err)
+		}
+	}
+
+	showOSUpdateStatus(statuses)
+	if failure != nil {
+		Exitf("Failed to update OS of cluster: %v\n", failure)
+	}
+	updateClusterState(provider, updateOSFlags.ClusterInfo, func(c *state.Cluster) {
+		if recorded, err := semver.NewVersion(c.Options.MinOSVersion); err != nil || recorded.LessThan(*minOSVersion) {
+			c.Options.MinOSVersion = minOSVersion.String()
+		}
+	})
+}
+
+// showOSUpdateStatus prints a table with the OS update state of all instances.
+func showOSUpdateStatus(statuses osUpdateOrder) {
+	lines := []string{"Name | Role | OS | New OS | Result"}
+	for _, s := range statuses {
+		oldVersion, newVersion := "", ""
+		if s.Instance.OS == providers.OSNameCoreOS {
+			oldVersion = s.OldVersion.String()
+		}
+		if s.NewVersion != nil {
+			newVersion = s.NewVersion.String()
+		}
+		lines = append(lines, fmt.Sprintf("%s | %s | %s | %s | %s", s.Instance.Name, s.role(), oldVersion, newVersion, s.Result))
+	}
+	fmt.Println(columnize.SimpleFormat(lines))
+}
@@ -0,0 +1,101 @@
+// Copyright (c) 2016 Pulcy.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/pulcy/quark/providers"
+	"github.com/pulcy/quark/providers/state"
+)
+
+const (
+	defaultHealthTimeout = time.Minute * 10
+)
+
+var (
+	cmdUpgradeGluonCluster = &cobra.Command{
+		Short: "Upgrade gluon on all instances of a cluster",
+		Long:  "Upgrade gluon on all instances of a cluster, one instance at a time",
+		Use:   "upgrade-gluon",
+		Run:   upgradeGluonCluster,
+	}
+
+	upgradeGluonFlags struct {
+		providers.ClusterInfo
+		GluonImage    string
+		HealthTimeout time.Duration
+	}
+)
+
+func init() {
+	cmdUpgradeGluonCluster.Flags().StringVar(&upgradeGluonFlags.Domain, "domain", defaultDomain(), "Cluster domain")
+	cmdUpgradeGluonCluster.Flags().StringVar(&upgradeGluonFlags.Name, "name", "", "Cluster name")
+	cmdUpgradeGluonCluster.Flags().StringVar(&upgradeGluonFlags.GluonImage, "gluon-image", defaultGluonImage, "Image containing gluon")
+	cmdUpgradeGluonCluster.Flags().DurationVar(&upgradeGluonFlags.HealthTimeout, "health-timeout", defaultHealthTimeout, "Maximum time to wait for etcd & fleet to become healthy after rebooting an instance")
+	cmdCluster.AddCommand(cmdUpgradeGluonCluster)
+}
+
+func upgradeGluonCluster(cmd *cobra.Command, args []string) {
+	requireProfile := false
+	loadArgumentsFromCluster(cmd.Flags(), requireProfile)
+	clusterInfoFromArgs(&upgradeGluonFlags.ClusterInfo, args)
+
+	provider := newProvider()
+	upgradeGluonFlags.ClusterInfo = provider.ClusterDefaults(upgradeGluonFlags.ClusterInfo)
+
+	if upgradeGluonFlags.Domain == "" {
+		Exitf("Please specify a domain\n")
+	}
+	if upgradeGluonFlags.Name == "" {
+		Exitf("Please specify a name\n")
+	}
+	if upgradeGluonFlags.GluonImage == "" {
+		Exitf("Please specify a gluon-image\n")
+	}
+	lockCluster(provider, upgradeGluonFlags.ClusterInfo, "cluster upgrade-gluon")
+	instances, err := provider.GetInstances(ctx, upgradeGluonFlags.ClusterInfo)
+	if err != nil {
+		Exitf("Failed to list instances: %v\n", err)
+	}
+	if len(instances) == 0 {
+		Exitf("Cluster %s has no instances\n", upgradeGluonFlags.ClusterInfo)
+	}
+	sort.Sort(instancesByName(instances))
+
+	if err := confirm(fmt.Sprintf("Are you sure you want to upgrade gluon on %d instances of %s to %s?", len(instances), upgradeGluonFlags.ClusterInfo, upgradeGluonFlags.GluonImage)); err != nil {
+		Exitf("%v\n", err)
+	}
+
+	// Do not start when the cluster is already unhealthy
+	if err := instances.WaitUntilHealthy(ctx, log, upgradeGluonFlags.HealthTimeout); err != nil {
+		Exitf("Cluster is not healthy: %v\n", err)
+	}
+	for index, i := range instances {
+		Infof("Upgrading gluon on %s (%d/%d)\n", i.Name, index+1, len(instances))
+		if err := i.UpgradeGluon(ctx, log, upgradeGluonFlags.GluonImage, upgradeGluonFlags.HealthTimeout, provider); err != nil {
+			Exitf("Failed to upgrade gluon on %s: %v\n", i.Name, err)
+		}
+		auditStep("upgrade-gluon", i.Name)
+	}
+	updateClusterState(provider, upgradeGluonFlags.ClusterInfo, func(c *state.Cluster) {
+		c.Options.GluonImage = upgradeGluonFlags.GluonImage
+	})
+	Successf("Upgraded gluon on %d instances\n", len(instances))
+}
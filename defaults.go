@@ -19,6 +19,7 @@ import (
 	"os"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/mitchellh/go-homedir"
 )
@@ -29,6 +30,16 @@ const (
 	defaultRebootStrategy      = "etcd-lock"
 	defaultMinOSVersion        = "835.13.0"
 	defaultGithubTokenPathTmpl = "~/.pulcy/github-token"
+	defaultKnownHostsPathTmpl  = "~/.pulcy/quark_known_hosts"
+	defaultSSHConfigPathTmpl   = "~/.ssh/config"
+	defaultJournalDirTmpl      = "~/.pulcy/quark-journals"
+	defaultStateDirTmpl        = "~/.pulcy/quark-state"
+	defaultAuditLogTmpl        = "~/.pulcy/quark-audit.log"
+	defaultProvisionTimeout    = time.Minute * 10
+	defaultSSHReadyTimeout     = time.Minute * 10
+	defaultBootstrapTimeout    = time.Minute * 30
+	defaultLockTTL             = time.Hour
+	defaultExecParallel        = 10
 )
 
 func defaultDomain() string {
@@ -71,10 +82,6 @@ func defaultGithubToken() string {
 	return strings.TrimSpace(string(content))
 }
 
-func defaultVagrantFolder() string {
-	return os.Getenv("QUARK_VAGRANT_FOLDER")
-}
-
 func defaultVaultAddr() string {
 	return os.Getenv("VAULT_ADDR")
 }
@@ -96,3 +103,107 @@ func defaultRegisterInstance() bool {
 	register, err := strconv.ParseBool(v)
 	return (err == nil) && register
 }
+
+func defaultKnownHosts() string {
+	if path := os.Getenv("QUARK_KNOWN_HOSTS"); path != "" {
+		return path
+	}
+	path, err := homedir.Expand(defaultKnownHostsPathTmpl)
+	if err != nil {
+		log.Warningf("Cannot expand %s: %#v", defaultKnownHostsPathTmpl, err)
+		return ""
+	}
+	return path
+}
+
+func defaultStrictHostKeyChecking() bool {
+	v := os.Getenv("QUARK_STRICT_HOST_KEY_CHECKING")
+	strict, err := strconv.ParseBool(v)
+	return (err == nil) && strict
+}
+
+func defaultSSHJump() string {
+	return os.Getenv("QUARK_SSH_JUMP")
+}
+
+func defaultSSHTunnel() string {
+	return os.Getenv("QUARK_SSH_TUNNEL")
+}
+
+func defaultSSHUser() string {
+	return os.Getenv("QUARK_SSH_USER")
+}
+
+func defaultSSHPort() int {
+	port, err := strconv.Atoi(os.Getenv("QUARK_SSH_PORT"))
+	if err != nil {
+		return 0
+	}
+	return port
+}
+
+func defaultSSHKeyFiles() []string {
+	if v := os.Getenv("QUARK_SSH_KEY_FILE"); v != "" {
+		return strings.Split(v, ",")
+	}
+	return nil
+}
+
+func defaultSSHConfig() string {
+	if path := os.Getenv("QUARK_SSH_CONFIG"); path != "" {
+		return path
+	}
+	path, err := homedir.Expand(defaultSSHConfigPathTmpl)
+	if err != nil {
+		log.Warningf("Cannot expand %s: %#v", defaultSSHConfigPathTmpl, err)
+		return ""
+	}
+	return path
+}
+
+func defaultJournalDir() string {
+	if dir := os.Getenv("QUARK_JOURNAL_DIR"); dir != "" {
+		return dir
+	}
+	dir, err := homedir.Expand(defaultJournalDirTmpl)
+	if err != nil {
+		log.Warningf("Cannot expand %s: %#v", defaultJournalDirTmpl, err)
+		return ""
+	}
+	return dir
+}
+
+func defaultStateStore() string {
+	if store := os.Getenv("QUARK_STATE_STORE"); store != "" {
+		return store
+	}
+	return stateStoreFile
+}
+
+func defaultStateDir() string {
+	if dir := os.Getenv("QUARK_STATE_DIR"); dir != "" {
+		return dir
+	}
+	dir, err := homedir.Expand(defaultStateDirTmpl)
+	if err != nil {
+		log.Warningf("Cannot expand %s: %#v", defaultStateDirTmpl, err)
+		return ""
+	}
+	return dir
+}
+
+func defaultAuditLog() string {
+	if path := os.Getenv("QUARK_AUDIT_LOG"); path != "" {
+		return path
+	}
+	path, err := homedir.Expand(defaultAuditLogTmpl)
+	if err != nil {
+		log.Warningf("Cannot expand %s: %#v", defaultAuditLogTmpl, err)
+		return ""
+	}
+	return path
+}
+
+func defaultAuditWebhook() string {
+	return os.Getenv("QUARK_AUDIT_WEBHOOK")
+}
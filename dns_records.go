@@ -15,6 +15,8 @@
 package main
 
 import (
+	"sort"
+
 	"github.com/spf13/cobra"
 )
 
@@ -41,8 +43,16 @@ func showDnsRecords(cmd *cobra.Command, args []string) {
 		Exitf("Please specify a domain\n")
 	}
 	provider := newDnsProvider()
-	err := provider.ShowDomainRecords(dnsFlags.Domain)
+	list, err := provider.GetDomainRecords(ctx, dnsFlags.Domain)
 	if err != nil {
 		Exitf("Failed to show dns records: %v\n", err)
 	}
+	sort.Sort(list)
+	showOutput(list, func() []string {
+		rows := [][]string{}
+		for _, r := range list {
+			rows = append(rows, []string{r.Type, trimLength(r.Name, 20), trimLength(r.Data, 60), formatInt(r.Priority), formatInt(r.Weight), formatInt(r.Port)})
+		}
+		return formatTable([]string{"Type", "Name", "Data", "Priority", "Weight", "Port"}, rows)
+	})
 }
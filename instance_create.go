@@ -16,10 +16,14 @@ package main
 
 import (
 	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
 	"golang.org/x/sync/errgroup"
 
 	"github.com/cenkalti/backoff"
 	"github.com/pulcy/quark/providers"
+	"github.com/pulcy/quark/providers/audit"
+	"github.com/pulcy/quark/providers/journal"
+	"github.com/pulcy/quark/providers/state"
 )
 
 var (
@@ -31,67 +35,249 @@ var (
 	
 Create a new instance with a specific cluster (tinc) IP.
 	./quark instance create -c worker@mycluster --tinc-ipv4=192.168.33.11
+
+Finish creating an instance after an earlier attempt failed.
+	./quark instance create -p vultr --resume mycluster.pulcy.com
 `,
 	}
 
-	createInstanceFlags providers.CreateInstanceOptions
+	createInstanceFlags        providers.CreateInstanceOptions
+	createInstanceJournalFlags journalFlags
 )
 
 func init() {
-	cmdCreateInstance.Flags().StringVar(&createInstanceFlags.Domain, "domain", defaultDomain(), "Cluster domain")
-	cmdCreateInstance.Flags().StringVar(&createInstanceFlags.Name, "name", "", "Cluster name")
-	cmdCreateInstance.Flags().StringVar(&createInstanceFlags.ImageID, "image", "", "OS image to run on new instances")
-	cmdCreateInstance.Flags().StringVar(&createInstanceFlags.RegionID, "region", "", "Region to create the instances in")
-	cmdCreateInstance.Flags().StringVar(&createInstanceFlags.TypeID, "type", "", "Type of the new instances")
-	cmdCreateInstance.Flags().StringVar(&createInstanceFlags.MinOSVersion, "min-os-version", defaultMinOSVersion, "Minimum version of the OS")
-	cmdCreateInstance.Flags().StringVar(&createInstanceFlags.GluonImage, "gluon-image", defaultGluonImage, "Image containing gluon")
-	cmdCreateInstance.Flags().StringVar(&createInstanceFlags.RebootStrategy, "reboot-strategy", defaultRebootStrategy, "CoreOS reboot strategy")
-	cmdCreateInstance.Flags().StringVar(&createInstanceFlags.PrivateRegistryUrl, "private-registry-url", defaultPrivateRegistryUrl(), "URL of private docker registry")
-	cmdCreateInstance.Flags().StringVar(&createInstanceFlags.PrivateRegistryUserName, "private-registry-username", defaultPrivateRegistryUserName(), "Username for private registry")
-	cmdCreateInstance.Flags().StringVar(&createInstanceFlags.PrivateRegistryPassword, "private-registry-password", defaultPrivateRegistryPassword(), "Password for private registry")
-	cmdCreateInstance.Flags().StringSliceVar(&createInstanceFlags.SSHKeyNames, "ssh-key", defaultSshKeys(), "Names of SSH keys to add to instance")
-	cmdCreateInstance.Flags().StringVar(&createInstanceFlags.SSHKeyGithubAccount, "ssh-key-github-account", defaultSshKeyGithubAccount(), "Github account name used to fetch SSH keys (to add to instances)")
-	cmdCreateInstance.Flags().BoolVar(&createInstanceFlags.EtcdProxy, "etcd-proxy", false, "If set, the new instance will be an ETCD proxy")
-	cmdCreateInstance.Flags().BoolVar(&createInstanceFlags.RoleCore, "role-core", false, "If set, the new instance will get `core=true` metadata")
-	cmdCreateInstance.Flags().BoolVar(&createInstanceFlags.RoleLoadBalancer, "role-lb", false, "If set, the new instance will get `lb=true` metadata and register with cluster name in DNS")
-	cmdCreateInstance.Flags().BoolVar(&createInstanceFlags.RoleVault, "role-vault", false, "If set, the new instance will get `vault=true` metadata")
-	cmdCreateInstance.Flags().BoolVar(&createInstanceFlags.RoleWorker, "role-worker", false, "If set, the new instance will get `worker=true` metadata")
-	cmdCreateInstance.Flags().IntVar(&createInstanceFlags.InstanceIndex, "index", 0, "Used to create `odd=true` or `even=true` metadata")
-	cmdCreateInstance.Flags().StringVar(&createInstanceFlags.TincCIDR, "tinc-cidr", "", "CIDR of the TINC network in this cluster")
-	cmdCreateInstance.Flags().StringVar(&createInstanceFlags.TincIpv4, "tinc-ipv4", "", "IPv4 address of the new instance inside the TINC network")
-	cmdCreateInstance.Flags().BoolVar(&createInstanceFlags.RegisterInstance, "register-instance", defaultRegisterInstance(), "If set, the instance will be registered with its instance name in DNS")
-	cmdCreateInstance.Flags().StringVar(&createInstanceFlags.HttpProxy, "http-proxy", "", "Address of HTTP proxy to use on the instance")
+	addCreateInstanceFlags(cmdCreateInstance.Flags(), &createInstanceFlags)
+	addJournalFlags(cmdCreateInstance.Flags(), &createInstanceJournalFlags)
 	cmdInstance.AddCommand(cmdCreateInstance)
 }
 
+// addCreateInstanceFlags registers all flags used to create an instance in the given flag set.
+func addCreateInstanceFlags(flagSet *pflag.FlagSet, options *providers.CreateInstanceOptions) {
+	flagSet.StringVar(&options.Domain, "domain", defaultDomain(), "Cluster domain")
+	flagSet.StringVar(&options.Name, "name", "", "Cluster name")
+	flagSet.StringVar(&options.ImageID, "image", "", "OS image to run on new instances")
+	flagSet.StringVar(&options.RegionID, "region", "", "Region to create the instances in")
+	flagSet.StringVar(&options.TypeID, "type", "", "Type of the new instances")
+	flagSet.StringVar(&options.MinOSVersion, "min-os-version", defaultMinOSVersion, "Minimum version of the OS")
+	flagSet.StringVar(&options.GluonImage, "gluon-image", defaultGluonImage, "Image containing gluon")
+	flagSet.StringVar(&options.RebootStrategy, "reboot-strategy", defaultRebootStrategy, "CoreOS reboot strategy")
+	flagSet.StringVar(&options.PrivateRegistryUrl, "private-registry-url", defaultPrivateRegistryUrl(), "URL of private docker registry")
+	flagSet.StringVar(&options.PrivateRegistryUserName, "private-registry-username", defaultPrivateRegistryUserName(), "Username for private registry")
+	flagSet.StringVar(&options.PrivateRegistryPassword, "private-registry-password", defaultPrivateRegistryPassword(), "Password for private registry")
+	flagSet.StringSliceVar(&options.SSHKeyNames, "ssh-key", defaultSshKeys(), "Names of SSH keys to add to instance")
+	flagSet.StringVar(&options.SSHKeyGithubAccount, "ssh-key-github-account", defaultSshKeyGithubAccount(), "Github account name used to fetch SSH keys (to add to instances)")
+	flagSet.BoolVar(&options.EtcdProxy, "etcd-proxy", false, "If set, the new instance will be an ETCD proxy")
+	flagSet.BoolVar(&options.RoleCore, "role-core", false, "If set, the new instance will get `core=true` metadata")
+	flagSet.BoolVar(&options.RoleLoadBalancer, "role-lb", false, "If set, the new instance will get `lb=true` metadata and register with cluster name in DNS")
+	flagSet.BoolVar(&options.RoleVault, "role-vault", false, "If set, the new instance will get `vault=true` metadata")
+	flagSet.BoolVar(&options.RoleWorker, "role-worker", false, "If set, the new instance will get `worker=true` metadata")
+	flagSet.IntVar(&options.InstanceIndex, "index", 0, "Used to create `odd=true` or `even=true` metadata")
+	flagSet.StringVar(&options.TincCIDR, "tinc-cidr", "", "CIDR of the TINC network in this cluster")
+	flagSet.StringVar(&options.TincIpv4, "tinc-ipv4", "", "IPv4 address of the new instance inside the TINC network")
+	flagSet.BoolVar(&options.RegisterInstance, "register-instance", defaultRegisterInstance(), "If set, the instance will be registered with its instance name in DNS")
+	flagSet.StringVar(&options.HttpProxy, "http-proxy", "", "Address of HTTP proxy to use on the instance")
+}
+
 func createInstance(cmd *cobra.Command, args []string) {
 	createInstanceFlags.VaultAddress = vaultCfg.VaultAddr
 	createInstanceFlags.VaultCertificatePath = vaultCfg.VaultCACert
 	createInstanceFlags.VaultServerKeyPath = vaultCfg.VaultCAKey
 	createInstanceFlags.VaultServerKeyCommand = vaultCfg.VaultCAKeyCommand
 
-	requireProfile := true
+	// The options of a resumed operation are recorded in its journal
+	requireProfile := !createInstanceJournalFlags.Resume && !createInstanceJournalFlags.Rollback
 	loadArgumentsFromCluster(cmd.Flags(), requireProfile)
 	clusterInfoFromArgs(&createInstanceFlags.ClusterInfo, args)
 
 	provider := newProvider()
-	createInstanceFlags = provider.CreateInstanceDefaults(createInstanceFlags)
-	createInstanceFlags.SetupNames("", createInstanceFlags.Name, createInstanceFlags.Domain)
+	lockCluster(provider, createInstanceFlags.ClusterInfo, operationCreateInstance)
+	if createInstanceJournalFlags.Rollback {
+		rollbackJournal(openJournal(operationCreateInstance, createInstanceFlags.ClusterInfo, createInstanceJournalFlags))
+		Successf("Rolled back instance creation\n")
+		return
+	}
+
+	// Options that are not specified default to those the cluster was created with
+	applyClusterStateDefaults(cmd.Flags(), provider, createInstanceFlags.ClusterInfo)
+	createClusterInstance(provider, createInstanceFlags, createInstanceJournalFlags.Resume)
+
+	Successf("Instance created\n")
+}
+
+// createClusterInstance creates a new instance with given options and adds it to the existing cluster.
+// All resources that are created are recorded in a journal. If resume is set, the operation recorded in
+// the journal of an earlier attempt is finished instead.
+func createClusterInstance(provider providers.CloudProvider, options providers.CreateInstanceOptions, resume bool) providers.ClusterInstance {
+	j := openJournal(operationCreateInstance, options.ClusterInfo, journalFlags{Resume: resume})
+	var instances providers.ClusterInstanceList
+	if j.HasOptions() {
+		// Secrets are not recorded, the registry password is taken from the flags again
+		registryPassword := options.PrivateRegistryPassword
+		if err := j.GetOptions(&options); err != nil {
+			Exitf("Failed to load options from journal: %v\n", err)
+		}
+		options.PrivateRegistryPassword = registryPassword
+		if options.PrivateRegistryUserName != "" && options.PrivateRegistryPassword == "" {
+			Exitf("Please specify --private-registry-password again, it is not recorded in the journal\n")
+		}
+		var err error
+		instances, err = provider.GetInstances(ctx, options.ClusterInfo)
+		if err != nil {
+			Exitf("Failed to query existing instances: %v\n", err)
+		}
+		// The vault certificate & weave.env are not recorded
+		vaultCACert, err := instances.GetVaultCrt(ctx, log)
+		if err != nil {
+			Exitf("Failed to get vault-cacert: %v\n", err)
+		}
+		options.SetVaultCertificate(vaultCACert)
+		weaveEnv, err := instances.GetWeaveEnv(ctx, log)
+		if err != nil {
+			Exitf("Failed to get weave.env: %v\n", err)
+		}
+		options.WeaveEnv = weaveEnv
+	} else {
+		options, instances = prepareCreateInstanceOptions(provider, options)
+		if err := j.SetOptions(options); err != nil {
+			Exitf("Failed to record options in journal: %v\n", err)
+		}
+	}
+	auditLog.SetClusterID(options.ClusterInfo.ID)
+
+	// Create
+	var instance providers.ClusterInstance
+	if created := j.Instances(); len(created) > 0 {
+		instance = created[0]
+		instances = instances.Except(instance)
+		log.Infof("Resuming creation of %s", instance.Name)
+	} else if pending := j.Pending(journal.KindServer); len(pending) > 0 {
+		Exitf("Creation of instance %s was interrupted, it cannot be resumed.\n", pending[0].Name)
+	} else {
+		log.Infof("Creating new instance on %s.%s", options.Name, options.Domain)
+		var err error
+		instance, err = journal.NewProvider(j, provider).CreateInstance(ctx, log, options, newDnsProvider())
+		if err != nil {
+			Exitf("Failed to create new instance: %v\n", err)
+		}
+	}
+
+	// Get the id of the new machine
+	machineID, err := instance.GetMachineID(ctx, log)
+	if err != nil {
+		Exitf("Failed to get machine ID: %v\n", err)
+	}
+
+	// Add new instance to ETCD (if not a proxy)
+	if !options.EtcdProxy && !j.IsDone(journal.KindEtcd, machineID) {
+		if err := j.Begin(journal.Entry{Kind: journal.KindEtcd, Name: machineID, ClusterIP: instance.ClusterIP}); err != nil {
+			Exitf("Failed to record etcd member in journal: %v\n", err)
+		}
+		if err := instances.AddEtcdMember(ctx, log, machineID, instance.ClusterIP); err != nil {
+			Exitf("Failed to add new instance to etcd: %v\n", err)
+		}
+		auditLog.Emit(audit.Event{Type: audit.EventEtcdMemberAdded, Instance: instance.Name, Name: machineID, Data: instance.ClusterIP})
+		if err := j.Complete(journal.KindEtcd, machineID, nil); err != nil {
+			Exitf("Failed to record etcd member in journal: %v\n", err)
+		}
+	}
+
+	// Add new instance to vault cluster
+	if !j.IsDone(journal.KindVault, machineID) {
+		vaultProvider := journal.NewVaultProvider(j, newVaultProvider())
+		if err := providers.Retry(ctx, func() error {
+			log.Debugf("Adding machine to vault cluster")
+			if err := vaultProvider.AddMachine(ctx, options.ClusterInfo.ID, machineID); err != nil {
+				log.Warningf("Failed to add machine to vault: %v", err)
+				return maskAny(err)
+			}
+			return nil
+		}, backoff.NewExponentialBackOff()); err != nil {
+			log.Warningf("Failed to add machine to vault: %v", err)
+			log.Warningf("To fix, run: vault-monkey cluster add -c %s -m %s", options.ClusterInfo.ID, machineID)
+		}
+	}
+
+	// Add new instance to list
+	instances = append(instances, instance)
+
+	// Load cluster-members data
+	isEtcdProxy := func(i providers.ClusterInstance) (bool, error) {
+		if i.ClusterIP == instance.ClusterIP {
+			return options.EtcdProxy, nil
+		}
+		result, err := i.IsEtcdProxy(ctx, log)
+		return result, maskAny(err)
+	}
+
+	// Perform initial setup on new instance
+	if !j.IsDone(journal.KindStep, stepInitialSetup) {
+		clusterMembers, err := instances.AsClusterMemberList(ctx, log, isEtcdProxy)
+		if err != nil {
+			Exitf("Failed to convert instance list to member list: %v\n", err)
+		}
+		iso := providers.InitialSetupOptions{
+			ClusterMembers:   clusterMembers,
+			FleetMetadata:    options.CreateFleetMetadata(options.InstanceIndex),
+			EtcdClusterState: "existing",
+		}
+		if err := instance.InitialSetup(ctx, log, options, iso, provider); err != nil {
+			Exitf("Failed to perform initial instance setup: %v\n", err)
+		}
+		recordStep(j, stepInitialSetup)
+	}
+
+	// Update existing members
+	if !j.IsDone(journal.KindStep, stepUpdateMembers) {
+		if err := providers.UpdateClusterMembers(ctx, log, options.ClusterInfo, false, isEtcdProxy, provider); err != nil {
+			Exitf("Failed to update cluster members: %v\n", err)
+		}
+		recordStep(j, stepUpdateMembers)
+	}
+
+	// Reboot new instance
+	if !j.IsDone(journal.KindStep, stepReboot) {
+		if err := provider.RebootInstance(ctx, instance); err != nil {
+			Exitf("Failed to reboot new instance: %v\n", err)
+		}
+		recordStep(j, stepReboot)
+	}
+
+	finishJournal(j)
+
+	// Record the new instance
+	updateClusterState(provider, options.ClusterInfo, func(c *state.Cluster) {
+		if c.Options.IsEmpty() {
+			c.Options = state.OptionsFromInstance(options)
+		}
+		i := state.NewInstance(instance)
+		i.Roles = options.Roles()
+		i.EtcdProxy = options.EtcdProxy
+		c.SetInstance(i)
+	})
+
+	return instance
+}
+
+// prepareCreateInstanceOptions completes the given options with the settings of the existing cluster.
+// It returns the completed options and the existing instances of the cluster.
+func prepareCreateInstanceOptions(provider providers.CloudProvider, options providers.CreateInstanceOptions) (providers.CreateInstanceOptions, providers.ClusterInstanceList) {
+	options = provider.CreateInstanceDefaults(options)
+	options.SetupNames("", options.Name, options.Domain)
 
 	// Validate
 	validateVault := false
 	validateWeave := false
-	if err := createInstanceFlags.Validate(validateVault, validateWeave); err != nil {
+	if err := options.Validate(validateVault, validateWeave); err != nil {
 		Exitf("Create failed: %s\n", err.Error())
 	}
 
 	// See if there are already instances for the given cluster
-	instances, err := provider.GetInstances(createInstanceFlags.ClusterInfo)
+	instances, err := provider.GetInstances(ctx, options.ClusterInfo)
 	if err != nil {
 		Exitf("Failed to query existing instances: %v\n", err)
 	}
 	if len(instances) == 0 {
-		Exitf("Cluster %s.%s does not exist.\n", createInstanceFlags.Name, createInstanceFlags.Domain)
+		Exitf("Cluster %s.%s does not exist.\n", options.Name, options.Domain)
 	}
 
 	// Fetch various variables from existing cluster
@@ -99,61 +285,65 @@ func createInstance(cmd *cobra.Command, args []string) {
 
 	// Fetch cluster ID
 	g.Go(func() error {
-		clusterID, err := instances.GetClusterID(log)
+		clusterID, err := instances.GetClusterID(ctx, log)
 		if err != nil {
 			Exitf("Failed to get cluster-id: %v\n", err)
 		}
-		createInstanceFlags.ClusterInfo.ID = clusterID
+		options.ClusterInfo.ID = clusterID
 		return nil
 	})
 
 	// Fetch vault address
 	g.Go(func() error {
-		vaultAddr, err := instances.GetVaultAddr(log)
+		vaultAddr, err := instances.GetVaultAddr(ctx, log)
 		if err != nil {
 			Exitf("Failed to get vault-addr: %v\n", err)
 		}
-		createInstanceFlags.VaultAddress = vaultAddr
+		options.VaultAddress = vaultAddr
 		return nil
 	})
 
 	// Fetch vault CA certificate
 	g.Go(func() error {
-		vaultCACert, err := instances.GetVaultCrt(log)
+		vaultCACert, err := instances.GetVaultCrt(ctx, log)
 		if err != nil {
 			Exitf("Failed to get vault-cacert: %v\n", err)
 		}
-		createInstanceFlags.SetVaultCertificate(vaultCACert)
+		options.SetVaultCertificate(vaultCACert)
 		return nil
 	})
 
 	// Fetch gluon.env
 	g.Go(func() error {
-		gluonEnv, err := instances.GetGluonEnv(log)
+		gluonEnv, err := instances.GetGluonEnv(ctx, log)
 		if err != nil {
 			Exitf("Failed to get gluon.env: %v\n", err)
 		}
-		createInstanceFlags.GluonEnv = gluonEnv
+		options.GluonEnv = gluonEnv
+		if options.HttpProxy == "" {
+			// Use the same HTTP proxy as the rest of the cluster
+			options.HttpProxy = providers.HttpProxyFromEnv(gluonEnv)
+		}
 		return nil
 	})
 
 	// Fetch weave.env
 	g.Go(func() error {
-		weaveEnv, err := instances.GetWeaveEnv(log)
+		weaveEnv, err := instances.GetWeaveEnv(ctx, log)
 		if err != nil {
 			Exitf("Failed to get weave.env: %v\n", err)
 		}
-		createInstanceFlags.WeaveEnv = weaveEnv
+		options.WeaveEnv = weaveEnv
 		return nil
 	})
 
 	// Fetch weave-seed
 	g.Go(func() error {
-		weaveSeed, err := instances.GetWeaveSeed(log)
+		weaveSeed, err := instances.GetWeaveSeed(ctx, log)
 		if err != nil {
 			Exitf("Failed to get weave-seed: %v\n", err)
 		}
-		createInstanceFlags.WeaveSeed = weaveSeed
+		options.WeaveSeed = weaveSeed
 		return nil
 	})
 
@@ -163,15 +353,15 @@ func createInstance(cmd *cobra.Command, args []string) {
 	}
 
 	// Setup instance index
-	if createInstanceFlags.InstanceIndex == 0 {
-		createInstanceFlags.InstanceIndex = len(instances) + 1
+	if options.InstanceIndex == 0 {
+		options.InstanceIndex = len(instances) + 1
 	}
 
 	// Check tinc IP (if any)
-	if createInstanceFlags.TincIpv4 != "" {
+	if options.TincIpv4 != "" {
 		for _, i := range instances {
-			if i.ClusterIP == createInstanceFlags.TincIpv4 {
-				Exitf("Duplicate cluster IP: %s\n", createInstanceFlags.TincIpv4)
+			if i.ClusterIP == options.TincIpv4 {
+				Exitf("Duplicate cluster IP: %s\n", options.TincIpv4)
 			}
 		}
 	}
@@ -179,78 +369,9 @@ func createInstance(cmd *cobra.Command, args []string) {
 	// Now validate everything
 	validateVault = true
 	validateWeave = true
-	if err := createInstanceFlags.Validate(validateVault, validateWeave); err != nil {
+	if err := options.Validate(validateVault, validateWeave); err != nil {
 		Exitf("Create failed: %s\n", err.Error())
 	}
 
-	// Create
-	log.Infof("Creating new instance on %s.%s", createInstanceFlags.Name, createInstanceFlags.Domain)
-	instance, err := provider.CreateInstance(log, createInstanceFlags, newDnsProvider())
-	if err != nil {
-		Exitf("Failed to create new instance: %v\n", err)
-	}
-
-	// Get the id of the new machine
-	machineID, err := instance.GetMachineID(log)
-	if err != nil {
-		Exitf("Failed to get machine ID: %v\n", err)
-	}
-
-	// Add new instance to ETCD (if not a proxy)
-	if !createInstanceFlags.EtcdProxy {
-		if err := instances.AddEtcdMember(log, machineID, instance.ClusterIP); err != nil {
-			Exitf("Failed to add new instance to etcd: %v\n", err)
-		}
-	}
-
-	// Add new instance to vault cluster
-	if err := backoff.Retry(func() error {
-		log.Debugf("Adding machine to vault cluster")
-		if err := newVaultProvider().AddMachine(createInstanceFlags.ClusterInfo.ID, machineID); err != nil {
-			log.Warningf("Failed to add machine to vault: %v", err)
-			return maskAny(err)
-		}
-		return nil
-	}, backoff.NewExponentialBackOff()); err != nil {
-		log.Warningf("Failed to add machine to vault: %v", err)
-		log.Warningf("To fix, run: vault-monkey cluster add -c %s -m %s", createInstanceFlags.ClusterInfo.ID, machineID)
-	}
-
-	// Add new instance to list
-	instances = append(instances, instance)
-
-	// Load cluster-members data
-	isEtcdProxy := func(i providers.ClusterInstance) (bool, error) {
-		if i.ClusterIP == instance.ClusterIP {
-			return createInstanceFlags.EtcdProxy, nil
-		}
-		result, err := i.IsEtcdProxy(log)
-		return result, maskAny(err)
-	}
-	clusterMembers, err := instances.AsClusterMemberList(log, isEtcdProxy)
-	if err != nil {
-		Exitf("Failed to convert instance list to member list: %v\n", err)
-	}
-
-	// Perform initial setup on new instance
-	iso := providers.InitialSetupOptions{
-		ClusterMembers:   clusterMembers,
-		FleetMetadata:    createInstanceFlags.CreateFleetMetadata(createInstanceFlags.InstanceIndex),
-		EtcdClusterState: "existing",
-	}
-	if err := instance.InitialSetup(log, createInstanceFlags, iso, provider); err != nil {
-		Exitf("Failed to perform initial instance setup: %v\n", err)
-	}
-
-	// Update existing members
-	if err := providers.UpdateClusterMembers(log, createInstanceFlags.ClusterInfo, false, isEtcdProxy, provider); err != nil {
-		Exitf("Failed to update cluster members: %v\n", err)
-	}
-
-	// Reboot new instance
-	if err := provider.RebootInstance(instance); err != nil {
-		Exitf("Failed to reboot new instance: %v\n", err)
-	}
-
-	Infof("Instance created\n")
+	return options, instances
 }
@@ -16,10 +16,20 @@ package main
 
 import (
 	"fmt"
+	"strings"
+	"time"
 
+	"github.com/juju/errgo"
 	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
 
 	"github.com/pulcy/quark/providers"
+	"github.com/pulcy/quark/providers/audit"
+	"github.com/pulcy/quark/providers/state"
+)
+
+const (
+	defaultDrainTimeout = time.Minute * 5
 )
 
 var (
@@ -30,20 +40,37 @@ var (
 		Run:   destroyInstance,
 	}
 
-	destroyInstanceFlags providers.ClusterInstanceInfo
+	destroyInstanceFlags struct {
+		providers.ClusterInstanceInfo
+		drainFlags
+		Force bool
+	}
 )
 
+// drainFlags specifies how workloads are moved off an instance before it is destroyed.
+type drainFlags struct {
+	SkipDrain    bool
+	DrainTimeout time.Duration
+}
+
 func init() {
 	cmdDestroyInstance.Flags().StringVar(&destroyInstanceFlags.Domain, "domain", defaultDomain(), "Cluster domain")
 	cmdDestroyInstance.Flags().StringVar(&destroyInstanceFlags.Name, "name", "", "Cluster name")
 	cmdDestroyInstance.Flags().StringVar(&destroyInstanceFlags.Prefix, "prefix", "", "Instance prefix name")
+	cmdDestroyInstance.Flags().BoolVar(&destroyInstanceFlags.Force, "force", false, "If set, the instance is destroyed even when that breaks etcd quorum, removes the last instance with a role or draining it fails")
+	addDrainFlags(cmdDestroyInstance.Flags(), &destroyInstanceFlags.drainFlags)
 	cmdInstance.AddCommand(cmdDestroyInstance)
 }
 
+func addDrainFlags(flagSet *pflag.FlagSet, flags *drainFlags) {
+	flagSet.BoolVar(&flags.SkipDrain, "skip-drain", false, "If set, instances are destroyed without moving their fleet units & kubernetes pods to other instances first")
+	flagSet.DurationVar(&flags.DrainTimeout, "drain-timeout", defaultDrainTimeout, "Maximum time to move fleet units & kubernetes pods to other instances (evicting & waiting together)")
+}
+
 func destroyInstance(cmd *cobra.Command, args []string) {
 	requireProfile := false
 	loadArgumentsFromCluster(cmd.Flags(), requireProfile)
-	clusterInstanceInfoFromArgs(&destroyInstanceFlags, args)
+	clusterInstanceInfoFromArgs(&destroyInstanceFlags.ClusterInstanceInfo, args)
 
 	provider := newProvider()
 	destroyInstanceFlags.ClusterInfo = provider.ClusterDefaults(destroyInstanceFlags.ClusterInfo)
@@ -57,47 +84,123 @@ func destroyInstance(cmd *cobra.Command, args []string) {
 	if destroyInstanceFlags.Prefix == "" {
 		Exitf("Please specify a prefix\n")
 	}
+	lockCluster(provider, destroyInstanceFlags.ClusterInfo, "instance destroy")
+	checkInstanceRemoval(provider, destroyInstanceFlags.ClusterInstanceInfo, destroyInstanceFlags.Force)
 	if err := confirm(fmt.Sprintf("Are you sure you want to destroy %s?", destroyInstanceFlags.String())); err != nil {
 		Exitf("%v\n", err)
 	}
 
+	destroyClusterInstance(provider, destroyInstanceFlags.ClusterInstanceInfo, destroyInstanceFlags.drainFlags, destroyInstanceFlags.Force)
+
+	Successf("Destroyed instance %s\n", destroyInstanceFlags)
+}
+
+// checkInstanceRemoval exits when removing the given instance would break etcd quorum, leave an even number
+// of etcd members or remove the last instance with one of its roles.
+// With force set, the problems are only shown.
+func checkInstanceRemoval(provider providers.CloudProvider, info providers.ClusterInstanceInfo, force bool) {
+	instances, err := provider.GetInstances(ctx, info.ClusterInfo)
+	if err != nil {
+		Exitf("Failed to list instances: %v\n", err)
+	}
+	toRemove, err := instances.InstanceByName(info.String())
+	if err != nil {
+		Exitf("Failed to find instance '%s'\n", info.String())
+	}
+	problems, err := instances.CheckRemoval(ctx, log, toRemove)
+	if err != nil {
+		if !force {
+			Exitf("Failed to check if %s can be removed safely (use --force to remove it anyway): %v\n", info, err)
+		}
+		log.Warningf("Failed to check if %s can be removed safely: %v", info, err)
+	}
+	if len(problems) == 0 {
+		return
+	}
+	if !force {
+		Exitf("Refusing to remove %s (use --force to remove it anyway):\n- %s\n", info, strings.Join(problems, "\n- "))
+	}
+	for _, p := range problems {
+		log.Warningf("Forced removal: %s", p)
+	}
+}
+
+// destroyClusterInstance moves the workloads off the given instance, removes it from the cluster and destroys it.
+// When draining or removal from etcd fails, the instance is only destroyed when force is set.
+func destroyClusterInstance(provider providers.CloudProvider, info providers.ClusterInstanceInfo, drain drainFlags, force bool) {
 	// Remove instance from etcd
-	instances, err := provider.GetInstances(destroyInstanceFlags.ClusterInfo)
+	instances, err := provider.GetInstances(ctx, info.ClusterInfo)
 	if err != nil {
 		Exitf("Failed to list instances: %v\n", err)
 	}
-	toRemove, err := instances.InstanceByName(destroyInstanceFlags.String())
+	toRemove, err := instances.InstanceByName(info.String())
 	if err != nil {
-		Exitf("Failed to find instance '%s'\n", destroyInstanceFlags.String())
+		Exitf("Failed to find instance '%s'\n", info.String())
 	}
-	machineID, err := toRemove.GetMachineID(log)
+	machineID, err := toRemove.GetMachineID(ctx, log)
 	if err != nil {
 		Exitf("Failed to query machine id for instance: %#v", err)
 	}
-	isEtcdProxy, err := toRemove.IsEtcdProxy(log)
+	isEtcdProxy, err := toRemove.IsEtcdProxy(ctx, log)
 	if err != nil {
 		Exitf("Failed to query etcd mode for instance: %#v", err)
 	}
+
+	// Move fleet units & kubernetes pods to other instances
+	if !drain.SkipDrain {
+		drainClusterInstance(instances, toRemove, drain, force)
+	}
+
 	if !isEtcdProxy {
 		remainingInstances := instances.Except(toRemove)
-		if err := remainingInstances.RemoveEtcdMember(log, toRemove.Name, toRemove.ClusterIP); err != nil {
-			log.Errorf("Failed to remove instance '%s' from ETCD", destroyInstanceFlags.String())
+		if err := remainingInstances.RemoveEtcdMember(ctx, log, toRemove.Name, toRemove.ClusterIP); errgo.Cause(err) == providers.NotFoundError {
+			log.Warningf("Instance '%s' is not an ETCD member", info.String())
+		} else if err != nil {
+			if !force {
+				Exitf("Failed to remove instance '%s' from ETCD (use --force to destroy it anyway): %v\n", info.String(), err)
+			}
+			log.Errorf("Failed to remove instance '%s' from ETCD: %v", info.String(), err)
+		} else {
+			auditLog.Emit(audit.Event{Type: audit.EventEtcdMemberRemoved, Instance: toRemove.Name, Name: machineID, Data: toRemove.ClusterIP})
 		}
 	}
 
-	if err := provider.DeleteInstance(destroyInstanceFlags, newDnsProvider()); err != nil {
+	if err := provider.DeleteInstance(ctx, info, newDnsProvider()); err != nil {
 		Exitf("Failed to destroy instance: %v\n", err)
 	}
 
 	// Update existing members
-	if err := providers.UpdateClusterMembers(log, destroyInstanceFlags.ClusterInfo, false, nil, provider); err != nil {
+	if err := providers.UpdateClusterMembers(ctx, log, info.ClusterInfo, false, nil, provider); err != nil {
 		Exitf("Failed to update cluster members: %v\n", err)
 	}
 
 	// Remove machine from vault
-	if err := newVaultProvider().RemoveMachine(machineID); err != nil {
+	if err := newVaultProvider().RemoveMachine(ctx, machineID); err != nil {
 		log.Warningf("Failed to remove machine from vault: %#v", err)
 	}
 
-	Infof("Destroyed instance %s\n", destroyInstanceFlags)
+	// Remove the instance from the recorded state
+	updateClusterState(provider, info.ClusterInfo, func(c *state.Cluster) {
+		c.RemoveInstance(info.String())
+	})
+}
+
+// drainClusterInstance moves the workloads off the given instance to the other instances in the given list
+// and waits until they have moved. Both together take at most the drain timeout.
+// When that fails, it exits unless force is set.
+func drainClusterInstance(instances providers.ClusterInstanceList, i providers.ClusterInstance, drain drainFlags, force bool) {
+	others := instances.Except(i)
+	deadline := time.Now().Add(drain.DrainTimeout)
+	err := others.Drain(ctx, log, i, deadline)
+	if err == nil && !dryRun {
+		err = others.WaitUntilDrained(ctx, log, i, deadline)
+	}
+	if err != nil {
+		if !force {
+			Exitf("Failed to drain %s (use --skip-drain or --force to destroy it anyway): %v\n", i.Name, err)
+		}
+		log.Errorf("Failed to drain %s: %v", i.Name, err)
+		return
+	}
+	auditStep("drain", i.Name)
 }
@@ -15,6 +15,9 @@
 package main
 
 import (
+	"sort"
+	"strings"
+
 	"github.com/spf13/cobra"
 )
 
@@ -33,8 +36,16 @@ func showImages(cmd *cobra.Command, args []string) {
 	requireProfile := false
 	loadArgumentsFromCluster(cmd.Flags(), requireProfile)
 	provider := newProvider()
-	err := provider.ShowImages()
+	list, err := provider.GetImages(ctx)
 	if err != nil {
 		Exitf("Failed to show images: %v\n", err)
 	}
+	sort.Sort(list)
+	showOutput(list, func() []string {
+		rows := [][]string{}
+		for _, r := range list {
+			rows = append(rows, []string{r.ID, r.Name, r.Description, r.Distribution, r.Arch, r.Family, strings.Join(r.Categories, " "), strings.Join(r.Regions, " ")})
+		}
+		return formatTable([]string{"ID", "Name", "Description", "Distribution", "Arch", "Family", "Categories", "Regions"}, rows)
+	})
 }
@@ -15,6 +15,8 @@
 package main
 
 import (
+	"sort"
+
 	"github.com/spf13/cobra"
 )
 
@@ -33,8 +35,16 @@ func showKeys(cmd *cobra.Command, args []string) {
 	requireProfile := false
 	loadArgumentsFromCluster(cmd.Flags(), requireProfile)
 	provider := newProvider()
-	err := provider.ShowKeys()
+	list, err := provider.GetKeys(ctx)
 	if err != nil {
 		Exitf("Failed to show keys: %v\n", err)
 	}
+	sort.Sort(list)
+	showOutput(list, func() []string {
+		rows := [][]string{}
+		for _, r := range list {
+			rows = append(rows, []string{r.ID, r.Name, r.Fingerprint, r.PublicKey})
+		}
+		return formatTable([]string{"ID", "Name", "Fingerprint", "Public-key"}, rows)
+	})
 }
@@ -18,7 +18,6 @@ import (
 	"fmt"
 	"strings"
 
-	"github.com/ryanuber/columnize"
 	"github.com/spf13/cobra"
 
 	"github.com/pulcy/quark/providers"
@@ -33,6 +32,18 @@ var (
 	instancesFlags providers.ClusterInfo
 )
 
+// instanceOutput is the machine readable form of a single line of `instance list`.
+type instanceOutput struct {
+	Name             string   `json:"name"`
+	ClusterIP        string   `json:"cluster-ip"`
+	LoadBalancerIPv4 string   `json:"public-ipv4,omitempty"`
+	LoadBalancerIPv6 string   `json:"public-ipv6,omitempty"`
+	PrivateIP        string   `json:"private-ip,omitempty"`
+	MachineID        string   `json:"machine-id,omitempty"`
+	EtcdProxy        bool     `json:"etcd-proxy"`
+	Extra            []string `json:"extra,omitempty"`
+}
+
 func init() {
 	cmdInstanceList.Flags().StringVar(&instancesFlags.Domain, "domain", defaultDomain(), "Cluster domain")
 	cmdInstanceList.Flags().StringVar(&instancesFlags.Name, "name", "", "Cluster name")
@@ -50,25 +61,39 @@ func showInstances(cmd *cobra.Command, args []string) {
 	if instancesFlags.Name == "" {
 		Exitf("Please specify a name\n")
 	}
-	instances, err := provider.GetInstances(instancesFlags)
+	instances, err := provider.GetInstances(ctx, instancesFlags)
 	if err != nil {
 		Exitf("Failed to list instances: %v\n", err)
 	}
-	clusterMembers, err := instances.AsClusterMemberList(log, nil)
+	clusterMembers, err := instances.AsClusterMemberList(ctx, log, nil)
 	if err != nil {
 		Exitf("Failed to fetch instance member data: %v\n", err)
 	}
 
-	lines := []string{"Name | Cluster IP | Public IP | Private IP | Machine ID | Options | Extra"}
+	list := []instanceOutput{}
 	for _, i := range instances {
 		cm, _ := clusterMembers.Find(i) // ignore errors
-		options := []string{}
-		if cm.EtcdProxy {
-			options = append(options, "etcd-proxy")
-		}
-		lbIP := strings.TrimSpace(i.LoadBalancerIPv4 + " " + i.LoadBalancerIPv6)
-		lines = append(lines, fmt.Sprintf("%s | %s | %s | %s | %s | %s | %s", i.Name, i.ClusterIP, lbIP, i.PrivateIP, cm.MachineID, strings.Join(options, ","), strings.Join(i.Extra, ",")))
+		list = append(list, instanceOutput{
+			Name:             i.Name,
+			ClusterIP:        i.ClusterIP,
+			LoadBalancerIPv4: i.LoadBalancerIPv4,
+			LoadBalancerIPv6: i.LoadBalancerIPv6,
+			PrivateIP:        i.PrivateIP,
+			MachineID:        cm.MachineID,
+			EtcdProxy:        cm.EtcdProxy,
+			Extra:            i.Extra,
+		})
 	}
-	result := columnize.SimpleFormat(lines)
-	fmt.Println(result)
+	showOutput(list, func() []string {
+		lines := []string{"Name | Cluster IP | Public IP | Private IP | Machine ID | Options | Extra"}
+		for _, i := range list {
+			options := []string{}
+			if i.EtcdProxy {
+				options = append(options, "etcd-proxy")
+			}
+			lbIP := strings.TrimSpace(i.LoadBalancerIPv4 + " " + i.LoadBalancerIPv6)
+			lines = append(lines, fmt.Sprintf("%s | %s | %s | %s | %s | %s | %s", i.Name, i.ClusterIP, lbIP, i.PrivateIP, i.MachineID, strings.Join(options, ","), strings.Join(i.Extra, ",")))
+		}
+		return lines
+	})
 }
@@ -15,6 +15,9 @@
 package main
 
 import (
+	"sort"
+	"strings"
+
 	"github.com/spf13/cobra"
 )
 
@@ -33,8 +36,16 @@ func showRegions(cmd *cobra.Command, args []string) {
 	requireProfile := false
 	loadArgumentsFromCluster(cmd.Flags(), requireProfile)
 	provider := newProvider()
-	err := provider.ShowRegions()
+	list, err := provider.GetRegions(ctx)
 	if err != nil {
 		Exitf("Failed to show regions: %v\n", err)
 	}
+	sort.Sort(list)
+	showOutput(list, func() []string {
+		rows := [][]string{}
+		for _, r := range list {
+			rows = append(rows, []string{r.ID, r.Name, r.State, r.Country, r.Continent, strings.Join(r.Features, " "), strings.Join(r.Sizes, " ")})
+		}
+		return formatTable([]string{"ID", "Name", "State", "Country", "Continent", "Features", "Sizes"}, rows)
+	})
 }
@@ -0,0 +1,148 @@
+// Copyright (c) 2016 Pulcy.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/ryanuber/columnize"
+	"github.com/spf13/cobra"
+
+	"github.com/pulcy/quark/providers"
+)
+
+var (
+	cmdReplaceInstance = &cobra.Command{
+		Short: "Replace a single instance by a new instance with the same roles",
+		Long:  "Create a new instance with the same roles, etcd proxy status & fleet metadata as an existing instance, then drain and destroy the existing instance",
+		Use:   "replace",
+		Run:   replaceInstance,
+		Example: `Replace instance 'ldszw7sj' of 'c47.pulcy.com'.
+	./quark instance replace -p vultr ldszw7sj.c47.pulcy.com
+`,
+	}
+
+	replaceInstanceFlags struct {
+		providers.CreateInstanceOptions
+		drainFlags
+		Prefix        string
+		HealthTimeout time.Duration
+		Force         bool
+	}
+)
+
+func init() {
+	flags := cmdReplaceInstance.Flags()
+	addCreateInstanceFlags(flags, &replaceInstanceFlags.CreateInstanceOptions)
+	flags.StringVar(&replaceInstanceFlags.Prefix, "prefix", "", "Instance prefix name of the instance to replace")
+	flags.DurationVar(&replaceInstanceFlags.HealthTimeout, "health-timeout", defaultHealthTimeout, "Maximum time to wait for etcd & fleet to become healthy on the new instance")
+	flags.BoolVar(&replaceInstanceFlags.Force, "force", false, "If set, the old instance is destroyed even when that breaks etcd quorum or draining it fails")
+	addDrainFlags(flags, &replaceInstanceFlags.drainFlags)
+	// Roles are copied from the instance that is replaced
+	for _, name := range []string{"etcd-proxy", "role-core", "role-lb", "role-vault", "role-worker", "index"} {
+		flags.MarkHidden(name)
+	}
+	cmdInstance.AddCommand(cmdReplaceInstance)
+}
+
+func replaceInstance(cmd *cobra.Command, args []string) {
+	options := &replaceInstanceFlags.CreateInstanceOptions
+	options.VaultAddress = vaultCfg.VaultAddr
+	options.VaultCertificatePath = vaultCfg.VaultCACert
+	options.VaultServerKeyPath = vaultCfg.VaultCAKey
+	options.VaultServerKeyCommand = vaultCfg.VaultCAKeyCommand
+
+	requireProfile := false
+	loadArgumentsFromCluster(cmd.Flags(), requireProfile)
+	info := providers.ClusterInstanceInfo{
+		ClusterInfo: options.ClusterInfo,
+		Prefix:      replaceInstanceFlags.Prefix,
+	}
+	clusterInstanceInfoFromArgs(&info, args)
+
+	provider := newProvider()
+	info.ClusterInfo = provider.ClusterDefaults(info.ClusterInfo)
+	options.ClusterInfo = info.ClusterInfo
+
+	if info.Domain == "" {
+		Exitf("Please specify a domain\n")
+	}
+	if info.Name == "" {
+		Exitf("Please specify a name\n")
+	}
+	if info.Prefix == "" {
+		Exitf("Please specify a prefix\n")
+	}
+	lockCluster(provider, info.ClusterInfo, "instance replace")
+	applyClusterStateDefaults(cmd.Flags(), provider, info.ClusterInfo)
+	instances, err := provider.GetInstances(ctx, info.ClusterInfo)
+	if err != nil {
+		Exitf("Failed to list instances: %v\n", err)
+	}
+	old, err := instances.InstanceByName(info.String())
+	if err != nil {
+		Exitf("Failed to find instance '%s'\n", info.String())
+	}
+
+	// Create the replacement with the same settings as the old instance
+	profile, err := old.GetProfile(ctx, log)
+	if err != nil {
+		Exitf("Failed to read settings of %s: %v\n", old.Name, err)
+	}
+	profile.ApplyTo(options)
+	showInstanceProfile(old, profile)
+	if err := confirm(fmt.Sprintf("Are you sure you want to replace %s?", info)); err != nil {
+		Exitf("%v\n", err)
+	}
+
+	// Create the new instance (joins etcd, vault & the load-balancer DNS records)
+	log.Infof("Creating replacement for %s", old.Name)
+	replacement := createClusterInstance(provider, *options, false)
+	if !dryRun {
+		if err := replacement.WaitUntilHealthy(ctx, log, replaceInstanceFlags.HealthTimeout); err != nil {
+			Exitf("Replacement %s did not become healthy, %s is left in place: %v\n", replacement.Name, old.Name, err)
+		}
+	}
+
+	// Remove the old instance
+	checkInstanceRemoval(provider, info, replaceInstanceFlags.Force)
+	destroyClusterInstance(provider, info, replaceInstanceFlags.drainFlags, replaceInstanceFlags.Force)
+
+	Successf("Replaced %s by %s\n", old.Name, replacement.Name)
+}
+
+// showInstanceProfile prints the settings of an instance that are copied to its replacement.
+func showInstanceProfile(i providers.ClusterInstance, p providers.InstanceProfile) {
+	etcd := "member"
+	if p.EtcdProxy {
+		etcd = "proxy"
+	}
+	var metadata []string
+	for k, v := range p.FleetMetadata {
+		metadata = append(metadata, k+"="+v)
+	}
+	sort.Strings(metadata)
+	lines := []string{
+		fmt.Sprintf("Instance | %s", i.Name),
+		fmt.Sprintf("Roles | %s", p.Roles),
+		fmt.Sprintf("Etcd | %s", etcd),
+		fmt.Sprintf("Tinc IP | %s", p.ClusterIP),
+		fmt.Sprintf("Fleet metadata | %s", strings.Join(metadata, ",")),
+	}
+	fmt.Println(columnize.SimpleFormat(lines))
+}
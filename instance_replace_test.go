@@ -0,0 +1,115 @@
+// Copyright (c) 2016 Pulcy.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/pulcy/quark/providers"
+	"github.com/pulcy/quark/providers/dryrun"
+	"github.com/pulcy/quark/providers/fake"
+	"github.com/pulcy/quark/providers/sshtest"
+)
+
+// TestReplaceInstanceOrder replaces an instance of a fake cluster in a dry run and checks
+// that the replacement is created & joined before the old instance is removed.
+func TestReplaceInstanceOrder(t *testing.T) {
+	dir, err := ioutil.TempDir("", "quark-replace")
+	if err != nil {
+		t.Fatalf("Cannot create temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	statePath := filepath.Join(dir, "fake-state.json")
+	info := providers.ClusterInfo{ID: "c1-id", Name: "c1", Domain: "example.com"}
+
+	farm := sshtest.NewFarm()
+	farm.Setup = func(host string, s *sshtest.Server) {
+		s.FS.WriteFile("/etc/pulcy/cluster-id", info.ID, 0444, "root")
+		s.FS.WriteFile("/etc/pulcy/roles", "core,lb", 0644, "root")
+		s.FS.WriteFile("/etc/pulcy/vault.env", "VAULT_ADDR=https://vault.example.com:8200\nVAULT_CACERT=/etc/pulcy/vault.crt", 0400, "root")
+		s.FS.WriteFile("/etc/pulcy/vault.crt", "vault-certificate", 0400, "root")
+		s.FS.WriteFile("/etc/pulcy/gluon.env", "GLUON_FLEET_ENABLED=true", 0644, "root")
+		s.FS.WriteFile("/etc/pulcy/weave.env", "WEAVE_PASSWORD=weave", 0400, "root")
+	}
+	defer farm.Close()
+
+	// Create a cluster of 4 instances (the new member is not added in a dry run)
+	p := fake.NewProvider(log, statePath)
+	var instances providers.ClusterInstanceList
+	for index := 1; index <= 4; index++ {
+		options := p.CreateInstanceDefaults(providers.CreateInstanceOptions{ClusterInfo: info, RoleCore: true, RoleLoadBalancer: true})
+		options.SetupNames(fmt.Sprintf("i%d", index), info.Name, info.Domain)
+		i, err := p.CreateInstance(context.Background(), log, options, fake.NewDnsProvider(log, statePath))
+		if err != nil {
+			t.Fatalf("CreateInstance failed: %v", err)
+		}
+		instances = append(instances, i)
+		if _, err := farm.Server(i.String()); err != nil {
+			t.Fatalf("Server failed: %v", err)
+		}
+		farm.Etcd().AddMember(i.Name, i.ClusterIP)
+	}
+	old := instances[1]
+
+	// Replace the second instance in a dry run
+	defer func(previous string) { provider = previous }(provider)
+	defer func(previous bool) { dryRun = previous }(dryRun)
+	defer func(previous *dryrun.Plan) { dryRunPlan = previous }(dryRunPlan)
+	defer func(previous string) { journalDir = previous }(journalDir)
+	defer func(previous string) { stateDir = previous }(stateDir)
+	provider = "fake"
+	dryRun = true
+	dryRunPlan = dryrun.NewPlan()
+	journalDir = filepath.Join(dir, "journals")
+	stateDir = filepath.Join(dir, "state")
+	if err := providers.CloudProviderFlags("fake").Set("fake-state", statePath); err != nil {
+		t.Fatalf("Cannot set fake-state: %v", err)
+	}
+	defer providers.SetSSHDialer(providers.SetSSHDialer(dryrun.NewSSHDialer(dryRunPlan, farm.Dialer())))
+
+	replaceInstance(cmdReplaceInstance, []string{old.Name})
+
+	steps := dryRunPlan.Steps()
+	indexOf := func(kind, target, description string) int {
+		for idx, s := range steps {
+			if s.Kind == kind && strings.Contains(s.Target, target) && strings.Contains(s.Description, description) {
+				return idx
+			}
+		}
+		t.Fatalf("No %s step for %s containing '%s' in plan:\n%v", kind, target, description, steps)
+		return -1
+	}
+	// The replacement is fully set up before the old instance is drained & removed
+	order := []int{
+		indexOf(dryrun.KindServer, info.String(), "Create server"),
+		indexOf(dryrun.KindEtcd, "", "Add member"),
+		indexOf(dryrun.KindServer, info.String(), "Reboot"),
+		indexOf(dryrun.KindSSH, old.String(), "stop fleet"),
+		indexOf(dryrun.KindEtcd, "", "Remove member"),
+		indexOf(dryrun.KindServer, old.Name, "Delete server"),
+	}
+	for idx := 1; idx < len(order); idx++ {
+		if order[idx-1] >= order[idx] {
+			t.Errorf("Expected steps %v to be in order:\n%v", order, steps)
+			break
+		}
+	}
+}
@@ -0,0 +1,85 @@
+// Copyright (c) 2016 Pulcy.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/pulcy/quark/providers"
+)
+
+var (
+	cmdSSHInstance = &cobra.Command{
+		Short: "Open an SSH session to a single instance",
+		Long:  "Open an interactive SSH session to a single instance. Add a command after `--` to run it instead of the login shell",
+		Use:   "ssh",
+		Run:   sshInstance,
+	}
+
+	sshInstanceFlags providers.ClusterInstanceInfo
+)
+
+func init() {
+	cmdSSHInstance.Flags().StringVar(&sshInstanceFlags.Domain, "domain", defaultDomain(), "Cluster domain")
+	cmdSSHInstance.Flags().StringVar(&sshInstanceFlags.Name, "name", "", "Cluster name")
+	cmdSSHInstance.Flags().StringVar(&sshInstanceFlags.Prefix, "prefix", "", "Instance prefix name")
+	cmdInstance.AddCommand(cmdSSHInstance)
+}
+
+func sshInstance(cmd *cobra.Command, args []string) {
+	requireProfile := false
+	loadArgumentsFromCluster(cmd.Flags(), requireProfile)
+	args, command := splitArgsAtDash(cmd, args)
+	clusterInstanceInfoFromArgs(&sshInstanceFlags, args)
+
+	provider := newProvider()
+	sshInstanceFlags.ClusterInfo = provider.ClusterDefaults(sshInstanceFlags.ClusterInfo)
+
+	if sshInstanceFlags.Domain == "" {
+		Exitf("Please specify a domain\n")
+	}
+	if sshInstanceFlags.Name == "" {
+		Exitf("Please specify a name\n")
+	}
+	if sshInstanceFlags.Prefix == "" {
+		Exitf("Please specify a prefix\n")
+	}
+	instances, err := provider.GetInstances(ctx, sshInstanceFlags.ClusterInfo)
+	if err != nil {
+		Exitf("Failed to list instances: %v\n", err)
+	}
+	instance, err := instances.InstanceByName(sshInstanceFlags.String())
+	if err != nil {
+		Exitf("Failed to find instance '%s'\n", sshInstanceFlags.String())
+	}
+
+	status, err := instance.Shell(ctx, log, command)
+	if err != nil {
+		Exitf("SSH session to %s failed: %v\n", instance.Name, err)
+	}
+	exitStatus = status
+}
+
+// splitArgsAtDash splits the given arguments into the arguments before `--`
+// and a command made of all arguments after `--`.
+func splitArgsAtDash(cmd *cobra.Command, args []string) ([]string, string) {
+	dash := cmd.ArgsLenAtDash()
+	if dash < 0 {
+		return args, ""
+	}
+	return args[:dash], strings.Join(args[dash:], " ")
+}
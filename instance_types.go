@@ -15,6 +15,8 @@
 package main
 
 import (
+	"sort"
+
 	"github.com/spf13/cobra"
 )
 
@@ -33,8 +35,16 @@ func showInstanceTypes(cmd *cobra.Command, args []string) {
 	requireProfile := false
 	loadArgumentsFromCluster(cmd.Flags(), requireProfile)
 	provider := newProvider()
-	err := provider.ShowInstanceTypes()
+	list, err := provider.GetInstanceTypes(ctx)
 	if err != nil {
 		Exitf("Failed to show instance types: %v\n", err)
 	}
+	sort.Sort(list)
+	showOutput(list, func() []string {
+		rows := [][]string{}
+		for _, r := range list {
+			rows = append(rows, []string{r.ID, r.Name, formatInt(r.VCpus), r.RAM, r.Disk, r.Bandwidth, r.Price})
+		}
+		return formatTable([]string{"ID", "Name", "VCpu", "RAM", "Disk", "Bandwidth", "Price"}, rows)
+	})
 }
@@ -0,0 +1,144 @@
+// Copyright (c) 2016 Pulcy.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/juju/errgo"
+	"github.com/ryanuber/columnize"
+	"github.com/spf13/pflag"
+
+	"github.com/pulcy/quark/providers"
+	"github.com/pulcy/quark/providers/journal"
+)
+
+const (
+	operationCreateCluster  = "cluster create"
+	operationCreateInstance = "instance create"
+
+	// Steps recorded in the journal
+	stepInitialSetup  = "initial-setup"
+	stepUpdateMembers = "update-members"
+	stepReboot        = "reboot"
+)
+
+var (
+	// unfinishedJournal is the journal of the create operation that is running.
+	// When quark exits before the operation has finished, the journal is kept so the
+	// operation can be resumed or rolled back.
+	unfinishedJournal *journal.Journal
+)
+
+// journalFlags specifies what to do with the journal of an earlier create operation that did not finish.
+type journalFlags struct {
+	Resume   bool
+	Rollback bool
+}
+
+func addJournalFlags(flagSet *pflag.FlagSet, flags *journalFlags) {
+	flagSet.BoolVar(&flags.Resume, "resume", false, "If set, an earlier create operation that failed is finished, using the options recorded in its journal")
+	flagSet.BoolVar(&flags.Rollback, "rollback", false, "If set, all resources created by an earlier create operation that failed are removed")
+}
+
+// journalPath returns the path of the journal file of the given cluster.
+func journalPath(info providers.ClusterInfo) string {
+	return filepath.Join(journalDir, info.String()+".json")
+}
+
+// openJournal returns the journal used to record the create operation on the given cluster.
+// Without --resume or --rollback a new journal is started, unless the journal of an earlier operation
+// that did not finish exists. With --resume or --rollback, the journal of that earlier operation is returned.
+func openJournal(operation string, info providers.ClusterInfo, flags journalFlags) *journal.Journal {
+	path := journalPath(info)
+	if dryRun {
+		// Nothing is created, so there is nothing to record
+		path = ""
+	}
+	j, err := journal.Load(journalPath(info))
+	if err == nil && !j.IsEmpty() {
+		switch {
+		case flags.Rollback:
+		case !flags.Resume:
+			Exitf("An unfinished %s of %s is recorded in %s.\nUse --resume to finish it or --rollback to undo it.\n", j.Operation, info, j.Path())
+		case j.Operation != operation:
+			Exitf("The unfinished operation on %s is a %s, use `quark %s --resume` to finish it.\n", info, j.Operation, j.Operation)
+		}
+		if dryRun {
+			j.SetReadOnly()
+		}
+		unfinishedJournal = j
+		return j
+	} else if err != nil && errgo.Cause(err) != journal.NotFoundError {
+		Exitf("Failed to load journal of %s: %v\n", info, err)
+	}
+
+	if flags.Resume || flags.Rollback {
+		Exitf("There is no unfinished operation on %s.\n", info)
+	}
+	j = journal.New(path, operation, info)
+	unfinishedJournal = j
+	return j
+}
+
+// finishJournal removes the journal of a create operation that has finished.
+func finishJournal(j *journal.Journal) {
+	unfinishedJournal = nil
+	if err := j.Remove(); err != nil {
+		log.Warningf("Failed to remove journal %s: %v", j.Path(), err)
+	}
+}
+
+// rollbackJournal removes all resources recorded in the given journal.
+func rollbackJournal(j *journal.Journal) {
+	unfinishedJournal = nil
+	showJournal(j)
+	if err := confirm(fmt.Sprintf("Are you sure you want to remove all resources created by the unfinished %s of %s?", j.Operation, j.Cluster)); err != nil {
+		Exitf("%v\n", err)
+	}
+	if err := j.Rollback(ctx, log, newProvider(), newDnsProvider(), newVaultProvider()); err != nil {
+		Exitf("Failed to roll back %s of %s: %v\nUse --rollback to try again.\n", j.Operation, j.Cluster, err)
+	}
+}
+
+// showJournal prints all resources recorded in the given journal.
+func showJournal(j *journal.Journal) {
+	lines := []string{"Kind | Name | Status"}
+	for _, e := range j.Entries {
+		if e.Kind == journal.KindStep {
+			continue
+		}
+		name := e.Name
+		if e.Kind == journal.KindDns {
+			name = fmt.Sprintf("%s %s -> %s", e.Type, e.Name, e.Data)
+		}
+		status := "created"
+		if !e.Done {
+			status = "partially created"
+		}
+		lines = append(lines, strings.Join([]string{e.Kind, name, status}, " | "))
+	}
+	fmt.Println(columnize.SimpleFormat(lines))
+}
+
+// recordStep records a finished step of the operation in the given journal.
+func recordStep(j *journal.Journal, name string) {
+	if err := j.RecordStep(name); err != nil {
+		Exitf("Failed to record %s in journal: %v\n", name, err)
+	}
+	auditStep(name, "")
+}
@@ -0,0 +1,142 @@
+// Copyright (c) 2016 Pulcy.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/juju/errgo"
+
+	"github.com/pulcy/quark/providers"
+	"github.com/pulcy/quark/providers/audit"
+)
+
+const (
+	// minLockRenewInterval is the minimum time between renewals of a held cluster lock.
+	minLockRenewInterval = time.Second * 10
+)
+
+// heldClusterLock is the operation lock held by this run of quark.
+// It is renewed periodically until it is released.
+type heldClusterLock struct {
+	provider providers.CloudProvider
+	info     providers.ClusterInfo
+	lock     providers.ClusterLock
+	stop     chan struct{}
+	stopped  chan struct{}
+}
+
+var (
+	clusterLock      *heldClusterLock
+	clusterLockMutex sync.Mutex
+)
+
+// lockOwner returns the identity used as owner of cluster locks (user@host).
+func lockOwner() string {
+	return audit.LocalUser()
+}
+
+// lockCluster acquires the operation lock of the given cluster, so no other quark run
+// can change the cluster at the same time. The lock is released by releaseClusterLock.
+// When the cluster is locked by another operation, quark exits.
+// When the lock cannot be acquired for another reason, quark exits unless --no-lock is set.
+func lockCluster(provider providers.CloudProvider, info providers.ClusterInfo, operation string) {
+	clusterLockMutex.Lock()
+	held := clusterLock
+	clusterLockMutex.Unlock()
+	if held != nil && held.info == info {
+		// Already locked by us
+		return
+	}
+	// Every command that changes a cluster locks it first, so this is where its operation starts
+	startAuditOperation(provider, info, operation)
+	if dryRun {
+		// Nothing is changed, so only warn about a running operation
+		if lock, err := providers.GetClusterLock(ctx, log, provider, info); err == nil && !lock.IsExpired() {
+			log.Warningf("%s is locked by %s", info, lock)
+		}
+		return
+	}
+	lock, err := providers.LockCluster(ctx, log, provider, info, providers.NewClusterLock(lockOwner(), operation, lockTTL))
+	switch errgo.Cause(err) {
+	case nil:
+		held := &heldClusterLock{provider: provider, info: info, lock: lock, stop: make(chan struct{}), stopped: make(chan struct{})}
+		clusterLockMutex.Lock()
+		clusterLock = held
+		clusterLockMutex.Unlock()
+		go held.renew()
+	case providers.ClusterLockedError:
+		Exitf("%s is locked by %s.\nIf that operation is no longer running, use `quark cluster unlock --force %s` to remove the lock.\n", info, lock, info)
+	case providers.NotFoundError:
+		// There are no instances (yet), so there is nothing to lock
+		log.Debugf("Not locking %s: %v", info, err)
+	default:
+		if !noLock {
+			Exitf("Cannot lock %s: %v\nUse --no-lock to continue without lock, if you are sure no one else is changing the cluster.\n", info, err)
+		}
+		log.Warningf("Cannot lock %s, continuing without lock: %v", info, err)
+	}
+}
+
+// renew extends the lock periodically (at a third of its TTL), so it does not expire
+// while a long running operation still holds it. It stops when the lock is released.
+// When the lock has been lost (removed or taken over by someone else), the running operation is cancelled.
+func (l *heldClusterLock) renew() {
+	defer close(l.stopped)
+	interval := lockTTL / 3
+	if interval < minLockRenewInterval {
+		interval = minLockRenewInterval
+	}
+	for {
+		select {
+		case <-l.stop:
+			return
+		case <-time.After(interval):
+		}
+		// Use a new context, so the lock is held until it is released, also when quark has been interrupted
+		renewed, err := providers.RenewClusterLock(context.Background(), log, l.provider, l.info, l.lock, lockTTL)
+		switch errgo.Cause(err) {
+		case nil:
+		case providers.ClusterLockedError, providers.NotFoundError:
+			log.Errorf("Lost lock of %s, stopping: %v", l.info, err)
+			cancelCtx()
+			return
+		default:
+			log.Warningf("Failed to renew lock of %s: %v", l.info, err)
+			continue
+		}
+		l.lock = renewed
+	}
+}
+
+// releaseClusterLock releases the operation lock acquired by lockCluster (if any).
+// It is called when quark exits, which can happen from the interrupt handler as well.
+func releaseClusterLock() {
+	clusterLockMutex.Lock()
+	l := clusterLock
+	clusterLock = nil
+	clusterLockMutex.Unlock()
+	if l == nil {
+		return
+	}
+	close(l.stop)
+	<-l.stopped
+	// Use a new context, so the lock is also released when quark has been interrupted
+	if err := providers.UnlockCluster(context.Background(), log, l.provider, l.info, l.lock.ID); err != nil && errgo.Cause(err) != providers.NotFoundError {
+		log.Warningf("Failed to release lock of %s: %v\nUse `quark cluster unlock %s` to release it.", l.info, err, l.info)
+	}
+}
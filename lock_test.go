@@ -0,0 +1,60 @@
+// Copyright (c) 2016 Pulcy.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	"github.com/pulcy/quark/providers"
+)
+
+// emptyProvider is a cloud provider of clusters without instances.
+type emptyProvider struct {
+	providers.CloudProvider
+}
+
+func (p emptyProvider) GetInstances(ctx context.Context, info providers.ClusterInfo) (providers.ClusterInstanceList, error) {
+	return nil, nil
+}
+
+func TestReleaseClusterLock(t *testing.T) {
+	info := providers.ClusterInfo{Name: "c1", Domain: "example.com"}
+	clusterLock = &heldClusterLock{
+		provider: emptyProvider{},
+		info:     info,
+		lock:     providers.NewClusterLock("alice@host", "instance create", lockTTL),
+		stop:     make(chan struct{}),
+		stopped:  make(chan struct{}),
+	}
+	go clusterLock.renew()
+
+	// Exiting from the interrupt handler and the main goroutine at the same time must not panic
+	var wg sync.WaitGroup
+	for index := 0; index < 3; index++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			releaseClusterLock()
+		}()
+	}
+	wg.Wait()
+	if clusterLock != nil {
+		t.Errorf("Expected the lock to be released")
+	}
+	// Releasing again is a no-op
+	releaseClusterLock()
+}
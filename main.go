@@ -16,10 +16,13 @@ package main
 
 import (
 	"bufio"
+	"context"
 	"fmt"
 	"os"
+	"os/signal"
 	"path/filepath"
 	"strings"
+	"time"
 
 	"github.com/kardianos/osext"
 	"github.com/op/go-logging"
@@ -28,11 +31,16 @@ import (
 
 	clusterpkg "github.com/pulcy/quark/cluster"
 	"github.com/pulcy/quark/providers"
-	"github.com/pulcy/quark/providers/cloudflare"
-	"github.com/pulcy/quark/providers/digitalocean"
-	"github.com/pulcy/quark/providers/scaleway"
-	"github.com/pulcy/quark/providers/vagrant"
-	"github.com/pulcy/quark/providers/vultr"
+	"github.com/pulcy/quark/providers/audit"
+	"github.com/pulcy/quark/providers/dryrun"
+
+	// Register all providers
+	_ "github.com/pulcy/quark/providers/cloudflare"
+	_ "github.com/pulcy/quark/providers/digitalocean"
+	_ "github.com/pulcy/quark/providers/fake"
+	_ "github.com/pulcy/quark/providers/scaleway"
+	_ "github.com/pulcy/quark/providers/vagrant"
+	_ "github.com/pulcy/quark/providers/vultr"
 )
 
 var (
@@ -42,58 +50,90 @@ var (
 )
 
 const (
-	projectName     = "quark"
-	defaultLogLevel = "info"
+	projectName        = "quark"
+	defaultLogLevel    = "info"
+	defaultDnsProvider = "cloudflare"
 )
 
 var (
 	cmdMain = &cobra.Command{
-		Use:              projectName,
-		Run:              showUsage,
-		PersistentPreRun: loadDefaults,
+		Use:               projectName,
+		Run:               showUsage,
+		PersistentPreRun:  loadDefaults,
+		PersistentPostRun: showDryRunPlan,
 	}
 
-	provider          string
-	digitalOceanToken string
-	cloudflareApiKey  string
-	cloudflareEmail   string
-	scalewayCfg       scaleway.ScalewayProviderConfig
-	vagrantFolder     string
-	vultrApiKey       string
-	logLevel          string
-	cluster           string
-	vaultCfg          providers.VaultProviderConfig
+	provider     string
+	dnsProvider  string
+	logLevel     string
+	cluster      string
+	dryRun       bool
+	outputFormat string
+	journalDir   string
+	stateStore   string
+	stateDir     string
+	auditFile    string
+	auditWebhook string
+	lockTTL      time.Duration
+	noLock       bool
+	sshCfg       struct {
+		KnownHosts            string
+		StrictHostKeyChecking bool
+		Jump                  string
+		Tunnel                string
+		Config                string
+		providers.SSHSettings
+	}
+	dryRunPlan    = dryrun.NewPlan()
+	vaultCfg      providers.VaultProviderConfig
+	phaseTimeouts providers.PhaseTimeouts
 
 	log = logging.MustGetLogger(projectName)
+	// exitStatus is the exit code of quark after a command completed without calling Exitf
+	exitStatus = 0
+	// ctx is cancelled when quark is interrupted
+	ctx = context.Background()
+	// cancelCtx cancels ctx
+	cancelCtx context.CancelFunc = func() {}
 )
 
 func init() {
 	logging.SetFormatter(logging.MustStringFormatter("[%{level:-5s}] %{message}"))
-	scalewayCfg = scaleway.NewConfig()
 	cmdMain.PersistentFlags().StringVar(&logLevel, "log-level", defaultLogLevel, "Log level (debug|info|warning|error)")
-	cmdMain.PersistentFlags().StringVarP(&provider, "provider", "p", "", "Provider used for creating clusters [digitalocean|scaleway|vagrant|vultr]")
+	cmdMain.PersistentFlags().StringVarP(&provider, "provider", "p", "", fmt.Sprintf("Provider used for creating clusters [%s]", strings.Join(providers.CloudProviderNames(), "|")))
+	cmdMain.PersistentFlags().StringVar(&dnsProvider, "dns-provider", "", fmt.Sprintf("Provider used for DNS records [%s] (defaults to %s)", strings.Join(providers.DnsProviderNames(), "|"), defaultDnsProvider))
 	cmdMain.PersistentFlags().StringVarP(&cluster, "cluster", "c", "", "Path of the cluster template [<profile>@]path")
+	cmdMain.PersistentFlags().BoolVar(&dryRun, "dry-run", false, "If set, show what would be changed without changing anything")
+	cmdMain.PersistentFlags().StringVarP(&outputFormat, "output", "o", outputTable, fmt.Sprintf("Output format of listings [%s]", strings.Join(outputFormats, "|")))
+	cmdMain.PersistentFlags().StringVar(&journalDir, "journal-dir", defaultJournalDir(), "Directory containing the journals of create operations that did not finish")
+	cmdMain.PersistentFlags().StringVar(&stateStore, "state-store", defaultStateStore(), fmt.Sprintf("Store of the recorded state of clusters [%s]", strings.Join(stateStores, "|")))
+	cmdMain.PersistentFlags().StringVar(&stateDir, "state-dir", defaultStateDir(), "Directory containing the recorded state of clusters (with --state-store=file)")
+	cmdMain.PersistentFlags().StringVar(&auditFile, "audit-log", defaultAuditLog(), "File to which all changes are appended as JSON lines (empty to disable)")
+	cmdMain.PersistentFlags().StringVar(&auditWebhook, "audit-webhook", defaultAuditWebhook(), "URL to which all changes are posted as JSON (optional)")
 
-	// Digital ocean settings
-	cmdMain.PersistentFlags().StringVarP(&digitalOceanToken, "digitalocean-token", "t", "", "Digital Ocean token")
-
-	// Cloudflare settings
-	cmdMain.PersistentFlags().StringVarP(&cloudflareApiKey, "cloudflare-apikey", "k", "", "Cloudflare API key")
-	cmdMain.PersistentFlags().StringVarP(&cloudflareEmail, "cloudflare-email", "e", "", "Cloudflare email address")
-
-	// Scaleway settings
-	cmdMain.PersistentFlags().StringVarP(&scalewayCfg.Organization, "scaleway-organization", "", scalewayCfg.Organization, "Scaleway organization ID")
-	cmdMain.PersistentFlags().StringVarP(&scalewayCfg.Token, "scaleway-token", "", scalewayCfg.Token, "Scaleway token")
-	cmdMain.PersistentFlags().StringVarP(&scalewayCfg.Region, "scaleway-region", "", scalewayCfg.Region, "Scaleway region")
-	cmdMain.PersistentFlags().BoolVar(&scalewayCfg.ReserveLoadBalancerIP, "scaleway-reserve-ip", scalewayCfg.ReserveLoadBalancerIP, "Use reserved IPv4 addresses for load-balancer instances")
-	cmdMain.PersistentFlags().BoolVar(&scalewayCfg.EnableIPV6, "scaleway-ipv6", scalewayCfg.EnableIPV6, "Enabled IPv6 on all instances")
-	cmdMain.PersistentFlags().BoolVar(&scalewayCfg.NoIPv4, "scaleway-no-ipv4", scalewayCfg.NoIPv4, "Do not add IPv4 addresses to new instances")
+	// Provider settings (hidden, see `quark providers`)
+	providerFlags := providers.ProviderFlags()
+	cmdMain.PersistentFlags().AddFlagSet(providerFlags)
+	providerFlags.VisitAll(func(flag *pflag.Flag) {
+		cmdMain.PersistentFlags().MarkHidden(flag.Name)
+	})
 
-	// Vagrant settings
-	cmdMain.PersistentFlags().StringVarP(&vagrantFolder, "vagrant-folder", "f", defaultVagrantFolder(), "Directory containing vagrant files")
+	// SSH settings
+	cmdMain.PersistentFlags().StringVar(&sshCfg.KnownHosts, "known-hosts", defaultKnownHosts(), "Path of the known_hosts file used to verify SSH host keys")
+	cmdMain.PersistentFlags().BoolVar(&sshCfg.StrictHostKeyChecking, "strict-host-key-checking", defaultStrictHostKeyChecking(), "If set, SSH connections to instances with an unknown host key are refused (only the fake provider reports host keys)")
+	cmdMain.PersistentFlags().StringVar(&sshCfg.Jump, "ssh-jump", defaultSSHJump(), "Jump host ([user@]host[:port]) used for all SSH connections to instances")
+	cmdMain.PersistentFlags().StringVar(&sshCfg.Tunnel, "ssh-tunnel", defaultSSHTunnel(), "Jump host ([user@]host[:port]) used to reach instances without a public address (defaults to the tunnel of the cluster file)")
+	cmdMain.PersistentFlags().StringVar(&sshCfg.User, "ssh-user", defaultSSHUser(), "Account name used to SSH into instances (defaults to core)")
+	cmdMain.PersistentFlags().IntVar(&sshCfg.Port, "ssh-port", defaultSSHPort(), "Port used to SSH into instances (defaults to 22)")
+	cmdMain.PersistentFlags().StringSliceVar(&sshCfg.KeyFiles, "ssh-key-file", defaultSSHKeyFiles(), "Private key files used to SSH into instances (in addition to ssh-agent)")
+	cmdMain.PersistentFlags().StringVar(&sshCfg.Config, "ssh-config", defaultSSHConfig(), "Path of the SSH config file used for host specific SSH settings")
 
-	// Vultr settings
-	cmdMain.PersistentFlags().StringVarP(&vultrApiKey, "vultr-apikey", "", "", "Vultr API key")
+	// Timeouts
+	cmdMain.PersistentFlags().DurationVar(&phaseTimeouts.Provision, "provision-timeout", defaultProvisionTimeout, "Maximum time to wait for the provider to create a server (0 means no limit)")
+	cmdMain.PersistentFlags().DurationVar(&phaseTimeouts.SSHReady, "ssh-ready-timeout", defaultSSHReadyTimeout, "Maximum time to wait for an instance to accept SSH connections (0 means no limit)")
+	cmdMain.PersistentFlags().DurationVar(&phaseTimeouts.Bootstrap, "bootstrap-timeout", defaultBootstrapTimeout, "Maximum time of the initial setup of an instance (0 means no limit)")
+	cmdMain.PersistentFlags().DurationVar(&lockTTL, "lock-ttl", defaultLockTTL, "Time after which the lock of a cluster held by a command that changes it expires, unless it is renewed")
+	cmdMain.PersistentFlags().BoolVar(&noLock, "no-lock", false, "If set, commands that change a cluster continue when the cluster cannot be locked (not when it is locked by someone else)")
 
 	// Vault settings
 	vaultCfg.VaultCAPath = os.Getenv("VAULT_CAPATH")
@@ -106,7 +146,26 @@ func init() {
 }
 
 func main() {
+	ctx, cancelCtx = context.WithCancel(context.Background())
+	go cancelOnInterrupt(cancelCtx)
 	cmdMain.Execute()
+	auditLog.Finish()
+	releaseClusterLock()
+	providers.CloseSSHConnections()
+	os.Exit(exitStatus)
+}
+
+// cancelOnInterrupt cancels the running operation when quark is interrupted (Ctrl-C),
+// so it can stop in an orderly way and report what it created so far.
+// When interrupted a second time, quark exits immediately.
+func cancelOnInterrupt(cancel context.CancelFunc) {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt)
+	<-signals
+	log.Warningf("Interrupted, stopping (press Ctrl-C again to exit immediately)")
+	cancel()
+	<-signals
+	Exitf("Interrupted\n")
 }
 
 func showUsage(cmd *cobra.Command, args []string) {
@@ -114,89 +173,102 @@ func showUsage(cmd *cobra.Command, args []string) {
 }
 
 func loadDefaults(cmd *cobra.Command, args []string) {
-	if digitalOceanToken == "" {
-		digitalOceanToken = os.Getenv("DIGITALOCEAN_TOKEN")
-	}
-	if cloudflareApiKey == "" {
-		cloudflareApiKey = os.Getenv("CLOUDFLARE_APIKEY")
-	}
-	if cloudflareEmail == "" {
-		cloudflareEmail = os.Getenv("CLOUDFLARE_EMAIL")
-	}
-	if vultrApiKey == "" {
-		vultrApiKey = os.Getenv("VULTR_APIKEY")
-	}
-
 	// Set loglevel
 	level, err := logging.LogLevel(logLevel)
 	if err != nil {
 		Exitf("Invalid log-level '%s': %#v", logLevel, err)
 	}
 	logging.SetLevel(level, projectName)
-}
+	validateOutputFormat()
+	validateStateStore()
 
-func newProvider() providers.CloudProvider {
-	switch provider {
-	case "digitalocean":
-		if digitalOceanToken == "" {
-			Exitf("Please specify a token\n")
-		}
-		return digitalocean.NewProvider(log, digitalOceanToken)
-	case "scaleway":
-		if scalewayCfg.Organization == "" || scalewayCfg.Token == "" {
-			rc, err := scaleway.ReadRC()
-			if err != nil {
-				Exitf("Cannot read .scwrc: %#v\n", err)
-			}
-			scalewayCfg.Organization = rc.Organization
-			scalewayCfg.Token = rc.Token
-		}
-		if scalewayCfg.Organization == "" {
-			Exitf("Please specify a scaleway-organization\n")
-		}
-		if scalewayCfg.Token == "" {
-			Exitf("Please specify a scaleway-token\n")
-		}
-		provider, err := scaleway.NewProvider(log, scalewayCfg)
+	// Verify SSH host keys
+	if sshCfg.KnownHosts != "" {
+		providers.SetKnownHosts(providers.NewKnownHosts(sshCfg.KnownHosts, sshCfg.StrictHostKeyChecking))
+	} else if sshCfg.StrictHostKeyChecking {
+		Exitf("Please specify a known-hosts file\n")
+	}
+
+	providers.SetPhaseTimeouts(phaseTimeouts)
+	providers.SetSSHJumpHost(sshCfg.Jump)
+	providers.SetSSHTunnel(sshCfg.Tunnel)
+	providers.SetSSHSettings(sshCfg.SSHSettings)
+	if sshCfg.Config != "" {
+		sshConfig, err := providers.LoadSSHConfig(sshCfg.Config)
 		if err != nil {
-			Exitf("NewProvider failed: %#v\n", err)
-		}
-		return provider
-	case "vagrant":
-		if vagrantFolder == "" {
-			Exitf("Please specify a vagrant-folder\n")
-		}
-		return vagrant.NewProvider(log, vagrantFolder)
-	case "vultr":
-		if vultrApiKey == "" {
-			Exitf("Please specify a vultr-apikey\n")
+			Exitf("Cannot load SSH config from '%s': %#v\n", sshCfg.Config, err)
 		}
-		return vultr.NewProvider(log, vultrApiKey)
-	default:
-		Exitf("Unknown provider '%s'\n", provider)
-		return nil
+		providers.SetSSHConfig(sshConfig)
+	}
+
+	auditLog = newAuditLog()
+
+	// Record all changes made over SSH when doing a dry run
+	if dryRun {
+		providers.SetSSHDialer(dryrun.NewSSHDialer(dryRunPlan, providers.DialSSH))
+	}
+}
+
+// showDryRunPlan prints all changes recorded during a dry run.
+func showDryRunPlan(cmd *cobra.Command, args []string) {
+	if dryRun {
+		dryRunPlan.Print(os.Stdout)
+	}
+}
+
+func newProvider() providers.CloudProvider {
+	if dryRun {
+		return dryrun.NewProvider(log, dryRunPlan, newRealProvider())
+	}
+	return audit.NewProvider(auditLog, newRealProvider())
+}
+
+func newRealProvider() providers.CloudProvider {
+	if provider == "" {
+		Exitf("Please specify a provider [%s]\n", strings.Join(providers.CloudProviderNames(), "|"))
+	}
+	p, err := providers.NewCloudProvider(log, provider)
+	if err != nil {
+		Exitf("%v\n", err)
 	}
+	return p
 }
 
 func newDnsProvider() providers.DnsProvider {
-	if cloudflareApiKey == "" {
-		Exitf("Please specify a cloudflare-apikey\n")
+	if dryRun {
+		return dryrun.NewDnsProvider(dryRunPlan, newRealDnsProvider())
 	}
-	if cloudflareEmail == "" {
-		Exitf("Please specify a cloudflare-email\n")
+	return audit.NewDnsProvider(auditLog, newRealDnsProvider())
+}
+
+func newRealDnsProvider() providers.DnsProvider {
+	name := dnsProvider
+	if name == "" {
+		name = providers.DefaultDnsProviderFor(provider, defaultDnsProvider)
+	}
+	p, err := providers.NewDnsProvider(log, name)
+	if err != nil {
+		Exitf("%v\n", err)
 	}
-	return cloudflare.NewProvider(log, cloudflareApiKey, cloudflareEmail)
+	return p
 }
 
 func newVaultProvider() providers.VaultProvider {
+	if dryRun {
+		return dryrun.NewVaultProvider(dryRunPlan)
+	}
 	provider, err := providers.NewVaultProvider(log, vaultCfg)
 	if err != nil {
 		Exitf("Failed to created vault provider: %#v\n", err)
 	}
-	return provider
+	return audit.NewVaultProvider(auditLog, provider)
 }
 
 func confirm(question string) error {
+	if dryRun {
+		// Nothing will be changed, so there is nothing to confirm
+		return nil
+	}
 	for {
 		fmt.Printf("%s [yes|no]", question)
 		bufStdin := bufio.NewReader(os.Stdin)
@@ -214,6 +286,12 @@ func confirm(question string) error {
 
 func Exitf(format string, args ...interface{}) {
 	fmt.Printf(format, args...)
+	if j := unfinishedJournal; j != nil && !j.IsEmpty() && !dryRun {
+		showJournal(j)
+		fmt.Printf("The resources created so far are recorded in %s.\nUse --resume to finish the %s or --rollback to undo it.\n", j.Path(), j.Operation)
+	}
+	auditLog.Fail(strings.TrimSpace(fmt.Sprintf(format, args...)))
+	releaseClusterLock()
 	os.Exit(1)
 }
 
@@ -221,6 +299,15 @@ func Infof(format string, args ...interface{}) {
 	fmt.Printf(format, args...)
 }
 
+// Successf prints a message about a completed change.
+// Nothing is printed during a dry run, since nothing has been changed.
+func Successf(format string, args ...interface{}) {
+	if dryRun {
+		return
+	}
+	fmt.Printf(format, args...)
+}
+
 // clusterInfoFromArgs fills the given cluster info from a command line argument
 func clusterInfoFromArgs(info *providers.ClusterInfo, args []string) {
 	if len(args) == 1 && info.Name == "" {
@@ -253,11 +340,22 @@ func loadArgumentsFromCluster(flagSet *pflag.FlagSet, requireProfile bool) {
 	} else if requireProfile {
 		Exitf("No cluster profile specified (-c profile@cluster)")
 	}
+	c, clustersPath := loadCluster(cluster)
+	values, err := c.ResolveProfile(profile)
+	if err != nil {
+		Exitf("Cannot resolve profile '%s' in cluster path '%s': %#v", profile, clustersPath, err)
+	}
+	setFlagsFromValues(flagSet, values)
+}
+
+// loadCluster resolves the path of the given cluster and parses it.
+// It returns the parsed cluster and the folder used to resolve cluster names.
+func loadCluster(clusterPath string) (*clusterpkg.Cluster, string) {
 	clustersPath := os.Getenv("PULCY_CLUSTERS")
 	if clustersPath == "" {
 		clustersPath = "config/clusters"
 	}
-	path, err := resolvePath(cluster, clustersPath, ".hcl")
+	path, err := resolvePath(clusterPath, clustersPath, ".hcl")
 	if err != nil {
 		Exitf("Cannot resolve cluster path: %#v", err)
 	}
@@ -265,10 +363,23 @@ func loadArgumentsFromCluster(flagSet *pflag.FlagSet, requireProfile bool) {
 	if err != nil {
 		Exitf("Cannot load cluster from path '%s': %#v", clustersPath, err)
 	}
-	values, err := c.ResolveProfile(profile)
-	if err != nil {
-		Exitf("Cannot resolve profile '%s' in cluster path '%s': %#v", profile, clustersPath, err)
+	// Instances without a public address are reached through the tunnel of the cluster,
+	// unless a tunnel is specified on the commandline
+	if sshCfg.Tunnel == "" {
+		providers.SetSSHTunnel(c.Tunnel)
 	}
+	// SSH settings of the cluster are used unless specified on the commandline
+	providers.SetSSHSettings(sshCfg.SSHSettings.WithDefaults(providers.SSHSettings{
+		User:     c.SSHUser,
+		Port:     c.SSHPort,
+		KeyFiles: c.SSHKeyFiles,
+	}))
+	return c, clustersPath
+}
+
+// setFlagsFromValues sets all flags in the given flagset that have not been changed
+// to the value with the same name in the given values.
+func setFlagsFromValues(flagSet *pflag.FlagSet, values map[string]interface{}) {
 	flagSet.VisitAll(func(flag *pflag.Flag) {
 		if !flag.Changed {
 			value, ok := values[flag.Name]
@@ -0,0 +1,92 @@
+// Copyright (c) 2016 Pulcy.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/ryanuber/columnize"
+)
+
+const (
+	outputTable = "table"
+	outputJSON  = "json"
+	outputYAML  = "yaml"
+)
+
+var (
+	outputFormats = []string{outputTable, outputJSON, outputYAML}
+)
+
+// validateOutputFormat exits when the --output flag has an unknown value.
+func validateOutputFormat() {
+	for _, f := range outputFormats {
+		if f == outputFormat {
+			return
+		}
+	}
+	Exitf("Invalid output format '%s', expected one of %s\n", outputFormat, strings.Join(outputFormats, "|"))
+}
+
+// showOutput prints the given data in the format selected with --output.
+// The table format uses the (columnize formatted) lines returned by the given function.
+func showOutput(data interface{}, tableLines func() []string) {
+	switch outputFormat {
+	case outputJSON:
+		raw, err := json.MarshalIndent(data, "", "  ")
+		if err != nil {
+			Exitf("Failed to encode output: %v\n", err)
+		}
+		os.Stdout.Write(raw)
+		fmt.Println()
+	case outputYAML:
+		raw, err := marshalYAML(data)
+		if err != nil {
+			Exitf("Failed to encode output: %v\n", err)
+		}
+		os.Stdout.Write(raw)
+	default:
+		fmt.Println(columnize.SimpleFormat(tableLines()))
+	}
+}
+
+// formatTable builds columnize lines from the given headers & rows.
+// All columns are shown, even when they are empty in all rows, so the shape of
+// the table does not depend on the provider or the data.
+func formatTable(headers []string, rows [][]string) []string {
+	lines := []string{strings.Join(headers, " | ")}
+	for _, row := range rows {
+		lines = append(lines, strings.Join(row, " | "))
+	}
+	return lines
+}
+
+// formatInt returns the given value as string, or an empty string when the value is 0.
+func formatInt(value int) string {
+	if value == 0 {
+		return ""
+	}
+	return fmt.Sprintf("%d", value)
+}
+
+func trimLength(s string, maxLen int) string {
+	if len(s) > maxLen {
+		return s[:maxLen] + "..."
+	}
+	return s
+}
@@ -0,0 +1,37 @@
+// Copyright (c) 2016 Pulcy.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestFormatTable(t *testing.T) {
+	headers := []string{"ID", "Name", "Price"}
+	tests := []struct {
+		Rows     [][]string
+		Expected []string
+	}{
+		{nil, []string{"ID | Name | Price"}},
+		{[][]string{{"1", "small", ""}, {"2", "large", ""}}, []string{"ID | Name | Price", "1 | small | ", "2 | large | "}},
+		{[][]string{{"1", "", "5"}}, []string{"ID | Name | Price", "1 |  | 5"}},
+	}
+	for _, test := range tests {
+		if lines := formatTable(headers, test.Rows); !reflect.DeepEqual(lines, test.Expected) {
+			t.Errorf("formatTable(%v): expected %q, got %q", test.Rows, test.Expected, lines)
+		}
+	}
+}
@@ -0,0 +1,71 @@
+// Copyright (c) 2016 Pulcy.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+
+	"github.com/ryanuber/columnize"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+
+	"github.com/pulcy/quark/providers"
+)
+
+var (
+	cmdProviders = &cobra.Command{
+		Short: "Show all cloud & DNS providers and their options",
+		Use:   "providers",
+		Run:   showProviders,
+	}
+)
+
+func init() {
+	cmdMain.AddCommand(cmdProviders)
+}
+
+func showProviders(cmd *cobra.Command, args []string) {
+	for _, name := range providers.CloudProviderNames() {
+		fmt.Printf("Cloud provider: %s\n", name)
+		printProviderFlags(providers.CloudProviderFlags(name))
+	}
+	for _, name := range providers.DnsProviderNames() {
+		fmt.Printf("DNS provider: %s\n", name)
+		printProviderFlags(providers.DnsProviderFlags(name))
+	}
+}
+
+// printProviderFlags prints all flags in the given set.
+// Provider flags are hidden in the usage of other commands, so FlagUsages cannot be used.
+func printProviderFlags(flagSet *pflag.FlagSet) {
+	lines := []string{}
+	flagSet.VisitAll(func(flag *pflag.Flag) {
+		name := "--" + flag.Name
+		if flag.Shorthand != "" {
+			name = fmt.Sprintf("-%s, %s", flag.Shorthand, name)
+		}
+		usage := flag.Usage
+		if flag.DefValue != "" {
+			usage = fmt.Sprintf("%s (default %s)", usage, flag.DefValue)
+		}
+		lines = append(lines, fmt.Sprintf("%s | %s", name, usage))
+	})
+	if len(lines) > 0 {
+		config := columnize.DefaultConfig()
+		config.Prefix = "  "
+		fmt.Println(columnize.Format(lines, config))
+	}
+	fmt.Println()
+}
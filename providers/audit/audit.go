@@ -0,0 +1,161 @@
+// Copyright (c) 2016 Pulcy.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package audit records the changes made by quark as a stream of structured events.
+// Every event carries the operation (command) that caused it, the operator that ran it
+// and the cluster it changed. Events are sent to one or more sinks (an append-only
+// JSON-lines file, a webhook & the debug log).
+package audit
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"sync"
+	"time"
+
+	"github.com/op/go-logging"
+
+	"github.com/pulcy/quark/providers"
+)
+
+const (
+	// Types of events
+	EventOperationStarted    = "operation-started"
+	EventOperationFinished   = "operation-finished"
+	EventOperationFailed     = "operation-failed"
+	EventServerCreated       = "server-created"
+	EventServerDestroyed     = "server-destroyed"
+	EventDnsRecordAdded      = "dns-record-added"
+	EventDnsRecordRemoved    = "dns-record-removed"
+	EventEtcdMemberAdded     = "etcd-member-added"
+	EventEtcdMemberRemoved   = "etcd-member-removed"
+	EventVaultMachineAdded   = "vault-machine-added"
+	EventVaultMachineRemoved = "vault-machine-removed"
+	EventStepFinished        = "step-finished"
+)
+
+// Event is a single change made by quark.
+type Event struct {
+	Time        time.Time `json:"time"`
+	Type        string    `json:"type"`                   // Type of event (see Event... constants)
+	Operation   string    `json:"operation,omitempty"`    // Command that caused the event (e.g. "instance destroy")
+	OperationID string    `json:"operation-id,omitempty"` // Unique ID of the run of the command, shared by all of its events
+	Operator    Operator  `json:"operator"`
+	Cluster     string    `json:"cluster,omitempty"`     // Full name of the cluster (e.g. "c47.pulcy.com")
+	ClusterID   string    `json:"cluster-id,omitempty"`  // ID of the cluster
+	Instance    string    `json:"instance,omitempty"`    // Name of the instance
+	Name        string    `json:"name,omitempty"`        // DNS record name, etcd member name, vault machine ID or step name
+	RecordType  string    `json:"record-type,omitempty"` // DNS record type
+	Domain      string    `json:"domain,omitempty"`      // DNS domain
+	Data        string    `json:"data,omitempty"`        // DNS record data or cluster IP of an etcd member
+	Error       string    `json:"error,omitempty"`       // Reason a failed operation failed
+}
+
+// Log sends the events of an operation to all of its sinks.
+type Log struct {
+	mutex       sync.Mutex
+	log         *logging.Logger
+	sinks       []Sink
+	operator    Operator
+	operation   string
+	operationID string
+	cluster     providers.ClusterInfo
+	finished    bool
+}
+
+// New creates a log that sends all events to the given sinks.
+func New(log *logging.Logger, sinks ...Sink) *Log {
+	return &Log{
+		log:   log,
+		sinks: sinks,
+	}
+}
+
+// Start starts a new operation by the given operator on the given cluster.
+// All following events belong to this operation.
+func (l *Log) Start(operation string, info providers.ClusterInfo, operator Operator) {
+	id := make([]byte, 8)
+	rand.Read(id)
+	l.mutex.Lock()
+	l.operation = operation
+	l.operationID = hex.EncodeToString(id)
+	l.cluster = info
+	l.operator = operator
+	l.finished = false
+	l.mutex.Unlock()
+	l.Emit(Event{Type: EventOperationStarted})
+}
+
+// IsStarted returns true if an operation has been started and has not yet finished or failed.
+func (l *Log) IsStarted() bool {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	return l.operation != "" && !l.finished
+}
+
+// SetClusterID sets the ID of the cluster of the current operation, once it is known.
+func (l *Log) SetClusterID(id string) {
+	if id == "" {
+		return
+	}
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	l.cluster.ID = id
+}
+
+// Finish records that the current operation has finished successfully.
+func (l *Log) Finish() {
+	if !l.IsStarted() {
+		return
+	}
+	l.Emit(Event{Type: EventOperationFinished})
+	l.mutex.Lock()
+	l.finished = true
+	l.mutex.Unlock()
+}
+
+// Fail records that the current operation has failed for the given reason.
+func (l *Log) Fail(reason string) {
+	if !l.IsStarted() {
+		return
+	}
+	l.Emit(Event{Type: EventOperationFailed, Error: reason})
+	l.mutex.Lock()
+	l.finished = true
+	l.mutex.Unlock()
+}
+
+// Emit completes the given event with the current operation and sends it to all sinks.
+// Sinks that fail are reported as warnings, they never stop the operation.
+func (l *Log) Emit(e Event) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	if e.Time.IsZero() {
+		e.Time = time.Now().UTC()
+	}
+	e.Operation = l.operation
+	e.OperationID = l.operationID
+	e.Operator = l.operator
+	if e.Cluster == "" && l.cluster.Name != "" {
+		e.Cluster = l.cluster.String()
+	}
+	if e.ClusterID == "" {
+		e.ClusterID = l.cluster.ID
+	}
+	for _, s := range l.sinks {
+		if err := s.Send(e); err != nil {
+			l.log.Warningf("Failed to send %s event to %s: %v", e.Type, s, err)
+		}
+	}
+}
@@ -0,0 +1,97 @@
+// Copyright (c) 2016 Pulcy.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package audit_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/op/go-logging"
+
+	"github.com/pulcy/quark/providers"
+	"github.com/pulcy/quark/providers/audit"
+)
+
+var (
+	testLog = logging.MustGetLogger("audit-test")
+)
+
+// memorySink keeps all events it receives.
+type memorySink struct {
+	events []audit.Event
+}
+
+func (s *memorySink) String() string { return "memory" }
+
+func (s *memorySink) Send(e audit.Event) error {
+	s.events = append(s.events, e)
+	return nil
+}
+
+func TestLog(t *testing.T) {
+	// A webhook that always fails
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+	sink := &memorySink{}
+	l := audit.New(testLog, audit.NewWebhookSink(server.URL), sink)
+
+	// Events outside an operation
+	if l.IsStarted() {
+		t.Errorf("Expected no operation before Start")
+	}
+	l.Finish()
+	if len(sink.events) != 0 {
+		t.Errorf("Expected no events before Start, got %#v", sink.events)
+	}
+
+	operator := audit.Operator{User: "alice@host", Git: "Alice <alice@example.com>"}
+	l.Start("instance create", providers.ClusterInfo{Name: "c1", Domain: "example.com"}, operator)
+	if !l.IsStarted() {
+		t.Errorf("Expected a started operation")
+	}
+	l.SetClusterID("cid")
+	l.Emit(audit.Event{Type: audit.EventServerCreated, Instance: "i1.c1.example.com"})
+	l.Finish()
+	l.Finish()
+	l.Fail("too late")
+
+	// The failing webhook does not stop events from reaching the other sinks
+	types := []string{audit.EventOperationStarted, audit.EventServerCreated, audit.EventOperationFinished}
+	if len(sink.events) != len(types) {
+		t.Fatalf("Expected %d events, got %#v", len(types), sink.events)
+	}
+	for index, e := range sink.events {
+		if e.Type != types[index] {
+			t.Errorf("Expected event %d to be %s, got %s", index, types[index], e.Type)
+		}
+		if e.Operation != "instance create" || e.OperationID == "" || e.OperationID != sink.events[0].OperationID || e.Operator != operator || e.Cluster != "c1.example.com" || e.Time.IsZero() {
+			t.Errorf("Expected event %d to be completed with the operation, got %#v", index, e)
+		}
+	}
+	if id := sink.events[1].ClusterID; id != "cid" {
+		t.Errorf("Expected cluster ID cid, got %s", id)
+	}
+
+	// A new operation gets a new ID
+	l.Start("cluster destroy", providers.ClusterInfo{Name: "c1", Domain: "example.com"}, operator)
+	l.Fail("interrupted")
+	last := sink.events[len(sink.events)-1]
+	if last.Type != audit.EventOperationFailed || last.Error != "interrupted" || last.OperationID == sink.events[0].OperationID {
+		t.Errorf("Expected a failed event of a new operation, got %#v", last)
+	}
+}
@@ -0,0 +1,61 @@
+// Copyright (c) 2016 Pulcy.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package audit
+
+import (
+	"context"
+
+	"github.com/pulcy/quark/providers"
+)
+
+// auditingDnsProvider implements providers.DnsProvider.
+// It records all DNS records it creates & deletes in the audit log.
+type auditingDnsProvider struct {
+	audit       *Log
+	dnsProvider providers.DnsProvider
+}
+
+// NewDnsProvider creates a DNS provider that records all records created & deleted by the given provider in the given audit log.
+func NewDnsProvider(audit *Log, dnsProvider providers.DnsProvider) providers.DnsProvider {
+	if p, ok := dnsProvider.(*auditingDnsProvider); ok && p.audit == audit {
+		// Already recorded in this audit log
+		return p
+	}
+	return &auditingDnsProvider{
+		audit:       audit,
+		dnsProvider: dnsProvider,
+	}
+}
+
+func (p *auditingDnsProvider) GetDomainRecords(ctx context.Context, domain string) (providers.DnsRecordList, error) {
+	list, err := p.dnsProvider.GetDomainRecords(ctx, domain)
+	return list, maskAny(err)
+}
+
+func (p *auditingDnsProvider) CreateDnsRecord(ctx context.Context, domain, recordType, name, data string) error {
+	if err := p.dnsProvider.CreateDnsRecord(ctx, domain, recordType, name, data); err != nil {
+		return maskAny(err)
+	}
+	p.audit.Emit(Event{Type: EventDnsRecordAdded, Domain: domain, RecordType: recordType, Name: name, Data: data})
+	return nil
+}
+
+func (p *auditingDnsProvider) DeleteDnsRecord(ctx context.Context, domain, recordType, name, data string) error {
+	if err := p.dnsProvider.DeleteDnsRecord(ctx, domain, recordType, name, data); err != nil {
+		return maskAny(err)
+	}
+	p.audit.Emit(Event{Type: EventDnsRecordRemoved, Domain: domain, RecordType: recordType, Name: name, Data: data})
+	return nil
+}
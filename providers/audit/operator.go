@@ -0,0 +1,95 @@
+// Copyright (c) 2016 Pulcy.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package audit
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"os/exec"
+	"os/user"
+	"strings"
+	"time"
+)
+
+const (
+	githubUserURL = "https://api.github.com/user"
+	githubTimeout = time.Second * 10
+)
+
+// Operator identifies who ran an operation.
+type Operator struct {
+	User   string `json:"user"`             // Local account (user@host)
+	Git    string `json:"git,omitempty"`    // Git user ("name <email>")
+	GitHub string `json:"github,omitempty"` // GitHub login of the personal github token
+}
+
+// LocalUser returns the local account that runs quark as user@host.
+func LocalUser() string {
+	userName := os.Getenv("USER")
+	if u, err := user.Current(); err == nil {
+		userName = u.Username
+	}
+	hostName, _ := os.Hostname()
+	return fmt.Sprintf("%s@%s", userName, hostName)
+}
+
+// GitUser returns the configured git user ("name <email>"), or an empty string if git has no user configured.
+func GitUser() string {
+	gitConfig := func(key string) string {
+		out, err := exec.Command("git", "config", "--get", key).Output()
+		if err != nil {
+			return ""
+		}
+		return strings.TrimSpace(string(out))
+	}
+	name, email := gitConfig("user.name"), gitConfig("user.email")
+	switch {
+	case name != "" && email != "":
+		return fmt.Sprintf("%s <%s>", name, email)
+	case email != "":
+		return email
+	default:
+		return name
+	}
+}
+
+// GithubLogin returns the login of the GitHub user that owns the given personal token.
+func GithubLogin(ctx context.Context, token string) (string, error) {
+	req, err := http.NewRequest("GET", githubUserURL, nil)
+	if err != nil {
+		return "", maskAny(err)
+	}
+	req.Header.Set("Authorization", "token "+token)
+	req.Header.Set("Accept", "application/vnd.github.v3+json")
+	client := &http.Client{Timeout: githubTimeout}
+	resp, err := client.Do(req.WithContext(ctx))
+	if err != nil {
+		return "", maskAny(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", maskAny(fmt.Errorf("github responded with status %d", resp.StatusCode))
+	}
+	var result struct {
+		Login string `json:"login"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return "", maskAny(err)
+	}
+	return result.Login, nil
+}
@@ -0,0 +1,160 @@
+// Copyright (c) 2016 Pulcy.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package audit
+
+import (
+	"context"
+
+	"github.com/op/go-logging"
+
+	"github.com/pulcy/quark/providers"
+)
+
+// auditingProvider implements providers.CloudProvider.
+// It records all servers it creates & destroys in the audit log,
+// all other operations are passed on to the wrapped provider.
+type auditingProvider struct {
+	audit    *Log
+	provider providers.CloudProvider
+}
+
+// NewProvider creates a cloud provider that records all servers created & destroyed by the given provider in the given audit log.
+func NewProvider(audit *Log, provider providers.CloudProvider) providers.CloudProvider {
+	return &auditingProvider{
+		audit:    audit,
+		provider: provider,
+	}
+}
+
+func (p *auditingProvider) GetRegions(ctx context.Context) (providers.RegionList, error) {
+	list, err := p.provider.GetRegions(ctx)
+	return list, maskAny(err)
+}
+
+func (p *auditingProvider) GetImages(ctx context.Context) (providers.ImageList, error) {
+	list, err := p.provider.GetImages(ctx)
+	return list, maskAny(err)
+}
+
+func (p *auditingProvider) GetKeys(ctx context.Context) (providers.SSHKeyList, error) {
+	list, err := p.provider.GetKeys(ctx)
+	return list, maskAny(err)
+}
+
+func (p *auditingProvider) GetInstanceTypes(ctx context.Context) (providers.InstanceTypeList, error) {
+	list, err := p.provider.GetInstanceTypes(ctx)
+	return list, maskAny(err)
+}
+
+func (p *auditingProvider) GetDomainRecords(ctx context.Context, domain string) (providers.DnsRecordList, error) {
+	list, err := p.provider.GetDomainRecords(ctx, domain)
+	return list, maskAny(err)
+}
+
+func (p *auditingProvider) GetClusterTags(ctx context.Context, info providers.ClusterInfo) ([]string, error) {
+	tags, err := p.provider.GetClusterTags(ctx, info)
+	return tags, maskAny(err)
+}
+
+func (p *auditingProvider) AddClusterTag(ctx context.Context, info providers.ClusterInfo, tag string) error {
+	return maskAny(p.provider.AddClusterTag(ctx, info, tag))
+}
+
+func (p *auditingProvider) RemoveClusterTag(ctx context.Context, info providers.ClusterInfo, tag string) error {
+	return maskAny(p.provider.RemoveClusterTag(ctx, info, tag))
+}
+
+// Apply defaults for the given options
+func (p *auditingProvider) ClusterDefaults(options providers.ClusterInfo) providers.ClusterInfo {
+	return p.provider.ClusterDefaults(options)
+}
+
+// Apply defaults for the given options
+func (p *auditingProvider) CreateInstanceDefaults(options providers.CreateInstanceOptions) providers.CreateInstanceOptions {
+	return p.provider.CreateInstanceDefaults(options)
+}
+
+// Apply defaults for the given options
+func (p *auditingProvider) CreateClusterDefaults(options providers.CreateClusterOptions) providers.CreateClusterOptions {
+	return p.provider.CreateClusterDefaults(options)
+}
+
+// Get names of instances of a cluster
+func (p *auditingProvider) GetInstances(ctx context.Context, info providers.ClusterInfo) (providers.ClusterInstanceList, error) {
+	list, err := p.provider.GetInstances(ctx, info)
+	return list, maskAny(err)
+}
+
+// Create a machine instance
+func (p *auditingProvider) CreateInstance(ctx context.Context, log *logging.Logger, options providers.CreateInstanceOptions, dnsProvider providers.DnsProvider) (providers.ClusterInstance, error) {
+	instance, err := p.provider.CreateInstance(ctx, log, options, NewDnsProvider(p.audit, dnsProvider))
+	if err != nil {
+		return providers.ClusterInstance{}, maskAny(err)
+	}
+	p.audit.Emit(Event{Type: EventServerCreated, Cluster: options.ClusterInfo.String(), Instance: instance.Name, Data: instance.ClusterIP})
+	return instance, nil
+}
+
+// Create an entire cluster.
+// All instances of the new cluster are recorded once the cluster has been created.
+func (p *auditingProvider) CreateCluster(ctx context.Context, log *logging.Logger, options providers.CreateClusterOptions, dnsProvider providers.DnsProvider) error {
+	if err := p.provider.CreateCluster(ctx, log, options, NewDnsProvider(p.audit, dnsProvider)); err != nil {
+		return maskAny(err)
+	}
+	instances, err := p.provider.GetInstances(ctx, options.ClusterInfo)
+	if err != nil {
+		log.Warningf("Cannot list instances of new cluster %s: %v", options.ClusterInfo, err)
+		return nil
+	}
+	for _, i := range instances {
+		p.audit.Emit(Event{Type: EventServerCreated, Cluster: options.ClusterInfo.String(), ClusterID: options.ID, Instance: i.Name, Data: i.ClusterIP})
+	}
+	return nil
+}
+
+// Remove all instances of a cluster.
+// The instances are listed first, so every destroyed instance is recorded.
+func (p *auditingProvider) DeleteCluster(ctx context.Context, info providers.ClusterInfo, dnsProvider providers.DnsProvider) error {
+	instances, err := p.provider.GetInstances(ctx, info)
+	if err != nil {
+		return maskAny(err)
+	}
+	if err := p.provider.DeleteCluster(ctx, info, NewDnsProvider(p.audit, dnsProvider)); err != nil {
+		return maskAny(err)
+	}
+	for _, i := range instances {
+		p.audit.Emit(Event{Type: EventServerDestroyed, Cluster: info.String(), Instance: i.Name, Data: i.ClusterIP})
+	}
+	return nil
+}
+
+// Remove a single instance of a cluster
+func (p *auditingProvider) DeleteInstance(ctx context.Context, info providers.ClusterInstanceInfo, dnsProvider providers.DnsProvider) error {
+	if err := p.provider.DeleteInstance(ctx, info, NewDnsProvider(p.audit, dnsProvider)); err != nil {
+		return maskAny(err)
+	}
+	p.audit.Emit(Event{Type: EventServerDestroyed, Cluster: info.ClusterInfo.String(), Instance: info.String()})
+	return nil
+}
+
+// Perform a reboot of the given instance
+func (p *auditingProvider) RebootInstance(ctx context.Context, instance providers.ClusterInstance) error {
+	return maskAny(p.provider.RebootInstance(ctx, instance))
+}
+
+// Update the instances of the cluster to all new services & formats
+func (p *auditingProvider) UpdateCluster(ctx context.Context, log *logging.Logger, info providers.ClusterInfo, dnsProvider providers.DnsProvider) error {
+	return maskAny(p.provider.UpdateCluster(ctx, log, info, NewDnsProvider(p.audit, dnsProvider)))
+}
@@ -0,0 +1,130 @@
+// Copyright (c) 2016 Pulcy.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package audit
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/op/go-logging"
+)
+
+const (
+	auditFileMode  = os.FileMode(0600)
+	webhookTimeout = time.Second * 10
+)
+
+// Sink receives audit events.
+type Sink interface {
+	fmt.Stringer
+	// Send delivers the given event.
+	Send(e Event) error
+}
+
+// fileSink appends every event as a single JSON line to a file.
+type fileSink struct {
+	path string
+}
+
+// NewFileSink creates a sink that appends every event as a single JSON line to the file with given path.
+func NewFileSink(path string) Sink {
+	return &fileSink{path: path}
+}
+
+func (s *fileSink) String() string {
+	return s.path
+}
+
+func (s *fileSink) Send(e Event) error {
+	raw, err := json.Marshal(e)
+	if err != nil {
+		return maskAny(err)
+	}
+	if err := os.MkdirAll(filepath.Dir(s.path), 0755); err != nil {
+		return maskAny(err)
+	}
+	// Open the file for every event, so multiple quark runs can append to the same file
+	f, err := os.OpenFile(s.path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, auditFileMode)
+	if err != nil {
+		return maskAny(err)
+	}
+	if _, err := f.Write(append(raw, '\n')); err != nil {
+		f.Close()
+		return maskAny(err)
+	}
+	return maskAny(f.Close())
+}
+
+// webhookSink posts every event as JSON to a URL.
+type webhookSink struct {
+	url    string
+	client *http.Client
+}
+
+// NewWebhookSink creates a sink that posts every event as JSON to the given URL.
+func NewWebhookSink(url string) Sink {
+	return &webhookSink{
+		url:    url,
+		client: &http.Client{Timeout: webhookTimeout},
+	}
+}
+
+func (s *webhookSink) String() string {
+	return s.url
+}
+
+func (s *webhookSink) Send(e Event) error {
+	raw, err := json.Marshal(e)
+	if err != nil {
+		return maskAny(err)
+	}
+	resp, err := s.client.Post(s.url, "application/json", bytes.NewReader(raw))
+	if err != nil {
+		return maskAny(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return maskAny(fmt.Errorf("webhook responded with status %d", resp.StatusCode))
+	}
+	return nil
+}
+
+// logSink writes every event to the debug log.
+type logSink struct {
+	log *logging.Logger
+}
+
+// NewLogSink creates a sink that writes every event to the debug level of the given logger.
+func NewLogSink(log *logging.Logger) Sink {
+	return &logSink{log: log}
+}
+
+func (s *logSink) String() string {
+	return "debug log"
+}
+
+func (s *logSink) Send(e Event) error {
+	raw, err := json.Marshal(e)
+	if err != nil {
+		return maskAny(err)
+	}
+	s.log.Debugf("audit: %s", raw)
+	return nil
+}
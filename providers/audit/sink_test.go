@@ -0,0 +1,130 @@
+// Copyright (c) 2016 Pulcy.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package audit_test
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/pulcy/quark/providers/audit"
+)
+
+func TestFileSink(t *testing.T) {
+	dir, err := ioutil.TempDir("", "quark-audit")
+	if err != nil {
+		t.Fatalf("Cannot create temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "logs", "audit.log")
+	sink := audit.NewFileSink(path)
+
+	created := time.Date(2016, 11, 1, 12, 0, 0, 0, time.UTC)
+	events := []audit.Event{
+		{Time: created, Type: audit.EventServerCreated, Operation: "instance create", OperationID: "op1", Operator: audit.Operator{User: "alice@host"}, Cluster: "c1.example.com", Instance: "i1.c1.example.com"},
+		{Time: created, Type: audit.EventOperationFailed, Operation: "instance create", OperationID: "op1", Operator: audit.Operator{User: "alice@host", GitHub: "alice"}, Error: "line 1\nline 2"},
+	}
+	for _, e := range events {
+		if err := sink.Send(e); err != nil {
+			t.Fatalf("Send failed: %v", err)
+		}
+	}
+	// A second sink on the same file (e.g. another quark run) appends
+	if err := audit.NewFileSink(path).Send(audit.Event{Time: created, Type: audit.EventOperationFinished}); err != nil {
+		t.Fatalf("Send failed: %v", err)
+	}
+
+	raw, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Cannot read audit log: %v", err)
+	}
+	lines := strings.Split(strings.TrimSuffix(string(raw), "\n"), "\n")
+	expected := []string{
+		`{"time":"2016-11-01T12:00:00Z","type":"server-created","operation":"instance create","operation-id":"op1","operator":{"user":"alice@host"},"cluster":"c1.example.com","instance":"i1.c1.example.com"}`,
+		`{"time":"2016-11-01T12:00:00Z","type":"operation-failed","operation":"instance create","operation-id":"op1","operator":{"user":"alice@host","github":"alice"},"error":"line 1\nline 2"}`,
+		`{"time":"2016-11-01T12:00:00Z","type":"operation-finished","operator":{"user":""}}`,
+	}
+	if len(lines) != len(expected) {
+		t.Fatalf("Expected %d lines, got %d:\n%s", len(expected), len(lines), raw)
+	}
+	for index, line := range lines {
+		if line != expected[index] {
+			t.Errorf("Expected line %d to be\n%s\ngot\n%s", index, expected[index], line)
+		}
+	}
+	if fi, err := os.Stat(path); err != nil || fi.Mode().Perm() != 0600 {
+		t.Errorf("Expected audit log with mode 0600, got %v", err)
+	}
+}
+
+func TestFileSinkFailure(t *testing.T) {
+	dir, err := ioutil.TempDir("", "quark-audit")
+	if err != nil {
+		t.Fatalf("Cannot create temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	// The path of the audit log is a directory
+	if err := audit.NewFileSink(dir).Send(audit.Event{Type: audit.EventOperationStarted}); err == nil {
+		t.Errorf("Expected Send to fail")
+	}
+}
+
+func TestWebhookSink(t *testing.T) {
+	var mutex sync.Mutex
+	var received []audit.Event
+	status := http.StatusOK
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mutex.Lock()
+		defer mutex.Unlock()
+		if r.Method != "POST" || r.Header.Get("Content-Type") != "application/json" {
+			t.Errorf("Expected a JSON post, got %s %s", r.Method, r.Header.Get("Content-Type"))
+		}
+		var e audit.Event
+		if err := json.NewDecoder(r.Body).Decode(&e); err != nil {
+			t.Errorf("Cannot decode event: %v", err)
+		}
+		received = append(received, e)
+		w.WriteHeader(status)
+	}))
+	defer server.Close()
+
+	sink := audit.NewWebhookSink(server.URL)
+	if err := sink.Send(audit.Event{Type: audit.EventDnsRecordAdded, Name: "i1", Data: "1.1.1.1"}); err != nil {
+		t.Fatalf("Send failed: %v", err)
+	}
+	mutex.Lock()
+	if len(received) != 1 || received[0].Type != audit.EventDnsRecordAdded || received[0].Name != "i1" || received[0].Data != "1.1.1.1" {
+		t.Errorf("Expected the event to be posted, got %#v", received)
+	}
+	status = http.StatusInternalServerError
+	mutex.Unlock()
+
+	if err := sink.Send(audit.Event{Type: audit.EventDnsRecordRemoved}); err == nil || !strings.Contains(err.Error(), "500") {
+		t.Errorf("Expected an error with status 500, got %v", err)
+	}
+
+	// Unreachable webhook
+	server.Close()
+	if err := sink.Send(audit.Event{Type: audit.EventDnsRecordRemoved}); err == nil {
+		t.Errorf("Expected Send to an unreachable webhook to fail")
+	}
+}
@@ -0,0 +1,52 @@
+// Copyright (c) 2016 Pulcy.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package audit
+
+import (
+	"context"
+
+	"github.com/pulcy/quark/providers"
+)
+
+// auditingVaultProvider implements providers.VaultProvider.
+// It records all machines it adds & removes in the audit log.
+type auditingVaultProvider struct {
+	audit         *Log
+	vaultProvider providers.VaultProvider
+}
+
+// NewVaultProvider creates a vault provider that records all machines added & removed by the given provider in the given audit log.
+func NewVaultProvider(audit *Log, vaultProvider providers.VaultProvider) providers.VaultProvider {
+	return &auditingVaultProvider{
+		audit:         audit,
+		vaultProvider: vaultProvider,
+	}
+}
+
+func (p *auditingVaultProvider) AddMachine(ctx context.Context, clusterId, machineId string) error {
+	if err := p.vaultProvider.AddMachine(ctx, clusterId, machineId); err != nil {
+		return maskAny(err)
+	}
+	p.audit.Emit(Event{Type: EventVaultMachineAdded, ClusterID: clusterId, Name: machineId})
+	return nil
+}
+
+func (p *auditingVaultProvider) RemoveMachine(ctx context.Context, machineId string) error {
+	if err := p.vaultProvider.RemoveMachine(ctx, machineId); err != nil {
+		return maskAny(err)
+	}
+	p.audit.Emit(Event{Type: EventVaultMachineRemoved, Name: machineId})
+	return nil
+}
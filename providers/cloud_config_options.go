@@ -20,4 +20,6 @@ type CloudConfigOptions struct {
 	PrivateIPv4    string
 	SshKeys        []string
 	RebootStrategy string
+	HttpProxy      string // Address of the http proxy to use (if any)
+	NoProxy        string // Destinations that must not use the http proxy
 }
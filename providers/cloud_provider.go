@@ -15,15 +15,24 @@
 package providers
 
 import (
+	"context"
+
 	"github.com/op/go-logging"
 )
 
 // CloudProvider holds all functions to be implemented by cloud providers
 type CloudProvider interface {
-	ShowRegions() error
-	ShowImages() error
-	ShowKeys() error
-	ShowInstanceTypes() error
+	// Get all regions in which instances can be created
+	GetRegions(ctx context.Context) (RegionList, error)
+
+	// Get all images from which instances can be created
+	GetImages(ctx context.Context) (ImageList, error)
+
+	// Get all SSH keys registered at the provider
+	GetKeys(ctx context.Context) (SSHKeyList, error)
+
+	// Get all instance types (plans) with which instances can be created
+	GetInstanceTypes(ctx context.Context) (InstanceTypeList, error)
 
 	// Apply defaults for the given options
 	ClusterDefaults(options ClusterInfo) ClusterInfo
@@ -35,25 +44,35 @@ type CloudProvider interface {
 	CreateClusterDefaults(options CreateClusterOptions) CreateClusterOptions
 
 	// Create a machine instance
-	CreateInstance(log *logging.Logger, options CreateInstanceOptions, dnsProvider DnsProvider) (ClusterInstance, error)
+	CreateInstance(ctx context.Context, log *logging.Logger, options CreateInstanceOptions, dnsProvider DnsProvider) (ClusterInstance, error)
 
 	// Create an entire cluster
-	CreateCluster(log *logging.Logger, options CreateClusterOptions, dnsProvider DnsProvider) error
+	CreateCluster(ctx context.Context, log *logging.Logger, options CreateClusterOptions, dnsProvider DnsProvider) error
 
 	// Get names of instances of a cluster
-	GetInstances(info ClusterInfo) (ClusterInstanceList, error)
+	GetInstances(ctx context.Context, info ClusterInfo) (ClusterInstanceList, error)
 
 	// Remove all instances of a cluster
-	DeleteCluster(info ClusterInfo, dnsProvider DnsProvider) error
+	DeleteCluster(ctx context.Context, info ClusterInfo, dnsProvider DnsProvider) error
 
 	// Remove a single instance of a cluster
-	DeleteInstance(info ClusterInstanceInfo, dnsProvider DnsProvider) error
+	DeleteInstance(ctx context.Context, info ClusterInstanceInfo, dnsProvider DnsProvider) error
 
 	// Perform a reboot of the given instance
-	RebootInstance(instance ClusterInstance) error
+	RebootInstance(ctx context.Context, instance ClusterInstance) error
 
 	// Update the instances of the cluster to all new services & formats
-	UpdateCluster(log *logging.Logger, info ClusterInfo, dnsProvider DnsProvider) error
+	UpdateCluster(ctx context.Context, log *logging.Logger, info ClusterInfo, dnsProvider DnsProvider) error
+
+	// Get all DNS records of the given domain
+	GetDomainRecords(ctx context.Context, domain string) (DnsRecordList, error)
+
+	// Get all tags of the instances of a cluster
+	GetClusterTags(ctx context.Context, info ClusterInfo) ([]string, error)
+
+	// Add a tag to all instances of a cluster
+	AddClusterTag(ctx context.Context, info ClusterInfo, tag string) error
 
-	ShowDomainRecords(domain string) error
+	// Remove a tag from all instances of a cluster
+	RemoveClusterTag(ctx context.Context, info ClusterInfo, tag string) error
 }
@@ -15,11 +15,12 @@
 package cloudflare
 
 import (
+	"context"
 	"fmt"
-	"sort"
 
 	"github.com/juju/errgo"
-	"github.com/ryanuber/columnize"
+
+	"github.com/pulcy/quark/providers"
 )
 
 type CfZone struct {
@@ -63,47 +64,38 @@ type CfDnsRecord struct {
 	TTL     int    `json:"ttl,omitempty"`
 }
 
-func (p *cfProvider) ShowDomainRecords(domain string) error {
+func (p *cfProvider) GetDomainRecords(ctx context.Context, domain string) (providers.DnsRecordList, error) {
 	id, err := p.zoneID(domain)
 	if err != nil {
-		return maskAny(err)
+		return nil, maskAny(err)
 	}
 
 	url := apiUrl + fmt.Sprintf("zones/%s/dns_records", id)
 	res, err := p.get(url, "application/json")
 	if err != nil {
-		return maskAny(err)
+		return nil, maskAny(err)
 	}
 
 	records := []CfDnsRecord{}
 	if err := res.UnmarshalResult(&records); err != nil {
-		return maskAny(err)
+		return nil, maskAny(err)
 	}
 
-	lines := []string{
-		"Type | Name | Data",
-	}
+	result := providers.DnsRecordList{}
 	for _, r := range records {
-		line := fmt.Sprintf("%s | %s | %s", r.Type, trimLength(r.Name, 20), trimLength(r.Content, 60))
-		lines = append(lines, line)
+		result = append(result, providers.DnsRecord{
+			ID:   r.ID,
+			Type: r.Type,
+			Name: r.Name,
+			Data: r.Content,
+			TTL:  r.TTL,
+		})
 	}
 
-	sort.Strings(lines[1:])
-	result := columnize.SimpleFormat(lines)
-	fmt.Println(result)
-
-	return nil
-}
-
-func trimLength(s string, maxLen int) string {
-	if len(s) > maxLen {
-		return s[:maxLen] + "..."
-	} else {
-		return s
-	}
+	return result, nil
 }
 
-func (p *cfProvider) CreateDnsRecord(domain, recordType, name, data string) error {
+func (p *cfProvider) CreateDnsRecord(ctx context.Context, domain, recordType, name, data string) error {
 	id, err := p.zoneID(domain)
 	if err != nil {
 		return maskAny(err)
@@ -122,7 +114,7 @@ func (p *cfProvider) CreateDnsRecord(domain, recordType, name, data string) erro
 	return nil
 }
 
-func (p *cfProvider) DeleteDnsRecord(domain, recordType, name, data string) error {
+func (p *cfProvider) DeleteDnsRecord(ctx context.Context, domain, recordType, name, data string) error {
 	id, err := p.zoneID(domain)
 	if err != nil {
 		return maskAny(err)
@@ -0,0 +1,51 @@
+// Copyright (c) 2016 Pulcy.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cloudflare
+
+import (
+	"github.com/juju/errgo"
+	"github.com/op/go-logging"
+	"github.com/spf13/pflag"
+
+	"github.com/pulcy/quark/providers"
+)
+
+var (
+	apiKey string
+	email  string
+)
+
+func init() {
+	providers.RegisterDnsProvider(providers.DnsProviderRegistration{
+		Name: "cloudflare",
+		SetupFlags: func(flagSet *pflag.FlagSet) {
+			flagSet.StringVarP(&apiKey, "cloudflare-apikey", "k", "", "Cloudflare API key (defaults to CLOUDFLARE_APIKEY environment variable)")
+			flagSet.StringVarP(&email, "cloudflare-email", "e", "", "Cloudflare email address (defaults to CLOUDFLARE_EMAIL environment variable)")
+		},
+		EnvDefaults: map[string]string{
+			"cloudflare-apikey": "CLOUDFLARE_APIKEY",
+			"cloudflare-email":  "CLOUDFLARE_EMAIL",
+		},
+		New: func(log *logging.Logger) (providers.DnsProvider, error) {
+			if apiKey == "" {
+				return nil, maskAny(errgo.WithCausef(nil, providers.InvalidArgumentError, "Please specify a cloudflare-apikey"))
+			}
+			if email == "" {
+				return nil, maskAny(errgo.WithCausef(nil, providers.InvalidArgumentError, "Please specify a cloudflare-email"))
+			}
+			return NewProvider(log, apiKey, email), nil
+		},
+	})
+}
@@ -0,0 +1,538 @@
+// Copyright (c) 2016 Pulcy.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package providers
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base32"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/juju/errgo"
+	"github.com/op/go-logging"
+)
+
+const (
+	// clusterLockKey is the etcd key that holds the operation lock of a cluster.
+	clusterLockKey = "/pulcy/quark/lock"
+	// clusterLockTagPrefix is the prefix of the provider tag that holds the operation lock of a cluster
+	// next to etcd, so it is also seen when etcd is not available.
+	clusterLockTagPrefix = "quark-lock:"
+	// maxLockTagLength is the maximum length of a lock tag (the tag limit of DigitalOcean).
+	maxLockTagLength = 255
+)
+
+var (
+	// lockTagEncoding encodes the owner of a lock such that it is valid in a tag for all providers.
+	// The padding is stripped from tags.
+	lockTagEncoding = base32.HexEncoding
+)
+
+// ClusterLock prevents multiple quark runs from changing the same cluster at the same time.
+type ClusterLock struct {
+	ID        string    `json:"id"`        // Unique ID of the lock
+	Owner     string    `json:"owner"`     // Who holds the lock (user@host)
+	Operation string    `json:"operation"` // Operation that holds the lock (e.g. "instance create")
+	Created   time.Time `json:"created"`
+	Expires   time.Time `json:"expires"`
+}
+
+// NewClusterLock creates a new lock for the given owner & operation that expires after the given TTL.
+func NewClusterLock(owner, operation string, ttl time.Duration) ClusterLock {
+	id := make([]byte, 8)
+	rand.Read(id)
+	now := time.Now().UTC().Truncate(time.Second)
+	return ClusterLock{
+		ID:        hex.EncodeToString(id),
+		Owner:     owner,
+		Operation: operation,
+		Created:   now,
+		Expires:   now.Add(ttl),
+	}
+}
+
+// IsExpired returns true if the lock is no longer valid.
+func (l ClusterLock) IsExpired() bool {
+	return time.Now().After(l.Expires)
+}
+
+func (l ClusterLock) String() string {
+	if l.Created.IsZero() {
+		// Read from a tag, which only holds the owner & expiry
+		return fmt.Sprintf("%s, expires %s", l.Owner, l.Expires.Local().Format(time.RFC3339))
+	}
+	return fmt.Sprintf("%s (%s) since %s, expires %s", l.Owner, l.Operation, l.Created.Local().Format(time.RFC3339), l.Expires.Local().Format(time.RFC3339))
+}
+
+// Renewed returns a copy of the lock that expires after the given TTL from now.
+func (l ClusterLock) Renewed(ttl time.Duration) ClusterLock {
+	l.Expires = time.Now().UTC().Truncate(time.Second).Add(ttl)
+	return l
+}
+
+// LockCluster acquires the operation lock of the given cluster.
+// The lock is kept in the etcd of the cluster and in a tag of its instances (on providers that support tags),
+// so it is also seen by quark runs that cannot reach etcd. At least one of both must be available.
+// If the cluster is locked by another lock that has not expired, that lock is returned with a ClusterLockedError.
+// If the cluster has no instances, a NotFoundError is returned.
+func LockCluster(ctx context.Context, log *logging.Logger, provider CloudProvider, info ClusterInfo, lock ClusterLock) (ClusterLock, error) {
+	stores, err := openClusterLockStores(ctx, log, provider, info)
+	if err != nil {
+		return ClusterLock{}, maskAny(err)
+	}
+	defer stores.Close()
+	current, err := stores.lock(log, info, lock)
+	if err != nil {
+		return current, maskAny(err)
+	}
+	return lock, nil
+}
+
+// RenewClusterLock extends the given operation lock of the given cluster by the given TTL.
+// It returns the renewed lock. If the lock is no longer held, a NotFoundError is returned.
+// If the cluster no longer has instances (e.g. while it is being destroyed), the lock is returned as is.
+func RenewClusterLock(ctx context.Context, log *logging.Logger, provider CloudProvider, info ClusterInfo, lock ClusterLock, ttl time.Duration) (ClusterLock, error) {
+	stores, err := openClusterLockStores(ctx, log, provider, info)
+	if errgo.Cause(err) == NotFoundError {
+		log.Debugf("Not renewing lock of %s: %v", info, err)
+		return lock, nil
+	} else if err != nil {
+		return ClusterLock{}, maskAny(err)
+	}
+	defer stores.Close()
+	renewed := lock.Renewed(ttl)
+	if err := stores.renew(lock, renewed); err != nil {
+		return ClusterLock{}, maskAny(err)
+	}
+	log.Debugf("Renewed lock of %s until %s", info, renewed.Expires.Local().Format(time.RFC3339))
+	return renewed, nil
+}
+
+// GetClusterLock returns the operation lock of the given cluster.
+// If the cluster is not locked, a NotFoundError is returned.
+func GetClusterLock(ctx context.Context, log *logging.Logger, provider CloudProvider, info ClusterInfo) (ClusterLock, error) {
+	stores, err := openClusterLockStores(ctx, log, provider, info)
+	if err != nil {
+		return ClusterLock{}, maskAny(err)
+	}
+	defer stores.Close()
+	for _, store := range stores {
+		lock, _, err := store.get()
+		if err == nil {
+			return lock, nil
+		} else if errgo.Cause(err) != NotFoundError {
+			return ClusterLock{}, maskAny(err)
+		}
+	}
+	return ClusterLock{}, maskAny(errgo.WithCausef(nil, NotFoundError, "%s is not locked", info))
+}
+
+// UnlockCluster releases the operation lock of the given cluster, if it is the lock with given ID.
+// If id is empty, any lock is released.
+// If the cluster is not locked (by the lock with given ID), a NotFoundError is returned.
+func UnlockCluster(ctx context.Context, log *logging.Logger, provider CloudProvider, info ClusterInfo, id string) error {
+	stores, err := openClusterLockStores(ctx, log, provider, info)
+	if err != nil {
+		return maskAny(err)
+	}
+	defer stores.Close()
+	if err := stores.remove(id); err != nil {
+		return maskAny(err)
+	}
+	log.Debugf("Unlocked %s in %s", info, stores)
+	return nil
+}
+
+// clusterLockStore keeps the operation lock of a single cluster.
+type clusterLockStore interface {
+	fmt.Stringer
+	// create stores the given lock. It returns AlreadyExistsError if there already is a lock.
+	create(lock ClusterLock) error
+	// get returns the current lock and its encoded form. It returns NotFoundError if there is no lock.
+	get() (ClusterLock, string, error)
+	// update replaces the given current lock by the given lock (with the same ID).
+	// It returns NotFoundError if the current lock is not stored.
+	update(current, lock ClusterLock) error
+	// remove removes the lock with given ID (any lock if id is empty).
+	// It returns NotFoundError if there is no such lock.
+	remove(id string) error
+	Close() error
+}
+
+// clusterLockStores is the list of stores that keep the operation lock of a single cluster.
+// A lock is held when it is in all of them.
+type clusterLockStores []clusterLockStore
+
+// openClusterLockStores returns the stores that keep the operation lock of the given cluster.
+// These are the etcd of the first instance that can be reached and the tags of the instances
+// (when supported by the provider). When neither can be used, an error is returned.
+func openClusterLockStores(ctx context.Context, log *logging.Logger, provider CloudProvider, info ClusterInfo) (clusterLockStores, error) {
+	instances, err := provider.GetInstances(ctx, info)
+	if err != nil {
+		return nil, maskAny(err)
+	}
+	if len(instances) == 0 {
+		return nil, maskAny(errgo.WithCausef(nil, NotFoundError, "%s has no instances", info))
+	}
+	var stores clusterLockStores
+	client, i, etcdErr := instances.EtcdClient(ctx, log)
+	if etcdErr == nil {
+		stores = append(stores, &etcdLockStore{client: client, host: i.String()})
+	}
+	_, tagsErr := provider.GetClusterTags(ctx, info)
+	if tagsErr == nil {
+		stores = append(stores, &tagLockStore{ctx: ctx, provider: provider, info: info})
+	}
+	switch {
+	case len(stores) == 0:
+		return nil, maskAny(errgo.Notef(etcdErr, "%s cannot be locked and instance tags cannot be used (%v)", info, tagsErr))
+	case etcdErr != nil:
+		log.Warningf("Cannot reach etcd of %s, using instance tags only to lock it: %v", info, etcdErr)
+	case errgo.Cause(tagsErr) != TagsNotSupportedError:
+		log.Warningf("Cannot use instance tags of %s, using etcd only to lock it: %v", info, tagsErr)
+	}
+	return stores, nil
+}
+
+func (stores clusterLockStores) String() string {
+	var names []string
+	for _, s := range stores {
+		names = append(names, s.String())
+	}
+	return strings.Join(names, " & ")
+}
+
+// lock stores the given lock in all stores.
+// If one of the stores holds another lock that has not expired, that lock is returned with a ClusterLockedError.
+// Expired locks are removed.
+func (stores clusterLockStores) lock(log *logging.Logger, info ClusterInfo, lock ClusterLock) (ClusterLock, error) {
+	for attempt := 0; attempt < 2; attempt++ {
+		for _, store := range stores {
+			current, _, err := store.get()
+			if errgo.Cause(err) == NotFoundError {
+				continue
+			} else if err != nil {
+				return ClusterLock{}, maskAny(err)
+			}
+			if !current.IsExpired() {
+				return current, maskAny(errgo.WithCausef(nil, ClusterLockedError, "%s is locked by %s", info, current.Owner))
+			}
+			log.Warningf("Removing expired lock of %s held by %s from %s", info, current, store)
+			if err := store.remove(current.ID); err != nil && errgo.Cause(err) != NotFoundError {
+				return ClusterLock{}, maskAny(err)
+			}
+		}
+
+		var created clusterLockStores
+		var err error
+		for _, store := range stores {
+			if err = store.create(lock); err != nil {
+				break
+			}
+			created = append(created, store)
+		}
+		if err == nil {
+			log.Debugf("Locked %s in %s", info, stores)
+			return lock, nil
+		}
+		// Do not leave a partial lock behind
+		if len(created) > 0 {
+			if rmErr := created.remove(lock.ID); rmErr != nil {
+				log.Warningf("Failed to remove partial lock of %s: %v", info, rmErr)
+			}
+		}
+		if errgo.Cause(err) != AlreadyExistsError {
+			return ClusterLock{}, maskAny(err)
+		}
+		// Locked by someone else in the meantime, check again
+	}
+	return ClusterLock{}, maskAny(errgo.WithCausef(nil, ClusterLockedError, "cannot lock %s", info))
+}
+
+// renew replaces the given current lock by the given renewed lock in all stores.
+// Stores that did not hold the lock (e.g. etcd that could not be reached when the lock was acquired) get the renewed lock.
+// If none of the stores holds the lock anymore, a NotFoundError is returned.
+func (stores clusterLockStores) renew(current, renewed ClusterLock) error {
+	var missing clusterLockStores
+	for _, store := range stores {
+		if err := store.update(current, renewed); errgo.Cause(err) == NotFoundError {
+			missing = append(missing, store)
+		} else if err != nil {
+			return maskAny(err)
+		}
+	}
+	if len(missing) == len(stores) {
+		return maskAny(errgo.WithCausef(nil, NotFoundError, "lock %s is no longer held", current.ID))
+	}
+	for _, store := range missing {
+		if err := store.create(renewed); errgo.Cause(err) == AlreadyExistsError {
+			return maskAny(errgo.WithCausef(nil, ClusterLockedError, "%s holds another lock", store))
+		} else if err != nil {
+			return maskAny(err)
+		}
+	}
+	return nil
+}
+
+// remove removes the lock with given ID (any lock if id is empty) from all stores.
+// It returns NotFoundError if none of the stores holds such a lock.
+func (stores clusterLockStores) remove(id string) error {
+	var firstErr error
+	removed := false
+	for _, store := range stores {
+		if err := store.remove(id); err == nil {
+			removed = true
+		} else if errgo.Cause(err) != NotFoundError && firstErr == nil {
+			firstErr = err
+		}
+	}
+	if firstErr != nil {
+		return maskAny(firstErr)
+	}
+	if !removed {
+		return maskAny(errgo.WithCausef(nil, NotFoundError, "lock %s not found", id))
+	}
+	return nil
+}
+
+func (stores clusterLockStores) Close() error {
+	for _, store := range stores {
+		store.Close()
+	}
+	return nil
+}
+
+// etcdLockStore keeps the lock in an etcd key, that is removed by etcd when the lock expires.
+type etcdLockStore struct {
+	client EtcdClient
+	host   string
+}
+
+func (s *etcdLockStore) String() string {
+	return fmt.Sprintf("etcd on %s", s.host)
+}
+
+func (s *etcdLockStore) create(lock ClusterLock) error {
+	raw, err := json.Marshal(lock)
+	if err != nil {
+		return maskAny(err)
+	}
+	if err := s.client.CreateKey(clusterLockKey, string(raw), lockTTL(lock)); err != nil {
+		return maskAny(err)
+	}
+	return nil
+}
+
+func (s *etcdLockStore) get() (ClusterLock, string, error) {
+	raw, err := s.client.GetKey(clusterLockKey)
+	if err != nil {
+		return ClusterLock{}, "", maskAny(err)
+	}
+	var lock ClusterLock
+	if err := json.Unmarshal([]byte(raw), &lock); err != nil {
+		return ClusterLock{}, "", maskAny(errgo.Notef(err, "invalid lock in %s", clusterLockKey))
+	}
+	return lock, raw, nil
+}
+
+func (s *etcdLockStore) update(current, lock ClusterLock) error {
+	stored, raw, err := s.get()
+	if err != nil {
+		return maskAny(err)
+	}
+	if stored.ID != current.ID {
+		return maskAny(errgo.WithCausef(nil, NotFoundError, "lock %s not found", current.ID))
+	}
+	newRaw, err := json.Marshal(lock)
+	if err != nil {
+		return maskAny(err)
+	}
+	if err := s.client.UpdateKey(clusterLockKey, raw, string(newRaw), lockTTL(lock)); err != nil {
+		return maskAny(err)
+	}
+	return nil
+}
+
+func (s *etcdLockStore) remove(id string) error {
+	value := ""
+	if id != "" {
+		lock, raw, err := s.get()
+		if err != nil {
+			return maskAny(err)
+		}
+		if lock.ID != id {
+			return maskAny(errgo.WithCausef(nil, NotFoundError, "lock %s not found", id))
+		}
+		// Only remove the key when it has not been changed in the meantime
+		value = raw
+	}
+	if err := s.client.DeleteKey(clusterLockKey, value); err != nil {
+		return maskAny(err)
+	}
+	return nil
+}
+
+func (s *etcdLockStore) Close() error {
+	return maskAny(s.client.Close())
+}
+
+// lockTTL returns the time until the given lock expires (at least a second).
+func lockTTL(lock ClusterLock) time.Duration {
+	ttl := lock.Expires.Sub(time.Now())
+	if ttl < time.Second {
+		ttl = time.Second
+	}
+	return ttl
+}
+
+// tagLockStore keeps the lock in a tag of all instances of a cluster.
+// Unlike etcd, tags cannot be changed atomically, so this is a best effort.
+type tagLockStore struct {
+	ctx      context.Context
+	provider CloudProvider
+	info     ClusterInfo
+}
+
+func (s *tagLockStore) String() string {
+	return "instance tags"
+}
+
+func (s *tagLockStore) create(lock ClusterLock) error {
+	if _, _, err := s.get(); err == nil {
+		return maskAny(AlreadyExistsError)
+	} else if errgo.Cause(err) != NotFoundError {
+		return maskAny(err)
+	}
+	if err := s.provider.AddClusterTag(s.ctx, s.info, encodeLockTag(lock)); err != nil {
+		return maskAny(err)
+	}
+	return nil
+}
+
+func (s *tagLockStore) get() (ClusterLock, string, error) {
+	tags, err := s.provider.GetClusterTags(s.ctx, s.info)
+	if err != nil {
+		return ClusterLock{}, "", maskAny(err)
+	}
+	for _, tag := range tags {
+		if lock, ok := decodeLockTag(tag); ok {
+			return lock, tag, nil
+		}
+	}
+	return ClusterLock{}, "", maskAny(errgo.WithCausef(nil, NotFoundError, "%s is not locked", s.info))
+}
+
+func (s *tagLockStore) update(current, lock ClusterLock) error {
+	tags, err := s.provider.GetClusterTags(s.ctx, s.info)
+	if err != nil {
+		return maskAny(err)
+	}
+	var oldTags []string
+	for _, tag := range tags {
+		if stored, ok := decodeLockTag(tag); ok && stored.ID == current.ID && !containsString(oldTags, tag) {
+			oldTags = append(oldTags, tag)
+		}
+	}
+	if len(oldTags) == 0 {
+		return maskAny(errgo.WithCausef(nil, NotFoundError, "lock %s not found", current.ID))
+	}
+	// Add the new tag before removing the old one, so the cluster stays locked
+	tag := encodeLockTag(lock)
+	if !containsString(oldTags, tag) {
+		if err := s.provider.AddClusterTag(s.ctx, s.info, tag); err != nil {
+			return maskAny(err)
+		}
+	}
+	for _, old := range oldTags {
+		if old == tag {
+			continue
+		}
+		if err := s.provider.RemoveClusterTag(s.ctx, s.info, old); err != nil {
+			return maskAny(err)
+		}
+	}
+	return nil
+}
+
+func (s *tagLockStore) remove(id string) error {
+	tags, err := s.provider.GetClusterTags(s.ctx, s.info)
+	if err != nil {
+		return maskAny(err)
+	}
+	removed := make(map[string]bool)
+	for _, tag := range tags {
+		if lock, ok := decodeLockTag(tag); ok && (id == "" || lock.ID == id) && !removed[tag] {
+			if err := s.provider.RemoveClusterTag(s.ctx, s.info, tag); err != nil {
+				return maskAny(err)
+			}
+			removed[tag] = true
+		}
+	}
+	if len(removed) == 0 {
+		return maskAny(errgo.WithCausef(nil, NotFoundError, "lock %s not found", id))
+	}
+	return nil
+}
+
+func (s *tagLockStore) Close() error {
+	return nil
+}
+
+// encodeLockTag encodes the given lock as a tag.
+// Tags are limited in length, so only the ID, expiry and (a prefix of) the owner are encoded.
+// The complete lock is kept in etcd.
+func encodeLockTag(lock ClusterLock) string {
+	tag := fmt.Sprintf("%s%s:%d:", clusterLockTagPrefix, lock.ID, lock.Expires.Unix())
+	owner := []rune(lock.Owner)
+	for len(owner) > 0 && len(tag)+lockTagEncoding.EncodedLen(len(string(owner))) > maxLockTagLength {
+		owner = owner[:len(owner)-1]
+	}
+	encoded := strings.TrimRight(lockTagEncoding.EncodeToString([]byte(string(owner))), "=")
+	return tag + strings.ToLower(encoded)
+}
+
+// decodeLockTag decodes a lock from the given tag.
+// It returns false if the tag is not a lock tag.
+func decodeLockTag(tag string) (ClusterLock, bool) {
+	if !strings.HasPrefix(tag, clusterLockTagPrefix) {
+		return ClusterLock{}, false
+	}
+	parts := strings.Split(strings.TrimPrefix(tag, clusterLockTagPrefix), ":")
+	if len(parts) != 3 || parts[0] == "" {
+		return ClusterLock{}, false
+	}
+	expires, err := strconv.ParseInt(parts[1], 10, 64)
+	if err != nil {
+		return ClusterLock{}, false
+	}
+	encoded := strings.ToUpper(parts[2])
+	if rest := len(encoded) % 8; rest != 0 {
+		encoded += strings.Repeat("=", 8-rest)
+	}
+	owner, err := lockTagEncoding.DecodeString(encoded)
+	if err != nil {
+		return ClusterLock{}, false
+	}
+	return ClusterLock{
+		ID:      parts[0],
+		Owner:   string(owner),
+		Expires: time.Unix(expires, 0).UTC(),
+	}, true
+}
@@ -0,0 +1,205 @@
+// Copyright (c) 2016 Pulcy.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package providers
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/juju/errgo"
+	"github.com/op/go-logging"
+)
+
+// memoryLockStore is a clusterLockStore that keeps the lock in memory.
+type memoryLockStore struct {
+	name string
+	lock *ClusterLock
+}
+
+func (s *memoryLockStore) String() string { return s.name }
+
+func (s *memoryLockStore) create(lock ClusterLock) error {
+	if s.lock != nil {
+		return maskAny(AlreadyExistsError)
+	}
+	s.lock = &lock
+	return nil
+}
+
+func (s *memoryLockStore) get() (ClusterLock, string, error) {
+	if s.lock == nil {
+		return ClusterLock{}, "", maskAny(NotFoundError)
+	}
+	return *s.lock, s.lock.ID, nil
+}
+
+func (s *memoryLockStore) update(current, lock ClusterLock) error {
+	if s.lock == nil || s.lock.ID != current.ID {
+		return maskAny(NotFoundError)
+	}
+	s.lock = &lock
+	return nil
+}
+
+func (s *memoryLockStore) remove(id string) error {
+	if s.lock == nil || (id != "" && s.lock.ID != id) {
+		return maskAny(NotFoundError)
+	}
+	s.lock = nil
+	return nil
+}
+
+func (s *memoryLockStore) Close() error { return nil }
+
+func TestDecodeLockTag(t *testing.T) {
+	lock := NewClusterLock("alice@host", "instance create", time.Hour)
+	tag := encodeLockTag(lock)
+	if strings.Contains(tag, "=") || strings.ToLower(tag) != tag {
+		t.Errorf("Expected a lower case tag without padding, got %s", tag)
+	}
+	decoded, ok := decodeLockTag(tag)
+	if !ok {
+		t.Fatalf("Expected %s to be a lock tag", tag)
+	}
+	if decoded.ID != lock.ID || decoded.Owner != lock.Owner || !decoded.Expires.Equal(lock.Expires) {
+		t.Errorf("Expected %#v, got %#v", lock, decoded)
+	}
+
+	notLocks := []string{
+		"",
+		"core",
+		"quark-lock",
+		clusterLockTagPrefix,
+		clusterLockTagPrefix + "::",
+		clusterLockTagPrefix + lock.ID + ":soon:",
+		clusterLockTagPrefix + lock.ID + ":0:not base32!",
+		clusterLockTagPrefix + lock.ID + ":0:" + "x:y",
+		"other:" + tag[len(clusterLockTagPrefix):],
+	}
+	for _, tag := range notLocks {
+		if lock, ok := decodeLockTag(tag); ok {
+			t.Errorf("Expected %q not to be a lock tag, got %#v", tag, lock)
+		}
+	}
+}
+
+func TestLockTagLength(t *testing.T) {
+	for _, owner := range []string{"", "a", "bob@host", strings.Repeat("x", 150), strings.Repeat("é", 300) + "@host"} {
+		lock := NewClusterLock(owner, strings.Repeat("instance create ", 20), time.Hour)
+		tag := encodeLockTag(lock)
+		if len(tag) > maxLockTagLength {
+			t.Errorf("Expected a tag of at most %d characters, got %d for owner of %d characters", maxLockTagLength, len(tag), len(owner))
+		}
+		decoded, ok := decodeLockTag(tag)
+		if !ok {
+			t.Errorf("Expected %s to be a lock tag", tag)
+			continue
+		}
+		if decoded.ID != lock.ID || !decoded.Expires.Equal(lock.Expires) || !strings.HasPrefix(owner, decoded.Owner) {
+			t.Errorf("Expected %#v, got %#v", lock, decoded)
+		}
+		if len(owner) <= 100 && decoded.Owner != owner {
+			t.Errorf("Expected owner %s, got %s", owner, decoded.Owner)
+		}
+	}
+}
+
+func TestClusterLockStores(t *testing.T) {
+	log := logging.MustGetLogger("cluster-lock-test")
+	info := ClusterInfo{Name: "c1", Domain: "example.com"}
+	etcd, tags := &memoryLockStore{name: "etcd"}, &memoryLockStore{name: "tags"}
+	stores := clusterLockStores{etcd, tags}
+
+	// The lock is kept in all stores
+	first := NewClusterLock("alice@host", "instance create", time.Hour)
+	if _, err := stores.lock(log, info, first); err != nil {
+		t.Fatalf("lock failed: %v", err)
+	}
+	if etcd.lock == nil || tags.lock == nil || etcd.lock.ID != first.ID || tags.lock.ID != first.ID {
+		t.Fatalf("Expected the lock in both stores, got %v & %v", etcd.lock, tags.lock)
+	}
+
+	// A lock in any store refuses other locks, also when the other store cannot be reached
+	for _, only := range []clusterLockStores{stores, {etcd}, {tags}} {
+		current, err := only.lock(log, info, NewClusterLock("bob@host", "cluster update", time.Hour))
+		if errgo.Cause(err) != ClusterLockedError || current.ID != first.ID {
+			t.Errorf("Expected ClusterLockedError with the first lock from %s, got %v (%#v)", only, err, current)
+		}
+	}
+
+	// Renewal extends the lock in all stores and adds it to stores that missed it
+	tags.lock = nil
+	renewed := first.Renewed(time.Hour * 2)
+	if err := stores.renew(first, renewed); err != nil {
+		t.Fatalf("renew failed: %v", err)
+	}
+	for _, s := range []*memoryLockStore{etcd, tags} {
+		if s.lock == nil || !s.lock.Expires.Equal(renewed.Expires) {
+			t.Errorf("Expected the renewed lock in %s, got %v", s, s.lock)
+		}
+	}
+
+	// Release
+	if err := stores.remove(first.ID); err != nil {
+		t.Fatalf("remove failed: %v", err)
+	}
+	if etcd.lock != nil || tags.lock != nil {
+		t.Errorf("Expected no locks, got %v & %v", etcd.lock, tags.lock)
+	}
+	if err := stores.remove(first.ID); errgo.Cause(err) != NotFoundError {
+		t.Errorf("Expected NotFoundError when removing a released lock, got %v", err)
+	}
+	if err := stores.renew(first, renewed); errgo.Cause(err) != NotFoundError {
+		t.Errorf("Expected NotFoundError when renewing a released lock, got %v", err)
+	}
+
+	// Expired locks are replaced
+	expired := NewClusterLock("carol@host", "cluster destroy", -time.Minute)
+	tags.lock = &expired
+	second := NewClusterLock("bob@host", "cluster update", time.Hour)
+	if _, err := stores.lock(log, info, second); err != nil {
+		t.Fatalf("lock with an expired lock failed: %v", err)
+	}
+	if tags.lock == nil || tags.lock.ID != second.ID {
+		t.Errorf("Expected the expired lock to be replaced, got %v", tags.lock)
+	}
+}
+
+func TestClusterLockStoresPartial(t *testing.T) {
+	log := logging.MustGetLogger("cluster-lock-test")
+	info := ClusterInfo{Name: "c1", Domain: "example.com"}
+	etcd := &memoryLockStore{name: "etcd"}
+	failing := &failingLockStore{memoryLockStore{name: "tags"}}
+	stores := clusterLockStores{etcd, failing}
+
+	// A lock that cannot be stored everywhere is not left behind
+	if _, err := stores.lock(log, info, NewClusterLock("alice@host", "instance create", time.Hour)); err == nil {
+		t.Fatalf("Expected lock to fail")
+	}
+	if etcd.lock != nil {
+		t.Errorf("Expected no partial lock, got %v", etcd.lock)
+	}
+}
+
+// failingLockStore is a memoryLockStore that cannot create locks.
+type failingLockStore struct {
+	memoryLockStore
+}
+
+func (s *failingLockStore) create(lock ClusterLock) error {
+	return maskAny(fmt.Errorf("%s is not available", s.name))
+}
@@ -0,0 +1,93 @@
+// Copyright (c) 2016 Pulcy.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package providers
+
+import (
+	"context"
+	"time"
+
+	"github.com/cenkalti/backoff"
+)
+
+// PhaseTimeouts specifies how long each phase of creating an instance may take.
+// A zero timeout means that the phase is not limited.
+type PhaseTimeouts struct {
+	Provision time.Duration // Until the provider reports the new server as active
+	SSHReady  time.Duration // Until an instance accepts SSH connections (after creation or a reboot)
+	Bootstrap time.Duration // Initial setup of an instance (OS update, gluon & reboot)
+}
+
+var (
+	phaseTimeouts PhaseTimeouts
+)
+
+// SetPhaseTimeouts sets the timeouts of the phases of creating an instance.
+func SetPhaseTimeouts(timeouts PhaseTimeouts) {
+	phaseTimeouts = timeouts
+}
+
+// ProvisionContext returns a context that is cancelled when the provision timeout has passed.
+func ProvisionContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	return withPhaseTimeout(ctx, phaseTimeouts.Provision)
+}
+
+// SSHReadyContext returns a context that is cancelled when the SSH-ready timeout has passed.
+func SSHReadyContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	return withPhaseTimeout(ctx, phaseTimeouts.SSHReady)
+}
+
+// BootstrapContext returns a context that is cancelled when the bootstrap timeout has passed.
+func BootstrapContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	return withPhaseTimeout(ctx, phaseTimeouts.Bootstrap)
+}
+
+func withPhaseTimeout(ctx context.Context, timeout time.Duration) (context.Context, context.CancelFunc) {
+	if timeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, timeout)
+}
+
+// Sleep blocks for the given duration.
+// It returns the error of the given context when that is done before the duration has passed.
+func Sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return maskAny(ctx.Err())
+	}
+}
+
+// Retry calls the given operation until it succeeds, the given backoff stops or the given context is done.
+// Unlike backoff.Retry, it stops waiting between attempts as soon as the context is done.
+func Retry(ctx context.Context, operation func() error, b backoff.BackOff) error {
+	b.Reset()
+	for {
+		err := operation()
+		if err == nil {
+			return nil
+		}
+		next := b.NextBackOff()
+		if next == backoff.Stop {
+			return maskAny(err)
+		}
+		if err := Sleep(ctx, next); err != nil {
+			return maskAny(err)
+		}
+	}
+}
@@ -0,0 +1,117 @@
+// Copyright (c) 2016 Pulcy.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package providers
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/cenkalti/backoff"
+)
+
+func TestRetry(t *testing.T) {
+	attempts := 0
+	err := Retry(context.Background(), func() error {
+		attempts++
+		if attempts < 3 {
+			return errors.New("not yet")
+		}
+		return nil
+	}, backoff.NewConstantBackOff(time.Millisecond))
+	if err != nil || attempts != 3 {
+		t.Errorf("Expected success after 3 attempts, got %v after %d attempts", err, attempts)
+	}
+}
+
+func TestRetryCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	attempts := 0
+	done := make(chan error)
+	go func() {
+		done <- Retry(ctx, func() error {
+			attempts++
+			cancel()
+			return errors.New("failed")
+		}, backoff.NewConstantBackOff(time.Hour))
+	}()
+	select {
+	case err := <-done:
+		if err == nil || attempts != 1 {
+			t.Errorf("Expected an error after 1 attempt, got %v after %d attempts", err, attempts)
+		}
+	case <-time.After(time.Second * 5):
+		t.Fatalf("Retry does not stop when the context is cancelled")
+	}
+}
+
+func TestPhaseContexts(t *testing.T) {
+	defer SetPhaseTimeouts(PhaseTimeouts{})
+	phases := []func(context.Context) (context.Context, context.CancelFunc){ProvisionContext, SSHReadyContext, BootstrapContext}
+
+	// Without timeouts, the phases are not limited
+	SetPhaseTimeouts(PhaseTimeouts{})
+	for _, phase := range phases {
+		ctx, cancel := phase(context.Background())
+		if _, ok := ctx.Deadline(); ok {
+			t.Errorf("Expected no deadline without a timeout")
+		}
+		cancel()
+		if ctx.Err() != context.Canceled {
+			t.Errorf("Expected the context to be cancelled, got %v", ctx.Err())
+		}
+	}
+
+	SetPhaseTimeouts(PhaseTimeouts{
+		Provision: time.Minute,
+		SSHReady:  time.Minute * 2,
+		Bootstrap: time.Minute * 3,
+	})
+	expected := []time.Duration{time.Minute, time.Minute * 2, time.Minute * 3}
+	for index, phase := range phases {
+		start := time.Now()
+		ctx, cancel := phase(context.Background())
+		deadline, ok := ctx.Deadline()
+		cancel()
+		if !ok {
+			t.Errorf("Expected a deadline for phase %d", index)
+		} else if d := deadline.Sub(start); d < expected[index]-time.Second || d > expected[index]+time.Second {
+			t.Errorf("Expected a deadline after %s for phase %d, got %s", expected[index], index, d)
+		}
+	}
+
+	// A phase never outlives its parent
+	parent, cancelParent := context.WithTimeout(context.Background(), time.Millisecond)
+	defer cancelParent()
+	ctx, cancel := SSHReadyContext(parent)
+	defer cancel()
+	select {
+	case <-ctx.Done():
+	case <-time.After(time.Second * 5):
+		t.Errorf("Phase context is not done when its parent is")
+	}
+}
+
+func TestSleepCancelled(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond)
+	defer cancel()
+	if err := Sleep(ctx, time.Hour); err == nil {
+		t.Errorf("Expected Sleep to stop when the context is done")
+	}
+	if err := Sleep(context.Background(), time.Millisecond); err != nil {
+		t.Errorf("Expected Sleep to succeed, got %v", err)
+	}
+}
@@ -37,14 +37,14 @@ type CreateClusterOptions struct {
 	RebootStrategy          string
 	PrivateRegistryUrl      string // URL of private docker registry
 	PrivateRegistryUserName string // Username of private docker registry
-	PrivateRegistryPassword string // Password of private docker registry
+	PrivateRegistryPassword string `json:"-"` // Password of private docker registry (not recorded in journals)
 	VaultAddress            string // URL of the vault
 	VaultCertificatePath    string // Path of the vault ca-cert file
 	VaultServerKeyPath      string // Path of the vault ca-cert key file
 	VaultServerKeyCommand   string // Shell command that outputs a PEM-encoded CA key to use to as the Vault server SSL certificate key
 	TincCIDR                string // CIDR for the TINC network inside the cluster (e.g. 192.168.35.0/24)
 	HttpProxy               string // Address of the http proxy to use (if any)
-	WeavePassword           string // Encryption password of weave network
+	WeavePassword           string `json:"-"` // Encryption password of weave network (not recorded in journals)
 	EnableFleet             bool   // Install fleet on the cluster
 	EnableKubernetes        bool   // Install kubernetes on the cluster
 
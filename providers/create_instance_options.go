@@ -43,7 +43,7 @@ type CreateInstanceOptions struct {
 	RebootStrategy          string
 	PrivateRegistryUrl      string // URL of private docker registry
 	PrivateRegistryUserName string // Username of private docker registry
-	PrivateRegistryPassword string // Password of private docker registry
+	PrivateRegistryPassword string `json:"-"` // Password of private docker registry (not recorded in journals)
 	EtcdProxy               bool   // If set, this instance will be an ETCD proxy
 	VaultAddress            string // URL of the vault
 	VaultCertificatePath    string // Path of the vault ca-cert file
@@ -54,7 +54,7 @@ type CreateInstanceOptions struct {
 	TincCIDR                string // CIDR for the TINC network inside the cluster (e.g. 192.168.35.0/24)
 	TincIpv4                string // IP addres of tun0 (tinc) on this instance
 	HttpProxy               string // Address of the http proxy to use (if any)
-	WeaveEnv                string // Content of weave.env
+	WeaveEnv                string `json:"-"` // Content of weave.env (contains the weave password, not recorded in journals)
 	WeaveSeed               string // Content of weave-seed
 }
 
@@ -112,6 +112,8 @@ func (o *CreateInstanceOptions) NewCloudConfigOptions() CloudConfigOptions {
 	cco := CloudConfigOptions{
 		ClusterID:      o.ClusterInfo.ID,
 		RebootStrategy: o.RebootStrategy,
+		HttpProxy:      o.HttpProxy,
+		NoProxy:        o.NoProxy(),
 	}
 	return cco
 }
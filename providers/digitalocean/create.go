@@ -15,11 +15,13 @@
 package digitalocean
 
 import (
+	"context"
 	"errors"
 	"sync"
 	"time"
 
 	"github.com/digitalocean/godo"
+	"github.com/juju/errgo"
 	"github.com/op/go-logging"
 
 	"github.com/pulcy/quark/providers"
@@ -36,7 +38,7 @@ type instanceData struct {
 	FleetMetadata         string
 }
 
-func (dp *doProvider) CreateCluster(log *logging.Logger, options providers.CreateClusterOptions, dnsProvider providers.DnsProvider) error {
+func (dp *doProvider) CreateCluster(ctx context.Context, log *logging.Logger, options providers.CreateClusterOptions, dnsProvider providers.DnsProvider) error {
 	wg := sync.WaitGroup{}
 	errors := make(chan error, options.InstanceCount)
 	instanceDatas := make(chan instanceData, options.InstanceCount)
@@ -51,7 +53,7 @@ func (dp *doProvider) CreateCluster(log *logging.Logger, options providers.Creat
 				errors <- maskAny(err)
 				return
 			}
-			instance, err := dp.CreateInstance(log, instanceOptions, dnsProvider)
+			instance, err := dp.CreateInstance(ctx, log, instanceOptions, dnsProvider)
 			if err != nil {
 				errors <- maskAny(err)
 			} else {
@@ -78,19 +80,19 @@ func (dp *doProvider) CreateCluster(log *logging.Logger, options providers.Creat
 		instanceList = append(instanceList, data.ClusterInstance)
 	}
 
-	clusterMembers, err := instanceList.AsClusterMemberList(log, nil)
+	clusterMembers, err := instanceList.AsClusterMemberList(ctx, log, nil)
 	if err != nil {
 		return maskAny(err)
 	}
 
-	if err := dp.setupInstances(log, instances, clusterMembers); err != nil {
+	if err := dp.setupInstances(ctx, log, instances, clusterMembers); err != nil {
 		return maskAny(err)
 	}
 
 	return nil
 }
 
-func (dp *doProvider) setupInstances(log *logging.Logger, instances []instanceData, clusterMembers providers.ClusterMemberList) error {
+func (dp *doProvider) setupInstances(ctx context.Context, log *logging.Logger, instances []instanceData, clusterMembers providers.ClusterMemberList) error {
 	wg := sync.WaitGroup{}
 	errors := make(chan error, len(instances))
 	for _, instance := range instances {
@@ -101,7 +103,7 @@ func (dp *doProvider) setupInstances(log *logging.Logger, instances []instanceDa
 				ClusterMembers: clusterMembers,
 				FleetMetadata:  instance.FleetMetadata,
 			}
-			if err := instance.ClusterInstance.InitialSetup(log, instance.CreateInstanceOptions, iso, dp); err != nil {
+			if err := instance.ClusterInstance.InitialSetup(ctx, log, instance.CreateInstanceOptions, iso, dp); err != nil {
 				errors <- maskAny(err)
 				return
 			}
@@ -117,7 +119,7 @@ func (dp *doProvider) setupInstances(log *logging.Logger, instances []instanceDa
 	return nil
 }
 
-func (dp *doProvider) CreateInstance(log *logging.Logger, options providers.CreateInstanceOptions, dnsProvider providers.DnsProvider) (providers.ClusterInstance, error) {
+func (dp *doProvider) CreateInstance(ctx context.Context, log *logging.Logger, options providers.CreateInstanceOptions, dnsProvider providers.DnsProvider) (providers.ClusterInstance, error) {
 	client := NewDOClient(dp.token)
 
 	keys := []godo.DropletCreateSSHKey{}
@@ -163,7 +165,7 @@ func (dp *doProvider) CreateInstance(log *logging.Logger, options providers.Crea
 
 	// Wait for active
 	dp.Logger.Infof("Waiting for droplet '%s'", createDroplet.Name)
-	droplet, err := dp.waitUntilDropletActive(createDroplet.ID)
+	droplet, err := dp.waitUntilDropletActive(ctx, createDroplet.ID)
 	if err != nil {
 		return providers.ClusterInstance{}, maskAny(err)
 	}
@@ -171,7 +173,7 @@ func (dp *doProvider) CreateInstance(log *logging.Logger, options providers.Crea
 	privateIpv4 := getIpv4(*droplet, "private")
 	publicIpv4 := getIpv4(*droplet, "public")
 	publicIpv6 := getIpv6(*droplet, "public")
-	if err := providers.RegisterInstance(dp.Logger, dnsProvider, options, createDroplet.Name, options.RegisterInstance, options.RoleLoadBalancer, options.RoleLoadBalancer, publicIpv4, publicIpv6, privateIpv4); err != nil {
+	if err := providers.RegisterInstance(ctx, dp.Logger, dnsProvider, options, createDroplet.Name, options.RegisterInstance, options.RoleLoadBalancer, options.RoleLoadBalancer, publicIpv4, publicIpv6, privateIpv4); err != nil {
 		return providers.ClusterInstance{}, maskAny(err)
 	}
 
@@ -180,7 +182,9 @@ func (dp *doProvider) CreateInstance(log *logging.Logger, options providers.Crea
 	return dp.clusterInstance(*droplet), nil
 }
 
-func (dp *doProvider) waitUntilDropletActive(id int) (*godo.Droplet, error) {
+func (dp *doProvider) waitUntilDropletActive(ctx context.Context, id int) (*godo.Droplet, error) {
+	ctx, cancel := providers.ProvisionContext(ctx)
+	defer cancel()
 	client := NewDOClient(dp.token)
 	for {
 		droplet, _, err := client.Droplets.Get(id)
@@ -191,7 +195,9 @@ func (dp *doProvider) waitUntilDropletActive(id int) (*godo.Droplet, error) {
 			return droplet, nil
 		}
 		// Wait a while
-		time.Sleep(time.Second * 5)
+		if err := providers.Sleep(ctx, time.Second*5); err != nil {
+			return nil, maskAny(errgo.Notef(err, "droplet %d is not active", id))
+		}
 	}
 }
 
@@ -15,10 +15,12 @@
 package digitalocean
 
 import (
+	"context"
+
 	"github.com/pulcy/quark/providers"
 )
 
-func (this *doProvider) DeleteCluster(info providers.ClusterInfo, dnsProvider providers.DnsProvider) error {
+func (this *doProvider) DeleteCluster(ctx context.Context, info providers.ClusterInfo, dnsProvider providers.DnsProvider) error {
 	droplets, err := this.getInstances(info)
 	if err != nil {
 		return err
@@ -27,7 +29,7 @@ func (this *doProvider) DeleteCluster(info providers.ClusterInfo, dnsProvider pr
 	for _, d := range droplets {
 		// Delete DNS instance records
 		instance := this.clusterInstance(d)
-		if err := providers.UnRegisterInstance(this.Logger, dnsProvider, instance, info.Domain); err != nil {
+		if err := providers.UnRegisterInstance(ctx, this.Logger, dnsProvider, instance, info.Domain); err != nil {
 			return maskAny(err)
 		}
 
@@ -41,7 +43,7 @@ func (this *doProvider) DeleteCluster(info providers.ClusterInfo, dnsProvider pr
 	return nil
 }
 
-func (dp *doProvider) DeleteInstance(info providers.ClusterInstanceInfo, dnsProvider providers.DnsProvider) error {
+func (dp *doProvider) DeleteInstance(ctx context.Context, info providers.ClusterInstanceInfo, dnsProvider providers.DnsProvider) error {
 	fullName := info.String()
 	droplets, err := dp.getInstances(info.ClusterInfo)
 	if err != nil {
@@ -52,7 +54,7 @@ func (dp *doProvider) DeleteInstance(info providers.ClusterInstanceInfo, dnsProv
 		if d.Name == fullName {
 			// Delete DNS instance records
 			instance := dp.clusterInstance(d)
-			if err := providers.UnRegisterInstance(dp.Logger, dnsProvider, instance, info.Domain); err != nil {
+			if err := providers.UnRegisterInstance(ctx, dp.Logger, dnsProvider, instance, info.Domain); err != nil {
 				return maskAny(err)
 			}
 
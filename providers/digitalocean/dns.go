@@ -15,45 +15,39 @@
 package digitalocean
 
 import (
+	"context"
 	"fmt"
-	"sort"
 
 	"github.com/digitalocean/godo"
-	"github.com/ryanuber/columnize"
+
+	"github.com/pulcy/quark/providers"
 )
 
-func (this *doProvider) ShowDomainRecords(domain string) error {
-	// Load images
+func (this *doProvider) GetDomainRecords(ctx context.Context, domain string) (providers.DnsRecordList, error) {
+	// Load records
 	client := NewDOClient(this.token)
 	records, err := DomainRecordList(client, domain)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	lines := []string{
-		"Type | Name | Data | Priority | Weight | Port",
-	}
+	result := providers.DnsRecordList{}
 	for _, r := range records {
-		line := fmt.Sprintf("%s | %s | %s | %v | %v | %v", r.Type, trimLength(r.Name, 20), trimLength(r.Data, 60), r.Priority, r.Weight, r.Port)
-		lines = append(lines, line)
+		result = append(result, providers.DnsRecord{
+			ID:       fmt.Sprintf("%v", r.ID),
+			Type:     r.Type,
+			Name:     r.Name,
+			Data:     r.Data,
+			Priority: r.Priority,
+			Weight:   r.Weight,
+			Port:     r.Port,
+		})
 	}
 
-	sort.Strings(lines[1:])
-	result := columnize.SimpleFormat(lines)
-	fmt.Println(result)
-
-	return nil
-}
-
-func trimLength(s string, maxLen int) string {
-	if len(s) > maxLen {
-		return s[:maxLen] + "..."
-	} else {
-		return s
-	}
+	return result, nil
 }
 
-func (this *doProvider) CreateDnsRecord(domain, _type, name, data string) error {
+func (this *doProvider) CreateDnsRecord(ctx context.Context, domain, _type, name, data string) error {
 	client := NewDOClient(this.token)
 	record := &godo.DomainRecordEditRequest{
 		Type: _type,
@@ -67,7 +61,7 @@ func (this *doProvider) CreateDnsRecord(domain, _type, name, data string) error
 	return nil
 }
 
-func (this *doProvider) DeleteDnsRecord(domain, _type, name, data string) error {
+func (this *doProvider) DeleteDnsRecord(ctx context.Context, domain, _type, name, data string) error {
 	client := NewDOClient(this.token)
 	records, err := DomainRecordList(client, domain)
 	if err != nil {
@@ -15,35 +15,31 @@
 package digitalocean
 
 import (
-	"fmt"
-	"sort"
-	"strings"
+	"context"
 
-	"github.com/ryanuber/columnize"
+	"github.com/pulcy/quark/providers"
 )
 
-func (this *doProvider) ShowImages() error {
+func (this *doProvider) GetImages(ctx context.Context) (providers.ImageList, error) {
 	// Load images
 	client := NewDOClient(this.token)
 	images, err := ImageList(client)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	lines := []string{
-		"Slug | Name | Distribution | Regions",
-	}
+	result := providers.ImageList{}
 	for _, r := range images {
 		if !r.Public {
 			continue
 		}
-		line := fmt.Sprintf("%s | %s | %s | %s", r.Slug, r.Name, r.Distribution, strings.Join(r.Regions, " "))
-		lines = append(lines, line)
+		result = append(result, providers.Image{
+			ID:           r.Slug,
+			Name:         r.Name,
+			Distribution: r.Distribution,
+			Regions:      r.Regions,
+		})
 	}
 
-	sort.Strings(lines[1:])
-	result := columnize.SimpleFormat(lines)
-	fmt.Println(result)
-
-	return nil
+	return result, nil
 }
@@ -15,6 +15,7 @@
 package digitalocean
 
 import (
+	"context"
 	"fmt"
 	"strings"
 
@@ -23,7 +24,7 @@ import (
 	"github.com/pulcy/quark/providers"
 )
 
-func (dp *doProvider) GetInstances(info providers.ClusterInfo) (providers.ClusterInstanceList, error) {
+func (dp *doProvider) GetInstances(ctx context.Context, info providers.ClusterInfo) (providers.ClusterInstanceList, error) {
 	droplets, err := dp.getInstances(info)
 	if err != nil {
 		return nil, err
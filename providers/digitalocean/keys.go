@@ -15,31 +15,29 @@
 package digitalocean
 
 import (
+	"context"
 	"fmt"
-	"sort"
 
-	"github.com/ryanuber/columnize"
+	"github.com/pulcy/quark/providers"
 )
 
-func (this *doProvider) ShowKeys() error {
-	// Load images
+func (this *doProvider) GetKeys(ctx context.Context) (providers.SSHKeyList, error) {
+	// Load keys
 	client := NewDOClient(this.token)
 	keys, err := KeyList(client)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	lines := []string{
-		"ID | Name | FingerPrint | Public-key",
-	}
+	result := providers.SSHKeyList{}
 	for _, r := range keys {
-		line := fmt.Sprintf("%v | %s | %s | %s", r.ID, r.Name, r.Fingerprint, r.PublicKey)
-		lines = append(lines, line)
+		result = append(result, providers.SSHKey{
+			ID:          fmt.Sprintf("%v", r.ID),
+			Name:        r.Name,
+			Fingerprint: r.Fingerprint,
+			PublicKey:   r.PublicKey,
+		})
 	}
 
-	sort.Strings(lines[1:])
-	result := columnize.SimpleFormat(lines)
-	fmt.Println(result)
-
-	return nil
+	return result, nil
 }
@@ -15,6 +15,8 @@
 package digitalocean
 
 import (
+	"context"
+
 	"github.com/op/go-logging"
 
 	"github.com/pulcy/quark/providers"
@@ -32,6 +34,6 @@ func NewProvider(logger *logging.Logger, token string) providers.CloudProvider {
 	}
 }
 
-func (vp *doProvider) ShowInstanceTypes() error {
-	return maskAny(NotImplementedError)
+func (vp *doProvider) GetInstanceTypes(ctx context.Context) (providers.InstanceTypeList, error) {
+	return nil, maskAny(NotImplementedError)
 }
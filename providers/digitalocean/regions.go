@@ -15,35 +15,31 @@
 package digitalocean
 
 import (
-	"fmt"
-	"sort"
-	"strings"
+	"context"
 
-	"github.com/ryanuber/columnize"
+	"github.com/pulcy/quark/providers"
 )
 
-func (this *doProvider) ShowRegions() error {
+func (this *doProvider) GetRegions(ctx context.Context) (providers.RegionList, error) {
 	// Load regions
 	client := NewDOClient(this.token)
 	regions, err := RegionList(client)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	lines := []string{
-		"Slug | Name | Features | Size",
-	}
+	result := providers.RegionList{}
 	for _, r := range regions {
 		if !r.Available {
 			continue
 		}
-		line := fmt.Sprintf("%s | %s | %s | %s", r.Slug, r.Name, strings.Join(r.Features, " "), strings.Join(r.Sizes, " "))
-		lines = append(lines, line)
+		result = append(result, providers.Region{
+			ID:       r.Slug,
+			Name:     r.Name,
+			Features: r.Features,
+			Sizes:    r.Sizes,
+		})
 	}
 
-	sort.Strings(lines[1:])
-	result := columnize.SimpleFormat(lines)
-	fmt.Println(result)
-
-	return nil
+	return result, nil
 }
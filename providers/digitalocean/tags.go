@@ -0,0 +1,67 @@
+// Copyright (c) 2016 Pulcy.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package digitalocean
+
+import (
+	"context"
+	"strconv"
+
+	"github.com/digitalocean/godo"
+
+	"github.com/pulcy/quark/providers"
+)
+
+// Get all tags of the instances of a cluster
+func (dp *doProvider) GetClusterTags(ctx context.Context, info providers.ClusterInfo) ([]string, error) {
+	droplets, err := dp.getInstances(info)
+	if err != nil {
+		return nil, maskAny(err)
+	}
+	var tags []string
+	for _, d := range droplets {
+		tags = append(tags, d.Tags...)
+	}
+	return tags, nil
+}
+
+// Add a tag to all instances of a cluster
+func (dp *doProvider) AddClusterTag(ctx context.Context, info providers.ClusterInfo, tag string) error {
+	droplets, err := dp.getInstances(info)
+	if err != nil {
+		return maskAny(err)
+	}
+	client := NewDOClient(dp.token)
+	if _, _, err := client.Tags.Create(&godo.TagCreateRequest{Name: tag}); err != nil {
+		return maskAny(err)
+	}
+	req := &godo.TagResourcesRequest{}
+	for _, d := range droplets {
+		req.Resources = append(req.Resources, godo.Resource{ID: strconv.Itoa(d.ID), Type: godo.DropletResourceType})
+	}
+	if _, err := client.Tags.TagResources(tag, req); err != nil {
+		return maskAny(err)
+	}
+	return nil
+}
+
+// Remove a tag from all instances of a cluster
+func (dp *doProvider) RemoveClusterTag(ctx context.Context, info providers.ClusterInfo, tag string) error {
+	// Deleting the tag removes it from all droplets
+	client := NewDOClient(dp.token)
+	if _, err := client.Tags.Delete(tag); err != nil {
+		return maskAny(err)
+	}
+	return nil
+}
@@ -15,11 +15,13 @@
 package digitalocean
 
 import (
+	"context"
+
 	"github.com/op/go-logging"
 
 	"github.com/pulcy/quark/providers"
 )
 
-func (p *doProvider) UpdateCluster(log *logging.Logger, info providers.ClusterInfo, dnsProvider providers.DnsProvider) error {
+func (p *doProvider) UpdateCluster(ctx context.Context, log *logging.Logger, info providers.ClusterInfo, dnsProvider providers.DnsProvider) error {
 	return maskAny(NotImplementedError)
 }
@@ -14,9 +14,11 @@
 
 package providers
 
+import "context"
+
 // DnsProvider holds all functions to be implemented by DNS providers
 type DnsProvider interface {
-	ShowDomainRecords(domain string) error
-	CreateDnsRecord(domain, recordTpe, name, data string) error
-	DeleteDnsRecord(domain, recordType, name, data string) error
+	GetDomainRecords(ctx context.Context, domain string) (DnsRecordList, error)
+	CreateDnsRecord(ctx context.Context, domain, recordTpe, name, data string) error
+	DeleteDnsRecord(ctx context.Context, domain, recordType, name, data string) error
 }
@@ -0,0 +1,222 @@
+// Copyright (c) 2016 Pulcy.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package providers
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/juju/errgo"
+	"github.com/op/go-logging"
+)
+
+const (
+	drainCheckWait = time.Second * 5
+
+	kubectlNodesTemplate = `{range .items[*]}{.metadata.name} {.status.addresses[*].address}{"\n"}{end}`
+	kubectlPodsTemplate  = `{range .items[*]}{.metadata.namespace}/{.metadata.name} {.metadata.ownerReferences[*].kind}{"\n"}{end}`
+)
+
+// Drain moves the workloads off the given instance, so it can be removed from the cluster formed
+// by the instances in the list (which does not include the given instance).
+// When kubernetes is enabled, the node of the instance is cordoned and its pods are evicted, using
+// kubectl on one of the instances in the list, since the instance itself is about to go away.
+// When fleet is enabled, the fleet agent is stopped, so fleet reschedules its units on other instances.
+// Eviction is given up at the given deadline, which is meant to be shared with WaitUntilDrained.
+func (cil ClusterInstanceList) Drain(ctx context.Context, log *logging.Logger, toDrain ClusterInstance, deadline time.Time) error {
+	s, err := toDrain.Connect()
+	if err != nil {
+		return maskAny(err)
+	}
+	defer s.Close()
+	gluonEnv, err := s.GetGluonEnv(ctx, log)
+	if err != nil {
+		return maskAny(err)
+	}
+	if isKubernetesEnabled(gluonEnv) {
+		kubectl, node, err := cil.kubectlConnection(ctx, log, toDrain)
+		if errgo.Cause(err) == NotFoundError {
+			log.Warningf("%s is not a kubernetes node", toDrain)
+		} else if err != nil {
+			return maskAny(err)
+		}
+		if kubectl != nil {
+			defer kubectl.Close()
+		}
+		if node != "" {
+			timeout := deadline.Sub(time.Now())
+			timeout -= timeout % time.Second
+			if timeout <= 0 {
+				return maskAny(fmt.Errorf("no time left to evict kubernetes pods from %s", toDrain))
+			}
+			// `kubectl drain` cordons the node before it evicts the pods
+			log.Infof("Evicting kubernetes pods from %s", toDrain)
+			cmd := fmt.Sprintf("kubectl drain %s --ignore-daemonsets --delete-local-data --force --timeout=%s", node, timeout)
+			if _, err := kubectl.Run(ctx, log, cmd, "", false); err != nil {
+				return maskAny(err)
+			}
+		}
+	}
+	if isFleetEnabled(gluonEnv) {
+		log.Infof("Stopping fleet on %s", toDrain)
+		if _, err := s.Run(ctx, log, "sudo systemctl stop fleet.service", "", false); err != nil {
+			return maskAny(err)
+		}
+	}
+	return nil
+}
+
+// WaitUntilDrained blocks until no fleet units and no kubernetes pods (other than those of
+// daemon sets) are scheduled on the given instance, or the given deadline has passed.
+// Kubernetes pods are listed using kubectl on one of the instances in the list.
+func (cil ClusterInstanceList) WaitUntilDrained(ctx context.Context, log *logging.Logger, toDrain ClusterInstance, deadline time.Time) error {
+	log.Infof("Waiting for workloads to move off %s", toDrain)
+	for {
+		remaining, err := cil.remainingWorkloads(ctx, log, toDrain)
+		if err == nil && len(remaining) == 0 {
+			log.Infof("%s is drained", toDrain)
+			return nil
+		}
+		if err == nil {
+			err = fmt.Errorf("still running %s", strings.Join(remaining, ", "))
+		}
+		left := deadline.Sub(time.Now())
+		if left <= 0 {
+			return maskAny(errgo.Notef(err, "%s is not drained in time", toDrain))
+		}
+		log.Debugf("%s is not yet drained: %v", toDrain, err)
+		wait := drainCheckWait
+		if left < wait {
+			wait = left
+		}
+		if err := Sleep(ctx, wait); err != nil {
+			return maskAny(err)
+		}
+	}
+}
+
+// remainingWorkloads returns the names of the fleet units & kubernetes pods that are still scheduled on the given instance.
+func (cil ClusterInstanceList) remainingWorkloads(ctx context.Context, log *logging.Logger, toDrain ClusterInstance) ([]string, error) {
+	s, err := toDrain.Connect()
+	if err != nil {
+		return nil, maskAny(err)
+	}
+	defer s.Close()
+	gluonEnv, err := s.GetGluonEnv(ctx, log)
+	if err != nil {
+		return nil, maskAny(err)
+	}
+	var result []string
+	if isFleetEnabled(gluonEnv) {
+		machineID, err := s.GetMachineID(ctx, log)
+		if err != nil {
+			return nil, maskAny(err)
+		}
+		out, err := s.Run(ctx, log, "fleetctl list-units --no-legend --full --fields=unit,machine", "", true)
+		if err != nil {
+			return nil, maskAny(err)
+		}
+		for _, line := range strings.Split(out, "\n") {
+			fields := strings.Fields(line)
+			// The machine column is formatted as <machine-id>/<ip>
+			if len(fields) == 2 && strings.HasPrefix(fields[1], machineID+"/") {
+				result = append(result, fields[0])
+			}
+		}
+	}
+	if isKubernetesEnabled(gluonEnv) {
+		kubectl, node, err := cil.kubectlConnection(ctx, log, toDrain)
+		if errgo.Cause(err) == NotFoundError {
+			return result, nil
+		} else if err != nil {
+			return nil, maskAny(err)
+		}
+		defer kubectl.Close()
+		cmd := fmt.Sprintf("kubectl get pods --all-namespaces --field-selector=spec.nodeName=%s -o jsonpath='%s'", node, kubectlPodsTemplate)
+		out, err := kubectl.Run(ctx, log, cmd, "", true)
+		if err != nil {
+			return nil, maskAny(err)
+		}
+		for _, line := range strings.Split(out, "\n") {
+			fields := strings.Fields(line)
+			if len(fields) == 0 {
+				continue
+			}
+			// Pods of daemon sets & static (mirror) pods are not evicted
+			owners := fields[1:]
+			if !containsString(owners, "DaemonSet") && !containsString(owners, "Node") {
+				result = append(result, "pod "+fields[0])
+			}
+		}
+	}
+	return result, nil
+}
+
+// kubectlConnection opens a connection to the first instance in the list on which kubectl works
+// and returns it together with the name of the kubernetes node of the given instance.
+// When kubectl works but the given instance is no kubernetes node, a NotFoundError is returned
+// together with the (open) connection.
+func (cil ClusterInstanceList) kubectlConnection(ctx context.Context, log *logging.Logger, toDrain ClusterInstance) (InstanceConnection, string, error) {
+	lastErr := fmt.Errorf("no other instances to run kubectl on")
+	for _, i := range cil {
+		s, err := i.Connect()
+		if err != nil {
+			log.Warningf("cannot connect to '%s': %#v", i, err)
+			lastErr = err
+			continue
+		}
+		node, err := toDrain.kubernetesNode(ctx, log, s)
+		if err == nil {
+			return s, node, nil
+		} else if errgo.Cause(err) == NotFoundError {
+			return s, "", maskAny(err)
+		}
+		log.Warningf("cannot run kubectl on '%s': %#v", i, err)
+		s.Close()
+		lastErr = err
+	}
+	return nil, "", maskAny(lastErr)
+}
+
+// kubernetesNode returns the name of the kubernetes node of the instance, using kubectl on the given connection.
+// Nodes are matched by their name or one of their addresses.
+func (i ClusterInstance) kubernetesNode(ctx context.Context, log *logging.Logger, s InstanceConnection) (string, error) {
+	out, err := s.Run(ctx, log, fmt.Sprintf("kubectl get nodes -o jsonpath='%s'", kubectlNodesTemplate), "", false)
+	if err != nil {
+		return "", maskAny(err)
+	}
+	candidates := []string{i.Name, strings.SplitN(i.Name, ".", 2)[0], i.ClusterIP, i.PrivateIP}
+	for _, line := range strings.Split(out, "\n") {
+		fields := strings.Fields(line)
+		for _, f := range fields {
+			if f != "" && containsString(candidates, f) {
+				return fields[0], nil
+			}
+		}
+	}
+	return "", maskAny(errgo.WithCausef(nil, NotFoundError, "no kubernetes node found for %s", i))
+}
+
+// isFleetEnabled returns true unless fleet is disabled in the given gluon environment.
+func isFleetEnabled(gluonEnv string) bool {
+	return !strings.Contains(gluonEnv, "GLUON_FLEET_ENABLED=false")
+}
+
+// isKubernetesEnabled returns true if kubernetes is enabled in the given gluon environment.
+func isKubernetesEnabled(gluonEnv string) bool {
+	return strings.Contains(gluonEnv, "GLUON_K8S_ENABLED=true")
+}
@@ -0,0 +1,55 @@
+// Copyright (c) 2016 Pulcy.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dryrun
+
+import (
+	"context"
+
+	"github.com/pulcy/quark/providers"
+)
+
+// recordingDnsProvider implements providers.DnsProvider by recording all changes in a plan.
+type recordingDnsProvider struct {
+	plan        *Plan
+	dnsProvider providers.DnsProvider
+}
+
+// NewDnsProvider creates a DNS provider that records all record changes in the given plan
+// and passes all read-only operations on to the given provider.
+func NewDnsProvider(plan *Plan, dnsProvider providers.DnsProvider) providers.DnsProvider {
+	return &recordingDnsProvider{
+		plan:        plan,
+		dnsProvider: dnsProvider,
+	}
+}
+
+func (p *recordingDnsProvider) GetDomainRecords(ctx context.Context, domain string) (providers.DnsRecordList, error) {
+	list, err := p.dnsProvider.GetDomainRecords(ctx, domain)
+	return list, maskAny(err)
+}
+
+func (p *recordingDnsProvider) CreateDnsRecord(ctx context.Context, domain, recordType, name, data string) error {
+	p.plan.Add(KindDns, name, "Create %s record -> %s", recordType, data)
+	return nil
+}
+
+func (p *recordingDnsProvider) DeleteDnsRecord(ctx context.Context, domain, recordType, name, data string) error {
+	if data == "" {
+		p.plan.Add(KindDns, name, "Delete all %s records", recordType)
+	} else {
+		p.plan.Add(KindDns, name, "Delete %s record -> %s", recordType, data)
+	}
+	return nil
+}
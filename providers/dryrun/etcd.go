@@ -0,0 +1,122 @@
+// Copyright (c) 2016 Pulcy.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dryrun
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// remoteTransporter is implemented by SSH clients that can send HTTP requests from the remote host.
+type remoteTransporter interface {
+	RemoteTransport() (http.RoundTripper, error)
+}
+
+// recordingTransport passes read-only etcd API requests on to the real transport
+// and records all other requests in the plan.
+type recordingTransport struct {
+	plan      *Plan
+	host      string
+	transport http.RoundTripper
+}
+
+// RemoteTransport returns an HTTP transport that records all requests that change the etcd membership.
+func (s *recordingSSHClient) RemoteTransport() (http.RoundTripper, error) {
+	if s.client == nil {
+		return nil, maskAny(fmt.Errorf("cannot forward connections to planned instance %s", s.host))
+	}
+	transporter, ok := s.client.(remoteTransporter)
+	if !ok {
+		return nil, maskAny(fmt.Errorf("cannot forward connections to %s", s.host))
+	}
+	transport, err := transporter.RemoteTransport()
+	if err != nil {
+		return nil, maskAny(err)
+	}
+	return &recordingTransport{plan: s.plan, host: s.host, transport: transport}, nil
+}
+
+func (t *recordingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	path := req.URL.Path
+	if req.Method == "GET" || strings.HasSuffix(path, "/member/list") || strings.HasSuffix(path, "/maintenance/status") || strings.HasSuffix(path, "/kv/range") {
+		return t.transport.RoundTrip(req)
+	}
+
+	var body struct {
+		PeerURLs []string `json:"peerURLs"`
+		ID       string   `json:"ID"`
+	}
+	if req.Body != nil {
+		raw, err := ioutil.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, maskAny(err)
+		}
+		json.Unmarshal(raw, &body)
+	}
+	switch {
+	case req.Method == "POST" && (strings.HasSuffix(path, "/v2/members") || strings.HasSuffix(path, "/member/add")):
+		t.plan.Add(KindEtcd, t.host, "Add member %s", strings.Join(body.PeerURLs, ","))
+		// Answer with a member in both the v2 & v3 format
+		member := map[string]interface{}{"peerURLs": body.PeerURLs}
+		return jsonResponse(req, http.StatusCreated, map[string]interface{}{"peerURLs": body.PeerURLs, "member": member}), nil
+	case req.Method == "DELETE" && strings.Contains(path, "/v2/members/"):
+		t.plan.Add(KindEtcd, t.host, "Remove member %s", path[strings.LastIndex(path, "/")+1:])
+		return jsonResponse(req, http.StatusNoContent, nil), nil
+	case req.Method == "POST" && strings.HasSuffix(path, "/member/remove"):
+		id := body.ID
+		if n, err := strconv.ParseUint(id, 10, 64); err == nil {
+			id = strconv.FormatUint(n, 16)
+		}
+		t.plan.Add(KindEtcd, t.host, "Remove member %s", id)
+		return jsonResponse(req, http.StatusOK, map[string]interface{}{}), nil
+	default:
+		t.plan.Add(KindEtcd, t.host, "%s %s", req.Method, path)
+		return jsonResponse(req, http.StatusOK, map[string]interface{}{}), nil
+	}
+}
+
+// CloseIdleConnections closes the idle connections of the real transport.
+func (t *recordingTransport) CloseIdleConnections() {
+	if closer, ok := t.transport.(interface {
+		CloseIdleConnections()
+	}); ok {
+		closer.CloseIdleConnections()
+	}
+}
+
+// jsonResponse creates a response to the given request with the given JSON encoded body.
+func jsonResponse(req *http.Request, statusCode int, body interface{}) *http.Response {
+	var raw []byte
+	if body != nil {
+		raw, _ = json.Marshal(body)
+	}
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", statusCode, http.StatusText(statusCode)),
+		StatusCode:    statusCode,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        http.Header{"Content-Type": []string{"application/json"}},
+		Body:          ioutil.NopCloser(bytes.NewReader(raw)),
+		ContentLength: int64(len(raw)),
+		Request:       req,
+	}
+}
@@ -0,0 +1,205 @@
+// Copyright (c) 2016 Pulcy.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package dryrun contains recording implementations of the cloud, DNS & vault providers
+// and of the SSH client. They record every mutating operation in a Plan instead of
+// performing it, while read-only operations are passed on to the real implementations.
+package dryrun
+
+import (
+	"crypto/md5"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+
+	"github.com/ryanuber/columnize"
+
+	"github.com/pulcy/quark/providers"
+)
+
+const (
+	// Kinds of steps
+	KindServer = "server"
+	KindDns    = "dns"
+	KindVault  = "vault"
+	KindEtcd   = "etcd"
+	KindFile   = "file"
+	KindSSH    = "ssh"
+	KindState  = "state"
+
+	// OS release reported by instances created during the dry run.
+	// It is assumed to be up to date, so no OS update is planned for new instances.
+	assumedOSRelease = "9999.0.0"
+)
+
+// Step is a single operation that would have been performed.
+type Step struct {
+	Kind        string // Kind of operation (see Kind... constants)
+	Target      string // Server, DNS name or machine the operation applies to
+	Description string
+}
+
+// String returns a human readable representation of the given step.
+func (s Step) String() string {
+	return fmt.Sprintf("%s %s: %s", s.Kind, s.Target, s.Description)
+}
+
+// Plan is an ordered list of operations recorded during a dry run.
+type Plan struct {
+	mutex    sync.Mutex
+	steps    []Step
+	planned  []*plannedInstance
+	deleted  map[string]bool
+	lastAddr int
+}
+
+// plannedInstance is an instance that would have been created during the dry run.
+type plannedInstance struct {
+	Instance providers.ClusterInstance
+	Info     providers.ClusterInfo
+	files    map[string]string
+}
+
+// NewPlan creates a new empty plan.
+func NewPlan() *Plan {
+	return &Plan{
+		deleted: make(map[string]bool),
+	}
+}
+
+// Add records an operation.
+func (p *Plan) Add(kind, target, format string, args ...interface{}) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	p.steps = append(p.steps, Step{
+		Kind:        kind,
+		Target:      target,
+		Description: fmt.Sprintf(format, args...),
+	})
+}
+
+// Steps returns a copy of all recorded operations, in order.
+func (p *Plan) Steps() []Step {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	return append([]Step(nil), p.steps...)
+}
+
+// Print writes a human readable representation of the plan to the given writer.
+func (p *Plan) Print(w io.Writer) {
+	steps := p.Steps()
+	if len(steps) == 0 {
+		fmt.Fprintln(w, "Dry run, nothing would be changed.")
+		return
+	}
+	fmt.Fprintln(w, "Dry run, nothing has been changed. Planned operations:")
+	lines := []string{"# | Kind | Target | Operation"}
+	for i, s := range steps {
+		lines = append(lines, fmt.Sprintf("%d | %s | %s | %s", i+1, s.Kind, s.Target, strings.Replace(s.Description, "|", "/", -1)))
+	}
+	fmt.Fprintln(w, columnize.SimpleFormat(lines))
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	if len(p.planned) > 0 {
+		fmt.Fprintln(w, "New instances are shown with placeholder addresses from 192.0.2.0/24.")
+	}
+}
+
+// addInstance registers a new instance that would have been created.
+// It returns the instance with placeholder addresses filled in.
+func (p *Plan) addInstance(info providers.ClusterInfo, options providers.CreateInstanceOptions) providers.ClusterInstance {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	p.lastAddr++
+	ip := fmt.Sprintf("192.0.2.%d", p.lastAddr)
+	etcdProxy := options.EtcdProxy
+	instance := providers.ClusterInstance{
+		Name:      options.InstanceName,
+		ClusterIP: ip,
+		PrivateIP: ip,
+		EtcdProxy: &etcdProxy,
+		OS:        providers.OSNameCoreOS,
+	}
+	if options.TincIpv4 != "" {
+		instance.ClusterIP = options.TincIpv4
+	}
+	if !options.NoPublicIPv4 {
+		instance.LoadBalancerIPv4 = ip
+	}
+	p.planned = append(p.planned, &plannedInstance{
+		Instance: instance,
+		Info:     info,
+		files: map[string]string{
+			"/etc/machine-id":       fmt.Sprintf("%x", md5.Sum([]byte(instance.Name))),
+			"/etc/lsb-release":      "DISTRIB_ID=CoreOS\nDISTRIB_RELEASE=" + assumedOSRelease,
+			"/etc/pulcy/cluster-id": options.ClusterInfo.ID,
+		},
+	})
+	return instance
+}
+
+// markDeleted records that the instance with given name would have been deleted.
+func (p *Plan) markDeleted(name string) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	p.deleted[name] = true
+}
+
+// isDeleted returns true if the instance with given name would have been deleted.
+func (p *Plan) isDeleted(name string) bool {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	return p.deleted[name]
+}
+
+// plannedInstances returns all planned (not deleted) instances of the given cluster.
+func (p *Plan) plannedInstances(info providers.ClusterInfo) providers.ClusterInstanceList {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	var result providers.ClusterInstanceList
+	for _, pi := range p.planned {
+		if !p.deleted[pi.Instance.Name] && pi.Info.Name == info.Name && pi.Info.Domain == info.Domain {
+			result = append(result, pi.Instance)
+		}
+	}
+	return result
+}
+
+// plannedInstanceByHost returns the planned instance that is reachable on the given host.
+func (p *Plan) plannedInstanceByHost(host string) *plannedInstance {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	for _, pi := range p.planned {
+		if pi.Instance.String() == host {
+			return pi
+		}
+	}
+	return nil
+}
+
+// readFile returns the content of a file on a planned instance.
+func (p *Plan) readFile(pi *plannedInstance, path string) (string, bool) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	content, ok := pi.files[path]
+	return content, ok
+}
+
+// writeFile stores the content of a file on a planned instance.
+func (p *Plan) writeFile(pi *plannedInstance, path, content string) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	pi.files[path] = content
+}
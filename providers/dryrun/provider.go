@@ -0,0 +1,211 @@
+// Copyright (c) 2016 Pulcy.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dryrun
+
+import (
+	"context"
+
+	"github.com/op/go-logging"
+
+	"github.com/pulcy/quark/providers"
+)
+
+// recordingProvider implements providers.CloudProvider.
+// Read-only operations are passed on to the wrapped provider,
+// all other operations are recorded in the plan.
+type recordingProvider struct {
+	Logger   *logging.Logger
+	plan     *Plan
+	provider providers.CloudProvider
+}
+
+// NewProvider creates a cloud provider that records all mutating operations in the given plan
+// and passes all read-only operations on to the given provider.
+func NewProvider(logger *logging.Logger, plan *Plan, provider providers.CloudProvider) providers.CloudProvider {
+	return &recordingProvider{
+		Logger:   logger,
+		plan:     plan,
+		provider: provider,
+	}
+}
+
+func (p *recordingProvider) GetRegions(ctx context.Context) (providers.RegionList, error) {
+	list, err := p.provider.GetRegions(ctx)
+	return list, maskAny(err)
+}
+
+func (p *recordingProvider) GetImages(ctx context.Context) (providers.ImageList, error) {
+	list, err := p.provider.GetImages(ctx)
+	return list, maskAny(err)
+}
+
+func (p *recordingProvider) GetKeys(ctx context.Context) (providers.SSHKeyList, error) {
+	list, err := p.provider.GetKeys(ctx)
+	return list, maskAny(err)
+}
+
+func (p *recordingProvider) GetInstanceTypes(ctx context.Context) (providers.InstanceTypeList, error) {
+	list, err := p.provider.GetInstanceTypes(ctx)
+	return list, maskAny(err)
+}
+
+func (p *recordingProvider) GetDomainRecords(ctx context.Context, domain string) (providers.DnsRecordList, error) {
+	list, err := p.provider.GetDomainRecords(ctx, domain)
+	return list, maskAny(err)
+}
+
+func (p *recordingProvider) GetClusterTags(ctx context.Context, info providers.ClusterInfo) ([]string, error) {
+	tags, err := p.provider.GetClusterTags(ctx, info)
+	return tags, maskAny(err)
+}
+
+func (p *recordingProvider) AddClusterTag(ctx context.Context, info providers.ClusterInfo, tag string) error {
+	p.plan.Add(KindServer, info.String(), "Add tag %s", tag)
+	return nil
+}
+
+func (p *recordingProvider) RemoveClusterTag(ctx context.Context, info providers.ClusterInfo, tag string) error {
+	p.plan.Add(KindServer, info.String(), "Remove tag %s", tag)
+	return nil
+}
+
+// Apply defaults for the given options
+func (p *recordingProvider) ClusterDefaults(options providers.ClusterInfo) providers.ClusterInfo {
+	return p.provider.ClusterDefaults(options)
+}
+
+// Apply defaults for the given options
+func (p *recordingProvider) CreateInstanceDefaults(options providers.CreateInstanceOptions) providers.CreateInstanceOptions {
+	return p.provider.CreateInstanceDefaults(options)
+}
+
+// Apply defaults for the given options
+func (p *recordingProvider) CreateClusterDefaults(options providers.CreateClusterOptions) providers.CreateClusterOptions {
+	return p.provider.CreateClusterDefaults(options)
+}
+
+// Get names of instances of a cluster.
+// The result includes instances created during the dry run and excludes instances deleted during the dry run.
+func (p *recordingProvider) GetInstances(ctx context.Context, info providers.ClusterInfo) (providers.ClusterInstanceList, error) {
+	instances, err := p.provider.GetInstances(ctx, info)
+	if err != nil {
+		return nil, maskAny(err)
+	}
+	var result providers.ClusterInstanceList
+	for _, i := range instances {
+		if !p.plan.isDeleted(i.Name) {
+			result = append(result, i)
+		}
+	}
+	result = append(result, p.plan.plannedInstances(info)...)
+	return result, nil
+}
+
+// Create a machine instance
+func (p *recordingProvider) CreateInstance(ctx context.Context, log *logging.Logger, options providers.CreateInstanceOptions, dnsProvider providers.DnsProvider) (providers.ClusterInstance, error) {
+	instance := p.plan.addInstance(options.ClusterInfo, options)
+	p.plan.Add(KindServer, instance.Name, "Create server (region %s, type %s, image %s, roles %s)", options.RegionID, options.TypeID, options.ImageID, options.Roles())
+	if err := providers.RegisterInstance(ctx, log, dnsProvider, options, instance.Name, options.RegisterInstance, options.RoleLoadBalancer, options.RoleLoadBalancer, instance.LoadBalancerIPv4, "", instance.PrivateIP); err != nil {
+		return providers.ClusterInstance{}, maskAny(err)
+	}
+	return instance, nil
+}
+
+// Create an entire cluster.
+// This follows the way most providers create a cluster: create all instances, then set them up.
+func (p *recordingProvider) CreateCluster(ctx context.Context, log *logging.Logger, options providers.CreateClusterOptions, dnsProvider providers.DnsProvider) error {
+	type instanceData struct {
+		options  providers.CreateInstanceOptions
+		instance providers.ClusterInstance
+	}
+	var instances []instanceData
+	var instanceList providers.ClusterInstanceList
+	for i := 1; i <= options.InstanceCount; i++ {
+		isCore := true
+		isLB := true
+		instanceOptions, err := options.NewCreateInstanceOptions(isCore, isLB, i)
+		if err != nil {
+			return maskAny(err)
+		}
+		instance, err := p.CreateInstance(ctx, log, instanceOptions, dnsProvider)
+		if err != nil {
+			return maskAny(err)
+		}
+		instances = append(instances, instanceData{instanceOptions, instance})
+		instanceList = append(instanceList, instance)
+	}
+	clusterMembers, err := instanceList.AsClusterMemberList(ctx, log, nil)
+	if err != nil {
+		return maskAny(err)
+	}
+	for i, data := range instances {
+		iso := providers.InitialSetupOptions{
+			ClusterMembers: clusterMembers,
+			FleetMetadata:  data.options.CreateFleetMetadata(i + 1),
+		}
+		if err := data.instance.InitialSetup(ctx, log, data.options, iso, p); err != nil {
+			return maskAny(err)
+		}
+	}
+	return nil
+}
+
+// Remove all instances of a cluster
+func (p *recordingProvider) DeleteCluster(ctx context.Context, info providers.ClusterInfo, dnsProvider providers.DnsProvider) error {
+	instances, err := p.GetInstances(ctx, info)
+	if err != nil {
+		return maskAny(err)
+	}
+	for _, i := range instances {
+		if err := p.deleteInstance(ctx, i, info.Domain, dnsProvider); err != nil {
+			return maskAny(err)
+		}
+	}
+	return nil
+}
+
+// Remove a single instance of a cluster
+func (p *recordingProvider) DeleteInstance(ctx context.Context, info providers.ClusterInstanceInfo, dnsProvider providers.DnsProvider) error {
+	instances, err := p.GetInstances(ctx, info.ClusterInfo)
+	if err != nil {
+		return maskAny(err)
+	}
+	instance, err := instances.InstanceByName(info.String())
+	if err != nil {
+		return maskAny(err)
+	}
+	return maskAny(p.deleteInstance(ctx, instance, info.Domain, dnsProvider))
+}
+
+func (p *recordingProvider) deleteInstance(ctx context.Context, instance providers.ClusterInstance, domain string, dnsProvider providers.DnsProvider) error {
+	if err := providers.UnRegisterInstance(ctx, p.Logger, dnsProvider, instance, domain); err != nil {
+		return maskAny(err)
+	}
+	p.plan.Add(KindServer, instance.Name, "Delete server")
+	p.plan.markDeleted(instance.Name)
+	return nil
+}
+
+// Perform a reboot of the given instance
+func (p *recordingProvider) RebootInstance(ctx context.Context, instance providers.ClusterInstance) error {
+	p.plan.Add(KindServer, instance.Name, "Reboot server")
+	return nil
+}
+
+// Update the instances of the cluster to all new services & formats
+func (p *recordingProvider) UpdateCluster(ctx context.Context, log *logging.Logger, info providers.ClusterInfo, dnsProvider providers.DnsProvider) error {
+	p.plan.Add(KindServer, info.String(), "Update all instances to new services & formats")
+	return nil
+}
@@ -0,0 +1,196 @@
+// Copyright (c) 2016 Pulcy.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dryrun
+
+import (
+	"context"
+	"strings"
+
+	"github.com/juju/errgo"
+	"github.com/op/go-logging"
+
+	"github.com/pulcy/quark/providers"
+)
+
+var (
+	// Commands (without sudo or `sh -c` wrapper) that do not change anything on an instance.
+	// These are run on existing instances during a dry run, their output is never shown.
+	readOnlyPrefixes = []string{
+		"cat ",
+		"etcdctl cluster-health",
+		"etcdctl member list",
+		"fleetctl list-machines",
+		"fleetctl list-units",
+		"kubectl get ",
+		"ping ",
+		"stat ",
+		"systemctl cat ",
+		"systemctl is-active ",
+	}
+	// Files that can be read from existing instances during a dry run.
+	// Reading other files (e.g. private keys) is refused.
+	readableFilePrefixes = []string{
+		"/etc/lsb-release",
+		"/etc/machine-id",
+		"/etc/pulcy/",
+		"/etc/tinc/",
+	}
+	// Files in readableFilePrefixes that can not be read during a dry run.
+	unreadableFilePrefixes = []string{
+		"/etc/pulcy/vault/",
+	}
+)
+
+// recordingSSHClient implements providers.SSHClient.
+// Read-only commands are passed on to the real client (for existing instances)
+// or answered from memory (for instances created during the dry run).
+// All other commands are recorded in the plan.
+type recordingSSHClient struct {
+	plan     *Plan
+	host     string              // Name used for the instance in the plan
+	client   providers.SSHClient // Real client, nil for planned instances
+	instance *plannedInstance    // Planned instance, nil for existing instances
+}
+
+// NewSSHDialer creates an SSH dialer that records all mutating commands in the given plan.
+// Connections to existing instances are opened using the given dialer.
+func NewSSHDialer(plan *Plan, dialer providers.SSHDialer) providers.SSHDialer {
+	return func(options providers.SSHOptions) (providers.SSHClient, error) {
+		if pi := plan.plannedInstanceByHost(options.Host); pi != nil {
+			return &recordingSSHClient{plan: plan, host: pi.Instance.Name, instance: pi}, nil
+		}
+		client, err := dialer(options)
+		if err != nil {
+			return nil, maskAny(err)
+		}
+		return &recordingSSHClient{plan: plan, host: options.Host, client: client}, nil
+	}
+}
+
+func (s *recordingSSHClient) Close() error {
+	if s.client != nil {
+		return maskAny(s.client.Close())
+	}
+	return nil
+}
+
+func (s *recordingSSHClient) Run(ctx context.Context, log *logging.Logger, command, stdin string, quiet bool) (string, error) {
+	cmd := unwrapCommand(command)
+	if isReadOnly(cmd) {
+		if s.client != nil {
+			if !isReadableFile(cmd) {
+				return "", maskAny(errgo.WithCausef(nil, NotReadableError, "`%s` is not allowed on %s during a dry run", cmd, s.host))
+			}
+			out, err := s.client.Run(ctx, log, command, stdin, quiet)
+			return out, maskAny(err)
+		}
+		return s.runPlanned(cmd, command)
+	}
+
+	args := strings.Fields(cmd)
+	if target, ok := writtenFile(cmd); ok {
+		// Atomic file write (see InstanceConnection.WriteFile)
+		args = []string{"tee", target}
+	}
+	switch {
+	case len(args) == 2 && args[0] == "tee":
+		s.plan.Add(KindFile, s.host, "Write %s (%d bytes)", args[1], len(stdin))
+		if s.instance != nil {
+			s.plan.writeFile(s.instance, args[1], stdin)
+		}
+	case len(args) >= 3 && args[0] == "etcdctl" && args[1] == "member":
+		s.plan.Add(KindEtcd, s.host, "%s member %s", strings.Title(args[2]), strings.Join(args[3:], " "))
+	default:
+		s.plan.Add(KindSSH, s.host, "Run `%s`", providers.RedactCommand(command))
+	}
+	return "", nil
+}
+
+// runPlanned answers a read-only command for an instance created during the dry run.
+func (s *recordingSSHClient) runPlanned(cmd, command string) (string, error) {
+	args := strings.Fields(cmd)
+	if len(args) == 2 && args[0] == "cat" {
+		if content, ok := s.plan.readFile(s.instance, args[1]); ok {
+			return strings.TrimSuffix(content, "\n"), nil
+		}
+		if strings.Contains(command, "|| echo") {
+			return "", nil
+		}
+		return "", maskAny(errgo.WithCausef(nil, NotFoundError, "%s does not exist on %s", args[1], s.host))
+	}
+	return "", nil
+}
+
+// unwrapCommand removes `sh -c '...'`, `|| echo ""` fallbacks and `sudo` from the given command.
+func unwrapCommand(command string) string {
+	cmd := command
+	if strings.HasPrefix(cmd, "sh -c '") && strings.HasSuffix(cmd, "'") {
+		cmd = cmd[len("sh -c '") : len(cmd)-1]
+	}
+	if idx := strings.Index(cmd, " || "); idx >= 0 {
+		cmd = cmd[:idx]
+	}
+	cmd = strings.TrimPrefix(cmd, "sudo ")
+	if strings.HasPrefix(cmd, "/usr/bin/") {
+		cmd = cmd[len("/usr/bin/"):]
+	}
+	return strings.TrimSpace(cmd)
+}
+
+// writtenFile returns the path of the file written by the given (unwrapped) command,
+// if it writes a temporary file with `tee` and moves it into place with `mv -f`.
+func writtenFile(cmd string) (string, bool) {
+	tmpPath := ""
+	for _, part := range strings.Split(cmd, " && ") {
+		args := strings.Fields(strings.TrimPrefix(strings.TrimSpace(part), "sudo "))
+		switch {
+		case len(args) == 2 && args[0] == "tee":
+			tmpPath = args[1]
+		case len(args) == 4 && args[0] == "mv" && args[1] == "-f" && tmpPath != "" && args[2] == tmpPath:
+			return args[3], true
+		}
+	}
+	return "", false
+}
+
+// isReadOnly returns true if the given (unwrapped) command does not change anything.
+func isReadOnly(cmd string) bool {
+	for _, prefix := range readOnlyPrefixes {
+		if strings.HasPrefix(cmd, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// isReadableFile returns false if the given (unwrapped) command reads a file that
+// must not be read from an existing instance during a dry run.
+func isReadableFile(cmd string) bool {
+	args := strings.Fields(cmd)
+	if len(args) != 2 || args[0] != "cat" {
+		return true
+	}
+	for _, prefix := range unreadableFilePrefixes {
+		if strings.HasPrefix(args[1], prefix) {
+			return false
+		}
+	}
+	for _, prefix := range readableFilePrefixes {
+		if strings.HasPrefix(args[1], prefix) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,107 @@
+// Copyright (c) 2016 Pulcy.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dryrun
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/juju/errgo"
+	"github.com/op/go-logging"
+
+	"github.com/pulcy/quark/providers"
+)
+
+// stubSSHClient records the commands that are passed on to an existing instance.
+type stubSSHClient struct {
+	commands []string
+}
+
+func (c *stubSSHClient) Close() error { return nil }
+
+func (c *stubSSHClient) Run(ctx context.Context, log *logging.Logger, command, stdin string, quiet bool) (string, error) {
+	c.commands = append(c.commands, command)
+	return "out", nil
+}
+
+func newTestClient() (*recordingSSHClient, *stubSSHClient) {
+	stub := &stubSSHClient{}
+	plan := NewPlan()
+	dial := NewSSHDialer(plan, func(options providers.SSHOptions) (providers.SSHClient, error) {
+		return stub, nil
+	})
+	client, err := dial(providers.SSHOptions{Host: "10.0.0.1"})
+	if err != nil {
+		panic(err)
+	}
+	return client.(*recordingSSHClient), stub
+}
+
+func TestRunRedactsSecrets(t *testing.T) {
+	client, stub := newTestClient()
+	log := logging.MustGetLogger("test")
+	if _, err := client.Run(context.Background(), log, "sudo /home/core/bin/gluon setup --private-registry-password=s3cret", "", false); err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	if len(stub.commands) != 0 {
+		t.Errorf("Expected no commands on the instance, got %v", stub.commands)
+	}
+	steps := client.plan.Steps()
+	if len(steps) != 1 {
+		t.Fatalf("Expected 1 step, got %v", steps)
+	}
+	if strings.Contains(steps[0].Description, "s3cret") || !strings.Contains(steps[0].Description, "--private-registry-password=<redacted>") {
+		t.Errorf("Expected redacted password, got %q", steps[0].Description)
+	}
+}
+
+func TestRunReadOnly(t *testing.T) {
+	tests := []struct {
+		Command string
+		Allowed bool
+	}{
+		{"sudo cat /etc/pulcy/cluster-id", true},
+		{"sh -c 'sudo cat /etc/pulcy/roles || echo \"\"'", true},
+		{"cat /etc/machine-id", true},
+		{"cat /etc/tinc/pulcy/hosts/abc", true},
+		{"sh -c 'sudo systemctl cat gluon.service || echo \"\"'", true},
+		{"kubectl get nodes", true},
+		{"sudo cat /etc/pulcy/vault/key.pem", false},
+		{"sudo cat /home/core/.ssh/id_rsa", false},
+	}
+	for _, test := range tests {
+		client, stub := newTestClient()
+		log := logging.MustGetLogger("test")
+		_, err := client.Run(context.Background(), log, test.Command, "", false)
+		if test.Allowed {
+			if err != nil {
+				t.Errorf("%s: expected success, got %v", test.Command, err)
+			} else if len(stub.commands) != 1 || stub.commands[0] != test.Command {
+				t.Errorf("%s: expected command to run on the instance, got %v", test.Command, stub.commands)
+			}
+		} else {
+			if errgo.Cause(err) != NotReadableError {
+				t.Errorf("%s: expected NotReadableError, got %v", test.Command, err)
+			}
+			if len(stub.commands) != 0 {
+				t.Errorf("%s: expected no commands on the instance, got %v", test.Command, stub.commands)
+			}
+		}
+		if steps := client.plan.Steps(); len(steps) != 0 {
+			t.Errorf("%s: expected no steps, got %v", test.Command, steps)
+		}
+	}
+}
@@ -0,0 +1,41 @@
+// Copyright (c) 2016 Pulcy.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dryrun
+
+import (
+	"context"
+
+	"github.com/pulcy/quark/providers"
+)
+
+// recordingVaultProvider implements providers.VaultProvider by recording all changes in a plan.
+type recordingVaultProvider struct {
+	plan *Plan
+}
+
+// NewVaultProvider creates a vault provider that records all changes in the given plan.
+func NewVaultProvider(plan *Plan) providers.VaultProvider {
+	return &recordingVaultProvider{plan: plan}
+}
+
+func (p *recordingVaultProvider) AddMachine(ctx context.Context, clusterId, machineId string) error {
+	p.plan.Add(KindVault, machineId, "Add machine to cluster %s", clusterId)
+	return nil
+}
+
+func (p *recordingVaultProvider) RemoveMachine(ctx context.Context, machineId string) error {
+	p.plan.Add(KindVault, machineId, "Remove machine")
+	return nil
+}
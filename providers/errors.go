@@ -21,4 +21,20 @@ import (
 var (
 	maskAny       = errgo.MaskFunc(errgo.Any)
 	NotFoundError = errgo.New("not-found")
+
+	AlreadyExistsError = errgo.New("already exists")
+	ClusterLockedError = errgo.New("cluster locked")
+
+	InvalidArgumentError = errgo.New("invalid argument")
+	UnknownProviderError = errgo.New("unknown provider")
+	UnknownRolesError    = errgo.New("unknown roles")
+
+	OSUpdateNotSupportedError = errgo.New("OS update not supported")
+	TagsNotSupportedError     = errgo.New("tags not supported")
+
+	UnknownHostKeyError  = errgo.New("unknown host key")
+	HostKeyMismatchError = errgo.New("host key mismatch")
+
+	NoSSHAuthError     = errgo.New("no SSH authentication method")
+	SSHConnectionError = errgo.New("SSH connection failed")
 )
@@ -0,0 +1,710 @@
+// Copyright (c) 2016 Pulcy.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package providers
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/coreos/go-semver/semver"
+	"github.com/juju/errgo"
+	"github.com/op/go-logging"
+)
+
+const (
+	// etcdClientURL is the address of the etcd client API as seen from an instance.
+	etcdClientURL = "http://127.0.0.1:2379"
+	// etcdPeerPort is the port etcd members use to talk to each other.
+	etcdPeerPort = 2380
+	// etcdRequestTimeout is the maximum duration of a single etcd API request.
+	etcdRequestTimeout = time.Second * 15
+
+	// Error codes of the etcd v2 keys API
+	etcdErrorTestFailed = 101
+	etcdErrorKeyExists  = 105
+)
+
+// EtcdMember is a single member of an etcd cluster.
+type EtcdMember struct {
+	ID         string   `json:"id"` // Hexadecimal member ID (as shown by etcdctl)
+	Name       string   `json:"name"`
+	PeerURLs   []string `json:"peerURLs"`
+	ClientURLs []string `json:"clientURLs"`
+}
+
+// IsStarted returns true if the member has joined the cluster.
+// Members that have been added but never started have no name.
+func (m EtcdMember) IsStarted() bool {
+	return m.Name != ""
+}
+
+// HasPeerIP returns true if one of the peer URLs of the member uses exactly the given IP address.
+func (m EtcdMember) HasPeerIP(ip string) bool {
+	for _, peerURL := range m.PeerURLs {
+		u, err := url.Parse(peerURL)
+		if err != nil {
+			continue
+		}
+		host := u.Host
+		if h, _, err := net.SplitHostPort(u.Host); err == nil {
+			host = h
+		}
+		if host == ip {
+			return true
+		}
+	}
+	return false
+}
+
+// EtcdMemberList is a list of etcd members.
+type EtcdMemberList []EtcdMember
+
+// FindByPeerIP returns the member that uses the given IP address in its peer URLs.
+func (l EtcdMemberList) FindByPeerIP(ip string) (EtcdMember, bool) {
+	for _, m := range l {
+		if m.HasPeerIP(ip) {
+			return m, true
+		}
+	}
+	return EtcdMember{}, false
+}
+
+// EtcdClient manages the membership of an etcd cluster through the etcd client API of an instance.
+// Requests are sent through the SSH connection to the instance.
+type EtcdClient interface {
+	io.Closer
+
+	// APIVersion returns the version of the etcd API that is used ("v2" or "v3").
+	APIVersion() string
+	// Members returns all members of the cluster.
+	Members() (EtcdMemberList, error)
+	// AddMember adds a new member with given peer URL to the cluster.
+	AddMember(peerURL string) (EtcdMember, error)
+	// RemoveMember removes the member with given ID from the cluster.
+	RemoveMember(id string) error
+	// Leader returns the current leader of the cluster.
+	Leader() (EtcdMember, error)
+	// IsHealthy returns true if the given member reports to be healthy.
+	IsHealthy(member EtcdMember) (bool, error)
+
+	// CreateKey creates a key with given value that is removed after the given TTL.
+	// It returns AlreadyExistsError if the key already exists.
+	CreateKey(key, value string, ttl time.Duration) error
+	// SetKey sets the value of the given key, creating it when it does not exist.
+	SetKey(key, value string) error
+	// UpdateKey replaces the value of the given key if it has the given previous value,
+	// and removes it after the given TTL.
+	// It returns NotFoundError if the key does not exist or has a different value.
+	UpdateKey(key, prevValue, value string, ttl time.Duration) error
+	// GetKey returns the value of the given key or NotFoundError if it does not exist.
+	GetKey(key string) (string, error)
+	// DeleteKey removes the given key if it has the given value (or any value if value is empty).
+	// It returns NotFoundError if the key does not exist or has a different value.
+	DeleteKey(key, value string) error
+}
+
+// remoteTransporter is implemented by SSH clients that can send HTTP requests from the remote host.
+type remoteTransporter interface {
+	// RemoteTransport returns an HTTP transport that opens all connections from the remote host.
+	RemoteTransport() (http.RoundTripper, error)
+}
+
+// EtcdClient opens an etcd client that uses the etcd client API of the instance.
+// The v3 API is used when the cluster runs etcd 3, otherwise the v2 API.
+// Make sure to close the client when done.
+func (i ClusterInstance) EtcdClient(ctx context.Context, log *logging.Logger) (EtcdClient, error) {
+	s, err := i.Connect()
+	if err != nil {
+		return nil, maskAny(err)
+	}
+	client, err := s.EtcdClient(ctx, log)
+	if err != nil {
+		s.Close()
+		return nil, maskAny(err)
+	}
+	return &closingEtcdClient{EtcdClient: client, conn: s}, nil
+}
+
+// AddEtcdMember adds a member with given cluster IP to ETCD using the etcd API of the instance.
+func (i ClusterInstance) AddEtcdMember(ctx context.Context, log *logging.Logger, name, clusterIP string) error {
+	s, err := i.Connect()
+	if err != nil {
+		return maskAny(err)
+	}
+	defer s.Close()
+	return maskAny(s.AddEtcdMember(ctx, log, name, clusterIP))
+}
+
+// RemoveEtcdMember removes the member with given cluster IP from ETCD using the etcd API of the instance.
+func (i ClusterInstance) RemoveEtcdMember(ctx context.Context, log *logging.Logger, name, clusterIP string) error {
+	s, err := i.Connect()
+	if err != nil {
+		return maskAny(err)
+	}
+	defer s.Close()
+	return maskAny(s.RemoveEtcdMember(ctx, log, name, clusterIP))
+}
+
+// closingEtcdClient closes the SSH connection of an etcd client.
+type closingEtcdClient struct {
+	EtcdClient
+	conn io.Closer
+}
+
+func (c *closingEtcdClient) Close() error {
+	c.EtcdClient.Close()
+	return maskAny(c.conn.Close())
+}
+
+// newEtcdClient creates an etcd client that sends its requests from the remote host of the given SSH client.
+func newEtcdClient(ctx context.Context, log *logging.Logger, client SSHClient, host string) (EtcdClient, error) {
+	transporter, ok := client.(remoteTransporter)
+	if !ok {
+		return nil, maskAny(fmt.Errorf("cannot forward etcd requests to %s", host))
+	}
+	transport, err := transporter.RemoteTransport()
+	if err != nil {
+		return nil, maskAny(err)
+	}
+	api := &etcdAPI{
+		ctx:    ctx,
+		log:    log,
+		host:   host,
+		client: &http.Client{Transport: transport, Timeout: etcdRequestTimeout},
+	}
+	version, err := api.clusterVersion()
+	if err != nil {
+		api.Close()
+		return nil, maskAny(err)
+	}
+	log.Debugf("etcd cluster on %s runs version %s", host, version)
+	if version.Major < 3 {
+		return &etcdV2Client{api}, nil
+	}
+	return &etcdV3Client{etcdAPI: api, prefix: etcdV3Prefix(version)}, nil
+}
+
+// etcdV3Prefix returns the path prefix of the v3 JSON gateway for the given cluster version.
+func etcdV3Prefix(version semver.Version) string {
+	switch {
+	case version.Major == 3 && version.Minor < 3:
+		return "/v3alpha"
+	case version.Major == 3 && version.Minor == 3:
+		return "/v3beta"
+	default:
+		return "/v3"
+	}
+}
+
+// etcdAPI sends JSON requests to the etcd client API.
+type etcdAPI struct {
+	ctx    context.Context // Cancels all requests
+	log    *logging.Logger
+	host   string
+	client *http.Client
+}
+
+func (a *etcdAPI) Close() error {
+	if t, ok := a.client.Transport.(interface {
+		CloseIdleConnections()
+	}); ok {
+		t.CloseIdleConnections()
+	}
+	return nil
+}
+
+// clusterVersion fetches the version of the etcd cluster.
+func (a *etcdAPI) clusterVersion() (semver.Version, error) {
+	var result struct {
+		Server  string `json:"etcdserver"`
+		Cluster string `json:"etcdcluster"`
+	}
+	if err := a.do("GET", etcdClientURL+"/version", nil, &result); err != nil {
+		return semver.Version{}, maskAny(err)
+	}
+	raw := result.Cluster
+	if raw == "" || raw == "not_decided" {
+		raw = result.Server
+	}
+	version, err := semver.NewVersion(raw)
+	if err != nil {
+		return semver.Version{}, maskAny(errgo.Notef(err, "invalid etcd version '%s'", raw))
+	}
+	return *version, nil
+}
+
+// isHealthy fetches the /health endpoint of the given client URL.
+func (a *etcdAPI) isHealthy(clientURL string) (bool, error) {
+	var result struct {
+		Health string `json:"health"`
+	}
+	if err := a.do("GET", strings.TrimSuffix(clientURL, "/")+"/health", nil, &result); err != nil {
+		return false, maskAny(err)
+	}
+	return result.Health == "true", nil
+}
+
+// IsHealthy returns true if the given member reports to be healthy.
+func (a *etcdAPI) IsHealthy(member EtcdMember) (bool, error) {
+	if len(member.ClientURLs) == 0 {
+		return false, nil
+	}
+	var lastErr error
+	for _, clientURL := range member.ClientURLs {
+		healthy, err := a.isHealthy(clientURL)
+		if err == nil {
+			return healthy, nil
+		}
+		lastErr = err
+	}
+	return false, maskAny(lastErr)
+}
+
+// do sends a request with given (JSON encoded) body and decodes the JSON response into result.
+func (a *etcdAPI) do(method, url string, body, result interface{}) error {
+	var reqBody io.Reader
+	if body != nil {
+		raw, err := json.Marshal(body)
+		if err != nil {
+			return maskAny(err)
+		}
+		reqBody = bytes.NewReader(raw)
+	}
+	return maskAny(a.send(method, url, "application/json", reqBody, result))
+}
+
+// doForm sends a request with given (form encoded) values and decodes the JSON response into result.
+func (a *etcdAPI) doForm(method, url string, values url.Values, result interface{}) error {
+	return maskAny(a.send(method, url, "application/x-www-form-urlencoded", strings.NewReader(values.Encode()), result))
+}
+
+// send sends a request with given body and decodes the JSON response into result.
+func (a *etcdAPI) send(method, url, contentType string, reqBody io.Reader, result interface{}) error {
+	req, err := http.NewRequest(method, url, reqBody)
+	if err != nil {
+		return maskAny(err)
+	}
+	req = req.WithContext(a.ctx)
+	if reqBody != nil {
+		req.Header.Set("Content-Type", contentType)
+	}
+	a.log.Debugf("etcd %s %s on %s", method, url, a.host)
+	resp, err := a.client.Do(req)
+	if err != nil {
+		return maskAny(errgo.Notef(err, "etcd request to %s on %s failed", url, a.host))
+	}
+	defer resp.Body.Close()
+	raw, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return maskAny(err)
+	}
+	if resp.StatusCode >= 300 {
+		var errResp struct {
+			Message   string `json:"message"`   // v2
+			ErrorCode int    `json:"errorCode"` // v2 keys API
+			Error     string `json:"error"`     // v3
+		}
+		json.Unmarshal(raw, &errResp)
+		msg := errResp.Message
+		if msg == "" {
+			msg = errResp.Error
+		}
+		if msg == "" {
+			msg = strings.TrimSpace(string(raw))
+		}
+		switch {
+		case errResp.ErrorCode == etcdErrorKeyExists:
+			return maskAny(errgo.WithCausef(nil, AlreadyExistsError, "%s %s: %s", method, url, msg))
+		case errResp.ErrorCode == etcdErrorTestFailed:
+			// The key does not have the expected value
+			return maskAny(errgo.WithCausef(nil, NotFoundError, "%s %s: %s", method, url, msg))
+		case resp.StatusCode == http.StatusNotFound || strings.Contains(msg, "member not found"):
+			return maskAny(errgo.WithCausef(nil, NotFoundError, "%s %s: %s", method, url, msg))
+		}
+		return maskAny(fmt.Errorf("%s %s returned status %d: %s", method, url, resp.StatusCode, msg))
+	}
+	if result != nil && len(raw) > 0 {
+		if err := json.Unmarshal(raw, result); err != nil {
+			return maskAny(errgo.Notef(err, "cannot decode response of %s %s", method, url))
+		}
+	}
+	return nil
+}
+
+// etcdV2Client uses the etcd v2 members API.
+type etcdV2Client struct {
+	*etcdAPI
+}
+
+func (c *etcdV2Client) APIVersion() string { return "v2" }
+
+func (c *etcdV2Client) Members() (EtcdMemberList, error) {
+	var result struct {
+		Members EtcdMemberList `json:"members"`
+	}
+	if err := c.do("GET", etcdClientURL+"/v2/members", nil, &result); err != nil {
+		return nil, maskAny(err)
+	}
+	return result.Members, nil
+}
+
+func (c *etcdV2Client) AddMember(peerURL string) (EtcdMember, error) {
+	var result EtcdMember
+	body := struct {
+		PeerURLs []string `json:"peerURLs"`
+	}{[]string{peerURL}}
+	if err := c.do("POST", etcdClientURL+"/v2/members", body, &result); err != nil {
+		return EtcdMember{}, maskAny(err)
+	}
+	return result, nil
+}
+
+func (c *etcdV2Client) RemoveMember(id string) error {
+	if err := c.do("DELETE", etcdClientURL+"/v2/members/"+id, nil, nil); err != nil {
+		return maskAny(err)
+	}
+	return nil
+}
+
+func (c *etcdV2Client) Leader() (EtcdMember, error) {
+	var result EtcdMember
+	if err := c.do("GET", etcdClientURL+"/v2/members/leader", nil, &result); err != nil {
+		return EtcdMember{}, maskAny(err)
+	}
+	return result, nil
+}
+
+func (c *etcdV2Client) CreateKey(key, value string, ttl time.Duration) error {
+	values := url.Values{}
+	values.Set("value", value)
+	values.Set("ttl", strconv.Itoa(int(ttl.Seconds())))
+	if err := c.doForm("PUT", c.keyURL(key)+"?prevExist=false", values, nil); err != nil {
+		return maskAny(err)
+	}
+	return nil
+}
+
+func (c *etcdV2Client) SetKey(key, value string) error {
+	values := url.Values{}
+	values.Set("value", value)
+	if err := c.doForm("PUT", c.keyURL(key), values, nil); err != nil {
+		return maskAny(err)
+	}
+	return nil
+}
+
+func (c *etcdV2Client) UpdateKey(key, prevValue, value string, ttl time.Duration) error {
+	values := url.Values{}
+	values.Set("value", value)
+	values.Set("ttl", strconv.Itoa(int(ttl.Seconds())))
+	if err := c.doForm("PUT", c.keyURL(key)+"?prevValue="+url.QueryEscape(prevValue), values, nil); err != nil {
+		return maskAny(err)
+	}
+	return nil
+}
+
+func (c *etcdV2Client) GetKey(key string) (string, error) {
+	var result struct {
+		Node struct {
+			Value string `json:"value"`
+		} `json:"node"`
+	}
+	if err := c.do("GET", c.keyURL(key), nil, &result); err != nil {
+		return "", maskAny(err)
+	}
+	return result.Node.Value, nil
+}
+
+func (c *etcdV2Client) DeleteKey(key, value string) error {
+	keyURL := c.keyURL(key)
+	if value != "" {
+		keyURL += "?prevValue=" + url.QueryEscape(value)
+	}
+	if err := c.do("DELETE", keyURL, nil, nil); err != nil {
+		return maskAny(err)
+	}
+	return nil
+}
+
+// keyURL returns the URL of the given key in the v2 keys API.
+func (c *etcdV2Client) keyURL(key string) string {
+	return etcdClientURL + "/v2/keys/" + strings.TrimPrefix(key, "/")
+}
+
+// etcdV3Client uses the cluster API of the etcd v3 JSON gateway.
+type etcdV3Client struct {
+	*etcdAPI
+	prefix string
+}
+
+// etcdV3Member is a member as encoded by the v3 JSON gateway (with decimal IDs).
+type etcdV3Member struct {
+	ID         string   `json:"ID"`
+	Name       string   `json:"name"`
+	PeerURLs   []string `json:"peerURLs"`
+	ClientURLs []string `json:"clientURLs"`
+}
+
+func (m etcdV3Member) member() EtcdMember {
+	id := m.ID
+	if n, err := strconv.ParseUint(m.ID, 10, 64); err == nil {
+		id = strconv.FormatUint(n, 16)
+	}
+	return EtcdMember{ID: id, Name: m.Name, PeerURLs: m.PeerURLs, ClientURLs: m.ClientURLs}
+}
+
+// v3ID converts a hexadecimal member ID into the decimal form used by the v3 JSON gateway.
+func v3ID(id string) (string, error) {
+	n, err := strconv.ParseUint(id, 16, 64)
+	if err != nil {
+		return "", maskAny(errgo.WithCausef(err, InvalidArgumentError, "invalid etcd member ID '%s'", id))
+	}
+	return strconv.FormatUint(n, 10), nil
+}
+
+func (c *etcdV3Client) APIVersion() string { return "v3" }
+
+func (c *etcdV3Client) Members() (EtcdMemberList, error) {
+	var result struct {
+		Members []etcdV3Member `json:"members"`
+	}
+	if err := c.do("POST", etcdClientURL+c.prefix+"/cluster/member/list", struct{}{}, &result); err != nil {
+		return nil, maskAny(err)
+	}
+	var list EtcdMemberList
+	for _, m := range result.Members {
+		list = append(list, m.member())
+	}
+	return list, nil
+}
+
+func (c *etcdV3Client) AddMember(peerURL string) (EtcdMember, error) {
+	var result struct {
+		Member etcdV3Member `json:"member"`
+	}
+	body := struct {
+		PeerURLs []string `json:"peerURLs"`
+	}{[]string{peerURL}}
+	if err := c.do("POST", etcdClientURL+c.prefix+"/cluster/member/add", body, &result); err != nil {
+		return EtcdMember{}, maskAny(err)
+	}
+	return result.Member.member(), nil
+}
+
+func (c *etcdV3Client) RemoveMember(id string) error {
+	decimalID, err := v3ID(id)
+	if err != nil {
+		return maskAny(err)
+	}
+	body := struct {
+		ID string `json:"ID"`
+	}{decimalID}
+	if err := c.do("POST", etcdClientURL+c.prefix+"/cluster/member/remove", body, nil); err != nil {
+		return maskAny(err)
+	}
+	return nil
+}
+
+func (c *etcdV3Client) Leader() (EtcdMember, error) {
+	var status struct {
+		Leader string `json:"leader"`
+	}
+	if err := c.do("POST", etcdClientURL+c.prefix+"/maintenance/status", struct{}{}, &status); err != nil {
+		return EtcdMember{}, maskAny(err)
+	}
+	members, err := c.Members()
+	if err != nil {
+		return EtcdMember{}, maskAny(err)
+	}
+	leaderID := etcdV3Member{ID: status.Leader}.member().ID
+	for _, m := range members {
+		if m.ID == leaderID {
+			return m, nil
+		}
+	}
+	return EtcdMember{}, maskAny(errgo.WithCausef(nil, NotFoundError, "leader %s is not a member", leaderID))
+}
+
+// etcdV3Compare is a comparison of a transaction, as encoded by the v3 JSON gateway.
+type etcdV3Compare struct {
+	Key            string `json:"key"`
+	Target         string `json:"target"`
+	Result         string `json:"result"`
+	CreateRevision string `json:"create_revision,omitempty"`
+	Value          string `json:"value,omitempty"`
+}
+
+// etcdV3Request is an operation of a transaction, as encoded by the v3 JSON gateway.
+type etcdV3Request struct {
+	Put         *etcdV3KeyValue `json:"request_put,omitempty"`
+	DeleteRange *etcdV3KeyValue `json:"request_delete_range,omitempty"`
+}
+
+// etcdV3KeyValue is a key (with value) as encoded by the v3 JSON gateway.
+type etcdV3KeyValue struct {
+	Key   string `json:"key"`
+	Value string `json:"value,omitempty"`
+	Lease string `json:"lease,omitempty"`
+}
+
+// txn runs a transaction that performs the given request when the given comparison succeeds.
+// It returns true if the comparison succeeded.
+func (c *etcdV3Client) txn(compare etcdV3Compare, success etcdV3Request) (bool, error) {
+	var result struct {
+		Succeeded bool `json:"succeeded"`
+	}
+	body := struct {
+		Compare []etcdV3Compare `json:"compare"`
+		Success []etcdV3Request `json:"success"`
+	}{[]etcdV3Compare{compare}, []etcdV3Request{success}}
+	if err := c.do("POST", etcdClientURL+c.prefix+"/kv/txn", body, &result); err != nil {
+		return false, maskAny(err)
+	}
+	return result.Succeeded, nil
+}
+
+func (c *etcdV3Client) CreateKey(key, value string, ttl time.Duration) error {
+	encodedKey := base64.StdEncoding.EncodeToString([]byte(key))
+	created, err := c.putWithTTL(etcdV3Compare{
+		Key:            encodedKey,
+		Target:         "CREATE",
+		Result:         "EQUAL",
+		CreateRevision: "0",
+	}, key, value, ttl)
+	if err != nil {
+		return maskAny(err)
+	}
+	if !created {
+		return maskAny(errgo.WithCausef(nil, AlreadyExistsError, "key %s already exists", key))
+	}
+	return nil
+}
+
+func (c *etcdV3Client) UpdateKey(key, prevValue, value string, ttl time.Duration) error {
+	updated, err := c.putWithTTL(etcdV3Compare{
+		Key:    base64.StdEncoding.EncodeToString([]byte(key)),
+		Target: "VALUE",
+		Result: "EQUAL",
+		Value:  base64.StdEncoding.EncodeToString([]byte(prevValue)),
+	}, key, value, ttl)
+	if err != nil {
+		return maskAny(err)
+	}
+	if !updated {
+		return maskAny(errgo.WithCausef(nil, NotFoundError, "key %s not found", key))
+	}
+	return nil
+}
+
+// putWithTTL puts the given key with a new lease of given TTL, if the given comparison succeeds.
+// It returns true if the comparison succeeded.
+func (c *etcdV3Client) putWithTTL(compare etcdV3Compare, key, value string, ttl time.Duration) (bool, error) {
+	var lease struct {
+		ID string `json:"ID"`
+	}
+	leaseReq := struct {
+		TTL string `json:"TTL"`
+	}{strconv.Itoa(int(ttl.Seconds()))}
+	if err := c.do("POST", etcdClientURL+c.prefix+"/lease/grant", leaseReq, &lease); err != nil {
+		return false, maskAny(err)
+	}
+	succeeded, err := c.txn(compare, etcdV3Request{Put: &etcdV3KeyValue{
+		Key:   base64.StdEncoding.EncodeToString([]byte(key)),
+		Value: base64.StdEncoding.EncodeToString([]byte(value)),
+		Lease: lease.ID,
+	}})
+	if err != nil || !succeeded {
+		// The lease is not used
+		revokeReq := struct {
+			ID string `json:"ID"`
+		}{lease.ID}
+		c.do("POST", etcdClientURL+c.prefix+"/lease/revoke", revokeReq, nil)
+	}
+	if err != nil {
+		return false, maskAny(err)
+	}
+	return succeeded, nil
+}
+
+func (c *etcdV3Client) SetKey(key, value string) error {
+	body := etcdV3KeyValue{
+		Key:   base64.StdEncoding.EncodeToString([]byte(key)),
+		Value: base64.StdEncoding.EncodeToString([]byte(value)),
+	}
+	if err := c.do("POST", etcdClientURL+c.prefix+"/kv/put", body, nil); err != nil {
+		return maskAny(err)
+	}
+	return nil
+}
+
+func (c *etcdV3Client) GetKey(key string) (string, error) {
+	var result struct {
+		KVs []etcdV3KeyValue `json:"kvs"`
+	}
+	body := etcdV3KeyValue{Key: base64.StdEncoding.EncodeToString([]byte(key))}
+	if err := c.do("POST", etcdClientURL+c.prefix+"/kv/range", body, &result); err != nil {
+		return "", maskAny(err)
+	}
+	if len(result.KVs) == 0 {
+		return "", maskAny(errgo.WithCausef(nil, NotFoundError, "key %s not found", key))
+	}
+	value, err := base64.StdEncoding.DecodeString(result.KVs[0].Value)
+	if err != nil {
+		return "", maskAny(err)
+	}
+	return string(value), nil
+}
+
+func (c *etcdV3Client) DeleteKey(key, value string) error {
+	encodedKey := base64.StdEncoding.EncodeToString([]byte(key))
+	compare := etcdV3Compare{
+		Key:            encodedKey,
+		Target:         "CREATE",
+		Result:         "NOT_EQUAL",
+		CreateRevision: "0",
+	}
+	if value != "" {
+		compare = etcdV3Compare{
+			Key:    encodedKey,
+			Target: "VALUE",
+			Result: "EQUAL",
+			Value:  base64.StdEncoding.EncodeToString([]byte(value)),
+		}
+	}
+	deleted, err := c.txn(compare, etcdV3Request{DeleteRange: &etcdV3KeyValue{Key: encodedKey}})
+	if err != nil {
+		return maskAny(err)
+	}
+	if !deleted {
+		return maskAny(errgo.WithCausef(nil, NotFoundError, "key %s not found", key))
+	}
+	return nil
+}
+
+// etcdPeerURL returns the peer URL of an etcd member on the given cluster IP.
+func etcdPeerURL(clusterIP string) string {
+	return fmt.Sprintf("http://%s", net.JoinHostPort(clusterIP, strconv.Itoa(etcdPeerPort)))
+}
@@ -0,0 +1,178 @@
+// Copyright (c) 2016 Pulcy.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package providers_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/juju/errgo"
+
+	"github.com/pulcy/quark/providers"
+	"github.com/pulcy/quark/providers/sshtest"
+)
+
+var (
+	testEtcdVersions = []string{"2.3.7", "3.1.0"}
+)
+
+func etcdPeerURLs(members []sshtest.EtcdMember) map[string]bool {
+	result := make(map[string]bool)
+	for _, m := range members {
+		result[m.PeerURL] = true
+	}
+	return result
+}
+
+func TestAddEtcdMember(t *testing.T) {
+	for _, version := range testEtcdVersions {
+		c := newTestCluster(t, 3)
+		c.Servers[0].Etcd.SetVersion(version)
+		c.AddEtcdMembers()
+
+		if err := c.Instances.AddEtcdMember(context.Background(), testLog, "new", "192.168.35.4"); err != nil {
+			t.Errorf("etcd %s: AddEtcdMember failed: %v", version, err)
+		}
+		members := c.Servers[0].Etcd.Members()
+		if len(members) != 4 || !etcdPeerURLs(members)["http://192.168.35.4:2380"] {
+			t.Errorf("etcd %s: expected the new member to be added, got %#v", version, members)
+		}
+
+		// Adding an existing member changes nothing
+		if err := c.Instances.AddEtcdMember(context.Background(), testLog, "new", "192.168.35.4"); err != nil {
+			t.Errorf("etcd %s: AddEtcdMember of an existing member failed: %v", version, err)
+		}
+		if members := c.Servers[0].Etcd.Members(); len(members) != 4 {
+			t.Errorf("etcd %s: expected 4 members, got %d", version, len(members))
+		}
+		c.Close()
+	}
+}
+
+func TestAddEtcdMemberFallback(t *testing.T) {
+	c := newTestCluster(t, 2)
+	defer c.Close()
+	c.AddEtcdMembers()
+	// The first instance cannot be reached
+	c.Servers[0].Close()
+
+	if err := c.Instances.AddEtcdMember(context.Background(), testLog, "new", "192.168.35.3"); err != nil {
+		t.Fatalf("AddEtcdMember failed: %v", err)
+	}
+	if members := c.Servers[1].Etcd.Members(); len(members) != 3 {
+		t.Errorf("Expected 3 members, got %d", len(members))
+	}
+}
+
+func TestRemoveEtcdMember(t *testing.T) {
+	for _, version := range testEtcdVersions {
+		c := newTestCluster(t, 2)
+		c.Servers[0].Etcd.SetVersion(version)
+		c.AddEtcdMembers()
+		// A member whose IP address starts with the IP address of the member to remove
+		c.Servers[0].Etcd.AddMember("other", "192.168.35.10")
+
+		if err := c.Instances.RemoveEtcdMember(context.Background(), testLog, "i1", "192.168.35.1"); err != nil {
+			t.Errorf("etcd %s: RemoveEtcdMember failed: %v", version, err)
+		}
+		urls := etcdPeerURLs(c.Servers[0].Etcd.Members())
+		if len(urls) != 2 || urls["http://192.168.35.1:2380"] || !urls["http://192.168.35.10:2380"] {
+			t.Errorf("etcd %s: expected only 192.168.35.1 to be removed, got %v", version, urls)
+		}
+
+		err := c.Instances.RemoveEtcdMember(context.Background(), testLog, "i1", "192.168.35.1")
+		if errgo.Cause(err) != providers.NotFoundError {
+			t.Errorf("etcd %s: expected NotFoundError when removing a non-member, got %v", version, err)
+		}
+		c.Close()
+	}
+}
+
+func TestEtcdMemberHasPeerIP(t *testing.T) {
+	m := providers.EtcdMember{
+		PeerURLs: []string{"http://192.168.35.1:2380", "http://[fd00::1]:2380", "http://10.0.0.1", "%zz"},
+	}
+	tests := map[string]bool{
+		"192.168.35.1":  true,
+		"fd00::1":       true,
+		"10.0.0.1":      true,
+		"192.168.35.10": false,
+		"192.168.35.":   false,
+		"2380":          false,
+		"":              false,
+	}
+	for ip, expected := range tests {
+		if result := m.HasPeerIP(ip); result != expected {
+			t.Errorf("Expected HasPeerIP(%q) to return %v, got %v", ip, expected, result)
+		}
+	}
+	if (providers.EtcdMember{}).HasPeerIP("192.168.35.1") {
+		t.Errorf("Expected a member without peer URLs to have no peer IP")
+	}
+}
+
+func TestEtcdMemberListFindByPeerIP(t *testing.T) {
+	l := providers.EtcdMemberList{
+		{ID: "1", Name: "i1", PeerURLs: []string{"http://192.168.35.1:2380"}},
+		{ID: "2", Name: "i2", PeerURLs: []string{"http://192.168.35.10:2380"}},
+		{ID: "3", PeerURLs: []string{"http://192.168.35.100:2380"}},
+	}
+	for ip, expectedID := range map[string]string{"192.168.35.1": "1", "192.168.35.10": "2", "192.168.35.100": "3"} {
+		m, found := l.FindByPeerIP(ip)
+		if !found || m.ID != expectedID {
+			t.Errorf("Expected FindByPeerIP(%s) to return member %s, got %#v (found=%v)", ip, expectedID, m, found)
+		}
+	}
+	if m, found := l.FindByPeerIP("192.168.35.2"); found {
+		t.Errorf("Expected FindByPeerIP of an unknown IP to find nothing, got %#v", m)
+	}
+}
+
+func TestEtcdKeys(t *testing.T) {
+	for _, version := range testEtcdVersions {
+		c := newTestCluster(t, 1)
+		c.Servers[0].Etcd.SetVersion(version)
+		c.AddEtcdMembers()
+		client, _, err := c.Instances.EtcdClient(context.Background(), testLog)
+		if err != nil {
+			t.Fatalf("etcd %s: EtcdClient failed: %v", version, err)
+		}
+
+		if err := client.CreateKey("/test/lock", "one", time.Minute); err != nil {
+			t.Errorf("etcd %s: CreateKey failed: %v", version, err)
+		}
+		if err := client.CreateKey("/test/lock", "two", time.Minute); errgo.Cause(err) != providers.AlreadyExistsError {
+			t.Errorf("etcd %s: expected AlreadyExistsError, got %v", version, err)
+		}
+		if err := client.UpdateKey("/test/lock", "other", "two", time.Minute); errgo.Cause(err) != providers.NotFoundError {
+			t.Errorf("etcd %s: expected NotFoundError when updating with another previous value, got %v", version, err)
+		}
+		if err := client.UpdateKey("/test/lock", "one", "two", time.Minute); err != nil {
+			t.Errorf("etcd %s: UpdateKey failed: %v", version, err)
+		}
+		if value, err := client.GetKey("/test/lock"); err != nil || value != "two" {
+			t.Errorf("etcd %s: expected value two, got %q (%v)", version, value, err)
+		}
+		if err := client.DeleteKey("/test/lock", "two"); err != nil {
+			t.Errorf("etcd %s: DeleteKey failed: %v", version, err)
+		}
+		if err := client.UpdateKey("/test/lock", "two", "three", time.Minute); errgo.Cause(err) != providers.NotFoundError {
+			t.Errorf("etcd %s: expected NotFoundError when updating a removed key, got %v", version, err)
+		}
+		client.Close()
+		c.Close()
+	}
+}
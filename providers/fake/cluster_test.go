@@ -0,0 +1,174 @@
+// Copyright (c) 2016 Pulcy.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fake
+
+import (
+	"context"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/pulcy/quark/providers"
+	"github.com/pulcy/quark/providers/sshtest"
+)
+
+// TestClusterLifecycle creates a cluster, adds an instance and destroys it again,
+// the way the cluster & instance commands do, with every instance emulated by an sshtest server.
+func TestClusterLifecycle(t *testing.T) {
+	p, cleanup := newTestProvider(t)
+	defer cleanup()
+	info := providers.ClusterInfo{ID: "c1-id", Name: "c1", Domain: "example.com"}
+	farm := sshtest.NewFarm()
+	farm.Setup = func(host string, s *sshtest.Server) {
+		// Written by cloud-config on real instances
+		s.FS.WriteFile("/etc/pulcy/cluster-id", info.ID, 0444, "root")
+	}
+	defer farm.Close()
+	defer farm.Install()()
+
+	certPath := filepath.Join(filepath.Dir(p.statePath), "vault.crt")
+	if err := ioutil.WriteFile(certPath, []byte("vault-certificate"), 0600); err != nil {
+		t.Fatalf("Cannot write certificate: %v", err)
+	}
+	ctx := context.Background()
+
+	// Create cluster
+	cco := p.CreateClusterDefaults(providers.CreateClusterOptions{
+		ClusterInfo:          info,
+		InstanceConfig:       providers.InstanceConfig{MinOSVersion: "835.13.0"},
+		InstanceCount:        3,
+		GluonImage:           "pulcy/gluon:test",
+		VaultAddress:         "https://vault.example.com:8200",
+		VaultCertificatePath: certPath,
+		WeavePassword:        "weave",
+	})
+	if err := p.CreateCluster(ctx, p.Logger, cco, p); err != nil {
+		t.Fatalf("CreateCluster failed: %v", err)
+	}
+	instances, err := p.GetInstances(ctx, info)
+	if err != nil {
+		t.Fatalf("GetInstances failed: %v", err)
+	}
+	if len(instances) != 3 {
+		t.Fatalf("Expected 3 instances, got %d", len(instances))
+	}
+	for _, i := range instances {
+		s, err := farm.Server(i.String())
+		if err != nil {
+			t.Fatalf("Server %s failed: %v", i, err)
+		}
+		if setups := s.Gluon.SetupArgs(); len(setups) != 1 {
+			t.Errorf("Expected 1 gluon setup on %s, got %d", i.Name, len(setups))
+		}
+		farm.Etcd().AddMember(i.Name, i.ClusterIP)
+	}
+
+	// Add instance
+	cio := p.CreateInstanceDefaults(providers.CreateInstanceOptions{
+		ClusterInfo:          info,
+		InstanceConfig:       cco.InstanceConfig,
+		RoleCore:             true,
+		GluonImage:           cco.GluonImage,
+		VaultAddress:         cco.VaultAddress,
+		VaultCertificatePath: certPath,
+	})
+	cio.SetupNames("added", info.Name, info.Domain)
+	added, err := p.CreateInstance(ctx, p.Logger, cio, p)
+	if err != nil {
+		t.Fatalf("CreateInstance failed: %v", err)
+	}
+	if err := instances.AddEtcdMember(ctx, p.Logger, added.Name, added.ClusterIP); err != nil {
+		t.Fatalf("AddEtcdMember failed: %v", err)
+	}
+	members, err := append(instances, added).AsClusterMemberList(ctx, p.Logger, nil)
+	if err != nil {
+		t.Fatalf("AsClusterMemberList failed: %v", err)
+	}
+	iso := providers.InitialSetupOptions{ClusterMembers: members}
+	if err := added.InitialSetup(ctx, p.Logger, cio, iso, p); err != nil {
+		t.Fatalf("InitialSetup failed: %v", err)
+	}
+	if err := providers.UpdateClusterMembers(ctx, p.Logger, info, false, nil, p); err != nil {
+		t.Fatalf("UpdateClusterMembers failed: %v", err)
+	}
+	instances, err = p.GetInstances(ctx, info)
+	if err != nil {
+		t.Fatalf("GetInstances failed: %v", err)
+	}
+	if len(instances) != 4 {
+		t.Fatalf("Expected 4 instances, got %d", len(instances))
+	}
+	expectMembers(t, farm, instances)
+
+	// Destroy instance
+	toRemove, err := instances.InstanceByName(added.Name)
+	if err != nil {
+		t.Fatalf("InstanceByName failed: %v", err)
+	}
+	problems, err := instances.CheckRemoval(ctx, p.Logger, toRemove)
+	if err != nil {
+		t.Fatalf("CheckRemoval failed: %v", err)
+	}
+	if len(problems) != 0 {
+		t.Errorf("Expected no removal problems, got %v", problems)
+	}
+	if err := instances.Except(toRemove).RemoveEtcdMember(ctx, p.Logger, toRemove.Name, toRemove.ClusterIP); err != nil {
+		t.Fatalf("RemoveEtcdMember failed: %v", err)
+	}
+	if err := p.DeleteInstance(ctx, providers.ClusterInstanceInfo{ClusterInfo: info, Prefix: "added"}, p); err != nil {
+		t.Fatalf("DeleteInstance failed: %v", err)
+	}
+	if err := providers.UpdateClusterMembers(ctx, p.Logger, info, false, nil, p); err != nil {
+		t.Fatalf("UpdateClusterMembers failed: %v", err)
+	}
+	instances, err = p.GetInstances(ctx, info)
+	if err != nil {
+		t.Fatalf("GetInstances failed: %v", err)
+	}
+	if len(instances) != 3 {
+		t.Fatalf("Expected 3 instances, got %d", len(instances))
+	}
+	for _, m := range farm.Etcd().Members() {
+		if m.Name == added.Name {
+			t.Errorf("Expected %s to be removed from etcd", added.Name)
+		}
+	}
+	expectMembers(t, farm, instances)
+}
+
+// expectMembers checks that the cluster-members file of every instance lists exactly the given instances.
+func expectMembers(t *testing.T, farm *sshtest.Farm, instances providers.ClusterInstanceList) {
+	for _, i := range instances {
+		s, err := farm.Server(i.String())
+		if err != nil {
+			t.Fatalf("Server %s failed: %v", i, err)
+		}
+		f, ok := s.FS.ReadFile("/etc/pulcy/cluster-members")
+		if !ok {
+			t.Errorf("Expected cluster-members on %s", i.Name)
+			continue
+		}
+		lines := strings.Split(strings.TrimSpace(f.Content), "\n")
+		if len(lines) != len(instances) {
+			t.Errorf("Expected %d cluster members on %s, got %d", len(instances), i.Name, len(lines))
+		}
+		for _, other := range instances {
+			if !strings.Contains(f.Content, other.ClusterIP) {
+				t.Errorf("Expected cluster-members on %s to contain %s", i.Name, other.ClusterIP)
+			}
+		}
+	}
+}
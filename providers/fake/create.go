@@ -0,0 +1,159 @@
+// Copyright (c) 2016 Pulcy.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fake
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"sync"
+
+	"github.com/juju/errgo"
+	"github.com/op/go-logging"
+
+	"github.com/pulcy/quark/providers"
+)
+
+const (
+	maxServers = 254
+)
+
+type instanceData struct {
+	CreateInstanceOptions providers.CreateInstanceOptions
+	ClusterInstance       providers.ClusterInstance
+	FleetMetadata         string
+}
+
+// Create an entire cluster
+func (p *fakeProvider) CreateCluster(ctx context.Context, log *logging.Logger, options providers.CreateClusterOptions, dnsProvider providers.DnsProvider) error {
+	instances := []instanceData{}
+	instanceList := providers.ClusterInstanceList{}
+	for i := 1; i <= options.InstanceCount; i++ {
+		isCore := true
+		isLB := true
+		instanceOptions, err := options.NewCreateInstanceOptions(isCore, isLB, i)
+		if err != nil {
+			return maskAny(err)
+		}
+		instance, err := p.CreateInstance(ctx, log, instanceOptions, dnsProvider)
+		if err != nil {
+			return maskAny(err)
+		}
+		instances = append(instances, instanceData{
+			CreateInstanceOptions: instanceOptions,
+			ClusterInstance:       instance,
+			FleetMetadata:         instanceOptions.CreateFleetMetadata(i),
+		})
+		instanceList = append(instanceList, instance)
+	}
+
+	clusterMembers, err := instanceList.AsClusterMemberList(ctx, log, nil)
+	if err != nil {
+		return maskAny(err)
+	}
+
+	if err := p.setupInstances(ctx, log, instances, clusterMembers); err != nil {
+		return maskAny(err)
+	}
+
+	return nil
+}
+
+func (p *fakeProvider) setupInstances(ctx context.Context, log *logging.Logger, instances []instanceData, clusterMembers providers.ClusterMemberList) error {
+	wg := sync.WaitGroup{}
+	errors := make(chan error, len(instances))
+	for _, instance := range instances {
+		wg.Add(1)
+		go func(instance instanceData) {
+			defer wg.Done()
+			iso := providers.InitialSetupOptions{
+				ClusterMembers: clusterMembers,
+				FleetMetadata:  instance.FleetMetadata,
+			}
+			if err := instance.ClusterInstance.InitialSetup(ctx, log, instance.CreateInstanceOptions, iso, p); err != nil {
+				errors <- maskAny(err)
+				return
+			}
+		}(instance)
+	}
+	wg.Wait()
+	close(errors)
+	err := <-errors
+	if err != nil {
+		return maskAny(err)
+	}
+
+	return nil
+}
+
+// Create a machine instance
+func (p *fakeProvider) CreateInstance(ctx context.Context, log *logging.Logger, options providers.CreateInstanceOptions, dnsProvider providers.DnsProvider) (providers.ClusterInstance, error) {
+	if _, ok := regions[options.RegionID]; !ok {
+		return providers.ClusterInstance{}, maskAny(errgo.WithCausef(nil, InvalidArgumentError, "unknown region '%s'", options.RegionID))
+	}
+	if _, ok := images[options.ImageID]; !ok {
+		return providers.ClusterInstance{}, maskAny(errgo.WithCausef(nil, InvalidArgumentError, "unknown image '%s'", options.ImageID))
+	}
+	if _, ok := plans[options.TypeID]; !ok {
+		return providers.ClusterInstance{}, maskAny(errgo.WithCausef(nil, InvalidArgumentError, "unknown type '%s'", options.TypeID))
+	}
+
+	var srv server
+	if err := p.updateState(func(s *state) error {
+		for _, x := range s.Servers {
+			if x.Name == options.InstanceName {
+				return maskAny(errgo.WithCausef(nil, InvalidArgumentError, "server '%s' already exists", x.Name))
+			}
+		}
+		index, err := s.freeIndex()
+		if err != nil {
+			return maskAny(err)
+		}
+		s.LastServerID++
+		srv = server{
+			ID:          strconv.Itoa(s.LastServerID),
+			Index:       index,
+			Name:        options.InstanceName,
+			RegionID:    options.RegionID,
+			ImageID:     options.ImageID,
+			TypeID:      options.TypeID,
+			PrivateIPv4: fmt.Sprintf("10.99.0.%d", index),
+			Roles:       options.Roles(),
+			EtcdProxy:   options.EtcdProxy,
+		}
+		if !options.NoPublicIPv4 {
+			// Use loopback addresses, so a local SSH server can stand in for the instances.
+			srv.PublicIPv4 = fmt.Sprintf("127.0.1.%d", index)
+		}
+		srv.PublicIPv6 = fmt.Sprintf("fd00::%x", index)
+		srv.ClusterIP = srv.PrivateIPv4
+		if options.TincIpv4 != "" {
+			srv.ClusterIP = options.TincIpv4
+		}
+		s.Servers = append(s.Servers, srv)
+		return nil
+	}); err != nil {
+		return providers.ClusterInstance{}, maskAny(err)
+	}
+	p.Logger.Infof("Created server %s %s", srv.ID, srv.Name)
+
+	if err := providers.RegisterInstance(ctx, p.Logger, dnsProvider, options, srv.Name, options.RegisterInstance, options.RoleLoadBalancer, options.RoleLoadBalancer, srv.PublicIPv4, srv.PublicIPv6, srv.PrivateIPv4); err != nil {
+		return providers.ClusterInstance{}, maskAny(err)
+	}
+
+	p.Logger.Infof("Server '%s' is ready", srv.Name)
+
+	return p.clusterInstance(srv), nil
+}
@@ -0,0 +1,109 @@
+// Copyright (c) 2016 Pulcy.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fake
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/op/go-logging"
+
+	"github.com/pulcy/quark/providers"
+)
+
+func newTestProvider(t *testing.T) (*fakeProvider, func()) {
+	dir, err := ioutil.TempDir("", "quark-fake")
+	if err != nil {
+		t.Fatalf("Cannot create temp dir: %v", err)
+	}
+	p := newFakeProvider(logging.MustGetLogger("fake-test"), filepath.Join(dir, "state.json"))
+	return p, func() { os.RemoveAll(dir) }
+}
+
+func createTestInstance(t *testing.T, p *fakeProvider, info providers.ClusterInfo, prefix string) providers.ClusterInstance {
+	options := p.CreateInstanceDefaults(providers.CreateInstanceOptions{ClusterInfo: info})
+	options.SetupNames(prefix, info.Name, info.Domain)
+	instance, err := p.CreateInstance(context.Background(), p.Logger, options, p)
+	if err != nil {
+		t.Fatalf("CreateInstance %s failed: %v", prefix, err)
+	}
+	return instance
+}
+
+func TestCreateReusesDeletedAddresses(t *testing.T) {
+	p, cleanup := newTestProvider(t)
+	defer cleanup()
+	info := providers.ClusterInfo{Name: "c1", Domain: "example.com"}
+
+	first := createTestInstance(t, p, info, "first")
+	createTestInstance(t, p, info, "second")
+	if err := p.DeleteInstance(context.Background(), providers.ClusterInstanceInfo{ClusterInfo: info, Prefix: "first"}, p); err != nil {
+		t.Fatalf("DeleteInstance failed: %v", err)
+	}
+	third := createTestInstance(t, p, info, "third")
+	if third.PrivateIP != first.PrivateIP {
+		t.Errorf("Expected address %s of the deleted instance to be reused, got %s", first.PrivateIP, third.PrivateIP)
+	}
+	if third.ID == first.ID {
+		t.Errorf("Expected a new ID, got the ID %s of the deleted instance", third.ID)
+	}
+}
+
+func TestCreateAfterManyDeletes(t *testing.T) {
+	p, cleanup := newTestProvider(t)
+	defer cleanup()
+	info := providers.ClusterInfo{Name: "c1", Domain: "example.com"}
+
+	for i := 0; i < maxServers+10; i++ {
+		prefix := fmt.Sprintf("i%d", i)
+		createTestInstance(t, p, info, prefix)
+		if err := p.DeleteInstance(context.Background(), providers.ClusterInstanceInfo{ClusterInfo: info, Prefix: prefix}, p); err != nil {
+			t.Fatalf("DeleteInstance %s failed: %v", prefix, err)
+		}
+	}
+}
+
+func TestFreeIndex(t *testing.T) {
+	tests := []struct {
+		Servers  []server
+		Expected int
+	}{
+		{nil, 1},
+		{[]server{{ID: "1"}, {ID: "2"}}, 3},
+		{[]server{{ID: "2"}, {ID: "3"}}, 1},
+		{[]server{{ID: "7", Index: 1}, {ID: "1"}}, 2},
+	}
+	for _, test := range tests {
+		s := state{Servers: test.Servers}
+		index, err := s.freeIndex()
+		if err != nil {
+			t.Errorf("freeIndex(%v) failed: %v", test.Servers, err)
+		} else if index != test.Expected {
+			t.Errorf("freeIndex(%v): expected %d, got %d", test.Servers, test.Expected, index)
+		}
+	}
+
+	s := state{}
+	for i := 1; i <= maxServers; i++ {
+		s.Servers = append(s.Servers, server{Index: i})
+	}
+	if _, err := s.freeIndex(); err == nil {
+		t.Errorf("Expected an error when all %d indexes are used", maxServers)
+	}
+}
@@ -0,0 +1,79 @@
+// Copyright (c) 2016 Pulcy.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fake
+
+import (
+	"context"
+
+	"github.com/pulcy/quark/providers"
+)
+
+// Remove all instances of a cluster
+func (p *fakeProvider) DeleteCluster(ctx context.Context, info providers.ClusterInfo, dnsProvider providers.DnsProvider) error {
+	servers, err := p.getServers(info)
+	if err != nil {
+		return maskAny(err)
+	}
+	for _, s := range servers {
+		if err := p.deleteServer(ctx, s, dnsProvider, info.Domain); err != nil {
+			return maskAny(err)
+		}
+	}
+
+	return nil
+}
+
+// Remove a single instance of a cluster
+func (p *fakeProvider) DeleteInstance(ctx context.Context, info providers.ClusterInstanceInfo, dnsProvider providers.DnsProvider) error {
+	fullName := info.String()
+	servers, err := p.getServers(info.ClusterInfo)
+	if err != nil {
+		return maskAny(err)
+	}
+	for _, s := range servers {
+		if s.Name == fullName {
+			if err := p.deleteServer(ctx, s, dnsProvider, info.Domain); err != nil {
+				return maskAny(err)
+			}
+			return nil
+		}
+	}
+
+	return maskAny(NotFoundError)
+}
+
+func (p *fakeProvider) deleteServer(ctx context.Context, s server, dnsProvider providers.DnsProvider, domain string) error {
+	// Delete DNS instance records
+	instance := p.clusterInstance(s)
+	if err := providers.UnRegisterInstance(ctx, p.Logger, dnsProvider, instance, domain); err != nil {
+		return maskAny(err)
+	}
+
+	// Delete server
+	p.Logger.Infof("Deleting server %s %s", s.ID, s.Name)
+	if err := p.updateState(func(st *state) error {
+		servers := []server{}
+		for _, x := range st.Servers {
+			if x.ID != s.ID {
+				servers = append(servers, x)
+			}
+		}
+		st.Servers = servers
+		return nil
+	}); err != nil {
+		return maskAny(err)
+	}
+	return nil
+}
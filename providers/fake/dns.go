@@ -0,0 +1,75 @@
+// Copyright (c) 2016 Pulcy.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fake
+
+import (
+	"context"
+
+	"github.com/pulcy/quark/providers"
+)
+
+func (p *fakeProvider) GetDomainRecords(ctx context.Context, domain string) (providers.DnsRecordList, error) {
+	s, err := p.readState()
+	if err != nil {
+		return nil, maskAny(err)
+	}
+
+	result := providers.DnsRecordList{}
+	for _, r := range s.DnsRecords {
+		if r.Domain != domain {
+			continue
+		}
+		result = append(result, providers.DnsRecord{
+			Type: r.Type,
+			Name: r.Name,
+			Data: r.Data,
+		})
+	}
+
+	return result, nil
+}
+
+func (p *fakeProvider) CreateDnsRecord(ctx context.Context, domain, recordType, name, data string) error {
+	if err := p.updateState(func(s *state) error {
+		s.DnsRecords = append(s.DnsRecords, dnsRecord{
+			Domain: domain,
+			Type:   recordType,
+			Name:   name,
+			Data:   data,
+		})
+		return nil
+	}); err != nil {
+		return maskAny(err)
+	}
+	return nil
+}
+
+func (p *fakeProvider) DeleteDnsRecord(ctx context.Context, domain, recordType, name, data string) error {
+	if err := p.updateState(func(s *state) error {
+		records := []dnsRecord{}
+		for _, r := range s.DnsRecords {
+			if r.Domain == domain && r.Type == recordType && r.Name == name && (data == "" || r.Data == data) {
+				// Found matching record
+				continue
+			}
+			records = append(records, r)
+		}
+		s.DnsRecords = records
+		return nil
+	}); err != nil {
+		return maskAny(err)
+	}
+	return nil
+}
@@ -0,0 +1,76 @@
+// Copyright (c) 2016 Pulcy.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fake
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/pulcy/quark/providers"
+)
+
+// Get names of instances of a cluster
+func (p *fakeProvider) GetInstances(ctx context.Context, info providers.ClusterInfo) (providers.ClusterInstanceList, error) {
+	servers, err := p.getServers(info)
+	if err != nil {
+		return nil, maskAny(err)
+	}
+	result := providers.ClusterInstanceList{}
+	for _, s := range servers {
+		result = append(result, p.clusterInstance(s))
+	}
+	// Instances without a public IP are reached through a gateway
+	return result.UseGateways(), nil
+}
+
+func (p *fakeProvider) getServers(info providers.ClusterInfo) ([]server, error) {
+	s, err := p.readState()
+	if err != nil {
+		return nil, maskAny(err)
+	}
+
+	postfix := fmt.Sprintf(".%s.%s", info.Name, info.Domain)
+	result := []server{}
+	for _, srv := range s.Servers {
+		if strings.HasSuffix(srv.Name, postfix) {
+			result = append(result, srv)
+		}
+	}
+
+	return result, nil
+}
+
+// clusterInstance creates a ClusterInstance record for the given server
+func (p *fakeProvider) clusterInstance(s server) providers.ClusterInstance {
+	etcdProxy := s.EtcdProxy
+	info := providers.ClusterInstance{
+		ID:                 s.ID,
+		Name:               s.Name,
+		ClusterIP:          s.ClusterIP,
+		PrivateIP:          s.PrivateIPv4,
+		LoadBalancerIPv4:   s.PublicIPv4,
+		LoadBalancerIPv6:   s.PublicIPv6,
+		IsGateway:          s.PublicIPv4 != "",
+		ClusterDevice:      privateClusterDevice,
+		OS:                 providers.OSNameCoreOS,
+		EtcdProxy:          &etcdProxy,
+		HostKeyFingerprint: s.HostKeyFingerprint,
+	}
+	if s.Roles != "" {
+		info.Extra = append(info.Extra, s.Roles)
+	}
+	return info
+}
@@ -0,0 +1,26 @@
+// Copyright (c) 2016 Pulcy.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fake
+
+import (
+	"context"
+
+	"github.com/pulcy/quark/providers"
+)
+
+func (p *fakeProvider) GetKeys(ctx context.Context) (providers.SSHKeyList, error) {
+	// The fake provider does not manage SSH keys, every key name is accepted.
+	return providers.SSHKeyList{}, nil
+}
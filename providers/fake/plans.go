@@ -0,0 +1,38 @@
+// Copyright (c) 2016 Pulcy.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fake
+
+import (
+	"context"
+
+	"github.com/pulcy/quark/providers"
+)
+
+var (
+	plans = map[string]providers.InstanceType{
+		"small":  {VCpus: 1, RAM: "1024MB", Disk: "20GB"},
+		"medium": {VCpus: 2, RAM: "4096MB", Disk: "60GB"},
+		"large":  {VCpus: 4, RAM: "8192MB", Disk: "120GB"},
+	}
+)
+
+func (p *fakeProvider) GetInstanceTypes(ctx context.Context) (providers.InstanceTypeList, error) {
+	result := providers.InstanceTypeList{}
+	for id, pl := range plans {
+		pl.ID = id
+		result = append(result, pl)
+	}
+	return result, nil
+}
@@ -0,0 +1,46 @@
+// Copyright (c) 2016 Pulcy.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fake
+
+import (
+	"github.com/op/go-logging"
+
+	"github.com/pulcy/quark/providers"
+)
+
+// fakeProvider implements providers.CloudProvider and providers.DnsProvider
+// without talking to any cloud. All servers and DNS records are kept in a
+// local JSON file, so consecutive quark invocations see the same "cloud".
+type fakeProvider struct {
+	Logger    *logging.Logger
+	statePath string
+}
+
+// NewProvider creates a new fake cloud provider implementation that stores its state in the given file.
+func NewProvider(logger *logging.Logger, statePath string) providers.CloudProvider {
+	return newFakeProvider(logger, statePath)
+}
+
+// NewDnsProvider creates a new fake DNS provider implementation that stores its state in the given file.
+func NewDnsProvider(logger *logging.Logger, statePath string) providers.DnsProvider {
+	return newFakeProvider(logger, statePath)
+}
+
+func newFakeProvider(logger *logging.Logger, statePath string) *fakeProvider {
+	return &fakeProvider{
+		Logger:    logger,
+		statePath: statePath,
+	}
+}
@@ -0,0 +1,39 @@
+// Copyright (c) 2016 Pulcy.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fake
+
+import (
+	"context"
+
+	"github.com/pulcy/quark/providers"
+)
+
+// Perform a reboot of the given instance.
+// The fake provider only records the number of reboots of each server.
+func (p *fakeProvider) RebootInstance(ctx context.Context, instance providers.ClusterInstance) error {
+	if err := p.updateState(func(s *state) error {
+		for i, x := range s.Servers {
+			if x.ID == instance.ID {
+				s.Servers[i].Reboots++
+				return nil
+			}
+		}
+		return maskAny(NotFoundError)
+	}); err != nil {
+		return maskAny(err)
+	}
+	p.Logger.Infof("Rebooted server %s %s", instance.ID, instance.Name)
+	return nil
+}
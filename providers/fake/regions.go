@@ -0,0 +1,36 @@
+// Copyright (c) 2016 Pulcy.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fake
+
+import (
+	"context"
+
+	"github.com/pulcy/quark/providers"
+)
+
+var (
+	regions = map[string]string{
+		"local1": "Local region 1",
+		"local2": "Local region 2",
+	}
+)
+
+func (p *fakeProvider) GetRegions(ctx context.Context) (providers.RegionList, error) {
+	result := providers.RegionList{}
+	for id, name := range regions {
+		result = append(result, providers.Region{ID: id, Name: name})
+	}
+	return result, nil
+}
@@ -0,0 +1,74 @@
+// Copyright (c) 2016 Pulcy.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fake
+
+import (
+	"os"
+
+	"github.com/juju/errgo"
+	"github.com/mitchellh/go-homedir"
+	"github.com/op/go-logging"
+	"github.com/spf13/pflag"
+
+	"github.com/pulcy/quark/providers"
+)
+
+const (
+	providerName         = "fake"
+	defaultStatePathTmpl = "~/.pulcy/quark-fake-state.json"
+)
+
+var (
+	statePath string
+)
+
+func init() {
+	providers.RegisterCloudProvider(providers.CloudProviderRegistration{
+		Name: providerName,
+		SetupFlags: func(flagSet *pflag.FlagSet) {
+			flagSet.StringVar(&statePath, "fake-state", defaultStatePath(), "Path of the state file used by the fake provider")
+		},
+		New: func(log *logging.Logger) (providers.CloudProvider, error) {
+			if statePath == "" {
+				return nil, maskAny(errgo.WithCausef(nil, providers.InvalidArgumentError, "Please specify a fake-state"))
+			}
+			return NewProvider(log, statePath), nil
+		},
+		// Keep DNS records next to the fake servers
+		DnsProvider: providerName,
+	})
+	providers.RegisterDnsProvider(providers.DnsProviderRegistration{
+		Name: providerName,
+		New: func(log *logging.Logger) (providers.DnsProvider, error) {
+			if statePath == "" {
+				return nil, maskAny(errgo.WithCausef(nil, providers.InvalidArgumentError, "Please specify a fake-state"))
+			}
+			return NewDnsProvider(log, statePath), nil
+		},
+	})
+}
+
+// defaultStatePath returns the QUARK_FAKE_STATE environment variable or
+// a file in the users home directory.
+func defaultStatePath() string {
+	if path := os.Getenv("QUARK_FAKE_STATE"); path != "" {
+		return path
+	}
+	path, err := homedir.Expand(defaultStatePathTmpl)
+	if err != nil {
+		return ""
+	}
+	return path
+}
@@ -0,0 +1,154 @@
+// Copyright (c) 2016 Pulcy.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fake
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+)
+
+const (
+	stateFileMode = os.FileMode(0600)
+)
+
+var (
+	// stateMutex serializes all access to state files within this process.
+	stateMutex sync.Mutex
+)
+
+// state is the content of the JSON state file
+type state struct {
+	LastServerID int         `json:"last-server-id"`
+	Servers      []server    `json:"servers,omitempty"`
+	DnsRecords   []dnsRecord `json:"dns-records,omitempty"`
+}
+
+// server describes a single fake machine
+type server struct {
+	ID                 string   `json:"id"`
+	Index              int      `json:"index,omitempty"` // Index used in the addresses of the server
+	Name               string   `json:"name"`
+	RegionID           string   `json:"region"`
+	ImageID            string   `json:"image"`
+	TypeID             string   `json:"type"`
+	ClusterIP          string   `json:"cluster-ip"`
+	PrivateIPv4        string   `json:"private-ipv4"`
+	PublicIPv4         string   `json:"public-ipv4,omitempty"`
+	PublicIPv6         string   `json:"public-ipv6,omitempty"`
+	Roles              string   `json:"roles,omitempty"`
+	EtcdProxy          bool     `json:"etcd-proxy,omitempty"`
+	Reboots            int      `json:"reboots,omitempty"`
+	Tags               []string `json:"tags,omitempty"`
+	HostKeyFingerprint string   `json:"host-key-fingerprint,omitempty"` // Set by hand to test host key verification
+}
+
+// dnsRecord describes a single fake DNS record
+type dnsRecord struct {
+	Domain string `json:"domain"`
+	Type   string `json:"type"`
+	Name   string `json:"name"`
+	Data   string `json:"data"`
+}
+
+// index returns the index used in the addresses of the server.
+// Servers of older state files have no index, their ID was used instead.
+func (s server) index() int {
+	if s.Index != 0 {
+		return s.Index
+	}
+	index, _ := strconv.Atoi(s.ID)
+	return index
+}
+
+// freeIndex returns the lowest address index that is not used by any server.
+// Indexes of deleted servers are reused, so a state file never runs out of addresses.
+func (s *state) freeIndex() (int, error) {
+	used := make(map[int]bool)
+	for _, x := range s.Servers {
+		used[x.index()] = true
+	}
+	for index := 1; index <= maxServers; index++ {
+		if !used[index] {
+			return index, nil
+		}
+	}
+	return 0, maskAny(fmt.Errorf("no more than %d servers can exist at the same time", maxServers))
+}
+
+// readState loads the current state from disk.
+// A missing state file results in an empty state.
+func (p *fakeProvider) readState() (state, error) {
+	stateMutex.Lock()
+	defer stateMutex.Unlock()
+
+	return p.loadState()
+}
+
+// updateState loads the current state, calls the given callback to modify it
+// and saves the result when the callback succeeds.
+func (p *fakeProvider) updateState(cb func(s *state) error) error {
+	stateMutex.Lock()
+	defer stateMutex.Unlock()
+
+	s, err := p.loadState()
+	if err != nil {
+		return maskAny(err)
+	}
+	if err := cb(&s); err != nil {
+		return maskAny(err)
+	}
+	if err := p.saveState(s); err != nil {
+		return maskAny(err)
+	}
+	return nil
+}
+
+func (p *fakeProvider) loadState() (state, error) {
+	var s state
+	raw, err := ioutil.ReadFile(p.statePath)
+	if os.IsNotExist(err) {
+		return s, nil
+	} else if err != nil {
+		return s, maskAny(err)
+	}
+	if err := json.Unmarshal(raw, &s); err != nil {
+		return s, maskAny(err)
+	}
+	return s, nil
+}
+
+func (p *fakeProvider) saveState(s state) error {
+	raw, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return maskAny(err)
+	}
+	if err := os.MkdirAll(filepath.Dir(p.statePath), 0755); err != nil {
+		return maskAny(err)
+	}
+	// Write to a temporary file first, so an interrupted write never leaves a corrupt state behind
+	tmpPath := p.statePath + ".tmp"
+	if err := ioutil.WriteFile(tmpPath, raw, stateFileMode); err != nil {
+		return maskAny(err)
+	}
+	if err := os.Rename(tmpPath, p.statePath); err != nil {
+		return maskAny(err)
+	}
+	return nil
+}
@@ -0,0 +1,69 @@
+// Copyright (c) 2016 Pulcy.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fake
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/pulcy/quark/providers"
+)
+
+// Get all tags of the instances of a cluster
+func (p *fakeProvider) GetClusterTags(ctx context.Context, info providers.ClusterInfo) ([]string, error) {
+	servers, err := p.getServers(info)
+	if err != nil {
+		return nil, maskAny(err)
+	}
+	var tags []string
+	for _, s := range servers {
+		tags = append(tags, s.Tags...)
+	}
+	return tags, nil
+}
+
+// Add a tag to all instances of a cluster
+func (p *fakeProvider) AddClusterTag(ctx context.Context, info providers.ClusterInfo, tag string) error {
+	return maskAny(p.updateTags(info, func(tags []string) []string {
+		return append(tags, tag)
+	}))
+}
+
+// Remove a tag from all instances of a cluster
+func (p *fakeProvider) RemoveClusterTag(ctx context.Context, info providers.ClusterInfo, tag string) error {
+	return maskAny(p.updateTags(info, func(tags []string) []string {
+		var result []string
+		for _, t := range tags {
+			if t != tag {
+				result = append(result, t)
+			}
+		}
+		return result
+	}))
+}
+
+// updateTags replaces the tags of all servers of the given cluster with the result of the given function.
+func (p *fakeProvider) updateTags(info providers.ClusterInfo, update func(tags []string) []string) error {
+	postfix := fmt.Sprintf(".%s.%s", info.Name, info.Domain)
+	return maskAny(p.updateState(func(s *state) error {
+		for i, srv := range s.Servers {
+			if strings.HasSuffix(srv.Name, postfix) {
+				s.Servers[i].Tags = update(srv.Tags)
+			}
+		}
+		return nil
+	}))
+}
@@ -0,0 +1,39 @@
+// Copyright (c) 2016 Pulcy.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fake
+
+import (
+	"context"
+
+	"github.com/op/go-logging"
+
+	"github.com/pulcy/quark/providers"
+)
+
+func (p *fakeProvider) UpdateCluster(ctx context.Context, log *logging.Logger, info providers.ClusterInfo, dnsProvider providers.DnsProvider) error {
+	instances, err := p.GetInstances(ctx, info)
+	if err != nil {
+		return maskAny(err)
+	}
+	members, err := instances.AsClusterMemberList(ctx, log, nil)
+	if err != nil {
+		return maskAny(err)
+	}
+	rebootAfter := false
+	if err := instances.UpdateClusterMembers(ctx, log, members, rebootAfter, p); err != nil {
+		return maskAny(err)
+	}
+	return nil
+}
@@ -0,0 +1,217 @@
+// Copyright (c) 2016 Pulcy.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package providers
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/cenkalti/backoff"
+	"github.com/juju/errgo"
+	"github.com/op/go-logging"
+)
+
+const (
+	// gluonArgsPath is the file in which the arguments of the last `gluon setup` are stored (one per line).
+	gluonArgsPath = "/etc/pulcy/gluon-args"
+	// gluonSecretArgsPath is the root-only file in which the secret arguments (passwords) of the last
+	// `gluon setup` are stored (one per line). They are kept out of gluonArgsPath.
+	gluonSecretArgsPath = "/etc/pulcy/gluon-secret-args"
+	gluonImageFlag      = "--gluon-image="
+	healthCheckWait     = time.Second * 5
+)
+
+// binDir returns the directory on the instance that holds the gluon binary.
+func (i ClusterInstance) binDir() string {
+	return path.Join(i.Home(), "bin")
+}
+
+// setupGluon extracts gluon from the given image, records the given arguments and runs `gluon setup`.
+func (i ClusterInstance) setupGluon(ctx context.Context, s InstanceConnection, log *logging.Logger, gluonImage string, gluonArgs []string) error {
+	log.Infof("Downloading gluon on %s", i)
+	binDir := i.binDir()
+	if _, err := s.Run(ctx, log, fmt.Sprintf("sudo /usr/bin/mkdir -p %s", binDir), "", false); err != nil {
+		return maskAny(err)
+	}
+	// Docker registry is not always stable to retry if needed
+	extractGluon := func() error {
+		if _, err := s.Run(ctx, log, fmt.Sprintf("docker run --rm -v %s:/destination/ %s", binDir, gluonImage), "", false); err != nil {
+			log.Warningf("Extracting gluon failed: %#v", err)
+			return maskAny(err)
+		}
+		return nil
+	}
+	if err := Retry(ctx, extractGluon, backoff.NewExponentialBackOff()); err != nil {
+		return maskAny(err)
+	}
+
+	// Store the arguments, so gluon can be upgraded later with the same settings
+	publicArgs, secretArgs := splitSecretArgs(gluonArgs)
+	if err := s.WriteFile(ctx, log, gluonArgsPath, strings.Join(publicArgs, "\n"), 0400, ""); err != nil {
+		return maskAny(err)
+	}
+	if err := s.WriteFile(ctx, log, gluonSecretArgsPath, strings.Join(secretArgs, "\n"), 0400, ""); err != nil {
+		return maskAny(err)
+	}
+
+	log.Infof("Running gluon on %s", i)
+	log.Debugf("Gluon args on %s: %s", i, RedactCommand(strings.Join(gluonArgs, " ")))
+	gluonPath := path.Join(binDir, "gluon")
+	if _, err := s.Run(ctx, log, fmt.Sprintf("sudo %s setup %s", gluonPath, strings.Join(gluonArgs, " ")), "", false); err != nil {
+		return maskAny(err)
+	}
+	return nil
+}
+
+// UpgradeGluon extracts gluon from the given image and re-runs `gluon setup` with the
+// existing settings of the instance. Afterwards the instance is rebooted and this function
+// waits until etcd (and fleet) are healthy again.
+func (i ClusterInstance) UpgradeGluon(ctx context.Context, log *logging.Logger, gluonImage string, healthTimeout time.Duration, provider CloudProvider) error {
+	s, err := i.Connect()
+	if err != nil {
+		return maskAny(err)
+	}
+	defer s.Close()
+
+	gluonArgs, err := s.GetGluonArgs(ctx, log)
+	if err != nil {
+		return maskAny(err)
+	}
+	if len(gluonArgs) == 0 {
+		return maskAny(errgo.WithCausef(nil, NotFoundError, "no gluon settings found on %s", i))
+	}
+	gluonArgs = replaceGluonImage(gluonArgs, gluonImage)
+	if err := i.setupGluon(ctx, s, log, gluonImage, gluonArgs); err != nil {
+		return maskAny(err)
+	}
+
+	log.Infof("Rebooting %s", i)
+	if err := i.reboot(ctx, log, s, provider); err != nil {
+		return maskAny(err)
+	}
+	if err := i.WaitUntilHealthy(ctx, log, healthTimeout); err != nil {
+		return maskAny(err)
+	}
+	return nil
+}
+
+// WaitUntilHealthy blocks until etcd reports a healthy cluster on the instance
+// and fleet (when enabled) lists the cluster machines, or the given timeout has passed.
+func (i ClusterInstance) WaitUntilHealthy(ctx context.Context, log *logging.Logger, timeout time.Duration) error {
+	log.Infof("Waiting for etcd & fleet to be healthy on %s", i)
+	deadline := time.Now().Add(timeout)
+	for {
+		err := i.checkHealth(ctx, log)
+		if err == nil {
+			log.Infof("%s is healthy", i)
+			return nil
+		}
+		if time.Now().After(deadline) {
+			return maskAny(errgo.Notef(err, "%s is not healthy after %s", i, timeout))
+		}
+		log.Debugf("%s is not yet healthy: %v", i, err)
+		wait := healthCheckWait
+		if remaining := deadline.Sub(time.Now()); remaining < wait {
+			wait = remaining
+		}
+		if err := Sleep(ctx, wait); err != nil {
+			return maskAny(err)
+		}
+	}
+}
+
+// WaitUntilHealthy blocks until all instances of the list are healthy (see ClusterInstance.WaitUntilHealthy),
+// or the given timeout has passed. The timeout applies to the list as a whole.
+func (cil ClusterInstanceList) WaitUntilHealthy(ctx context.Context, log *logging.Logger, timeout time.Duration) error {
+	deadline := time.Now().Add(timeout)
+	for _, i := range cil {
+		if err := i.WaitUntilHealthy(ctx, log, deadline.Sub(time.Now())); err != nil {
+			return maskAny(err)
+		}
+	}
+	return nil
+}
+
+// checkHealth returns an error if etcd or fleet (when enabled) are not healthy on the instance.
+func (i ClusterInstance) checkHealth(ctx context.Context, log *logging.Logger) error {
+	s, err := i.Connect()
+	if err != nil {
+		return maskAny(err)
+	}
+	defer s.Close()
+
+	out, err := s.Run(ctx, log, "etcdctl cluster-health", "", true)
+	if err != nil {
+		return maskAny(err)
+	}
+	if !strings.Contains(out, "cluster is healthy") {
+		return maskAny(fmt.Errorf("etcd cluster is not healthy"))
+	}
+
+	gluonEnv, err := s.GetGluonEnv(ctx, log)
+	if err != nil {
+		return maskAny(err)
+	}
+	if !strings.Contains(gluonEnv, "GLUON_FLEET_ENABLED=false") {
+		if _, err := s.Run(ctx, log, "fleetctl list-machines --no-legend", "", true); err != nil {
+			return maskAny(err)
+		}
+	}
+	return nil
+}
+
+// replaceGluonImage returns the given gluon arguments with the --gluon-image argument set to the given image.
+func replaceGluonImage(gluonArgs []string, gluonImage string) []string {
+	result := []string{gluonImageFlag + gluonImage}
+	for _, arg := range gluonArgs {
+		if !strings.HasPrefix(arg, gluonImageFlag) {
+			result = append(result, arg)
+		}
+	}
+	return result
+}
+
+// splitSecretArgs splits the given gluon arguments in arguments without and with secret values.
+func splitSecretArgs(gluonArgs []string) (publicArgs, secretArgs []string) {
+	for _, arg := range gluonArgs {
+		if isSecretArg(arg) {
+			secretArgs = append(secretArgs, arg)
+		} else {
+			publicArgs = append(publicArgs, arg)
+		}
+	}
+	return publicArgs, secretArgs
+}
+
+// parseGluonServiceArgs returns the arguments of `gluon setup` found in the ExecStart line
+// of the given gluon.service unit, or nil if there is no such line.
+func parseGluonServiceArgs(unit string) []string {
+	for _, line := range strings.Split(unit, "\n") {
+		line = strings.TrimSpace(line)
+		if !strings.HasPrefix(line, "ExecStart=") {
+			continue
+		}
+		fields := strings.Fields(strings.TrimPrefix(line, "ExecStart="))
+		for idx, f := range fields {
+			if f == "setup" && idx > 0 && path.Base(fields[idx-1]) == "gluon" {
+				return fields[idx+1:]
+			}
+		}
+	}
+	return nil
+}
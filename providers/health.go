@@ -0,0 +1,432 @@
+// Copyright (c) 2016 Pulcy.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package providers
+
+import (
+	"context"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/juju/errgo"
+	"github.com/op/go-logging"
+)
+
+// HealthStatus is the outcome of a single health check.
+type HealthStatus string
+
+const (
+	HealthPass HealthStatus = "pass"
+	HealthWarn HealthStatus = "warn"
+	HealthFail HealthStatus = "fail"
+)
+
+// severity returns a number that is higher for worse statuses.
+func (s HealthStatus) severity() int {
+	switch s {
+	case HealthPass:
+		return 0
+	case HealthWarn:
+		return 1
+	default:
+		return 2
+	}
+}
+
+// HealthCheck is the result of a single check of a cluster, or of one of its instances.
+type HealthCheck struct {
+	Check    string       `json:"check"`
+	Instance string       `json:"instance,omitempty"` // Empty for cluster wide checks
+	Status   HealthStatus `json:"status"`
+	Message  string       `json:"message"`
+}
+
+// HealthReport holds the results of all health checks of a cluster.
+type HealthReport []HealthCheck
+
+// Status returns the worst status of all checks in the report.
+func (r HealthReport) Status() HealthStatus {
+	result := HealthPass
+	for _, c := range r {
+		if c.Status.severity() > result.severity() {
+			result = c.Status
+		}
+	}
+	return result
+}
+
+// HealthOptions specifies how a cluster is checked.
+type HealthOptions struct {
+	ClusterInfo       ClusterInfo   // Cluster whose DNS records are checked
+	DnsProvider       DnsProvider   // Provider used to check the DNS records of the cluster (nil to skip)
+	CertExpiryWarning time.Duration // Warn when the vault CA certificate expires within this period
+}
+
+const (
+	healthCheckSSH       = "ssh"
+	healthCheckClusterID = "cluster-id"
+	healthCheckEtcd      = "etcd"
+	healthCheckGluon     = "gluon"
+	healthCheckTinc      = "tinc"
+	healthCheckVaultCA   = "vault-ca"
+	healthCheckDNS       = "dns"
+
+	pingTimeoutSeconds = 2
+)
+
+// healthReporter collects health checks from multiple goroutines.
+type healthReporter struct {
+	mutex  sync.Mutex
+	report HealthReport
+}
+
+func (r *healthReporter) add(check, instance string, status HealthStatus, format string, args ...interface{}) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.report = append(r.report, HealthCheck{
+		Check:    check,
+		Instance: instance,
+		Status:   status,
+		Message:  fmt.Sprintf(format, args...),
+	})
+}
+
+// CheckHealth checks the SSH reachability, cluster ID, etcd, gluon, tinc mesh & vault CA certificate
+// of all instances in the given list, as well as the DNS records of the cluster.
+func (cil ClusterInstanceList) CheckHealth(ctx context.Context, log *logging.Logger, options HealthOptions) HealthReport {
+	r := &healthReporter{}
+
+	// Only check instances that can be reached
+	reachable := make([]bool, len(cil))
+	clusterIDs := make([]string, len(cil))
+	wg := sync.WaitGroup{}
+	for idx, i := range cil {
+		wg.Add(1)
+		go func(idx int, i ClusterInstance) {
+			defer wg.Done()
+			s, err := i.Connect()
+			if err != nil {
+				r.add(healthCheckSSH, i.Name, HealthFail, "cannot connect: %v", err)
+				return
+			}
+			defer s.Close()
+			if _, err := s.Run(ctx, log, "true", "", true); err != nil {
+				r.add(healthCheckSSH, i.Name, HealthFail, "cannot run commands: %v", err)
+				return
+			}
+			r.add(healthCheckSSH, i.Name, HealthPass, "reachable as %s@%s", i.User(), i.sshOptions().address())
+			reachable[idx] = true
+
+			if id, err := s.GetClusterID(ctx, log); err != nil {
+				r.add(healthCheckClusterID, i.Name, HealthFail, "cannot read cluster-id: %v", err)
+			} else {
+				clusterIDs[idx] = id
+			}
+			checkGluonHealth(ctx, log, r, i, s)
+			checkVaultCAHealth(ctx, log, r, i, s, options.CertExpiryWarning)
+		}(idx, i)
+	}
+	wg.Wait()
+
+	available := ClusterInstanceList{}
+	for idx, i := range cil {
+		if reachable[idx] {
+			available = append(available, i)
+		}
+	}
+	checkClusterIDHealth(r, cil, clusterIDs)
+	if len(available) > 0 {
+		checkEtcdHealth(ctx, log, r, available[0])
+	}
+	checkTincHealth(ctx, log, r, available, cil)
+	if options.DnsProvider != nil {
+		checkDNSHealth(ctx, log, r, available, options)
+	}
+
+	report := r.report
+	sort.Sort(healthReportOrder(report))
+	return report
+}
+
+// checkClusterIDHealth checks that all instances have the same cluster ID.
+// The ID found on most instances is considered the cluster ID.
+func checkClusterIDHealth(r *healthReporter, cil ClusterInstanceList, clusterIDs []string) {
+	counts := make(map[string]int)
+	expected := ""
+	for _, id := range clusterIDs {
+		if id == "" {
+			continue
+		}
+		counts[id]++
+		if counts[id] > counts[expected] || (counts[id] == counts[expected] && id < expected) {
+			expected = id
+		}
+	}
+	for idx, i := range cil {
+		switch id := clusterIDs[idx]; id {
+		case "":
+			// Not reachable or already reported
+		case expected:
+			r.add(healthCheckClusterID, i.Name, HealthPass, "%s", id)
+		default:
+			r.add(healthCheckClusterID, i.Name, HealthFail, "%s differs from %s", id, expected)
+		}
+	}
+}
+
+// checkEtcdHealth checks the health of all etcd members and the quorum, as seen from the given instance.
+func checkEtcdHealth(ctx context.Context, log *logging.Logger, r *healthReporter, i ClusterInstance) {
+	client, err := i.EtcdClient(ctx, log)
+	if err != nil {
+		r.add(healthCheckEtcd, "", HealthFail, "cannot connect to etcd on %s: %v", i.Name, err)
+		return
+	}
+	defer client.Close()
+	members, err := client.Members()
+	if err != nil {
+		r.add(healthCheckEtcd, "", HealthFail, "cannot list members: %v", err)
+		return
+	}
+	healthy := 0
+	var unhealthy []string
+	for _, m := range members {
+		ok, err := client.IsHealthy(m)
+		if err == nil && ok {
+			healthy++
+			continue
+		}
+		name := m.Name
+		if name == "" {
+			name = m.ID
+		}
+		unhealthy = append(unhealthy, name)
+	}
+	total := len(members)
+	leader := "no leader"
+	if m, err := client.Leader(); err == nil {
+		leader = "leader " + m.Name
+	}
+	switch {
+	case total == 0:
+		r.add(healthCheckEtcd, "", HealthFail, "no members found")
+	case healthy <= total/2:
+		r.add(healthCheckEtcd, "", HealthFail, "%d of %d members healthy, no quorum, %s (unhealthy: %s)", healthy, total, leader, strings.Join(unhealthy, ", "))
+	case healthy < total:
+		r.add(healthCheckEtcd, "", HealthWarn, "%d of %d members healthy, %s (unhealthy: %s)", healthy, total, leader, strings.Join(unhealthy, ", "))
+	default:
+		r.add(healthCheckEtcd, "", HealthPass, "%d of %d members healthy, %s", healthy, total, leader)
+	}
+}
+
+// checkGluonHealth checks that the gluon service is active on the given instance.
+func checkGluonHealth(ctx context.Context, log *logging.Logger, r *healthReporter, i ClusterInstance, s InstanceConnection) {
+	out, err := s.Run(ctx, log, "sh -c 'systemctl is-active gluon.service || true'", "", true)
+	if err != nil {
+		r.add(healthCheckGluon, i.Name, HealthFail, "cannot query gluon.service: %v", err)
+		return
+	}
+	if state := strings.TrimSpace(out); state != "active" {
+		r.add(healthCheckGluon, i.Name, HealthFail, "gluon.service is %s", state)
+		return
+	}
+	r.add(healthCheckGluon, i.Name, HealthPass, "gluon.service is active")
+}
+
+// checkVaultCAHealth checks that the vault CA certificate on the given instance has not expired
+// and does not expire within the given period.
+func checkVaultCAHealth(ctx context.Context, log *logging.Logger, r *healthReporter, i ClusterInstance, s InstanceConnection, warning time.Duration) {
+	content, err := s.GetVaultCrt(ctx, log)
+	if err != nil {
+		r.add(healthCheckVaultCA, i.Name, HealthFail, "cannot read vault.crt: %v", err)
+		return
+	}
+	block, _ := pem.Decode([]byte(content))
+	if block == nil {
+		r.add(healthCheckVaultCA, i.Name, HealthFail, "vault.crt contains no PEM data")
+		return
+	}
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		r.add(healthCheckVaultCA, i.Name, HealthFail, "cannot parse vault.crt: %v", err)
+		return
+	}
+	expires := cert.NotAfter.UTC().Format("2006-01-02")
+	switch left := cert.NotAfter.Sub(time.Now()); {
+	case left <= 0:
+		r.add(healthCheckVaultCA, i.Name, HealthFail, "expired on %s", expires)
+	case left < warning:
+		r.add(healthCheckVaultCA, i.Name, HealthWarn, "expires on %s", expires)
+	default:
+		r.add(healthCheckVaultCA, i.Name, HealthPass, "expires on %s", expires)
+	}
+}
+
+// checkTincHealth checks that every instance can reach every other instance over the tinc VPN.
+func checkTincHealth(ctx context.Context, log *logging.Logger, r *healthReporter, available, all ClusterInstanceList) {
+	wg := sync.WaitGroup{}
+	for _, i := range available {
+		wg.Add(1)
+		go func(i ClusterInstance) {
+			defer wg.Done()
+			s, err := i.Connect()
+			if err != nil {
+				r.add(healthCheckTinc, i.Name, HealthFail, "cannot connect: %v", err)
+				return
+			}
+			defer s.Close()
+
+			var mutex sync.Mutex
+			var unreachable []string
+			peers := all.Except(i)
+			pwg := sync.WaitGroup{}
+			for _, peer := range peers {
+				pwg.Add(1)
+				go func(peer ClusterInstance) {
+					defer pwg.Done()
+					cmd := fmt.Sprintf("ping -c 1 -W %d %s", pingTimeoutSeconds, peer.ClusterIP)
+					if _, err := s.Run(ctx, log, cmd, "", true); err != nil {
+						mutex.Lock()
+						unreachable = append(unreachable, peer.Name)
+						mutex.Unlock()
+					}
+				}(peer)
+			}
+			pwg.Wait()
+
+			if len(unreachable) > 0 {
+				sort.Strings(unreachable)
+				r.add(healthCheckTinc, i.Name, HealthFail, "cannot reach %s", strings.Join(unreachable, ", "))
+				return
+			}
+			r.add(healthCheckTinc, i.Name, HealthPass, "reaches all %d peers", len(peers))
+		}(i)
+	}
+	wg.Wait()
+}
+
+// checkDNSHealth checks that the A & AAAA records of the cluster name point to exactly
+// the load-balancer instances of the cluster.
+func checkDNSHealth(ctx context.Context, log *logging.Logger, r *healthReporter, available ClusterInstanceList, options HealthOptions) {
+	records, err := options.DnsProvider.GetDomainRecords(ctx, options.ClusterInfo.Domain)
+	if err != nil {
+		r.add(healthCheckDNS, "", HealthFail, "cannot list DNS records of %s: %v", options.ClusterInfo.Domain, err)
+		return
+	}
+
+	// Find the load-balancer instances
+	expected := map[string][]string{"A": nil, "AAAA": nil}
+	for _, i := range available {
+		roles, err := i.GetRoles(ctx, log)
+		if errgo.Cause(err) == UnknownRolesError {
+			r.add(healthCheckDNS, i.Name, HealthWarn, "unknown roles, cannot tell if it is a load-balancer")
+			continue
+		} else if err != nil {
+			r.add(healthCheckDNS, i.Name, HealthFail, "cannot read roles: %v", err)
+			return
+		}
+		if !hasRole(roles, "lb") {
+			continue
+		}
+		if i.LoadBalancerIPv4 != "" {
+			expected["A"] = append(expected["A"], i.LoadBalancerIPv4)
+		}
+		if i.LoadBalancerIPv6 != "" {
+			expected["AAAA"] = append(expected["AAAA"], i.LoadBalancerIPv6)
+		}
+	}
+
+	name := options.ClusterInfo.String()
+	fqdn := DnsRecord{Name: name}.FQDN(options.ClusterInfo.Domain)
+	for _, recordType := range []string{"A", "AAAA"} {
+		var actual []string
+		for _, record := range records {
+			if record.Type == recordType && record.FQDN(options.ClusterInfo.Domain) == fqdn {
+				actual = append(actual, record.Data)
+			}
+		}
+		missing := subtractStrings(expected[recordType], actual)
+		extra := subtractStrings(actual, expected[recordType])
+		switch {
+		case len(missing) > 0 || len(extra) > 0:
+			var problems []string
+			if len(missing) > 0 {
+				problems = append(problems, "missing "+strings.Join(missing, ", "))
+			}
+			if len(extra) > 0 {
+				problems = append(problems, "unexpected "+strings.Join(extra, ", "))
+			}
+			r.add(healthCheckDNS, "", HealthFail, "%s %s: %s", recordType, name, strings.Join(problems, "; "))
+		case len(actual) == 0:
+			// No records and none expected (e.g. no IPv6)
+		default:
+			r.add(healthCheckDNS, "", HealthPass, "%s %s: %s", recordType, name, strings.Join(actual, ", "))
+		}
+	}
+}
+
+// hasRole returns true if the given comma separated list of roles contains the given role.
+func hasRole(roles, role string) bool {
+	for _, r := range strings.Split(roles, ",") {
+		if strings.TrimSpace(r) == role {
+			return true
+		}
+	}
+	return false
+}
+
+// subtractStrings returns all elements of a that are not in b, sorted.
+func subtractStrings(a, b []string) []string {
+	var result []string
+	for _, x := range a {
+		found := false
+		for _, y := range b {
+			if x == y {
+				found = true
+				break
+			}
+		}
+		if !found {
+			result = append(result, x)
+		}
+	}
+	sort.Strings(result)
+	return result
+}
+
+// healthReportOrder sorts checks in the order in which they are performed, then by instance name.
+type healthReportOrder HealthReport
+
+func (l healthReportOrder) Len() int { return len(l) }
+func (l healthReportOrder) Less(i, j int) bool {
+	if l[i].Check != l[j].Check {
+		return healthCheckRank(l[i].Check) < healthCheckRank(l[j].Check)
+	}
+	return l[i].Instance < l[j].Instance
+}
+func (l healthReportOrder) Swap(i, j int) { l[i], l[j] = l[j], l[i] }
+
+// healthCheckRank returns the position of the given check in a report.
+func healthCheckRank(check string) int {
+	for idx, c := range []string{healthCheckSSH, healthCheckClusterID, healthCheckEtcd, healthCheckGluon, healthCheckTinc, healthCheckVaultCA, healthCheckDNS} {
+		if c == check {
+			return idx
+		}
+	}
+	return -1
+}
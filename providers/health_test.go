@@ -0,0 +1,108 @@
+// Copyright (c) 2016 Pulcy.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package providers_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/pulcy/quark/providers"
+)
+
+// recordsDnsProvider is a DNS provider that only lists a fixed set of records.
+type recordsDnsProvider struct {
+	providers.DnsProvider
+	records providers.DnsRecordList
+}
+
+func (p recordsDnsProvider) GetDomainRecords(ctx context.Context, domain string) (providers.DnsRecordList, error) {
+	return p.records, nil
+}
+
+func TestDnsRecordFQDN(t *testing.T) {
+	tests := []struct {
+		Name     string
+		Domain   string
+		Expected string
+	}{
+		{"@", "example.com", "example.com"},
+		{"", "example.com", "example.com"},
+		{"c1", "example.com", "c1.example.com"},
+		{"C1", "Example.com.", "c1.example.com"},
+		{"c1.example.com", "example.com", "c1.example.com"},
+		{"c1.example.com.", "example.com", "c1.example.com"},
+		{"example.com", "example.com", "example.com"},
+		{"other.org.", "example.com", "other.org"},
+	}
+	for _, test := range tests {
+		if actual := (providers.DnsRecord{Name: test.Name}).FQDN(test.Domain); actual != test.Expected {
+			t.Errorf("FQDN of '%s' in %s: expected %s, got %s", test.Name, test.Domain, test.Expected, actual)
+		}
+	}
+}
+
+func TestCheckHealthDNS(t *testing.T) {
+	c := newTestCluster(t, 2)
+	defer c.Close()
+	for _, s := range c.Servers {
+		s.FS.WriteFile("/etc/pulcy/roles", "core,lb", 0644, "root")
+	}
+	info := providers.ClusterInfo{Name: "c1", Domain: "example.com"}
+
+	tests := []struct {
+		Records  providers.DnsRecordList
+		Status   providers.HealthStatus
+		Contains string
+	}{
+		// Relative names, as returned by DigitalOcean
+		{providers.DnsRecordList{
+			{Type: "A", Name: "c1", Data: "10.0.0.1"},
+			{Type: "A", Name: "c1.example.com.", Data: "10.0.0.2"},
+			{Type: "A", Name: "@", Data: "10.0.0.3"},
+			{Type: "A", Name: "i1.c1", Data: "10.0.0.1"},
+		}, providers.HealthPass, "10.0.0.1, 10.0.0.2"},
+		// Fully qualified names
+		{providers.DnsRecordList{
+			{Type: "A", Name: "c1.example.com", Data: "10.0.0.1"},
+			{Type: "A", Name: "c1.example.com", Data: "10.0.0.2"},
+		}, providers.HealthPass, "10.0.0.1, 10.0.0.2"},
+		// Missing & unexpected records
+		{providers.DnsRecordList{
+			{Type: "A", Name: "c1", Data: "10.0.0.1"},
+			{Type: "A", Name: "c1", Data: "10.0.0.9"},
+		}, providers.HealthFail, "missing 10.0.0.2; unexpected 10.0.0.9"},
+	}
+	for _, test := range tests {
+		options := providers.HealthOptions{
+			ClusterInfo: info,
+			DnsProvider: recordsDnsProvider{records: test.Records},
+		}
+		report := c.Instances.CheckHealth(context.Background(), testLog, options)
+		found := false
+		for _, check := range report {
+			if check.Check != "dns" {
+				continue
+			}
+			found = true
+			if check.Status != test.Status || !strings.Contains(check.Message, test.Contains) {
+				t.Errorf("Records %v: expected %s containing '%s', got %s '%s'", test.Records, test.Status, test.Contains, check.Status, check.Message)
+			}
+		}
+		if !found {
+			t.Errorf("Records %v: expected a dns check in %v", test.Records, report)
+		}
+	}
+}
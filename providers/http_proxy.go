@@ -0,0 +1,97 @@
+// Copyright (c) 2016 Pulcy.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package providers
+
+import (
+	"fmt"
+	"strings"
+)
+
+const (
+	// Used to test the internet connection of instances behind a HTTP proxy
+	proxyTestURL = "https://registry-1.docker.io/v2/"
+)
+
+var (
+	// Destinations of all clusters that are never accessed through the HTTP proxy:
+	// the loopback & link-local addresses (cloud metadata services) and the private network ranges.
+	defaultNoProxy = []string{"localhost", "127.0.0.1", "169.254.0.0/16", "10.0.0.0/8", "172.16.0.0/12", "192.168.0.0/16"}
+	httpProxyVars  = []string{"HTTP_PROXY", "HTTPS_PROXY", "NO_PROXY", "http_proxy", "https_proxy", "no_proxy"}
+)
+
+// NoProxy returns the destinations that are never accessed through the HTTP proxy by the instance:
+// the defaults, the tinc network and the instances of the cluster.
+func (o CreateInstanceOptions) NoProxy() string {
+	list := append([]string{}, defaultNoProxy...)
+	if o.TincCIDR != "" {
+		list = append(list, o.TincCIDR)
+	}
+	if o.ClusterInfo.Name != "" && o.ClusterInfo.Domain != "" {
+		list = append(list, o.ClusterInfo.String())
+	}
+	return strings.Join(list, ",")
+}
+
+// httpProxyEnv returns the environment variables that make processes use the given HTTP proxy.
+func httpProxyEnv(httpProxy, noProxy string) []string {
+	if httpProxy == "" {
+		return nil
+	}
+	return []string{
+		fmt.Sprintf("HTTP_PROXY=%s", httpProxy),
+		fmt.Sprintf("HTTPS_PROXY=%s", httpProxy),
+		fmt.Sprintf("NO_PROXY=%s", noProxy),
+		fmt.Sprintf("http_proxy=%s", httpProxy),
+		fmt.Sprintf("https_proxy=%s", httpProxy),
+		fmt.Sprintf("no_proxy=%s", noProxy),
+	}
+}
+
+// withHttpProxyEnv returns the given environment file content with all HTTP proxy
+// variables replaced by those for the given HTTP proxy and destinations without proxy.
+func withHttpProxyEnv(env, httpProxy, noProxy string) string {
+	lines := []string{}
+	for _, line := range strings.Split(env, "\n") {
+		if isHttpProxyVar(line) || strings.TrimSpace(line) == "" {
+			continue
+		}
+		lines = append(lines, line)
+	}
+	lines = append(lines, httpProxyEnv(httpProxy, noProxy)...)
+	return strings.Join(lines, "\n")
+}
+
+// HttpProxyFromEnv returns the HTTP proxy configured in the given environment file content.
+// If no HTTP proxy is configured, an empty string is returned.
+func HttpProxyFromEnv(env string) string {
+	const prefix = "HTTP_PROXY="
+	for _, line := range strings.Split(env, "\n") {
+		line = strings.TrimSpace(line)
+		if strings.HasPrefix(line, prefix) {
+			return strings.TrimSpace(line[len(prefix):])
+		}
+	}
+	return ""
+}
+
+func isHttpProxyVar(line string) bool {
+	line = strings.TrimSpace(line)
+	for _, name := range httpProxyVars {
+		if strings.HasPrefix(line, name+"=") {
+			return true
+		}
+	}
+	return false
+}
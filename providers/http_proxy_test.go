@@ -0,0 +1,56 @@
+// Copyright (c) 2016 Pulcy.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package providers
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestNoProxy(t *testing.T) {
+	o := CreateInstanceOptions{
+		ClusterInfo: ClusterInfo{Name: "c47", Domain: "pulcy.com"},
+		TincCIDR:    "192.168.35.0/24",
+	}
+	noProxy := strings.Split(o.NoProxy(), ",")
+	for _, expected := range []string{"localhost", "127.0.0.1", "169.254.0.0/16", "10.0.0.0/8", "172.16.0.0/12", "192.168.0.0/16", "192.168.35.0/24", "c47.pulcy.com"} {
+		found := false
+		for _, x := range noProxy {
+			found = found || x == expected
+		}
+		if !found {
+			t.Errorf("Expected %s in NoProxy, got %v", expected, noProxy)
+		}
+	}
+}
+
+func TestWithHttpProxyEnv(t *testing.T) {
+	env := "GLUON_FLEET_ENABLED=true\nHTTP_PROXY=http://old:3128\nno_proxy=localhost\n"
+	expected := strings.Join([]string{
+		"GLUON_FLEET_ENABLED=true",
+		"HTTP_PROXY=http://proxy:3128",
+		"HTTPS_PROXY=http://proxy:3128",
+		"NO_PROXY=localhost,10.0.0.0/8",
+		"http_proxy=http://proxy:3128",
+		"https_proxy=http://proxy:3128",
+		"no_proxy=localhost,10.0.0.0/8",
+	}, "\n")
+	if result := withHttpProxyEnv(env, "http://proxy:3128", "localhost,10.0.0.0/8"); result != expected {
+		t.Errorf("Expected '%s', got '%s'", expected, result)
+	}
+	if result := withHttpProxyEnv(env, "", "localhost"); result != "GLUON_FLEET_ENABLED=true" {
+		t.Errorf("Expected proxy variables to be removed, got '%s'", result)
+	}
+}
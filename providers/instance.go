@@ -15,15 +15,16 @@
 package providers
 
 import (
+	"context"
 	"fmt"
 	"net"
-	"path"
+	"os"
 	"strconv"
 	"strings"
 	"time"
 
-	"github.com/cenkalti/backoff"
 	"github.com/coreos/go-semver/semver"
+	"github.com/juju/errgo"
 	"github.com/op/go-logging"
 )
 
@@ -42,21 +43,25 @@ const (
 
 // ClusterInstance describes a single instance
 type ClusterInstance struct {
-	ID               string // Provider specific ID of the server (only used by provider, can be empty)
-	Name             string // Name of the instance as known by the provider
-	ClusterIP        string // IPv4 address of the instance used for all private communication in the cluster
-	LoadBalancerIPv4 string // IPv4 address of the instance on which the load-balancer is listening (can be empty)
-	LoadBalancerIPv6 string // IPv6 address of the instance on which the load-balancer is listening (can be empty)
-	IsGateway        bool   // If set, this instance can be used as a gateway by instances that have not direct IPv4 internet connection
-	LoadBalancerDNS  string // Provider hosted public DNS name of the instance on which the load-balancer is listening (can be empty)
-	ClusterDevice    string // Device name of the nic that is configured for the ClusterIP
-	PrivateIP        string // IP address of the instance's private network (can be same as ClusterIP)
-	PrivateNetwork   net.IPNet
-	PrivateDNS       string   // Provider hosted private DNS name of the instance's private network
-	UserName         string   // Account name used to SSH into this instance. (empty defaults to 'core')
-	OS               OSName   // Name of the OS on the instance
-	Extra            []string // Extra informational data
-	EtcdProxy        *bool
+	ID                 string // Provider specific ID of the server (only used by provider, can be empty)
+	Name               string // Name of the instance as known by the provider
+	ClusterIP          string // IPv4 address of the instance used for all private communication in the cluster
+	LoadBalancerIPv4   string // IPv4 address of the instance on which the load-balancer is listening (can be empty)
+	LoadBalancerIPv6   string // IPv6 address of the instance on which the load-balancer is listening (can be empty)
+	IsGateway          bool   // If set, this instance can be used as a gateway by instances that have not direct IPv4 internet connection
+	LoadBalancerDNS    string // Provider hosted public DNS name of the instance on which the load-balancer is listening (can be empty)
+	ClusterDevice      string // Device name of the nic that is configured for the ClusterIP
+	PrivateIP          string // IP address of the instance's private network (can be same as ClusterIP)
+	PrivateNetwork     net.IPNet
+	PrivateDNS         string   // Provider hosted private DNS name of the instance's private network
+	UserName           string   // Account name used to SSH into this instance. (empty defaults to 'core')
+	OS                 OSName   // Name of the OS on the instance
+	Extra              []string // Extra informational data
+	EtcdProxy          *bool
+	HostKeyFingerprint string // Fingerprint of the SSH host key as reported by the provider (can be empty, only the fake provider reports it)
+	JumpHost           string // SSH jump host ([user@]host[:port]) used to reach this instance when it has no public address (can be empty)
+	SSHPort            int    // Port of the SSH server on this instance (0 defaults to the configured port or 22)
+	SSHKeyFile         string // Private key file used to SSH into this instance (can be empty)
 }
 
 // Equals returns true of the given cluster instances refer to the same instance.
@@ -86,12 +91,65 @@ func (i ClusterInstance) hostAddress(addBrackets bool) string {
 	return s
 }
 
-// User returns the standard username of this instance
+// User returns the account name used to SSH into this instance.
+// It is taken from the instance, the SSH settings or the SSH config file (in that order)
+// and defaults to 'core'.
 func (i ClusterInstance) User() string {
-	if i.UserName == "" {
-		return defaultUsername
+	if i.UserName != "" {
+		return i.UserName
 	}
-	return i.UserName
+	if sshSettings.User != "" {
+		return sshSettings.User
+	}
+	if user := sshConfig.Lookup(i.sshHostNames()...).User; user != "" {
+		return user
+	}
+	return defaultUsername
+}
+
+// Port returns the port of the SSH server on this instance.
+// It is taken from the instance, the SSH settings or the SSH config file (in that order)
+// and defaults to 22.
+func (i ClusterInstance) Port() int {
+	if i.SSHPort != 0 {
+		return i.SSHPort
+	}
+	if sshSettings.Port != 0 {
+		return sshSettings.Port
+	}
+	if port := sshConfig.Lookup(i.sshHostNames()...).Port; port != 0 {
+		return port
+	}
+	return sshPort
+}
+
+// sshOptions returns the options used to open an SSH connection to this instance.
+func (i ClusterInstance) sshOptions() SSHOptions {
+	hostAddress, jumpHost := i.sshRoute()
+	var keyFiles []string
+	if i.SSHKeyFile != "" {
+		keyFiles = append(keyFiles, i.SSHKeyFile)
+	}
+	keyFiles = append(keyFiles, sshSettings.KeyFiles...)
+	for _, path := range sshConfig.Lookup(i.sshHostNames()...).KeyFiles {
+		// Key files from the SSH config file are optional (like in OpenSSH)
+		if _, err := os.Stat(path); err == nil {
+			keyFiles = append(keyFiles, path)
+		}
+	}
+	return SSHOptions{
+		UserName: i.User(),
+		Host:     hostAddress,
+		Port:     i.Port(),
+		JumpHost: jumpHost,
+		KeyFiles: keyFiles,
+	}
+}
+
+// sshHostNames returns the names by which this instance is looked up in the SSH config file.
+func (i ClusterInstance) sshHostNames() []string {
+	hostAddress, _ := i.sshRoute()
+	return []string{i.Name, hostAddress}
 }
 
 // User returns the standard home directory instance
@@ -105,37 +163,74 @@ func (i ClusterInstance) Home() string {
 }
 
 // IsSSHPortOpen checks if the SSH port on this instance is open for communications.
-func (i ClusterInstance) IsSSHPortOpen(log *logging.Logger) (bool, error) {
+func (i ClusterInstance) IsSSHPortOpen(ctx context.Context, log *logging.Logger) (bool, error) {
 	log.Debugf("Testing SSH port status on %s", i)
-	hostAddress := i.String()
-	if hostAddress == "" {
+	options := i.sshOptions()
+	if options.Host == "" {
 		return false, maskAny(fmt.Errorf("don't have any address to communicate with instance %s", i.Name))
 	}
-	return isTCPPortOpen(hostAddress, sshPort), nil
+	if options.JumpHost != "" {
+		// The port cannot be tested directly, let the SSH connection find out
+		return true, nil
+	}
+	return isTCPPortOpen(options.Host, options.Port), nil
 }
 
 // Connect opens an SSH session to the instance.
 // Make sure to close the session when done.
 func (i ClusterInstance) Connect() (InstanceConnection, error) {
-	hostAddress := i.String()
-	if hostAddress == "" {
+	client, err := i.pooledClient()
+	if err != nil {
+		return nil, maskAny(err)
+	}
+	return &instanceConnection{client, client.conn.options.Host}, nil
+}
+
+// pooledClient returns a client that uses the connection to the instance that is shared
+// with all other users of the instance.
+// Make sure to close the client when done.
+func (i ClusterInstance) pooledClient() (*pooledClient, error) {
+	options := i.sshOptions()
+	if options.Host == "" {
 		return nil, maskAny(fmt.Errorf("don't have any address to communicate with instance %s", i.Name))
 	}
-	client, err := SSHConnect(i.User(), hostAddress)
+	if knownHosts != nil {
+		knownHosts.Expect(options.knownHostsHost(), i.HostKeyFingerprint)
+	}
+	client, err := sshConnections.get(options)
 	if err != nil {
 		return nil, maskAny(err)
 	}
 	return client, nil
 }
 
+// PinHostKey trusts the SSH host key that is currently presented by the instance,
+// replacing the known host key (if any). Use this after an instance has been rebuilt.
+func (i ClusterInstance) PinHostKey() error {
+	options := i.sshOptions()
+	if options.Host == "" {
+		return maskAny(fmt.Errorf("don't have any address to communicate with instance %s", i.Name))
+	}
+	if knownHosts != nil {
+		knownHosts.Repin(options.knownHostsHost())
+	}
+	// Make sure the host key is verified by a new connection
+	sshConnections.forget(options)
+	s, err := i.Connect()
+	if err != nil {
+		return maskAny(err)
+	}
+	return maskAny(s.Close())
+}
+
 // GetMachineID loads the machine specific unique ID of the instance.
-func (i ClusterInstance) GetMachineID(log *logging.Logger) (string, error) {
+func (i ClusterInstance) GetMachineID(ctx context.Context, log *logging.Logger) (string, error) {
 	s, err := i.Connect()
 	if err != nil {
 		return "", maskAny(err)
 	}
 	defer s.Close()
-	id, err := s.GetMachineID(log)
+	id, err := s.GetMachineID(ctx, log)
 	if err != nil {
 		return "", maskAny(err)
 	}
@@ -143,7 +238,7 @@ func (i ClusterInstance) GetMachineID(log *logging.Logger) (string, error) {
 }
 
 // IsEtcdProxy returns true if the instance in an ETCD proxy.
-func (i ClusterInstance) IsEtcdProxy(log *logging.Logger) (bool, error) {
+func (i ClusterInstance) IsEtcdProxy(ctx context.Context, log *logging.Logger) (bool, error) {
 	if i.EtcdProxy != nil {
 		return *i.EtcdProxy, nil
 	}
@@ -152,7 +247,7 @@ func (i ClusterInstance) IsEtcdProxy(log *logging.Logger) (bool, error) {
 		return false, maskAny(err)
 	}
 	defer s.Close()
-	result, err := s.IsEtcdProxyFromService(log)
+	result, err := s.IsEtcdProxyFromService(ctx, log)
 	if err != nil {
 		return false, maskAny(err)
 	}
@@ -160,7 +255,7 @@ func (i ClusterInstance) IsEtcdProxy(log *logging.Logger) (bool, error) {
 }
 
 // AsClusterMember fetches all data from the instance needed for a ClusterMember and returns that.
-func (i ClusterInstance) AsClusterMember(log *logging.Logger) (ClusterMember, error) {
+func (i ClusterInstance) AsClusterMember(ctx context.Context, log *logging.Logger) (ClusterMember, error) {
 	result := ClusterMember{
 		ClusterIP:     i.ClusterIP,
 		PrivateHostIP: i.PrivateIP,
@@ -171,19 +266,19 @@ func (i ClusterInstance) AsClusterMember(log *logging.Logger) (ClusterMember, er
 	}
 	defer s.Close()
 
-	clusterID, err := s.GetClusterID(log)
+	clusterID, err := s.GetClusterID(ctx, log)
 	if err != nil {
 		return ClusterMember{}, maskAny(err)
 	}
 	result.ClusterID = clusterID
 
-	machineID, err := s.GetMachineID(log)
+	machineID, err := s.GetMachineID(ctx, log)
 	if err != nil {
 		return ClusterMember{}, maskAny(err)
 	}
 	result.MachineID = machineID
 
-	etcdProxy, err := i.IsEtcdProxy(log)
+	etcdProxy, err := i.IsEtcdProxy(ctx, log)
 	if err != nil {
 		return ClusterMember{}, maskAny(err)
 	}
@@ -199,24 +294,82 @@ type InitialSetupOptions struct {
 }
 
 // waitUntilActive blocks until the instance is alive and its machine ID can be fetched.
-func (i ClusterInstance) waitUntilActive(log *logging.Logger) error {
+func (i ClusterInstance) waitUntilActive(ctx context.Context, log *logging.Logger) error {
+	// The instance has (likely) been rebooted, so an existing connection is no longer usable
+	sshConnections.forget(i.sshOptions())
+	ctx, cancel := SSHReadyContext(ctx)
+	defer cancel()
 	for {
 		// Attempt an SSH connection
-		if _, err := i.GetMachineID(log); err == nil {
+		if _, err := i.GetMachineID(ctx, log); err == nil {
 			// Success
 			return nil
 		}
 		// Wait a while
-		time.Sleep(time.Second * 5)
+		if err := Sleep(ctx, time.Second*5); err != nil {
+			return maskAny(errgo.Notef(err, "%s does not accept SSH connections", i))
+		}
+	}
+}
+
+// reboot reboots the instance and blocks until it has booted again and accepts SSH connections.
+// The instance has booted again when its boot ID has changed.
+func (i ClusterInstance) reboot(ctx context.Context, log *logging.Logger, s InstanceConnection, provider CloudProvider) error {
+	bootID, err := s.GetBootID(ctx, log)
+	if err != nil {
+		log.Debugf("Cannot fetch boot ID of %s: %v", i, err)
+	}
+	if err := provider.RebootInstance(ctx, i); err != nil {
+		// This may likely fail
+		log.Debugf("Reboot failed (likely): %#v", err)
+	}
+	if bootID == "" {
+		// Give the instance some time to go down
+		if err := Sleep(ctx, time.Second*5); err != nil {
+			return maskAny(err)
+		}
+		return maskAny(i.waitUntilActive(ctx, log))
+	}
+	return maskAny(i.waitUntilRebooted(ctx, log, bootID))
+}
+
+// waitUntilRebooted blocks until the instance accepts SSH connections with a boot ID
+// that is different from the given boot ID.
+func (i ClusterInstance) waitUntilRebooted(ctx context.Context, log *logging.Logger, oldBootID string) error {
+	// The instance is going down, so an existing connection is no longer usable
+	sshConnections.forget(i.sshOptions())
+	ctx, cancel := SSHReadyContext(ctx)
+	defer cancel()
+	for {
+		if s, err := i.Connect(); err == nil {
+			bootID, err := s.GetBootID(ctx, log)
+			s.Close()
+			if err == nil && bootID != oldBootID {
+				// Success
+				return nil
+			}
+			log.Debugf("%s has not yet rebooted", i)
+		}
+		// Wait a while
+		if err := Sleep(ctx, time.Second*5); err != nil {
+			return maskAny(errgo.Notef(err, "%s has not rebooted", i))
+		}
 	}
 }
 
 // waitUntilInternetConnection blocks until the instance can ping to 8.8.8.8.
-func (i ClusterInstance) waitUntilInternetConnection(log *logging.Logger) error {
+// Behind an HTTP proxy, ping is often blocked, so the proxy is used to reach the docker registry instead.
+func (i ClusterInstance) waitUntilInternetConnection(ctx context.Context, log *logging.Logger, httpProxy string) error {
+	cmd := "ping -c 3 -w 60 8.8.8.8"
+	if httpProxy != "" {
+		cmd = fmt.Sprintf("curl -s -o /dev/null --max-time 60 --proxy %s %s", httpProxy, proxyTestURL)
+	}
+	ctx, cancel := SSHReadyContext(ctx)
+	defer cancel()
 	for {
 		// Attempt an SSH connection
 		if s, err := i.Connect(); err == nil {
-			if _, err := s.Run(log, "ping -c 3 -w 60 8.8.8.8", "", true); err == nil {
+			if _, err := s.Run(ctx, log, cmd, "", true); err == nil {
 				// Success
 				s.Close()
 				return nil
@@ -224,40 +377,40 @@ func (i ClusterInstance) waitUntilInternetConnection(log *logging.Logger) error
 			s.Close()
 		}
 		// Wait a while
-		time.Sleep(time.Second * 2)
+		if err := Sleep(ctx, time.Second*2); err != nil {
+			return maskAny(errgo.Notef(err, "%s has no internet connection", i))
+		}
 	}
 }
 
-// osSetup updates the OS of the instance (if needed)
-func (i ClusterInstance) osSetup(s InstanceConnection, log *logging.Logger, minOSVersion semver.Version, provider CloudProvider) error {
-	v, err := s.GetOSRelease(log)
+// osSetup updates the OS of the instance (if needed).
+// It returns true if the OS was updated.
+func (i ClusterInstance) osSetup(ctx context.Context, s InstanceConnection, log *logging.Logger, minOSVersion semver.Version, provider CloudProvider) (bool, error) {
+	v, err := s.GetOSRelease(ctx, log)
 	if err != nil {
-		return maskAny(err)
+		return false, maskAny(err)
 	}
 	if !v.LessThan(minOSVersion) {
 		// OS is up to date
 		log.Infof("OS on %s is up to date", i)
-		return nil
+		return false, nil
 	}
 	// Run update
 	log.Infof("Updating OS on %s...", i)
-	if _, err := s.Run(log, "sudo update_engine_client -update", "", false); err != nil {
-		return maskAny(err)
+	if _, err := s.Run(ctx, log, "sudo update_engine_client -update", "", false); err != nil {
+		return false, maskAny(err)
 	}
-	if err := provider.RebootInstance(i); err != nil {
-		// This may likely fail
-		log.Debugf("Reboot failed (likely): %#v", err)
+	if err := i.reboot(ctx, log, s, provider); err != nil {
+		return true, maskAny(err)
 	}
-	time.Sleep(time.Second * 5)
-	// Wait until available
-	if err := i.waitUntilActive(log); err != nil {
-		return maskAny(err)
-	}
-	return nil
+	return true, nil
 }
 
 // InitialSetup creates initial files and calls gluon for the first time
-func (i ClusterInstance) InitialSetup(log *logging.Logger, cio CreateInstanceOptions, iso InitialSetupOptions, provider CloudProvider) error {
+func (i ClusterInstance) InitialSetup(ctx context.Context, log *logging.Logger, cio CreateInstanceOptions, iso InitialSetupOptions, provider CloudProvider) error {
+	ctx, cancel := BootstrapContext(ctx)
+	defer cancel()
+
 	s, err := i.Connect()
 	if err != nil {
 		return maskAny(err)
@@ -269,16 +422,13 @@ func (i ClusterInstance) InitialSetup(log *logging.Logger, cio CreateInstanceOpt
 		if err != nil {
 			return maskAny(err)
 		}
-		if err := i.osSetup(s, log, *minOSVersion, provider); err != nil {
+		if _, err := i.osSetup(ctx, s, log, *minOSVersion, provider); err != nil {
 			return maskAny(err)
 		}
 	}
 
-	if _, err := s.Run(log, "sudo /usr/bin/mkdir -p /etc/pulcy", "", false); err != nil {
-		return maskAny(err)
-	}
 	data := iso.ClusterMembers.Render()
-	if _, err := s.Run(log, "sudo tee /etc/pulcy/cluster-members", data, false); err != nil {
+	if err := s.WriteFile(ctx, log, "/etc/pulcy/cluster-members", data, 0644, ""); err != nil {
 		return maskAny(err)
 	}
 
@@ -297,68 +447,38 @@ func (i ClusterInstance) InitialSetup(log *logging.Logger, cio CreateInstanceOpt
 			return maskAny(err)
 		}
 	}
-	if _, err := s.Run(log, "sudo tee /etc/pulcy/vault.env", strings.Join(vaultEnv, "\n"), false); err != nil {
+	if err := s.WriteFile(ctx, log, "/etc/pulcy/vault.env", strings.Join(vaultEnv, "\n"), 0400, ""); err != nil {
 		return maskAny(err)
 	}
-	if _, err := s.Run(log, "sudo chmod 0400 /etc/pulcy/vault.env", "", false); err != nil {
-		return maskAny(err)
-	}
-	if _, err := s.Run(log, "sudo tee /etc/pulcy/vault.crt", vaultCertificate, false); err != nil {
-		return maskAny(err)
-	}
-	if _, err := s.Run(log, "sudo chmod 0400 /etc/pulcy/vault.crt", "", false); err != nil {
+	if err := s.WriteFile(ctx, log, "/etc/pulcy/vault.crt", vaultCertificate, 0400, ""); err != nil {
 		return maskAny(err)
 	}
 	if cio.RoleVault {
-		if _, err := s.Run(log, "sudo /usr/bin/mkdir -p /etc/pulcy/vault", "", false); err != nil {
-			return maskAny(err)
-		}
-		if _, err := s.Run(log, "sudo tee /etc/pulcy/vault/key.pem", vaultServerKey, false); err != nil {
-			return maskAny(err)
-		}
-		if _, err := s.Run(log, "sudo chmod 0400 /etc/pulcy/vault/key.pem", "", false); err != nil {
+		if err := s.WriteFile(ctx, log, "/etc/pulcy/vault/key.pem", vaultServerKey, 0400, ""); err != nil {
 			return maskAny(err)
 		}
 	}
 
-	if _, err := s.Run(log, "sudo tee /etc/pulcy/gluon.env", cio.GluonEnv, false); err != nil {
-		return maskAny(err)
-	}
-	if _, err := s.Run(log, "sudo chmod 0644 /etc/pulcy/gluon.env", "", false); err != nil {
+	gluonEnv := withHttpProxyEnv(cio.GluonEnv, cio.HttpProxy, cio.NoProxy())
+	if err := s.WriteFile(ctx, log, "/etc/pulcy/gluon.env", gluonEnv, 0644, ""); err != nil {
 		return maskAny(err)
 	}
 
-	if _, err := s.Run(log, "sudo tee /etc/pulcy/weave.env", cio.WeaveEnv, false); err != nil {
-		return maskAny(err)
-	}
-	if _, err := s.Run(log, "sudo chmod 0400 /etc/pulcy/weave.env", "", false); err != nil {
+	if err := s.WriteFile(ctx, log, "/etc/pulcy/weave.env", cio.WeaveEnv, 0400, ""); err != nil {
 		return maskAny(err)
 	}
 	if cio.WeaveSeed != "" {
-		if _, err := s.Run(log, "sudo tee /etc/pulcy/weave-seed", cio.WeaveSeed, false); err != nil {
+		if err := s.WriteFile(ctx, log, "/etc/pulcy/weave-seed", cio.WeaveSeed, 0644, ""); err != nil {
 			return maskAny(err)
 		}
 	}
 
-	log.Infof("Waiting for internet connection on %s", i)
-	if err := i.waitUntilInternetConnection(log); err != nil {
+	if err := s.WriteFile(ctx, log, rolesPath, cio.Roles(), 0644, ""); err != nil {
 		return maskAny(err)
 	}
 
-	log.Infof("Downloading gluon on %s", i)
-	binDir := path.Join(i.Home(), "bin")
-	if _, err := s.Run(log, fmt.Sprintf("sudo /usr/bin/mkdir -p %s", binDir), "", false); err != nil {
-		return maskAny(err)
-	}
-	// Docker registry is not always stable to retry if needed
-	extractGluon := func() error {
-		if _, err := s.Run(log, fmt.Sprintf("docker run --rm -v %s:/destination/ %s", binDir, cio.GluonImage), "", false); err != nil {
-			log.Warningf("Extracting gluon failed: %#v", err)
-			return maskAny(err)
-		}
-		return nil
-	}
-	if err := backoff.Retry(extractGluon, backoff.NewExponentialBackOff()); err != nil {
+	log.Infof("Waiting for internet connection on %s", i)
+	if err := i.waitUntilInternetConnection(ctx, log, cio.HttpProxy); err != nil {
 		return maskAny(err)
 	}
 
@@ -375,40 +495,34 @@ func (i ClusterInstance) InitialSetup(log *logging.Logger, cio CreateInstanceOpt
 	if iso.EtcdClusterState != "" {
 		gluonArgs = append(gluonArgs, fmt.Sprintf("--etcd-cluster-state=%s", iso.EtcdClusterState))
 	}
-	log.Infof("Running gluon on %s", i)
-	log.Debugf("Gluon args on %s: %#v", i, gluonArgs)
-	gluonPath := path.Join(binDir, "gluon")
-	if _, err := s.Run(log, fmt.Sprintf("sudo %s setup %s", gluonPath, strings.Join(gluonArgs, " ")), "", false); err != nil {
+	if err := i.setupGluon(ctx, s, log, cio.GluonImage, gluonArgs); err != nil {
 		return maskAny(err)
 	}
 	return nil
 }
 
 // UpdateClusterMembers updates /etc/pulcy/cluster-members on the given instance
-func (i ClusterInstance) UpdateClusterMembers(log *logging.Logger, members ClusterMemberList) error {
+func (i ClusterInstance) UpdateClusterMembers(ctx context.Context, log *logging.Logger, members ClusterMemberList) error {
 	s, err := i.Connect()
 	if err != nil {
 		return maskAny(err)
 	}
 	defer s.Close()
 
-	if _, err := s.Run(log, "sudo /usr/bin/mkdir -p /etc/pulcy", "", false); err != nil {
-		return maskAny(err)
-	}
 	data := members.Render()
-	if _, err := s.Run(log, "sudo tee /etc/pulcy/cluster-members", data, false); err != nil {
+	if err := s.WriteFile(ctx, log, "/etc/pulcy/cluster-members", data, 0644, ""); err != nil {
 		return maskAny(err)
 	}
 
 	log.Infof("Restarting gluon on %s", i)
-	if _, err := s.Run(log, fmt.Sprintf("sudo systemctl restart gluon.service"), "", false); err != nil {
+	if _, err := s.Run(ctx, log, fmt.Sprintf("sudo systemctl restart gluon.service"), "", false); err != nil {
 		return maskAny(err)
 	}
 
 	log.Infof("Enabling services on %s", i)
 	services := []string{"ip4tables.service", "ip6tables.service"}
 	for _, service := range services {
-		if err := s.EnableService(log, service); err != nil {
+		if err := s.EnableService(ctx, log, service); err != nil {
 			return maskAny(err)
 		}
 	}
@@ -1,7 +1,10 @@
 package providers
 
 import (
+	"context"
 	"fmt"
+	"net/http"
+	"os"
 	"strings"
 
 	"github.com/coreos/go-semver/semver"
@@ -13,40 +16,56 @@ type InstanceConnection interface {
 	SSHClient
 
 	// Sync the filesystems on the instance
-	Sync(log *logging.Logger) error
+	Sync(ctx context.Context, log *logging.Logger) error
 
 	// Exec executes a command on the instance
-	Exec(log *logging.Logger, command string) (string, error)
+	Exec(ctx context.Context, log *logging.Logger, command string) (string, error)
 
 	// EnableService calls `systemctl enable <name>`
-	EnableService(log *logging.Logger, name string) error
+	EnableService(ctx context.Context, log *logging.Logger, name string) error
 	// RunScript uploads a script with given content and executes it
-	RunScript(log *logging.Logger, scriptContent, scriptPath string) error
+	RunScript(ctx context.Context, log *logging.Logger, scriptContent, scriptPath string) error
+	// WriteFile atomically replaces a file on the instance with given content, mode & owner (user[:group], defaults to root).
+	// Nothing is written when the file already has the same content, mode & owner.
+	WriteFile(ctx context.Context, log *logging.Logger, path, content string, mode os.FileMode, owner string) error
 
-	GetClusterID(log *logging.Logger) (string, error)
+	GetClusterID(ctx context.Context, log *logging.Logger) (string, error)
 
-	GetGluonEnv(log *logging.Logger) (string, error)
+	GetGluonEnv(ctx context.Context, log *logging.Logger) (string, error)
 
-	GetMachineID(log *logging.Logger) (string, error)
+	// GetGluonArgs returns the arguments of the last `gluon setup`, including the secret ones (empty if unknown)
+	GetGluonArgs(ctx context.Context, log *logging.Logger) ([]string, error)
 
-	GetVaultCrt(log *logging.Logger) (string, error)
+	GetMachineID(ctx context.Context, log *logging.Logger) (string, error)
 
-	GetVaultAddr(log *logging.Logger) (string, error)
+	// GetBootID returns the ID of the current boot of the instance. It changes on every reboot.
+	GetBootID(ctx context.Context, log *logging.Logger) (string, error)
 
-	GetWeaveEnv(log *logging.Logger) (string, error)
+	GetVaultCrt(ctx context.Context, log *logging.Logger) (string, error)
 
-	GetWeaveSeed(log *logging.Logger) (string, error)
+	GetVaultAddr(ctx context.Context, log *logging.Logger) (string, error)
 
-	GetOSRelease(log *logging.Logger) (semver.Version, error)
+	GetWeaveEnv(ctx context.Context, log *logging.Logger) (string, error)
+
+	GetWeaveSeed(ctx context.Context, log *logging.Logger) (string, error)
+
+	GetOSRelease(ctx context.Context, log *logging.Logger) (semver.Version, error)
+
+	// GetRoles returns the content of /etc/pulcy/roles (empty if it does not exist).
+	// Use ClusterInstance.GetRoles to get the roles of instances that have no such file.
+	GetRoles(ctx context.Context, log *logging.Logger) (string, error)
 
 	// IsEtcdProxyFromService queries the ETCD2 service on the instance to look for an ETCD_PROXY variable.
-	IsEtcdProxyFromService(log *logging.Logger) (bool, error)
+	IsEtcdProxyFromService(ctx context.Context, log *logging.Logger) (bool, error)
 
-	// AddEtcdMember calls etcdctl to add a member to ETCD
-	AddEtcdMember(log *logging.Logger, name, clusterIP string) error
+	// EtcdClient opens an etcd client that uses the etcd client API of the instance
+	EtcdClient(ctx context.Context, log *logging.Logger) (EtcdClient, error)
 
-	// RemoveEtcdMember calls etcdctl to remove a member from ETCD
-	RemoveEtcdMember(log *logging.Logger, name, clusterIP string) error
+	// AddEtcdMember adds a member with given cluster IP to ETCD, unless there already is such a member
+	AddEtcdMember(ctx context.Context, log *logging.Logger, name, clusterIP string) error
+
+	// RemoveEtcdMember removes the member with given cluster IP from ETCD
+	RemoveEtcdMember(ctx context.Context, log *logging.Logger, name, clusterIP string) error
 }
 
 type instanceConnection struct {
@@ -54,35 +73,36 @@ type instanceConnection struct {
 	host   string
 }
 
-// SSHConnect creates a new SSH connection to the given user on the given host.
-func SSHConnect(userName, host string) (InstanceConnection, error) {
-	client, err := DialSSH(userName, host)
+// SSHConnect creates a new SSH connection using the given options.
+// The underlying connection is shared with all other users of the same host.
+func SSHConnect(options SSHOptions) (InstanceConnection, error) {
+	client, err := sshConnections.get(options)
 	if err != nil {
 		return nil, maskAny(err)
 	}
-	return &instanceConnection{client, host}, nil
+	return &instanceConnection{client, options.Host}, nil
 }
 
 func (s *instanceConnection) Close() error {
 	return maskAny(s.client.Close())
 }
 
-func (s *instanceConnection) Run(log *logging.Logger, command, stdin string, quiet bool) (string, error) {
-	out, err := s.client.Run(log, command, stdin, quiet)
+func (s *instanceConnection) Run(ctx context.Context, log *logging.Logger, command, stdin string, quiet bool) (string, error) {
+	out, err := s.client.Run(ctx, log, command, stdin, quiet)
 	return out, maskAny(err)
 }
 
 // Sync the filesystems on the instance
-func (s *instanceConnection) Sync(log *logging.Logger) error {
-	if _, err := s.Run(log, "sudo sync", "", false); err != nil {
+func (s *instanceConnection) Sync(ctx context.Context, log *logging.Logger) error {
+	if _, err := s.Run(ctx, log, "sudo sync", "", false); err != nil {
 		return maskAny(err)
 	}
 	return nil
 }
 
 // Exec executes a command on the instance
-func (s *instanceConnection) Exec(log *logging.Logger, command string) (string, error) {
-	stdout, err := s.Run(log, command, "", false)
+func (s *instanceConnection) Exec(ctx context.Context, log *logging.Logger, command string) (string, error) {
+	stdout, err := s.Run(ctx, log, command, "", false)
 	if err != nil {
 		return stdout, maskAny(err)
 	}
@@ -90,56 +110,86 @@ func (s *instanceConnection) Exec(log *logging.Logger, command string) (string,
 }
 
 // EnableService calls `systemctl enable <name>`
-func (s *instanceConnection) EnableService(log *logging.Logger, name string) error {
-	if _, err := s.Run(log, "sudo systemctl enable "+name, "", false); err != nil {
+func (s *instanceConnection) EnableService(ctx context.Context, log *logging.Logger, name string) error {
+	if _, err := s.Run(ctx, log, "sudo systemctl enable "+name, "", false); err != nil {
 		return maskAny(err)
 	}
 	return nil
 }
 
 // RunScript uploads a script with given content and executes it
-func (s *instanceConnection) RunScript(log *logging.Logger, scriptContent, scriptPath string) error {
-	if _, err := s.Run(log, fmt.Sprintf("sudo tee %s", scriptPath), scriptContent, false); err != nil {
+func (s *instanceConnection) RunScript(ctx context.Context, log *logging.Logger, scriptContent, scriptPath string) error {
+	if _, err := s.Run(ctx, log, fmt.Sprintf("sudo tee %s", scriptPath), scriptContent, false); err != nil {
 		return maskAny(err)
 	}
-	if _, err := s.Run(log, fmt.Sprintf("sudo chmod +x %s", scriptPath), "", false); err != nil {
+	if _, err := s.Run(ctx, log, fmt.Sprintf("sudo chmod +x %s", scriptPath), "", false); err != nil {
 		return maskAny(err)
 	}
-	if _, err := s.Run(log, fmt.Sprintf("sudo %s", scriptPath), "", false); err != nil {
+	if _, err := s.Run(ctx, log, fmt.Sprintf("sudo %s", scriptPath), "", false); err != nil {
 		return maskAny(err)
 	}
 	return nil
 }
 
-func (s *instanceConnection) GetClusterID(log *logging.Logger) (string, error) {
+func (s *instanceConnection) GetClusterID(ctx context.Context, log *logging.Logger) (string, error) {
 	log.Debugf("Fetching cluster-id on %s", s.host)
-	id, err := s.Run(log, "sudo cat /etc/pulcy/cluster-id", "", false)
+	id, err := s.Run(ctx, log, "sudo cat /etc/pulcy/cluster-id", "", false)
 	return id, maskAny(err)
 }
 
-func (s *instanceConnection) GetGluonEnv(log *logging.Logger) (string, error) {
+func (s *instanceConnection) GetGluonEnv(ctx context.Context, log *logging.Logger) (string, error) {
 	log.Debugf("Fetching gluon.env on %s", s.host)
 	// gluon.env does not have to exists, so ignore errors by the `|| echo ""` parts.
-	id, err := s.Run(log, "sh -c 'sudo cat /etc/pulcy/gluon.env || echo \"\"'", "", false)
+	id, err := s.Run(ctx, log, "sh -c 'sudo cat /etc/pulcy/gluon.env || echo \"\"'", "", false)
 	return id, maskAny(err)
 }
 
-func (s *instanceConnection) GetMachineID(log *logging.Logger) (string, error) {
+func (s *instanceConnection) GetGluonArgs(ctx context.Context, log *logging.Logger) ([]string, error) {
+	log.Debugf("Fetching gluon args on %s", s.host)
+	// gluon-args does not exist on instances created by older versions, so ignore errors by the `|| echo ""` parts.
+	raw, err := s.Run(ctx, log, fmt.Sprintf("sh -c 'sudo cat %s || echo \"\"'", gluonArgsPath), "", false)
+	if err != nil {
+		return nil, maskAny(err)
+	}
+	args := splitLines(raw)
+	if len(args) > 0 {
+		// Secret arguments are stored separately (older versions stored them in gluon-args)
+		secrets, err := s.Run(ctx, log, fmt.Sprintf("sh -c 'sudo cat %s || echo \"\"'", gluonSecretArgsPath), "", false)
+		if err != nil {
+			return nil, maskAny(err)
+		}
+		return append(args, splitLines(secrets)...), nil
+	}
+	// Fall back to the arguments in the gluon service
+	unit, err := s.Run(ctx, log, "sh -c 'sudo systemctl cat gluon.service || echo \"\"'", "", false)
+	if err != nil {
+		return nil, maskAny(err)
+	}
+	return parseGluonServiceArgs(unit), nil
+}
+
+func (s *instanceConnection) GetMachineID(ctx context.Context, log *logging.Logger) (string, error) {
 	log.Debugf("Fetching machine-id on %s", s.host)
-	id, err := s.Run(log, "cat /etc/machine-id", "", false)
+	id, err := s.Run(ctx, log, "cat /etc/machine-id", "", false)
+	return id, maskAny(err)
+}
+
+func (s *instanceConnection) GetBootID(ctx context.Context, log *logging.Logger) (string, error) {
+	log.Debugf("Fetching boot ID on %s", s.host)
+	id, err := s.Run(ctx, log, "cat /proc/sys/kernel/random/boot_id", "", true)
 	return id, maskAny(err)
 }
 
-func (s *instanceConnection) GetVaultCrt(log *logging.Logger) (string, error) {
+func (s *instanceConnection) GetVaultCrt(ctx context.Context, log *logging.Logger) (string, error) {
 	log.Debugf("Fetching vault.crt on %s", s.host)
-	id, err := s.Run(log, "sudo cat /etc/pulcy/vault.crt", "", false)
+	id, err := s.Run(ctx, log, "sudo cat /etc/pulcy/vault.crt", "", false)
 	return id, maskAny(err)
 }
 
-func (s *instanceConnection) GetVaultAddr(log *logging.Logger) (string, error) {
+func (s *instanceConnection) GetVaultAddr(ctx context.Context, log *logging.Logger) (string, error) {
 	const prefix = "VAULT_ADDR="
 	log.Debugf("Fetching vault-addr on %s", s.host)
-	env, err := s.Run(log, "sudo cat /etc/pulcy/vault.env", "", false)
+	env, err := s.Run(ctx, log, "sudo cat /etc/pulcy/vault.env", "", false)
 	if err != nil {
 		return "", maskAny(err)
 	}
@@ -152,23 +202,23 @@ func (s *instanceConnection) GetVaultAddr(log *logging.Logger) (string, error) {
 	return "", maskAny(errgo.New("VAULT_ADDR not found in /etc/pulcy/vault.env"))
 }
 
-func (s *instanceConnection) GetWeaveEnv(log *logging.Logger) (string, error) {
+func (s *instanceConnection) GetWeaveEnv(ctx context.Context, log *logging.Logger) (string, error) {
 	log.Debugf("Fetching weave.env on %s", s.host)
-	id, err := s.Run(log, "sudo cat /etc/pulcy/weave.env", "", false)
+	id, err := s.Run(ctx, log, "sudo cat /etc/pulcy/weave.env", "", false)
 	return id, maskAny(err)
 }
 
-func (s *instanceConnection) GetWeaveSeed(log *logging.Logger) (string, error) {
+func (s *instanceConnection) GetWeaveSeed(ctx context.Context, log *logging.Logger) (string, error) {
 	log.Debugf("Fetching weave-seed on %s", s.host)
 	// weave-seed does not have to exists, so ignore errors by the `|| echo ""` parts.
-	id, err := s.Run(log, "sh -c 'sudo cat /etc/pulcy/weave-seed || echo \"\"'", "", false)
+	id, err := s.Run(ctx, log, "sh -c 'sudo cat /etc/pulcy/weave-seed || echo \"\"'", "", false)
 	return id, maskAny(err)
 }
 
-func (s *instanceConnection) GetOSRelease(log *logging.Logger) (semver.Version, error) {
+func (s *instanceConnection) GetOSRelease(ctx context.Context, log *logging.Logger) (semver.Version, error) {
 	const prefix = "DISTRIB_RELEASE="
 	log.Debugf("Fetching OS release on %s", s.host)
-	env, err := s.Run(log, "cat /etc/lsb-release", "", false)
+	env, err := s.Run(ctx, log, "cat /etc/lsb-release", "", false)
 	if err != nil {
 		return semver.Version{}, maskAny(err)
 	}
@@ -185,45 +235,97 @@ func (s *instanceConnection) GetOSRelease(log *logging.Logger) (semver.Version,
 	return semver.Version{}, maskAny(errgo.Newf("%s not found in /etc/lsb-release", prefix))
 }
 
+func (s *instanceConnection) GetRoles(ctx context.Context, log *logging.Logger) (string, error) {
+	log.Debugf("Fetching roles on %s", s.host)
+	// roles does not have to exists, so ignore errors by the `|| echo ""` parts.
+	roles, err := s.Run(ctx, log, "sh -c 'sudo cat /etc/pulcy/roles || echo \"\"'", "", false)
+	return roles, maskAny(err)
+}
+
 // IsEtcdProxyFromService queries the ETCD2 service on the instance to look for an ETCD_PROXY variable.
-func (s *instanceConnection) IsEtcdProxyFromService(log *logging.Logger) (bool, error) {
+func (s *instanceConnection) IsEtcdProxyFromService(ctx context.Context, log *logging.Logger) (bool, error) {
 	log.Debugf("Fetching etcd proxy status on %s", s.host)
 	// weave-seed does not have to exists, so ignore errors by the `|| echo ""` parts.
-	cat, err := s.Run(log, "sh -c 'sudo systemctl cat etcd2.service || echo \"\"'", "", false)
+	cat, err := s.Run(ctx, log, "sh -c 'sudo systemctl cat etcd2.service || echo \"\"'", "", false)
 	return cat == "" || strings.Contains(cat, "ETCD_PROXY"), maskAny(err)
 }
 
-// AddEtcdMember calls etcdctl to add a member to ETCD
-func (s *instanceConnection) AddEtcdMember(log *logging.Logger, name, clusterIP string) error {
+// EtcdClient opens an etcd client that uses the etcd client API of the instance.
+func (s *instanceConnection) EtcdClient(ctx context.Context, log *logging.Logger) (EtcdClient, error) {
+	client, err := newEtcdClient(ctx, log, s.client, s.host)
+	if err != nil {
+		return nil, maskAny(err)
+	}
+	return client, nil
+}
+
+// RemoteTransport returns an HTTP transport that opens all connections from the instance.
+func (s *instanceConnection) RemoteTransport() (http.RoundTripper, error) {
+	transporter, ok := s.client.(remoteTransporter)
+	if !ok {
+		return nil, maskAny(fmt.Errorf("cannot forward connections to %s", s.host))
+	}
+	transport, err := transporter.RemoteTransport()
+	if err != nil {
+		return nil, maskAny(err)
+	}
+	return transport, nil
+}
+
+// AddEtcdMember adds a member with given cluster IP to ETCD, unless there already is such a member.
+func (s *instanceConnection) AddEtcdMember(ctx context.Context, log *logging.Logger, name, clusterIP string) error {
 	log.Infof("Adding %s(%s) to etcd on %s", name, clusterIP, s.host)
-	cmd := []string{
-		"etcdctl",
-		"member",
-		"add",
-		name,
-		fmt.Sprintf("http://%s:2380", clusterIP),
-	}
-	if _, err := s.Run(log, strings.Join(cmd, " "), "", false); err != nil {
+	client, err := s.EtcdClient(ctx, log)
+	if err != nil {
+		return maskAny(err)
+	}
+	defer client.Close()
+	members, err := client.Members()
+	if err != nil {
 		return maskAny(err)
 	}
+	if m, found := members.FindByPeerIP(clusterIP); found {
+		log.Infof("%s(%s) already is etcd member %s", name, clusterIP, m.ID)
+		return nil
+	}
+	m, err := client.AddMember(etcdPeerURL(clusterIP))
+	if err != nil {
+		return maskAny(err)
+	}
+	log.Infof("Added %s(%s) to etcd as member %s", name, clusterIP, m.ID)
 	return nil
 }
 
-// RemoveEtcdMember calls etcdctl to remove a member from ETCD
-func (s *instanceConnection) RemoveEtcdMember(log *logging.Logger, name, clusterIP string) error {
+// RemoveEtcdMember removes the member with given cluster IP from ETCD.
+// A NotFoundError is returned when there is no such member.
+func (s *instanceConnection) RemoveEtcdMember(ctx context.Context, log *logging.Logger, name, clusterIP string) error {
 	log.Infof("Removing %s(%s) from etcd on %s", name, clusterIP, s.host)
-	id, err := s.Run(log, fmt.Sprintf("sh -c 'etcdctl member list | grep %s | cut -d: -f1 | cut -d[ -f1'", clusterIP), "", false)
+	client, err := s.EtcdClient(ctx, log)
 	if err != nil {
 		return maskAny(err)
 	}
-	cmd := []string{
-		"etcdctl",
-		"member",
-		"remove",
-		id,
+	defer client.Close()
+	members, err := client.Members()
+	if err != nil {
+		return maskAny(err)
 	}
-	if _, err := s.Run(log, strings.Join(cmd, " "), "", false); err != nil {
+	m, found := members.FindByPeerIP(clusterIP)
+	if !found {
+		return maskAny(errgo.WithCausef(nil, NotFoundError, "%s(%s) is not an etcd member", name, clusterIP))
+	}
+	if err := client.RemoveMember(m.ID); err != nil {
 		return maskAny(err)
 	}
 	return nil
 }
+
+// splitLines returns the non-empty lines of the given text.
+func splitLines(text string) []string {
+	lines := []string{}
+	for _, line := range strings.Split(text, "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			lines = append(lines, line)
+		}
+	}
+	return lines
+}
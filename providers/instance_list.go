@@ -15,16 +15,18 @@
 package providers
 
 import (
+	"context"
 	"fmt"
 	"net"
 	"sync"
 
+	"github.com/juju/errgo"
 	"github.com/op/go-logging"
 )
 
 type ClusterInstanceList []ClusterInstance
 
-func (cil ClusterInstanceList) AsClusterMemberList(log *logging.Logger, isEtcdProxy func(ClusterInstance) (bool, error)) (ClusterMemberList, error) {
+func (cil ClusterInstanceList) AsClusterMemberList(ctx context.Context, log *logging.Logger, isEtcdProxy func(ClusterInstance) (bool, error)) (ClusterMemberList, error) {
 	wg := sync.WaitGroup{}
 	errors := make(chan error, len(cil))
 	memberChan := make(chan ClusterMember, len(cil))
@@ -32,7 +34,7 @@ func (cil ClusterInstanceList) AsClusterMemberList(log *logging.Logger, isEtcdPr
 		wg.Add(1)
 		go func(instance ClusterInstance) {
 			defer wg.Done()
-			member, err := instance.AsClusterMember(log)
+			member, err := instance.AsClusterMember(ctx, log)
 			if err != nil {
 				errors <- maskAny(err)
 				return
@@ -140,14 +142,14 @@ func (cil ClusterInstanceList) CreateClusterIP(cidr string) (net.IP, error) {
 }
 
 // GetClusterID loads the cluster ID from any of the instances in the given list
-func (cil ClusterInstanceList) GetClusterID(log *logging.Logger) (string, error) {
+func (cil ClusterInstanceList) GetClusterID(ctx context.Context, log *logging.Logger) (string, error) {
 	for _, i := range cil {
 		s, err := i.Connect()
 		if err != nil {
 			return "", maskAny(err)
 		}
 		defer s.Close()
-		result, err := s.GetClusterID(log)
+		result, err := s.GetClusterID(ctx, log)
 		if err == nil {
 			return result, nil
 		}
@@ -157,14 +159,14 @@ func (cil ClusterInstanceList) GetClusterID(log *logging.Logger) (string, error)
 }
 
 // GetVaultCrt loads the vault certificate from any of the instances in the given list
-func (cil ClusterInstanceList) GetVaultCrt(log *logging.Logger) (string, error) {
+func (cil ClusterInstanceList) GetVaultCrt(ctx context.Context, log *logging.Logger) (string, error) {
 	for _, i := range cil {
 		s, err := i.Connect()
 		if err != nil {
 			return "", maskAny(err)
 		}
 		defer s.Close()
-		result, err := s.GetVaultCrt(log)
+		result, err := s.GetVaultCrt(ctx, log)
 		if err == nil {
 			return result, nil
 		}
@@ -174,14 +176,14 @@ func (cil ClusterInstanceList) GetVaultCrt(log *logging.Logger) (string, error)
 }
 
 // GetVaultAddr loads the vault address from any of the instances in the given list
-func (cil ClusterInstanceList) GetVaultAddr(log *logging.Logger) (string, error) {
+func (cil ClusterInstanceList) GetVaultAddr(ctx context.Context, log *logging.Logger) (string, error) {
 	for _, i := range cil {
 		s, err := i.Connect()
 		if err != nil {
 			return "", maskAny(err)
 		}
 		defer s.Close()
-		result, err := s.GetVaultAddr(log)
+		result, err := s.GetVaultAddr(ctx, log)
 		if err == nil {
 			return result, nil
 		}
@@ -190,14 +192,14 @@ func (cil ClusterInstanceList) GetVaultAddr(log *logging.Logger) (string, error)
 	return "", maskAny(fmt.Errorf("cannot get vault address"))
 }
 
-func (cil ClusterInstanceList) GetGluonEnv(log *logging.Logger) (string, error) {
+func (cil ClusterInstanceList) GetGluonEnv(ctx context.Context, log *logging.Logger) (string, error) {
 	for _, i := range cil {
 		s, err := i.Connect()
 		if err != nil {
 			return "", maskAny(err)
 		}
 		defer s.Close()
-		result, err := s.GetGluonEnv(log)
+		result, err := s.GetGluonEnv(ctx, log)
 		if err == nil {
 			return result, nil
 		}
@@ -206,14 +208,14 @@ func (cil ClusterInstanceList) GetGluonEnv(log *logging.Logger) (string, error)
 	return "", maskAny(fmt.Errorf("cannot get gluon.env"))
 }
 
-func (cil ClusterInstanceList) GetWeaveEnv(log *logging.Logger) (string, error) {
+func (cil ClusterInstanceList) GetWeaveEnv(ctx context.Context, log *logging.Logger) (string, error) {
 	for _, i := range cil {
 		s, err := i.Connect()
 		if err != nil {
 			return "", maskAny(err)
 		}
 		defer s.Close()
-		result, err := s.GetWeaveEnv(log)
+		result, err := s.GetWeaveEnv(ctx, log)
 		if err == nil {
 			return result, nil
 		}
@@ -222,14 +224,14 @@ func (cil ClusterInstanceList) GetWeaveEnv(log *logging.Logger) (string, error)
 	return "", maskAny(fmt.Errorf("cannot get weave.env"))
 }
 
-func (cil ClusterInstanceList) GetWeaveSeed(log *logging.Logger) (string, error) {
+func (cil ClusterInstanceList) GetWeaveSeed(ctx context.Context, log *logging.Logger) (string, error) {
 	for _, i := range cil {
 		s, err := i.Connect()
 		if err != nil {
 			return "", maskAny(err)
 		}
 		defer s.Close()
-		result, err := s.GetWeaveSeed(log)
+		result, err := s.GetWeaveSeed(ctx, log)
 		if err == nil {
 			return result, nil
 		}
@@ -238,34 +240,45 @@ func (cil ClusterInstanceList) GetWeaveSeed(log *logging.Logger) (string, error)
 	return "", maskAny(fmt.Errorf("cannot get weave-seed"))
 }
 
-// AddEtcdMember calls etcdctl to add a member to ETCD on any of the instances in the given list
-func (cil ClusterInstanceList) AddEtcdMember(log *logging.Logger, name, clusterIP string) error {
+// AddEtcdMember adds a member with given cluster IP to ETCD, using the etcd API of any of the instances in the given list
+func (cil ClusterInstanceList) AddEtcdMember(ctx context.Context, log *logging.Logger, name, clusterIP string) error {
 	for _, i := range cil {
-		s, err := i.Connect()
-		if err != nil {
-			return maskAny(err)
-		}
-		defer s.Close()
-		if err := s.AddEtcdMember(log, name, clusterIP); err == nil {
+		err := i.AddEtcdMember(ctx, log, name, clusterIP)
+		if err == nil {
 			return nil
 		}
-		log.Warningf("cannot add '%s' to ETCD: %#v", name, err)
+		log.Warningf("cannot add '%s' to ETCD on '%s': %#v", name, i, err)
 	}
 	return maskAny(fmt.Errorf("cannot add '%s' to ETCD", name))
 }
 
-// RemoveEtcdMember calls etcdctl to remove a member from ETCD on any of the instances in the given list
-func (cil ClusterInstanceList) RemoveEtcdMember(log *logging.Logger, name, clusterIP string) error {
+// RemoveEtcdMember removes the member with given cluster IP from ETCD, using the etcd API of any of the instances in the given list.
+// A NotFoundError is returned when there is no such member.
+func (cil ClusterInstanceList) RemoveEtcdMember(ctx context.Context, log *logging.Logger, name, clusterIP string) error {
 	for _, i := range cil {
-		s, err := i.Connect()
-		if err != nil {
-			return maskAny(err)
-		}
-		defer s.Close()
-		if err := s.RemoveEtcdMember(log, name, clusterIP); err == nil {
+		err := i.RemoveEtcdMember(ctx, log, name, clusterIP)
+		if err == nil {
 			return nil
 		}
-		log.Warningf("cannot remove '%s' from ETCD: %#v", name, err)
+		if errgo.Cause(err) == NotFoundError {
+			return maskAny(err)
+		}
+		log.Warningf("cannot remove '%s' from ETCD on '%s': %#v", name, i, err)
 	}
 	return maskAny(fmt.Errorf("cannot remove '%s' from ETCD", name))
 }
+
+// EtcdClient returns a client for the etcd API of the first instance in the given list on which etcd can be reached,
+// together with that instance.
+func (cil ClusterInstanceList) EtcdClient(ctx context.Context, log *logging.Logger) (EtcdClient, ClusterInstance, error) {
+	var lastErr error = NotFoundError
+	for _, i := range cil {
+		client, err := i.EtcdClient(ctx, log)
+		if err == nil {
+			return client, i, nil
+		}
+		log.Debugf("Cannot reach etcd on %s: %v", i, err)
+		lastErr = err
+	}
+	return nil, ClusterInstance{}, maskAny(errgo.Notef(lastErr, "etcd is not reachable on any instance"))
+}
@@ -0,0 +1,109 @@
+// Copyright (c) 2016 Pulcy.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package providers
+
+import (
+	"context"
+	"net"
+	"strings"
+
+	"github.com/op/go-logging"
+)
+
+const (
+	fleetMetadataFlag = "--fleet-metadata="
+)
+
+// InstanceProfile describes the settings of an existing instance that are needed
+// to create a replacement for it.
+type InstanceProfile struct {
+	Roles         string            // Comma separated roles (e.g. "core,lb")
+	FleetMetadata map[string]string // Fleet metadata passed to gluon
+	EtcdProxy     bool              // Set if the instance is an ETCD proxy
+	ClusterIP     string            // IP address of the instance in the tinc network
+}
+
+// GetProfile loads the roles, fleet metadata & etcd proxy status of the instance.
+func (i ClusterInstance) GetProfile(ctx context.Context, log *logging.Logger) (InstanceProfile, error) {
+	s, err := i.Connect()
+	if err != nil {
+		return InstanceProfile{}, maskAny(err)
+	}
+	defer s.Close()
+
+	roles, _, err := loadRoles(ctx, log, s, i)
+	if err != nil {
+		return InstanceProfile{}, maskAny(err)
+	}
+	gluonArgs, err := s.GetGluonArgs(ctx, log)
+	if err != nil {
+		return InstanceProfile{}, maskAny(err)
+	}
+	etcdProxy := false
+	if i.EtcdProxy != nil {
+		etcdProxy = *i.EtcdProxy
+	} else if etcdProxy, err = s.IsEtcdProxyFromService(ctx, log); err != nil {
+		return InstanceProfile{}, maskAny(err)
+	}
+	p := InstanceProfile{
+		Roles:         roles,
+		FleetMetadata: parseFleetMetadata(gluonArgs),
+		EtcdProxy:     etcdProxy,
+		ClusterIP:     i.ClusterIP,
+	}
+	return p, nil
+}
+
+// ApplyTo configures the given options to create an instance with the same roles, etcd proxy status,
+// region & odd/even metadata as the instance of this profile, in the same tinc network.
+// Region & tinc network are only set when not yet specified.
+func (p InstanceProfile) ApplyTo(options *CreateInstanceOptions) {
+	options.RoleCore = hasRole(p.Roles, "core")
+	options.RoleLoadBalancer = hasRole(p.Roles, "lb")
+	options.RoleVault = hasRole(p.Roles, "vault")
+	options.RoleWorker = hasRole(p.Roles, "worker")
+	options.EtcdProxy = p.EtcdProxy
+	if options.RegionID == "" {
+		options.RegionID = p.FleetMetadata["region"]
+	}
+	if p.FleetMetadata["even"] == "true" {
+		options.InstanceIndex = 2
+	} else if p.FleetMetadata["odd"] == "true" {
+		options.InstanceIndex = 1
+	}
+	if options.TincCIDR == "" && p.ClusterIP != "" {
+		if ip := net.ParseIP(p.ClusterIP).To4(); ip != nil {
+			network := net.IPNet{IP: ip.Mask(net.CIDRMask(24, 32)), Mask: net.CIDRMask(24, 32)}
+			options.TincCIDR = network.String()
+		}
+	}
+}
+
+// parseFleetMetadata returns the key=value pairs of the --fleet-metadata argument in the given gluon arguments.
+func parseFleetMetadata(gluonArgs []string) map[string]string {
+	result := make(map[string]string)
+	for _, arg := range gluonArgs {
+		if !strings.HasPrefix(arg, fleetMetadataFlag) {
+			continue
+		}
+		for _, pair := range strings.Split(strings.TrimPrefix(arg, fleetMetadataFlag), ",") {
+			kv := strings.SplitN(pair, "=", 2)
+			if len(kv) == 2 {
+				result[strings.TrimSpace(kv[0])] = strings.TrimSpace(kv[1])
+			}
+		}
+	}
+	return result
+}
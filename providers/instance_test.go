@@ -0,0 +1,438 @@
+// Copyright (c) 2016 Pulcy.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package providers_test
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/juju/errgo"
+	"github.com/op/go-logging"
+
+	"github.com/pulcy/quark/providers"
+	"github.com/pulcy/quark/providers/sshtest"
+)
+
+var (
+	testLog = logging.MustGetLogger("providers-test")
+)
+
+// testCluster is a set of instances, each backed by an in-process SSH server.
+// All servers share a single etcd cluster.
+type testCluster struct {
+	Instances providers.ClusterInstanceList
+	Servers   []*sshtest.Server
+	uninstall func()
+}
+
+func newTestCluster(t *testing.T, count int) *testCluster {
+	c := &testCluster{}
+	servers := make(map[string]*sshtest.Server)
+	for index := 1; index <= count; index++ {
+		s, err := sshtest.NewServer("")
+		if err != nil {
+			c.Close()
+			t.Fatalf("NewServer failed: %v", err)
+		}
+		if index > 1 {
+			s.Etcd = c.Servers[0].Etcd
+		}
+		i := providers.ClusterInstance{
+			ID:               fmt.Sprintf("%d", index),
+			Name:             fmt.Sprintf("i%d.c1.example.com", index),
+			ClusterIP:        fmt.Sprintf("192.168.35.%d", index),
+			PrivateIP:        fmt.Sprintf("10.1.0.%d", index),
+			LoadBalancerIPv4: fmt.Sprintf("10.0.0.%d", index),
+			ClusterDevice:    "eth1",
+			OS:               providers.OSNameCoreOS,
+		}
+		servers[i.String()] = s
+		c.Servers = append(c.Servers, s)
+		c.Instances = append(c.Instances, i)
+	}
+	c.uninstall = sshtest.Install(servers)
+	return c
+}
+
+// AddEtcdMembers adds all instances as started members of etcd.
+func (c *testCluster) AddEtcdMembers() {
+	for _, i := range c.Instances {
+		c.Servers[0].Etcd.AddMember(i.Name, i.ClusterIP)
+	}
+}
+
+func (c *testCluster) Close() {
+	if c.uninstall != nil {
+		c.uninstall()
+	}
+	for _, s := range c.Servers {
+		s.Close()
+	}
+}
+
+func expectFile(t *testing.T, s *sshtest.Server, path, content string, mode os.FileMode) {
+	f, ok := s.FS.ReadFile(path)
+	if !ok {
+		t.Errorf("Expected %s to exist", path)
+		return
+	}
+	if f.Content != content {
+		t.Errorf("Expected %s to contain '%s', got '%s'", path, content, f.Content)
+	}
+	if f.Mode.Perm() != mode {
+		t.Errorf("Expected %s to have mode %o, got %o", path, mode, f.Mode.Perm())
+	}
+	if f.Owner != "root" {
+		t.Errorf("Expected %s to be owned by root, got %s", path, f.Owner)
+	}
+}
+
+func TestInitialSetup(t *testing.T) {
+	c := newTestCluster(t, 1)
+	defer c.Close()
+	i := c.Instances[0]
+	s := c.Servers[0]
+
+	dir, err := ioutil.TempDir("", "quark-providers")
+	if err != nil {
+		t.Fatalf("Cannot create temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	certPath := filepath.Join(dir, "vault.crt")
+	if err := ioutil.WriteFile(certPath, []byte("vault-certificate"), 0600); err != nil {
+		t.Fatalf("Cannot write certificate: %v", err)
+	}
+
+	cio := providers.CreateInstanceOptions{
+		InstanceConfig:          providers.InstanceConfig{MinOSVersion: "835.13.0"},
+		RoleCore:                true,
+		RoleLoadBalancer:        true,
+		GluonImage:              "pulcy/gluon:test",
+		GluonEnv:                "GLUON_FLEET_ENABLED=true",
+		VaultAddress:            "https://vault.example.com:8200",
+		VaultCertificatePath:    certPath,
+		PrivateRegistryUrl:      "registry.example.com",
+		PrivateRegistryPassword: "s3cret",
+	}
+	members := providers.ClusterMemberList{
+		{MachineID: "m1", ClusterIP: i.ClusterIP},
+		{MachineID: "m2", ClusterIP: "192.168.35.2", EtcdProxy: true},
+	}
+	iso := providers.InitialSetupOptions{
+		ClusterMembers: members,
+		FleetMetadata:  "core=true,lb=true",
+	}
+	if err := i.InitialSetup(context.Background(), testLog, cio, iso, nil); err != nil {
+		t.Fatalf("InitialSetup failed: %v", err)
+	}
+
+	expectFile(t, s, "/etc/pulcy/cluster-members", members.Render(), 0644)
+	expectFile(t, s, "/etc/pulcy/vault.crt", "vault-certificate", 0400)
+	expectFile(t, s, "/etc/pulcy/vault.env", "VAULT_ADDR=https://vault.example.com:8200\nVAULT_CACERT=/etc/pulcy/vault.crt", 0400)
+	expectFile(t, s, "/etc/pulcy/gluon.env", "GLUON_FLEET_ENABLED=true", 0644)
+	expectFile(t, s, "/etc/pulcy/roles", "core,lb", 0644)
+	expectFile(t, s, "/etc/pulcy/gluon-secret-args", "--private-registry-password=s3cret", 0400)
+	if f, ok := s.FS.ReadFile("/etc/pulcy/gluon-args"); !ok || strings.Contains(f.Content, "s3cret") {
+		t.Errorf("Expected gluon-args without the registry password, got '%s'", f.Content)
+	}
+	if _, ok := s.FS.ReadFile("/etc/pulcy/vault/key.pem"); ok {
+		t.Errorf("Expected no vault server key on an instance without vault role")
+	}
+
+	if images := s.Docker.Images(); len(images) != 1 || images[0] != cio.GluonImage {
+		t.Errorf("Expected gluon to be extracted from %s, got %v", cio.GluonImage, images)
+	}
+	setups := s.Gluon.SetupArgs()
+	if len(setups) != 1 {
+		t.Fatalf("Expected 1 gluon setup, got %d", len(setups))
+	}
+	args := strings.Join(setups[0], " ")
+	for _, expected := range []string{"--gluon-image=pulcy/gluon:test", "--private-ip=192.168.35.1", "--private-cluster-device=eth1", "--fleet-metadata=core=true,lb=true"} {
+		if !strings.Contains(args, expected) {
+			t.Errorf("Expected gluon setup arguments to contain %s, got %s", expected, args)
+		}
+	}
+}
+
+func TestUpdateClusterMembers(t *testing.T) {
+	c := newTestCluster(t, 3)
+	defer c.Close()
+
+	members := providers.ClusterMemberList{}
+	for index, i := range c.Instances {
+		members = append(members, providers.ClusterMember{MachineID: fmt.Sprintf("m%d", index), ClusterIP: i.ClusterIP})
+	}
+	if err := c.Instances.UpdateClusterMembers(context.Background(), testLog, members, false, nil); err != nil {
+		t.Fatalf("UpdateClusterMembers failed: %v", err)
+	}
+	for index, s := range c.Servers {
+		expectFile(t, s, "/etc/pulcy/cluster-members", members.Render(), 0644)
+		if restarts := s.Systemd.Restarts("gluon.service"); restarts != 1 {
+			t.Errorf("Expected gluon to be restarted once on instance %d, got %d", index, restarts)
+		}
+		for _, service := range []string{"ip4tables.service", "ip6tables.service"} {
+			if !s.Systemd.IsEnabled(service) {
+				t.Errorf("Expected %s to be enabled on instance %d", service, index)
+			}
+		}
+	}
+}
+
+func TestUpdateClusterMembersFailure(t *testing.T) {
+	c := newTestCluster(t, 2)
+	defer c.Close()
+	c.Servers[1].Handle("systemctl", func(cmd sshtest.Command) sshtest.Result {
+		return sshtest.Fail(1, "Failed to restart gluon.service\n")
+	})
+
+	members := providers.ClusterMemberList{{MachineID: "m1", ClusterIP: c.Instances[0].ClusterIP}}
+	if err := c.Instances.UpdateClusterMembers(context.Background(), testLog, members, false, nil); err == nil {
+		t.Errorf("Expected UpdateClusterMembers to fail")
+	}
+}
+
+// rebootProvider is a cloud provider that can only reboot instances.
+type rebootProvider struct {
+	providers.CloudProvider
+	servers map[string]*sshtest.Server
+}
+
+func (p rebootProvider) RebootInstance(ctx context.Context, i providers.ClusterInstance) error {
+	p.servers[i.Name].Reboot()
+	return nil
+}
+
+func TestUpgradeGluon(t *testing.T) {
+	c := newTestCluster(t, 1)
+	defer c.Close()
+	c.AddEtcdMembers()
+	i := c.Instances[0]
+	s := c.Servers[0]
+	s.FS.WriteFile("/etc/pulcy/gluon.env", "GLUON_FLEET_ENABLED=false", 0644, "root")
+	// Written by older versions, with the password among the other arguments
+	s.FS.WriteFile("/etc/pulcy/gluon-args", "--gluon-image=pulcy/gluon:old\n--private-registry-password=s3cret\n--fleet-metadata=core=true", 0400, "root")
+
+	provider := rebootProvider{servers: map[string]*sshtest.Server{i.Name: s}}
+	if err := i.UpgradeGluon(context.Background(), testLog, "pulcy/gluon:new", time.Minute, provider); err != nil {
+		t.Fatalf("UpgradeGluon failed: %v", err)
+	}
+	if reboots := s.Reboots(); reboots != 1 {
+		t.Errorf("Expected 1 reboot, got %d", reboots)
+	}
+	expectFile(t, s, "/etc/pulcy/gluon-args", "--gluon-image=pulcy/gluon:new\n--fleet-metadata=core=true", 0400)
+	expectFile(t, s, "/etc/pulcy/gluon-secret-args", "--private-registry-password=s3cret", 0400)
+	setups := s.Gluon.SetupArgs()
+	if len(setups) != 1 {
+		t.Fatalf("Expected 1 gluon setup, got %d", len(setups))
+	}
+	if args := strings.Join(setups[0], " "); !strings.Contains(args, "--private-registry-password=s3cret") || !strings.Contains(args, "--gluon-image=pulcy/gluon:new") {
+		t.Errorf("Expected gluon setup with new image and registry password, got %s", args)
+	}
+
+	// The next upgrade reads the secret arguments from their own file
+	if err := i.UpgradeGluon(context.Background(), testLog, "pulcy/gluon:newer", time.Minute, provider); err != nil {
+		t.Fatalf("UpgradeGluon failed: %v", err)
+	}
+	setups = s.Gluon.SetupArgs()
+	if args := strings.Join(setups[len(setups)-1], " "); !strings.Contains(args, "--private-registry-password=s3cret") {
+		t.Errorf("Expected gluon setup with registry password, got %s", args)
+	}
+}
+
+func TestWaitUntilHealthy(t *testing.T) {
+	c := newTestCluster(t, 3)
+	defer c.Close()
+	c.AddEtcdMembers()
+	for _, s := range c.Servers {
+		s.FS.WriteFile("/etc/pulcy/gluon.env", "GLUON_FLEET_ENABLED=false", 0644, "root")
+	}
+	if err := c.Instances.WaitUntilHealthy(context.Background(), testLog, time.Second); err != nil {
+		t.Fatalf("Expected a healthy cluster, got %v", err)
+	}
+
+	// The last instance cannot reach a healthy cluster
+	c.Servers[2].Handle("etcdctl", func(cmd sshtest.Command) sshtest.Result {
+		return sshtest.Fail(1, "cluster may be unhealthy: failed to list members\n")
+	})
+	if err := c.Instances[0].WaitUntilHealthy(context.Background(), testLog, time.Millisecond); err != nil {
+		t.Errorf("Expected the first instance to be healthy, got %v", err)
+	}
+	if err := c.Instances.WaitUntilHealthy(context.Background(), testLog, time.Millisecond); err == nil {
+		t.Errorf("Expected an unhealthy cluster")
+	}
+}
+
+func TestWriteFile(t *testing.T) {
+	c := newTestCluster(t, 1)
+	defer c.Close()
+	s, err := c.Instances[0].Connect()
+	if err != nil {
+		t.Fatalf("Connect failed: %v", err)
+	}
+	for _, path := range []string{"/etc/pulcy/cluster-members", "/etc/my dir/it's $(reboot)"} {
+		if err := s.WriteFile(context.Background(), testLog, path, "content", 0644, ""); err != nil {
+			t.Fatalf("WriteFile(%s) failed: %v", path, err)
+		}
+		expectFile(t, c.Servers[0], path, "content", 0644)
+		if _, ok := c.Servers[0].FS.ReadFile(path + ".quark-tmp"); ok {
+			t.Errorf("Expected temporary file of %s to be removed", path)
+		}
+	}
+	if reboots := c.Servers[0].Reboots(); reboots != 0 {
+		t.Errorf("Expected no reboots, got %d", reboots)
+	}
+}
+
+func TestDrain(t *testing.T) {
+	c := newTestCluster(t, 2)
+	defer c.Close()
+	c.Servers[0].FS.WriteFile("/etc/pulcy/gluon.env", "GLUON_FLEET_ENABLED=false\nGLUON_K8S_ENABLED=true", 0644, "root")
+	// kubectl only works on the other instance
+	c.Servers[0].Handle("kubectl", func(cmd sshtest.Command) sshtest.Result {
+		t.Errorf("Expected no kubectl on the drained instance, got %v", cmd.Args)
+		return sshtest.Fail(1, "The connection to the server localhost:8080 was refused\n")
+	})
+	var drainArgs []string
+	evicted := false
+	c.Servers[1].Handle("kubectl", func(cmd sshtest.Command) sshtest.Result {
+		switch cmd.Args[1] {
+		case "drain":
+			drainArgs = cmd.Args
+			evicted = true
+			return sshtest.OK("node/i1 drained\n")
+		case "get":
+			if cmd.Args[2] == "nodes" {
+				return sshtest.OK("i1 192.168.35.1 10.1.0.1\ni2 192.168.35.2 10.1.0.2\n")
+			}
+			if evicted {
+				return sshtest.OK("kube-system/proxy-i1 DaemonSet\n")
+			}
+			return sshtest.OK("default/web-1 ReplicaSet\nkube-system/proxy-i1 DaemonSet\n")
+		}
+		return sshtest.Fail(1, "unknown command\n")
+	})
+
+	others := c.Instances.Except(c.Instances[0])
+	deadline := time.Now().Add(time.Minute)
+	if err := others.Drain(context.Background(), testLog, c.Instances[0], deadline); err != nil {
+		t.Fatalf("Drain failed: %v", err)
+	}
+	if len(drainArgs) < 3 || drainArgs[2] != "i1" {
+		t.Fatalf("Expected node i1 to be drained, got %v", drainArgs)
+	}
+	timeout, err := time.ParseDuration(strings.TrimPrefix(drainArgs[len(drainArgs)-1], "--timeout="))
+	if err != nil || timeout <= 0 || timeout > time.Minute {
+		t.Errorf("Expected the drain timeout to be limited by the deadline, got %v", drainArgs)
+	}
+	if err := others.WaitUntilDrained(context.Background(), testLog, c.Instances[0], deadline); err != nil {
+		t.Errorf("WaitUntilDrained failed: %v", err)
+	}
+
+	// The deadline is shared, so nothing is left to wait once it has passed
+	evicted = false
+	if err := others.WaitUntilDrained(context.Background(), testLog, c.Instances[0], time.Now()); err == nil {
+		t.Errorf("Expected WaitUntilDrained to fail after the deadline")
+	}
+}
+
+func TestStream(t *testing.T) {
+	c := newTestCluster(t, 1)
+	defer c.Close()
+	i := c.Instances[0]
+	release := make(chan struct{})
+	c.Servers[0].Handle("sleep", func(cmd sshtest.Command) sshtest.Result {
+		<-release
+		return sshtest.OK("")
+	})
+	defer close(release)
+
+	var stdout, stderr bytes.Buffer
+	status, err := i.Stream(context.Background(), testLog, "echo hello", &stdout, &stderr)
+	if err != nil || status != 0 {
+		t.Fatalf("Expected echo to succeed, got %d, %v", status, err)
+	}
+	if stdout.String() != "hello\n" {
+		t.Errorf("Expected 'hello' on stdout, got '%s'", stdout.String())
+	}
+	if status, err := i.Stream(context.Background(), testLog, "false", &stdout, &stderr); err != nil || status != 1 {
+		t.Errorf("Expected exit status 1, got %d, %v", status, err)
+	}
+
+	// A running command is stopped when the context is cancelled
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*50)
+	defer cancel()
+	if _, err := i.Stream(ctx, testLog, "sleep 3600", &stdout, &stderr); errgo.Cause(err) != context.DeadlineExceeded {
+		t.Errorf("Expected DeadlineExceeded, got %v", err)
+	}
+}
+
+// staleRebootProvider is a cloud provider whose reboots never happen.
+type staleRebootProvider struct {
+	providers.CloudProvider
+}
+
+func (p staleRebootProvider) RebootInstance(ctx context.Context, i providers.ClusterInstance) error {
+	return nil
+}
+
+func TestInitialSetupSSHReadyTimeout(t *testing.T) {
+	c := newTestCluster(t, 1)
+	defer c.Close()
+	i := c.Instances[0]
+	providers.SetPhaseTimeouts(providers.PhaseTimeouts{SSHReady: time.Millisecond * 100})
+	defer providers.SetPhaseTimeouts(providers.PhaseTimeouts{})
+
+	// The OS update requires a reboot that never completes
+	cio := providers.CreateInstanceOptions{
+		InstanceConfig: providers.InstanceConfig{MinOSVersion: "9999.0.0"},
+	}
+	start := time.Now()
+	err := i.InitialSetup(context.Background(), testLog, cio, providers.InitialSetupOptions{}, staleRebootProvider{})
+	if err == nil || !strings.Contains(err.Error(), "has not rebooted") {
+		t.Fatalf("Expected reboot timeout, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second*4 {
+		t.Errorf("Expected InitialSetup to stop at the SSH-ready timeout, took %s", elapsed)
+	}
+}
+
+func TestInitialSetupBootstrapTimeout(t *testing.T) {
+	c := newTestCluster(t, 1)
+	defer c.Close()
+	i := c.Instances[0]
+	release := make(chan struct{})
+	c.Servers[0].Handle("cat", func(cmd sshtest.Command) sshtest.Result {
+		<-release
+		return sshtest.OK("")
+	})
+	defer close(release)
+	providers.SetPhaseTimeouts(providers.PhaseTimeouts{Bootstrap: time.Millisecond * 100})
+	defer providers.SetPhaseTimeouts(providers.PhaseTimeouts{})
+
+	cio := providers.CreateInstanceOptions{
+		InstanceConfig: providers.InstanceConfig{MinOSVersion: "835.13.0"},
+	}
+	err := i.InitialSetup(context.Background(), testLog, cio, providers.InitialSetupOptions{}, staleRebootProvider{})
+	if errgo.Cause(err) != context.DeadlineExceeded {
+		t.Errorf("Expected DeadlineExceeded, got %v", err)
+	}
+}
@@ -0,0 +1,56 @@
+// Copyright (c) 2016 Pulcy.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package journal
+
+import (
+	"context"
+
+	"github.com/pulcy/quark/providers"
+)
+
+// journalingDnsProvider implements providers.DnsProvider.
+// It records all DNS records it creates in the journal.
+type journalingDnsProvider struct {
+	journal     *Journal
+	dnsProvider providers.DnsProvider
+}
+
+// NewDnsProvider creates a DNS provider that records all records created by the given provider in the given journal.
+func NewDnsProvider(journal *Journal, dnsProvider providers.DnsProvider) providers.DnsProvider {
+	if p, ok := dnsProvider.(*journalingDnsProvider); ok && p.journal == journal {
+		// Already recorded in this journal
+		return p
+	}
+	return &journalingDnsProvider{
+		journal:     journal,
+		dnsProvider: dnsProvider,
+	}
+}
+
+func (p *journalingDnsProvider) GetDomainRecords(ctx context.Context, domain string) (providers.DnsRecordList, error) {
+	list, err := p.dnsProvider.GetDomainRecords(ctx, domain)
+	return list, maskAny(err)
+}
+
+func (p *journalingDnsProvider) CreateDnsRecord(ctx context.Context, domain, recordType, name, data string) error {
+	if err := p.dnsProvider.CreateDnsRecord(ctx, domain, recordType, name, data); err != nil {
+		return maskAny(err)
+	}
+	return maskAny(p.journal.Record(Entry{Kind: KindDns, Name: name, Type: recordType, Data: data, Domain: domain}))
+}
+
+func (p *journalingDnsProvider) DeleteDnsRecord(ctx context.Context, domain, recordType, name, data string) error {
+	return maskAny(p.dnsProvider.DeleteDnsRecord(ctx, domain, recordType, name, data))
+}
@@ -0,0 +1,260 @@
+// Copyright (c) 2016 Pulcy.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package journal records every resource created by a create operation in a journal file,
+// so an operation that failed half-way can be resumed or rolled back later.
+// The cloud, DNS & vault providers are wrapped by journaling implementations that record
+// the resources they create; other resources & finished steps are recorded by the caller.
+package journal
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/juju/errgo"
+
+	"github.com/pulcy/quark/providers"
+)
+
+const (
+	// Kinds of entries
+	KindCluster = "cluster" // All instances of a new cluster
+	KindServer  = "server"  // A single instance
+	KindDns     = "dns"     // A DNS record
+	KindVault   = "vault"   // A machine registered in vault
+	KindEtcd    = "etcd"    // An etcd member added to an existing cluster
+	KindStep    = "step"    // A finished step of the operation (nothing to roll back)
+
+	journalFileMode = os.FileMode(0600)
+)
+
+// Entry is a single resource created (or being created) by the operation.
+type Entry struct {
+	Kind      string                     `json:"kind"`           // Kind of resource (see Kind... constants)
+	Name      string                     `json:"name"`           // Instance name, DNS record name, machine ID or step name
+	Done      bool                       `json:"done,omitempty"` // Set when the resource has been created completely
+	Type      string                     `json:"type,omitempty"` // DNS record type
+	Data      string                     `json:"data,omitempty"` // DNS record data
+	Domain    string                     `json:"domain,omitempty"`
+	ClusterIP string                     `json:"cluster-ip,omitempty"` // Cluster IP of the etcd member
+	Instance  *providers.ClusterInstance `json:"instance,omitempty"`   // Created instance
+}
+
+// Journal is the persistent record of a single create operation on a cluster.
+type Journal struct {
+	Operation string                `json:"operation"`
+	Cluster   providers.ClusterInfo `json:"cluster"`
+	Started   time.Time             `json:"started"`
+	Options   json.RawMessage       `json:"options,omitempty"` // Options of the operation, used to resume it
+	Entries   []Entry               `json:"entries,omitempty"`
+
+	mutex    sync.Mutex
+	path     string
+	readOnly bool
+}
+
+// New creates a journal for a new operation that is saved in the given path.
+// The journal is saved as soon as options or resources are recorded.
+func New(path, operation string, cluster providers.ClusterInfo) *Journal {
+	return &Journal{
+		Operation: operation,
+		Cluster:   cluster,
+		Started:   time.Now(),
+		path:      path,
+	}
+}
+
+// Load reads the journal of an unfinished operation from the given path.
+// If there is no such journal, a NotFoundError is returned.
+func Load(path string) (*Journal, error) {
+	raw, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, maskAny(NotFoundError)
+	} else if err != nil {
+		return nil, maskAny(err)
+	}
+	j := &Journal{path: path}
+	if err := json.Unmarshal(raw, j); err != nil {
+		return nil, maskAny(err)
+	}
+	return j, nil
+}
+
+// Path returns the path of the journal file.
+func (j *Journal) Path() string {
+	return j.path
+}
+
+// IsEmpty returns true if no resources have been recorded in the journal.
+func (j *Journal) IsEmpty() bool {
+	j.mutex.Lock()
+	defer j.mutex.Unlock()
+	return len(j.Entries) == 0
+}
+
+// SetReadOnly ensures that changes to the journal are no longer saved (used for dry runs).
+func (j *Journal) SetReadOnly() {
+	j.mutex.Lock()
+	defer j.mutex.Unlock()
+	j.readOnly = true
+}
+
+// SetOptions records the options of the operation, so it can be resumed with the same options.
+func (j *Journal) SetOptions(options interface{}) error {
+	raw, err := json.Marshal(options)
+	if err != nil {
+		return maskAny(err)
+	}
+	j.mutex.Lock()
+	defer j.mutex.Unlock()
+	j.Options = raw
+	return maskAny(j.save())
+}
+
+// HasOptions returns true if options have been recorded for the operation.
+func (j *Journal) HasOptions() bool {
+	j.mutex.Lock()
+	defer j.mutex.Unlock()
+	return len(j.Options) > 0
+}
+
+// GetOptions loads the recorded options of the operation into the given value.
+func (j *Journal) GetOptions(options interface{}) error {
+	j.mutex.Lock()
+	defer j.mutex.Unlock()
+	if err := json.Unmarshal(j.Options, options); err != nil {
+		return maskAny(err)
+	}
+	return nil
+}
+
+// Begin records a resource that is about to be created.
+func (j *Journal) Begin(e Entry) error {
+	j.mutex.Lock()
+	defer j.mutex.Unlock()
+	e.Done = false
+	j.Entries = append(j.Entries, e)
+	return maskAny(j.save())
+}
+
+// Complete marks the last resource of given kind & name as created.
+// The instance (if any) is stored in that entry.
+func (j *Journal) Complete(kind, name string, instance *providers.ClusterInstance) error {
+	j.mutex.Lock()
+	defer j.mutex.Unlock()
+	for i := len(j.Entries) - 1; i >= 0; i-- {
+		e := &j.Entries[i]
+		if e.Kind == kind && e.Name == name {
+			e.Done = true
+			if instance != nil {
+				e.Instance = instance
+			}
+			return maskAny(j.save())
+		}
+	}
+	return maskAny(errgo.WithCausef(nil, NotFoundError, "no %s entry '%s' in journal", kind, name))
+}
+
+// Record records a resource that has been created.
+func (j *Journal) Record(e Entry) error {
+	j.mutex.Lock()
+	defer j.mutex.Unlock()
+	e.Done = true
+	j.Entries = append(j.Entries, e)
+	return maskAny(j.save())
+}
+
+// RecordStep records that the step with given name has finished.
+func (j *Journal) RecordStep(name string) error {
+	return maskAny(j.Record(Entry{Kind: KindStep, Name: name}))
+}
+
+// IsDone returns true if a resource of given kind & name has been created completely.
+func (j *Journal) IsDone(kind, name string) bool {
+	j.mutex.Lock()
+	defer j.mutex.Unlock()
+	for _, e := range j.Entries {
+		if e.Kind == kind && e.Name == name && e.Done {
+			return true
+		}
+	}
+	return false
+}
+
+// Pending returns all resources of given kind that have been started but were not created completely.
+func (j *Journal) Pending(kind string) []Entry {
+	j.mutex.Lock()
+	defer j.mutex.Unlock()
+	var result []Entry
+	for _, e := range j.Entries {
+		if e.Kind == kind && !e.Done {
+			result = append(result, e)
+		}
+	}
+	return result
+}
+
+// Instances returns all instances that have been created completely.
+func (j *Journal) Instances() providers.ClusterInstanceList {
+	j.mutex.Lock()
+	defer j.mutex.Unlock()
+	var result providers.ClusterInstanceList
+	for _, e := range j.Entries {
+		if e.Kind == KindServer && e.Done && e.Instance != nil {
+			result = append(result, *e.Instance)
+		}
+	}
+	return result
+}
+
+// Remove deletes the journal file, after the operation has finished or has been rolled back.
+func (j *Journal) Remove() error {
+	j.mutex.Lock()
+	defer j.mutex.Unlock()
+	if j.readOnly || j.path == "" {
+		return nil
+	}
+	if err := os.Remove(j.path); err != nil && !os.IsNotExist(err) {
+		return maskAny(err)
+	}
+	return nil
+}
+
+// save writes the journal to disk.
+// The caller must hold the mutex.
+func (j *Journal) save() error {
+	if j.readOnly || j.path == "" {
+		return nil
+	}
+	raw, err := json.MarshalIndent(j, "", "  ")
+	if err != nil {
+		return maskAny(err)
+	}
+	if err := os.MkdirAll(filepath.Dir(j.path), 0755); err != nil {
+		return maskAny(err)
+	}
+	// Write to a temporary file first, so an interrupted write never leaves a corrupt journal behind
+	tmpPath := j.path + ".tmp"
+	if err := ioutil.WriteFile(tmpPath, raw, journalFileMode); err != nil {
+		return maskAny(err)
+	}
+	if err := os.Rename(tmpPath, j.path); err != nil {
+		return maskAny(err)
+	}
+	return nil
+}
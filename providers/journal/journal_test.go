@@ -0,0 +1,143 @@
+// Copyright (c) 2016 Pulcy.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package journal_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/juju/errgo"
+
+	"github.com/pulcy/quark/providers"
+	"github.com/pulcy/quark/providers/journal"
+)
+
+func newTestJournal(t *testing.T) (*journal.Journal, func()) {
+	dir, err := ioutil.TempDir("", "quark-journal")
+	if err != nil {
+		t.Fatalf("Cannot create temp dir: %v", err)
+	}
+	j := journal.New(filepath.Join(dir, "journal.json"), "create-instance", providers.ClusterInfo{Name: "c1", Domain: "example.com"})
+	return j, func() { os.RemoveAll(dir) }
+}
+
+func TestPendingComplete(t *testing.T) {
+	j, cleanup := newTestJournal(t)
+	defer cleanup()
+	if !j.IsEmpty() {
+		t.Errorf("Expected a new journal to be empty")
+	}
+
+	if err := j.Begin(journal.Entry{Kind: journal.KindServer, Name: "i1.c1.example.com"}); err != nil {
+		t.Fatalf("Begin failed: %v", err)
+	}
+	if err := j.Begin(journal.Entry{Kind: journal.KindEtcd, Name: "machine1", ClusterIP: "192.168.35.1"}); err != nil {
+		t.Fatalf("Begin failed: %v", err)
+	}
+	if pending := j.Pending(journal.KindServer); len(pending) != 1 || pending[0].Name != "i1.c1.example.com" {
+		t.Errorf("Expected 1 pending server, got %#v", pending)
+	}
+	if j.IsDone(journal.KindServer, "i1.c1.example.com") {
+		t.Errorf("Expected a pending server not to be done")
+	}
+	if instances := j.Instances(); len(instances) != 0 {
+		t.Errorf("Expected no created instances, got %#v", instances)
+	}
+
+	instance := providers.ClusterInstance{ID: "1", Name: "i1.c1.example.com", ClusterIP: "192.168.35.1"}
+	if err := j.Complete(journal.KindServer, "i1.c1.example.com", &instance); err != nil {
+		t.Fatalf("Complete failed: %v", err)
+	}
+	if pending := j.Pending(journal.KindServer); len(pending) != 0 {
+		t.Errorf("Expected no pending servers, got %#v", pending)
+	}
+	if !j.IsDone(journal.KindServer, "i1.c1.example.com") {
+		t.Errorf("Expected a completed server to be done")
+	}
+	if instances := j.Instances(); len(instances) != 1 || instances[0].ID != "1" {
+		t.Errorf("Expected the completed instance, got %#v", instances)
+	}
+	// Other kinds are not affected
+	if pending := j.Pending(journal.KindEtcd); len(pending) != 1 || pending[0].ClusterIP != "192.168.35.1" {
+		t.Errorf("Expected 1 pending etcd member, got %#v", pending)
+	}
+	if err := j.Complete(journal.KindEtcd, "machine2", nil); errgo.Cause(err) != journal.NotFoundError {
+		t.Errorf("Expected NotFoundError when completing an unknown entry, got %v", err)
+	}
+
+	// A retried resource is completed in its last entry
+	if err := j.Begin(journal.Entry{Kind: journal.KindEtcd, Name: "machine1", ClusterIP: "192.168.35.1"}); err != nil {
+		t.Fatalf("Begin failed: %v", err)
+	}
+	if err := j.Complete(journal.KindEtcd, "machine1", nil); err != nil {
+		t.Fatalf("Complete failed: %v", err)
+	}
+	if pending := j.Pending(journal.KindEtcd); len(pending) != 1 {
+		t.Errorf("Expected the first attempt to remain pending, got %#v", pending)
+	}
+
+	// Everything survives a reload
+	loaded, err := journal.Load(j.Path())
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if !loaded.IsDone(journal.KindServer, "i1.c1.example.com") || len(loaded.Pending(journal.KindEtcd)) != 1 || len(loaded.Instances()) != 1 {
+		t.Errorf("Expected the same entries after loading, got %#v", loaded.Entries)
+	}
+}
+
+func TestLoadNotFound(t *testing.T) {
+	if _, err := journal.Load(filepath.Join(os.TempDir(), "quark-journal-does-not-exist.json")); errgo.Cause(err) != journal.NotFoundError {
+		t.Errorf("Expected NotFoundError, got %v", err)
+	}
+}
+
+func TestOptionsWithoutSecrets(t *testing.T) {
+	j, cleanup := newTestJournal(t)
+	defer cleanup()
+	options := providers.CreateInstanceOptions{
+		PrivateRegistryUserName: "deploy",
+		PrivateRegistryPassword: "s3cret-registry",
+		WeaveEnv:                "WEAVE_PASSWORD=s3cret-weave",
+	}
+	if err := j.SetOptions(options); err != nil {
+		t.Fatalf("SetOptions failed: %v", err)
+	}
+	raw, err := ioutil.ReadFile(j.Path())
+	if err != nil {
+		t.Fatalf("Cannot read journal: %v", err)
+	}
+	if strings.Contains(string(raw), "s3cret") {
+		t.Errorf("Expected no secrets in the journal, got %s", raw)
+	}
+
+	var loaded providers.CreateInstanceOptions
+	if err := j.GetOptions(&loaded); err != nil {
+		t.Fatalf("GetOptions failed: %v", err)
+	}
+	if loaded.PrivateRegistryUserName != "deploy" || loaded.PrivateRegistryPassword != "" {
+		t.Errorf("Expected options without registry password, got %#v", loaded)
+	}
+
+	if err := j.SetOptions(providers.CreateClusterOptions{WeavePassword: "s3cret-weave", PrivateRegistryPassword: "s3cret-registry"}); err != nil {
+		t.Fatalf("SetOptions failed: %v", err)
+	}
+	if raw, _ := ioutil.ReadFile(j.Path()); strings.Contains(string(raw), "s3cret") {
+		t.Errorf("Expected no secrets in the journal, got %s", raw)
+	}
+}
@@ -0,0 +1,150 @@
+// Copyright (c) 2016 Pulcy.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package journal
+
+import (
+	"context"
+
+	"github.com/op/go-logging"
+
+	"github.com/pulcy/quark/providers"
+)
+
+// journalingProvider implements providers.CloudProvider.
+// It records all instances & clusters it creates in the journal,
+// all other operations are passed on to the wrapped provider.
+type journalingProvider struct {
+	journal  *Journal
+	provider providers.CloudProvider
+}
+
+// NewProvider creates a cloud provider that records all instances & clusters created by the given provider in the given journal.
+func NewProvider(journal *Journal, provider providers.CloudProvider) providers.CloudProvider {
+	return &journalingProvider{
+		journal:  journal,
+		provider: provider,
+	}
+}
+
+func (p *journalingProvider) GetRegions(ctx context.Context) (providers.RegionList, error) {
+	list, err := p.provider.GetRegions(ctx)
+	return list, maskAny(err)
+}
+
+func (p *journalingProvider) GetImages(ctx context.Context) (providers.ImageList, error) {
+	list, err := p.provider.GetImages(ctx)
+	return list, maskAny(err)
+}
+
+func (p *journalingProvider) GetKeys(ctx context.Context) (providers.SSHKeyList, error) {
+	list, err := p.provider.GetKeys(ctx)
+	return list, maskAny(err)
+}
+
+func (p *journalingProvider) GetInstanceTypes(ctx context.Context) (providers.InstanceTypeList, error) {
+	list, err := p.provider.GetInstanceTypes(ctx)
+	return list, maskAny(err)
+}
+
+func (p *journalingProvider) GetDomainRecords(ctx context.Context, domain string) (providers.DnsRecordList, error) {
+	list, err := p.provider.GetDomainRecords(ctx, domain)
+	return list, maskAny(err)
+}
+
+func (p *journalingProvider) GetClusterTags(ctx context.Context, info providers.ClusterInfo) ([]string, error) {
+	tags, err := p.provider.GetClusterTags(ctx, info)
+	return tags, maskAny(err)
+}
+
+func (p *journalingProvider) AddClusterTag(ctx context.Context, info providers.ClusterInfo, tag string) error {
+	return maskAny(p.provider.AddClusterTag(ctx, info, tag))
+}
+
+func (p *journalingProvider) RemoveClusterTag(ctx context.Context, info providers.ClusterInfo, tag string) error {
+	return maskAny(p.provider.RemoveClusterTag(ctx, info, tag))
+}
+
+// Apply defaults for the given options
+func (p *journalingProvider) ClusterDefaults(options providers.ClusterInfo) providers.ClusterInfo {
+	return p.provider.ClusterDefaults(options)
+}
+
+// Apply defaults for the given options
+func (p *journalingProvider) CreateInstanceDefaults(options providers.CreateInstanceOptions) providers.CreateInstanceOptions {
+	return p.provider.CreateInstanceDefaults(options)
+}
+
+// Apply defaults for the given options
+func (p *journalingProvider) CreateClusterDefaults(options providers.CreateClusterOptions) providers.CreateClusterOptions {
+	return p.provider.CreateClusterDefaults(options)
+}
+
+// Get names of instances of a cluster
+func (p *journalingProvider) GetInstances(ctx context.Context, info providers.ClusterInfo) (providers.ClusterInstanceList, error) {
+	list, err := p.provider.GetInstances(ctx, info)
+	return list, maskAny(err)
+}
+
+// Create a machine instance.
+// The instance is recorded before it is created, so it can be found when creating it fails half-way.
+func (p *journalingProvider) CreateInstance(ctx context.Context, log *logging.Logger, options providers.CreateInstanceOptions, dnsProvider providers.DnsProvider) (providers.ClusterInstance, error) {
+	if err := p.journal.Begin(Entry{Kind: KindServer, Name: options.InstanceName, Domain: options.Domain}); err != nil {
+		return providers.ClusterInstance{}, maskAny(err)
+	}
+	instance, err := p.provider.CreateInstance(ctx, log, options, NewDnsProvider(p.journal, dnsProvider))
+	if err != nil {
+		return providers.ClusterInstance{}, maskAny(err)
+	}
+	if err := p.journal.Complete(KindServer, options.InstanceName, &instance); err != nil {
+		return providers.ClusterInstance{}, maskAny(err)
+	}
+	return instance, nil
+}
+
+// Create an entire cluster.
+// Since the cluster did not exist before, rolling it back removes all of its instances.
+func (p *journalingProvider) CreateCluster(ctx context.Context, log *logging.Logger, options providers.CreateClusterOptions, dnsProvider providers.DnsProvider) error {
+	name := options.ClusterInfo.String()
+	if err := p.journal.Begin(Entry{Kind: KindCluster, Name: name, Domain: options.Domain}); err != nil {
+		return maskAny(err)
+	}
+	if err := p.provider.CreateCluster(ctx, log, options, NewDnsProvider(p.journal, dnsProvider)); err != nil {
+		return maskAny(err)
+	}
+	if err := p.journal.Complete(KindCluster, name, nil); err != nil {
+		return maskAny(err)
+	}
+	return nil
+}
+
+// Remove all instances of a cluster
+func (p *journalingProvider) DeleteCluster(ctx context.Context, info providers.ClusterInfo, dnsProvider providers.DnsProvider) error {
+	return maskAny(p.provider.DeleteCluster(ctx, info, dnsProvider))
+}
+
+// Remove a single instance of a cluster
+func (p *journalingProvider) DeleteInstance(ctx context.Context, info providers.ClusterInstanceInfo, dnsProvider providers.DnsProvider) error {
+	return maskAny(p.provider.DeleteInstance(ctx, info, dnsProvider))
+}
+
+// Perform a reboot of the given instance
+func (p *journalingProvider) RebootInstance(ctx context.Context, instance providers.ClusterInstance) error {
+	return maskAny(p.provider.RebootInstance(ctx, instance))
+}
+
+// Update the instances of the cluster to all new services & formats
+func (p *journalingProvider) UpdateCluster(ctx context.Context, log *logging.Logger, info providers.ClusterInfo, dnsProvider providers.DnsProvider) error {
+	return maskAny(p.provider.UpdateCluster(ctx, log, info, dnsProvider))
+}
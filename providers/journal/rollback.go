@@ -0,0 +1,145 @@
+// Copyright (c) 2016 Pulcy.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package journal
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/juju/errgo"
+	"github.com/op/go-logging"
+
+	"github.com/pulcy/quark/providers"
+)
+
+// Rollback removes all resources recorded in the journal, newest first.
+// Resources that have been removed are dropped from the journal, so a rollback that failed
+// half-way can be retried. When all resources have been removed, the journal file is deleted.
+func (j *Journal) Rollback(ctx context.Context, log *logging.Logger, provider providers.CloudProvider, dnsProvider providers.DnsProvider, vaultProvider providers.VaultProvider) error {
+	j.mutex.Lock()
+	entries := append([]Entry{}, j.Entries...)
+	j.mutex.Unlock()
+
+	var failures []string
+	clusterCreated := j.hasKind(KindCluster)
+	removedServers := false
+	for i := len(entries) - 1; i >= 0; i-- {
+		e := entries[i]
+		var err error
+		switch e.Kind {
+		case KindCluster:
+			log.Infof("Removing all instances of %s", e.Name)
+			err = provider.DeleteCluster(ctx, j.Cluster, dnsProvider)
+		case KindServer:
+			err = j.rollbackServer(ctx, log, e, provider, dnsProvider)
+			removedServers = true
+		case KindDns:
+			log.Infof("Removing %s record %s -> %s", e.Type, e.Name, e.Data)
+			err = dnsProvider.DeleteDnsRecord(ctx, e.Domain, e.Type, e.Name, e.Data)
+		case KindVault:
+			log.Infof("Removing machine %s from vault", e.Name)
+			err = vaultProvider.RemoveMachine(ctx, e.Name)
+		case KindEtcd:
+			err = j.rollbackEtcdMember(ctx, log, e, provider)
+		}
+		if err != nil {
+			log.Errorf("Failed to remove %s %s: %v", e.Kind, e.Name, err)
+			failures = append(failures, fmt.Sprintf("%s %s", e.Kind, e.Name))
+			continue
+		}
+		if err := j.dropEntry(i); err != nil {
+			return maskAny(err)
+		}
+	}
+
+	// Remove the removed instances from the cluster-members of the remaining instances
+	if removedServers && !clusterCreated {
+		instances, err := provider.GetInstances(ctx, j.Cluster)
+		if err != nil {
+			return maskAny(err)
+		}
+		if len(instances) > 0 {
+			if err := providers.UpdateClusterMembers(ctx, log, j.Cluster, false, nil, provider); err != nil {
+				log.Errorf("Failed to update cluster members: %v", err)
+				failures = append(failures, "cluster-members")
+			}
+		}
+	}
+
+	if len(failures) > 0 {
+		return maskAny(fmt.Errorf("failed to remove %s", strings.Join(failures, ", ")))
+	}
+	return maskAny(j.Remove())
+}
+
+// rollbackServer destroys the instance described in the given entry (if it exists).
+func (j *Journal) rollbackServer(ctx context.Context, log *logging.Logger, e Entry, provider providers.CloudProvider, dnsProvider providers.DnsProvider) error {
+	instances, err := provider.GetInstances(ctx, j.Cluster)
+	if err != nil {
+		return maskAny(err)
+	}
+	if _, err := instances.InstanceByName(e.Name); errgo.Cause(err) == providers.NotFoundError {
+		log.Infof("Instance %s does not exist", e.Name)
+		return nil
+	}
+	log.Infof("Destroying instance %s", e.Name)
+	info := providers.ClusterInstanceInfo{
+		ClusterInfo: j.Cluster,
+		Prefix:      strings.SplitN(e.Name, ".", 2)[0],
+	}
+	return maskAny(provider.DeleteInstance(ctx, info, dnsProvider))
+}
+
+// rollbackEtcdMember removes the etcd member described in the given entry from the cluster.
+func (j *Journal) rollbackEtcdMember(ctx context.Context, log *logging.Logger, e Entry, provider providers.CloudProvider) error {
+	instances, err := provider.GetInstances(ctx, j.Cluster)
+	if err != nil {
+		return maskAny(err)
+	}
+	var remaining providers.ClusterInstanceList
+	for _, i := range instances {
+		if i.ClusterIP != e.ClusterIP {
+			remaining = append(remaining, i)
+		}
+	}
+	log.Infof("Removing etcd member %s (%s)", e.Name, e.ClusterIP)
+	if err := remaining.RemoveEtcdMember(ctx, log, e.Name, e.ClusterIP); errgo.Cause(err) == providers.NotFoundError {
+		log.Infof("%s is not an etcd member", e.ClusterIP)
+	} else if err != nil {
+		return maskAny(err)
+	}
+	return nil
+}
+
+// dropEntry removes the entry at the given index from the journal.
+func (j *Journal) dropEntry(index int) error {
+	j.mutex.Lock()
+	defer j.mutex.Unlock()
+	j.Entries = append(j.Entries[:index], j.Entries[index+1:]...)
+	return maskAny(j.save())
+}
+
+// hasKind returns true if the journal contains an entry of the given kind.
+func (j *Journal) hasKind(kind string) bool {
+	j.mutex.Lock()
+	defer j.mutex.Unlock()
+	for _, e := range j.Entries {
+		if e.Kind == kind {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,47 @@
+// Copyright (c) 2016 Pulcy.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package journal
+
+import (
+	"context"
+
+	"github.com/pulcy/quark/providers"
+)
+
+// journalingVaultProvider implements providers.VaultProvider.
+// It records all machines it adds in the journal.
+type journalingVaultProvider struct {
+	journal       *Journal
+	vaultProvider providers.VaultProvider
+}
+
+// NewVaultProvider creates a vault provider that records all machines added by the given provider in the given journal.
+func NewVaultProvider(journal *Journal, vaultProvider providers.VaultProvider) providers.VaultProvider {
+	return &journalingVaultProvider{
+		journal:       journal,
+		vaultProvider: vaultProvider,
+	}
+}
+
+func (p *journalingVaultProvider) AddMachine(ctx context.Context, clusterId, machineId string) error {
+	if err := p.vaultProvider.AddMachine(ctx, clusterId, machineId); err != nil {
+		return maskAny(err)
+	}
+	return maskAny(p.journal.Record(Entry{Kind: KindVault, Name: machineId}))
+}
+
+func (p *journalingVaultProvider) RemoveMachine(ctx context.Context, machineId string) error {
+	return maskAny(p.vaultProvider.RemoveMachine(ctx, machineId))
+}
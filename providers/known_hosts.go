@@ -0,0 +1,239 @@
+// Copyright (c) 2016 Pulcy.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package providers
+
+import (
+	"bytes"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/juju/errgo"
+	"golang.org/x/crypto/ssh"
+)
+
+const (
+	knownHostsFileMode = os.FileMode(0600)
+)
+
+var (
+	knownHosts *KnownHosts
+)
+
+// KnownHosts verifies SSH host keys against a known_hosts file managed by quark.
+// Unknown hosts are trusted on first use (and added to the file), unless strict mode is enabled.
+// The file uses the OpenSSH known_hosts format (hashed entries are not supported).
+// Hosts reached through a jump host are recorded as "<host>@<jump host>".
+type KnownHosts struct {
+	path     string
+	strict   bool
+	mutex    sync.Mutex
+	expected map[string]string // host -> fingerprint reported by the provider
+	repin    map[string]bool   // hosts for which the next key must replace the known key
+}
+
+// NewKnownHosts creates a host key verifier that uses the known_hosts file at the given path.
+func NewKnownHosts(path string, strict bool) *KnownHosts {
+	return &KnownHosts{
+		path:     path,
+		strict:   strict,
+		expected: make(map[string]string),
+		repin:    make(map[string]bool),
+	}
+}
+
+// SetKnownHosts sets the host key verifier used for all SSH connections opened by DialSSH.
+// If nil, all host keys are accepted.
+func SetKnownHosts(kh *KnownHosts) {
+	knownHosts = kh
+}
+
+// Expect records the host key fingerprint of the given host as reported by a provider.
+// An unknown host key is only accepted (even in strict mode) when it matches this fingerprint.
+func (k *KnownHosts) Expect(host, fingerprint string) {
+	if fingerprint == "" {
+		return
+	}
+	k.mutex.Lock()
+	defer k.mutex.Unlock()
+	k.expected[knownHostsName(host)] = fingerprint
+}
+
+// Repin forgets the known host key of the given host, so the key presented
+// during the next connection is trusted and stored (even in strict mode).
+func (k *KnownHosts) Repin(host string) {
+	k.mutex.Lock()
+	defer k.mutex.Unlock()
+	k.repin[knownHostsName(host)] = true
+}
+
+// Callback returns a function that verifies the host key of the given host.
+func (k *KnownHosts) Callback(host string) func(hostname string, remote net.Addr, key ssh.PublicKey) error {
+	return func(_ string, _ net.Addr, key ssh.PublicKey) error {
+		return maskAny(k.verify(host, key))
+	}
+}
+
+func (k *KnownHosts) verify(host string, key ssh.PublicKey) error {
+	k.mutex.Lock()
+	defer k.mutex.Unlock()
+
+	name := knownHostsName(host)
+	if expected, ok := k.expected[name]; ok && !FingerprintMatches(key, expected) {
+		return maskAny(errgo.WithCausef(nil, HostKeyMismatchError, "host key of %s (%s) does not match the fingerprint reported by the provider (%s)", host, FingerprintSHA256(key), expected))
+	}
+
+	lines, known, err := k.load(name)
+	if err != nil {
+		return maskAny(err)
+	}
+	if k.repin[name] {
+		// Forget all known keys of the host
+		delete(k.repin, name)
+	} else if len(known) > 0 {
+		// Any known key (of any type) pins the host, so a key of another type is refused as well
+		for _, knownKey := range known {
+			if bytes.Equal(knownKey.Marshal(), key.Marshal()) {
+				return nil
+			}
+		}
+		return maskAny(errgo.WithCausef(nil, HostKeyMismatchError, "host key of %s has changed to %s; if the instance has been rebuilt, run `quark cluster pin-host-keys`", host, FingerprintSHA256(key)))
+	} else if _, ok := k.expected[name]; !ok && k.strict {
+		return maskAny(errgo.WithCausef(nil, UnknownHostKeyError, "host key of %s (%s) is not known; run `quark cluster pin-host-keys` to trust it", host, FingerprintSHA256(key)))
+	}
+
+	// Trust (new) key
+	lines = append(lines, fmt.Sprintf("%s %s", name, strings.TrimSpace(string(ssh.MarshalAuthorizedKey(key)))))
+	if err := k.save(lines); err != nil {
+		return maskAny(err)
+	}
+	return nil
+}
+
+// hostKeyAlgorithms returns the types of the known keys of the given host, so the host is asked
+// to present a key that can be verified. It returns nil when no key is known (or the host must be re-pinned).
+func (k *KnownHosts) hostKeyAlgorithms(host string) []string {
+	k.mutex.Lock()
+	defer k.mutex.Unlock()
+
+	name := knownHostsName(host)
+	if k.repin[name] {
+		return nil
+	}
+	_, known, err := k.load(name)
+	if err != nil {
+		return nil
+	}
+	var result []string
+	for _, key := range known {
+		if !containsString(result, key.Type()) {
+			result = append(result, key.Type())
+		}
+	}
+	return result
+}
+
+// load reads the known_hosts file and returns all lines except those for the given host,
+// together with the known keys of the host (of any type).
+func (k *KnownHosts) load(name string) ([]string, []ssh.PublicKey, error) {
+	raw, err := ioutil.ReadFile(k.path)
+	if os.IsNotExist(err) {
+		return nil, nil, nil
+	} else if err != nil {
+		return nil, nil, maskAny(err)
+	}
+	var lines []string
+	var known []ssh.PublicKey
+	for _, line := range strings.Split(string(raw), "\n") {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		_, hosts, key, _, _, err := ssh.ParseKnownHosts([]byte(line))
+		if err == nil && containsString(hosts, name) {
+			known = append(known, key)
+			continue
+		}
+		lines = append(lines, line)
+	}
+	return lines, known, nil
+}
+
+func (k *KnownHosts) save(lines []string) error {
+	if err := os.MkdirAll(filepath.Dir(k.path), 0700); err != nil {
+		return maskAny(err)
+	}
+	if err := ioutil.WriteFile(k.path, []byte(strings.Join(lines, "\n")+"\n"), knownHostsFileMode); err != nil {
+		return maskAny(err)
+	}
+	return nil
+}
+
+// FingerprintSHA256 returns the OpenSSH SHA256 fingerprint of the given key (e.g. "SHA256:...").
+func FingerprintSHA256(key ssh.PublicKey) string {
+	sum := sha256.Sum256(key.Marshal())
+	return "SHA256:" + base64.RawStdEncoding.EncodeToString(sum[:])
+}
+
+// FingerprintMD5 returns the legacy MD5 fingerprint of the given key (e.g. "aa:bb:...").
+func FingerprintMD5(key ssh.PublicKey) string {
+	sum := md5.Sum(key.Marshal())
+	parts := make([]string, len(sum))
+	for i, b := range sum {
+		parts[i] = fmt.Sprintf("%02x", b)
+	}
+	return strings.Join(parts, ":")
+}
+
+// FingerprintMatches returns true if the given fingerprint (SHA256 or MD5 format) belongs to the given key.
+func FingerprintMatches(key ssh.PublicKey, fingerprint string) bool {
+	fingerprint = strings.TrimSpace(fingerprint)
+	if strings.HasPrefix(fingerprint, "SHA256:") {
+		return strings.TrimRight(fingerprint, "=") == FingerprintSHA256(key)
+	}
+	return strings.EqualFold(strings.TrimPrefix(fingerprint, "MD5:"), FingerprintMD5(key))
+}
+
+// knownHostsName returns the name of the given host (with optional port) as used in known_hosts files.
+// A host reached through a jump host is given as "<host>@<jump host>" (see SSHOptions.knownHostsHost)
+// and keeps that form, since private addresses behind different jump hosts can be the same.
+func knownHostsName(host string) string {
+	if idx := strings.LastIndex(host, "@"); idx >= 0 {
+		return knownHostsName(host[:idx]) + "@" + knownHostsName(host[idx+1:])
+	}
+	h, port, err := net.SplitHostPort(host)
+	if err != nil {
+		return host
+	}
+	if port == "22" {
+		return h
+	}
+	return fmt.Sprintf("[%s]:%s", h, port)
+}
+
+func containsString(list []string, s string) bool {
+	for _, x := range list {
+		if x == s {
+			return true
+		}
+	}
+	return false
+}
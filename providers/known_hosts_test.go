@@ -0,0 +1,124 @@
+// Copyright (c) 2016 Pulcy.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package providers
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/juju/errgo"
+	"golang.org/x/crypto/ssh"
+)
+
+func newTestHostKey(t *testing.T, curve elliptic.Curve) ssh.PublicKey {
+	priv, err := ecdsa.GenerateKey(curve, rand.Reader)
+	if err != nil {
+		t.Fatalf("Cannot generate key: %v", err)
+	}
+	key, err := ssh.NewPublicKey(&priv.PublicKey)
+	if err != nil {
+		t.Fatalf("Cannot convert key: %v", err)
+	}
+	return key
+}
+
+func newTestKnownHosts(t *testing.T, strict bool) (*KnownHosts, func()) {
+	dir, err := ioutil.TempDir("", "quark-known-hosts")
+	if err != nil {
+		t.Fatalf("Cannot create temp dir: %v", err)
+	}
+	return NewKnownHosts(filepath.Join(dir, "known_hosts"), strict), func() { os.RemoveAll(dir) }
+}
+
+func expectCause(t *testing.T, what string, err, cause error) {
+	if cause == nil {
+		if err != nil {
+			t.Errorf("%s: expected success, got %v", what, err)
+		}
+	} else if errgo.Cause(err) != cause {
+		t.Errorf("%s: expected %v, got %v", what, cause, err)
+	}
+}
+
+func TestKnownHostsVerify(t *testing.T) {
+	k, cleanup := newTestKnownHosts(t, false)
+	defer cleanup()
+	key := newTestHostKey(t, elliptic.P256())
+	otherKey := newTestHostKey(t, elliptic.P256())
+	otherTypeKey := newTestHostKey(t, elliptic.P384())
+
+	expectCause(t, "first key", k.verify("10.0.0.1:22", key), nil)
+	expectCause(t, "same key", k.verify("10.0.0.1:22", key), nil)
+	expectCause(t, "other key", k.verify("10.0.0.1:22", otherKey), HostKeyMismatchError)
+	expectCause(t, "key of other type", k.verify("10.0.0.1:22", otherTypeKey), HostKeyMismatchError)
+	expectCause(t, "key on other port", k.verify("10.0.0.1:2222", otherKey), nil)
+	if algs := k.hostKeyAlgorithms("10.0.0.1:22"); len(algs) != 1 || algs[0] != key.Type() {
+		t.Errorf("Expected host key algorithms [%s], got %v", key.Type(), algs)
+	}
+
+	// Re-pinning replaces all known keys
+	k.Repin("10.0.0.1:22")
+	if algs := k.hostKeyAlgorithms("10.0.0.1:22"); algs != nil {
+		t.Errorf("Expected no host key algorithms while re-pinning, got %v", algs)
+	}
+	expectCause(t, "re-pinned key", k.verify("10.0.0.1:22", otherTypeKey), nil)
+	expectCause(t, "old key after re-pin", k.verify("10.0.0.1:22", key), HostKeyMismatchError)
+	expectCause(t, "re-pinned key again", k.verify("10.0.0.1:22", otherTypeKey), nil)
+	expectCause(t, "other host", k.verify("10.0.0.2:2222", otherKey), nil)
+	expectCause(t, "key on other port again", k.verify("10.0.0.1:2222", otherKey), nil)
+}
+
+func TestKnownHostsVerifyJumpHost(t *testing.T) {
+	k, cleanup := newTestKnownHosts(t, false)
+	defer cleanup()
+	key1 := newTestHostKey(t, elliptic.P256())
+	key2 := newTestHostKey(t, elliptic.P256())
+
+	// The same private address behind different jump hosts belongs to different instances
+	expectCause(t, "cluster 1", k.verify("10.1.0.5:22@1.2.3.4:22", key1), nil)
+	expectCause(t, "cluster 2", k.verify("10.1.0.5:22@5.6.7.8:22", key2), nil)
+	expectCause(t, "cluster 1 again", k.verify("10.1.0.5:22@1.2.3.4:22", key1), nil)
+	expectCause(t, "cluster 1 other key", k.verify("10.1.0.5:22@1.2.3.4:22", key2), HostKeyMismatchError)
+	expectCause(t, "direct", k.verify("10.1.0.5:22", key2), nil)
+
+	if name := knownHostsName("10.1.0.5:22@1.2.3.4:2222"); name != "10.1.0.5@[1.2.3.4]:2222" {
+		t.Errorf("Unexpected known hosts name %s", name)
+	}
+	options := SSHOptions{UserName: "core", Host: "10.1.0.5", JumpHost: "admin@1.2.3.4"}
+	if host := options.knownHostsHost(); host != "10.1.0.5:22@1.2.3.4:22" {
+		t.Errorf("Unexpected known hosts host %s", host)
+	}
+}
+
+func TestKnownHostsVerifyStrict(t *testing.T) {
+	k, cleanup := newTestKnownHosts(t, true)
+	defer cleanup()
+	key := newTestHostKey(t, elliptic.P256())
+	otherKey := newTestHostKey(t, elliptic.P256())
+
+	expectCause(t, "unknown key", k.verify("10.0.0.1:22", key), UnknownHostKeyError)
+	k.Expect("10.0.0.1:22", FingerprintSHA256(otherKey))
+	expectCause(t, "key not reported by provider", k.verify("10.0.0.1:22", key), HostKeyMismatchError)
+	expectCause(t, "key reported by provider", k.verify("10.0.0.1:22", otherKey), nil)
+
+	k.Repin("10.0.0.2:22")
+	expectCause(t, "pinned key", k.verify("10.0.0.2:22", key), nil)
+	expectCause(t, "known key", k.verify("10.0.0.2:22", key), nil)
+}
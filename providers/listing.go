@@ -0,0 +1,134 @@
+// Copyright (c) 2016 Pulcy.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package providers
+
+import (
+	"strings"
+)
+
+// Region describes a location in which a cloud provider can create instances.
+type Region struct {
+	ID        string   `json:"id"`
+	Name      string   `json:"name"`
+	State     string   `json:"state,omitempty"`
+	Country   string   `json:"country,omitempty"`
+	Continent string   `json:"continent,omitempty"`
+	Features  []string `json:"features,omitempty"`
+	Sizes     []string `json:"sizes,omitempty"`
+}
+
+// Image describes an OS image that instances can be created from.
+type Image struct {
+	ID           string   `json:"id"`
+	Name         string   `json:"name"`
+	Description  string   `json:"description,omitempty"`
+	Distribution string   `json:"distribution,omitempty"`
+	Arch         string   `json:"arch,omitempty"`
+	Family       string   `json:"family,omitempty"`
+	Categories   []string `json:"categories,omitempty"`
+	Regions      []string `json:"regions,omitempty"`
+}
+
+// InstanceType describes a plan (size) that instances can be created with.
+type InstanceType struct {
+	ID        string `json:"id"`
+	Name      string `json:"name,omitempty"`
+	VCpus     int    `json:"vcpus,omitempty"`
+	RAM       string `json:"ram,omitempty"`
+	Disk      string `json:"disk,omitempty"`
+	Bandwidth string `json:"bandwidth,omitempty"`
+	Price     string `json:"price,omitempty"`
+}
+
+// SSHKey describes an SSH public key that is registered at a cloud provider.
+type SSHKey struct {
+	ID          string `json:"id,omitempty"`
+	Name        string `json:"name,omitempty"`
+	Fingerprint string `json:"fingerprint,omitempty"`
+	PublicKey   string `json:"public-key,omitempty"`
+}
+
+// DnsRecord describes a single record of a DNS domain.
+type DnsRecord struct {
+	ID       string `json:"id,omitempty"`
+	Type     string `json:"type"`
+	Name     string `json:"name"`
+	Data     string `json:"data"`
+	TTL      int    `json:"ttl,omitempty"`
+	Priority int    `json:"priority,omitempty"`
+	Weight   int    `json:"weight,omitempty"`
+	Port     int    `json:"port,omitempty"`
+}
+
+// FQDN returns the fully qualified name of the record (without trailing dot) in the given domain.
+// Providers return names relative to the domain (e.g. "@" or "host"), absolute names (ending with a dot)
+// or names that already include the domain.
+func (r DnsRecord) FQDN(domain string) string {
+	domain = strings.ToLower(strings.TrimSuffix(domain, "."))
+	name := strings.ToLower(r.Name)
+	switch {
+	case name == "" || name == "@":
+		return domain
+	case strings.HasSuffix(name, "."):
+		return strings.TrimSuffix(name, ".")
+	case name == domain || strings.HasSuffix(name, "."+domain):
+		return name
+	default:
+		return name + "." + domain
+	}
+}
+
+type RegionList []Region
+
+func (l RegionList) Len() int           { return len(l) }
+func (l RegionList) Less(i, j int) bool { return l[i].ID < l[j].ID }
+func (l RegionList) Swap(i, j int)      { l[i], l[j] = l[j], l[i] }
+
+type ImageList []Image
+
+func (l ImageList) Len() int           { return len(l) }
+func (l ImageList) Less(i, j int) bool { return l[i].ID < l[j].ID }
+func (l ImageList) Swap(i, j int)      { l[i], l[j] = l[j], l[i] }
+
+type InstanceTypeList []InstanceType
+
+func (l InstanceTypeList) Len() int           { return len(l) }
+func (l InstanceTypeList) Less(i, j int) bool { return l[i].ID < l[j].ID }
+func (l InstanceTypeList) Swap(i, j int)      { l[i], l[j] = l[j], l[i] }
+
+type SSHKeyList []SSHKey
+
+func (l SSHKeyList) Len() int { return len(l) }
+func (l SSHKeyList) Less(i, j int) bool {
+	if l[i].ID != l[j].ID {
+		return l[i].ID < l[j].ID
+	}
+	return l[i].Name < l[j].Name
+}
+func (l SSHKeyList) Swap(i, j int) { l[i], l[j] = l[j], l[i] }
+
+type DnsRecordList []DnsRecord
+
+func (l DnsRecordList) Len() int { return len(l) }
+func (l DnsRecordList) Less(i, j int) bool {
+	if l[i].Type != l[j].Type {
+		return l[i].Type < l[j].Type
+	}
+	if l[i].Name != l[j].Name {
+		return l[i].Name < l[j].Name
+	}
+	return l[i].Data < l[j].Data
+}
+func (l DnsRecordList) Swap(i, j int) { l[i], l[j] = l[j], l[i] }
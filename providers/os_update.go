@@ -0,0 +1,63 @@
+// Copyright (c) 2016 Pulcy.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package providers
+
+import (
+	"context"
+	"time"
+
+	"github.com/coreos/go-semver/semver"
+	"github.com/juju/errgo"
+	"github.com/op/go-logging"
+)
+
+// GetOSRelease loads the release of the OS running on the instance.
+func (i ClusterInstance) GetOSRelease(ctx context.Context, log *logging.Logger) (semver.Version, error) {
+	s, err := i.Connect()
+	if err != nil {
+		return semver.Version{}, maskAny(err)
+	}
+	defer s.Close()
+	v, err := s.GetOSRelease(ctx, log)
+	if err != nil {
+		return semver.Version{}, maskAny(err)
+	}
+	return v, nil
+}
+
+// UpdateOS updates the OS of the instance when it is older than the given minimum version.
+// After the update the instance is rebooted and this function waits until etcd (and fleet)
+// are healthy again. It returns true if the OS was updated.
+func (i ClusterInstance) UpdateOS(ctx context.Context, log *logging.Logger, minOSVersion semver.Version, healthTimeout time.Duration, provider CloudProvider) (bool, error) {
+	if i.OS != OSNameCoreOS {
+		return false, maskAny(errgo.WithCausef(nil, OSUpdateNotSupportedError, "%s runs %s", i, i.OS))
+	}
+	s, err := i.Connect()
+	if err != nil {
+		return false, maskAny(err)
+	}
+	updated, err := i.osSetup(ctx, s, log, minOSVersion, provider)
+	s.Close()
+	if err != nil {
+		return updated, maskAny(err)
+	}
+	if !updated {
+		return false, nil
+	}
+	if err := i.WaitUntilHealthy(ctx, log, healthTimeout); err != nil {
+		return true, maskAny(err)
+	}
+	return true, nil
+}
@@ -0,0 +1,35 @@
+// Copyright (c) 2016 Pulcy.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package providers
+
+import (
+	"regexp"
+)
+
+var (
+	// Matches `--<name>=<value>` and `--<name> <value>` arguments with a secret value.
+	secretArgPattern = regexp.MustCompile(`(--[\w-]*(?:password|passphrase|secret|token|key)[\w-]*)(=|\s+)('[^']*'|"[^"]*"|[^\s'"]+)`)
+)
+
+// RedactCommand replaces the values of password, token, secret & key arguments
+// in the given command, so it can be shown or logged.
+func RedactCommand(command string) string {
+	return secretArgPattern.ReplaceAllString(command, "$1$2<redacted>")
+}
+
+// isSecretArg returns true if the given `--<name>=<value>` argument has a secret value.
+func isSecretArg(arg string) bool {
+	return secretArgPattern.MatchString(arg)
+}
@@ -0,0 +1,38 @@
+// Copyright (c) 2016 Pulcy.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package providers
+
+import (
+	"testing"
+)
+
+func TestRedactCommand(t *testing.T) {
+	tests := []struct {
+		Command  string
+		Expected string
+	}{
+		{"gluon setup --private-registry-password=s3cret --docker-ip=10.0.0.1", "gluon setup --private-registry-password=<redacted> --docker-ip=10.0.0.1"},
+		{"gluon setup --private-registry-password s3cret --etcd-proxy", "gluon setup --private-registry-password <redacted> --etcd-proxy"},
+		{"vault write --token='abc def' x", "vault write --token=<redacted> x"},
+		{"tool --api-key=\"k\" --secret-id=1", "tool --api-key=<redacted> --secret-id=<redacted>"},
+		{"sudo systemctl restart gluon.service", "sudo systemctl restart gluon.service"},
+		{"etcdctl member list --peer-urls=http://10.0.0.1:2380", "etcdctl member list --peer-urls=http://10.0.0.1:2380"},
+	}
+	for _, test := range tests {
+		if result := RedactCommand(test.Command); result != test.Expected {
+			t.Errorf("RedactCommand(%q): expected %q, got %q", test.Command, test.Expected, result)
+		}
+	}
+}
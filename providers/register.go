@@ -15,6 +15,7 @@
 package providers
 
 import (
+	"context"
 	"strings"
 
 	"github.com/op/go-logging"
@@ -25,38 +26,38 @@ const (
 )
 
 // RegisterInstance creates DNS records for an instance
-func RegisterInstance(logger *logging.Logger, dnsProvider DnsProvider, options CreateInstanceOptions, name string, registerInstance, registerCluster, registerPrivateCluster bool, publicIpv4, publicIpv6, privateIpv4 string) error {
+func RegisterInstance(ctx context.Context, logger *logging.Logger, dnsProvider DnsProvider, options CreateInstanceOptions, name string, registerInstance, registerCluster, registerPrivateCluster bool, publicIpv4, publicIpv6, privateIpv4 string) error {
 	logger.Infof("%s: '%s': '%s'", name, publicIpv4, publicIpv6)
 
 	// Create DNS record for the instance
 	logger.Infof("Creating DNS records: '%s', '%s'", options.InstanceName, options.ClusterName)
 	if publicIpv4 != "" {
 		if registerInstance {
-			if err := dnsProvider.CreateDnsRecord(options.Domain, "A", options.InstanceName, publicIpv4); err != nil {
+			if err := dnsProvider.CreateDnsRecord(ctx, options.Domain, "A", options.InstanceName, publicIpv4); err != nil {
 				return maskAny(err)
 			}
 		}
 		if registerCluster {
-			if err := dnsProvider.CreateDnsRecord(options.Domain, "A", options.ClusterName, publicIpv4); err != nil {
+			if err := dnsProvider.CreateDnsRecord(ctx, options.Domain, "A", options.ClusterName, publicIpv4); err != nil {
 				return maskAny(err)
 			}
 		}
 	}
 	if privateIpv4 != "" {
 		if registerPrivateCluster {
-			if err := dnsProvider.CreateDnsRecord(options.Domain, "A", options.ClusterName+privatePostfix, privateIpv4); err != nil {
+			if err := dnsProvider.CreateDnsRecord(ctx, options.Domain, "A", options.ClusterName+privatePostfix, privateIpv4); err != nil {
 				return maskAny(err)
 			}
 		}
 	}
 	if publicIpv6 != "" {
 		if registerInstance {
-			if err := dnsProvider.CreateDnsRecord(options.Domain, "AAAA", options.InstanceName, publicIpv6); err != nil {
+			if err := dnsProvider.CreateDnsRecord(ctx, options.Domain, "AAAA", options.InstanceName, publicIpv6); err != nil {
 				return maskAny(err)
 			}
 		}
 		if registerCluster {
-			if err := dnsProvider.CreateDnsRecord(options.Domain, "AAAA", options.ClusterName, publicIpv6); err != nil {
+			if err := dnsProvider.CreateDnsRecord(ctx, options.Domain, "AAAA", options.ClusterName, publicIpv6); err != nil {
 				return maskAny(err)
 			}
 		}
@@ -66,12 +67,12 @@ func RegisterInstance(logger *logging.Logger, dnsProvider DnsProvider, options C
 }
 
 // UnRegisterInstance removes DNS records for an instance
-func UnRegisterInstance(logger *logging.Logger, dnsProvider DnsProvider, instance ClusterInstance, domain string) error {
+func UnRegisterInstance(ctx context.Context, logger *logging.Logger, dnsProvider DnsProvider, instance ClusterInstance, domain string) error {
 	// Delete DNS instance records
-	if err := dnsProvider.DeleteDnsRecord(domain, "A", instance.Name, ""); err != nil {
+	if err := dnsProvider.DeleteDnsRecord(ctx, domain, "A", instance.Name, ""); err != nil {
 		return maskAny(err)
 	}
-	if err := dnsProvider.DeleteDnsRecord(domain, "AAAA", instance.Name, ""); err != nil {
+	if err := dnsProvider.DeleteDnsRecord(ctx, domain, "AAAA", instance.Name, ""); err != nil {
 		return maskAny(err)
 	}
 
@@ -79,17 +80,17 @@ func UnRegisterInstance(logger *logging.Logger, dnsProvider DnsProvider, instanc
 	parts := strings.Split(instance.Name, ".")
 	clusterName := strings.Join(parts[1:], ".")
 	if instance.PrivateIP != "" {
-		if err := dnsProvider.DeleteDnsRecord(domain, "A", clusterName+privatePostfix, instance.PrivateIP); err != nil {
+		if err := dnsProvider.DeleteDnsRecord(ctx, domain, "A", clusterName+privatePostfix, instance.PrivateIP); err != nil {
 			return maskAny(err)
 		}
 	}
 	if instance.LoadBalancerIPv4 != "" {
-		if err := dnsProvider.DeleteDnsRecord(domain, "A", clusterName, instance.LoadBalancerIPv4); err != nil {
+		if err := dnsProvider.DeleteDnsRecord(ctx, domain, "A", clusterName, instance.LoadBalancerIPv4); err != nil {
 			return maskAny(err)
 		}
 	}
 	if instance.LoadBalancerIPv6 != "" {
-		if err := dnsProvider.DeleteDnsRecord(domain, "AAAA", clusterName, instance.LoadBalancerIPv6); err != nil {
+		if err := dnsProvider.DeleteDnsRecord(ctx, domain, "AAAA", clusterName, instance.LoadBalancerIPv6); err != nil {
 			return maskAny(err)
 		}
 	}
@@ -0,0 +1,220 @@
+// Copyright (c) 2016 Pulcy.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package providers
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"sync"
+
+	"github.com/juju/errgo"
+	"github.com/op/go-logging"
+	"github.com/spf13/pflag"
+)
+
+// CloudProviderRegistration describes a cloud provider implementation that can be selected by name.
+type CloudProviderRegistration struct {
+	// Name used to select the provider (--provider)
+	Name string
+	// SetupFlags registers the provider specific flags in the given flag set.
+	SetupFlags func(flagSet *pflag.FlagSet)
+	// EnvDefaults maps flag names to environment variables that are used when the flag is not set.
+	EnvDefaults map[string]string
+	// New creates the provider from the values of its flags.
+	New func(log *logging.Logger) (CloudProvider, error)
+	// DnsProvider is the name of the DNS provider used with this cloud provider when no DNS provider is specified.
+	// If empty, the default DNS provider is used.
+	DnsProvider string
+}
+
+// DnsProviderRegistration describes a DNS provider implementation that can be selected by name.
+type DnsProviderRegistration struct {
+	// Name used to select the provider (--dns-provider)
+	Name string
+	// SetupFlags registers the provider specific flags in the given flag set.
+	SetupFlags func(flagSet *pflag.FlagSet)
+	// EnvDefaults maps flag names to environment variables that are used when the flag is not set.
+	EnvDefaults map[string]string
+	// New creates the provider from the values of its flags.
+	New func(log *logging.Logger) (DnsProvider, error)
+}
+
+type registeredProvider struct {
+	flagSet     *pflag.FlagSet
+	envDefaults map[string]string
+}
+
+var (
+	registryMutex  sync.Mutex
+	cloudProviders = make(map[string]CloudProviderRegistration)
+	dnsProviders   = make(map[string]DnsProviderRegistration)
+	flagSets       = make(map[string]registeredProvider) // key is "cloud:<name>" or "dns:<name>"
+)
+
+// RegisterCloudProvider makes a cloud provider available by its name.
+// It is intended to be called from the init function of the provider package.
+// It panics when a cloud provider with the same name is already registered.
+func RegisterCloudProvider(r CloudProviderRegistration) {
+	registryMutex.Lock()
+	defer registryMutex.Unlock()
+	if _, ok := cloudProviders[r.Name]; ok {
+		panic(fmt.Sprintf("cloud provider '%s' registered twice", r.Name))
+	}
+	cloudProviders[r.Name] = r
+	flagSets["cloud:"+r.Name] = newRegisteredProvider(r.Name, r.SetupFlags, r.EnvDefaults)
+}
+
+// RegisterDnsProvider makes a DNS provider available by its name.
+// It is intended to be called from the init function of the provider package.
+// It panics when a DNS provider with the same name is already registered.
+func RegisterDnsProvider(r DnsProviderRegistration) {
+	registryMutex.Lock()
+	defer registryMutex.Unlock()
+	if _, ok := dnsProviders[r.Name]; ok {
+		panic(fmt.Sprintf("DNS provider '%s' registered twice", r.Name))
+	}
+	dnsProviders[r.Name] = r
+	flagSets["dns:"+r.Name] = newRegisteredProvider(r.Name, r.SetupFlags, r.EnvDefaults)
+}
+
+func newRegisteredProvider(name string, setupFlags func(*pflag.FlagSet), envDefaults map[string]string) registeredProvider {
+	flagSet := pflag.NewFlagSet(name, pflag.ContinueOnError)
+	if setupFlags != nil {
+		setupFlags(flagSet)
+	}
+	return registeredProvider{
+		flagSet:     flagSet,
+		envDefaults: envDefaults,
+	}
+}
+
+// CloudProviderNames returns the sorted names of all registered cloud providers.
+func CloudProviderNames() []string {
+	registryMutex.Lock()
+	defer registryMutex.Unlock()
+	var result []string
+	for name := range cloudProviders {
+		result = append(result, name)
+	}
+	sort.Strings(result)
+	return result
+}
+
+// DnsProviderNames returns the sorted names of all registered DNS providers.
+func DnsProviderNames() []string {
+	registryMutex.Lock()
+	defer registryMutex.Unlock()
+	var result []string
+	for name := range dnsProviders {
+		result = append(result, name)
+	}
+	sort.Strings(result)
+	return result
+}
+
+// CloudProviderFlags returns the flags of the cloud provider with given name.
+func CloudProviderFlags(name string) *pflag.FlagSet {
+	registryMutex.Lock()
+	defer registryMutex.Unlock()
+	return flagSets["cloud:"+name].flagSet
+}
+
+// DnsProviderFlags returns the flags of the DNS provider with given name.
+func DnsProviderFlags(name string) *pflag.FlagSet {
+	registryMutex.Lock()
+	defer registryMutex.Unlock()
+	return flagSets["dns:"+name].flagSet
+}
+
+// ProviderFlags returns a flag set containing the flags of all registered providers.
+// The returned flags share their values with the flags of the providers.
+func ProviderFlags() *pflag.FlagSet {
+	registryMutex.Lock()
+	defer registryMutex.Unlock()
+	result := pflag.NewFlagSet("providers", pflag.ContinueOnError)
+	for _, rp := range flagSets {
+		result.AddFlagSet(rp.flagSet)
+	}
+	return result
+}
+
+// NewCloudProvider creates the cloud provider with given name.
+func NewCloudProvider(log *logging.Logger, name string) (CloudProvider, error) {
+	registryMutex.Lock()
+	r, ok := cloudProviders[name]
+	rp := flagSets["cloud:"+name]
+	registryMutex.Unlock()
+	if !ok {
+		return nil, maskAny(errgo.WithCausef(nil, UnknownProviderError, "unknown provider '%s'", name))
+	}
+	if err := rp.applyEnvDefaults(); err != nil {
+		return nil, maskAny(err)
+	}
+	p, err := r.New(log)
+	if err != nil {
+		return nil, maskAny(err)
+	}
+	return p, nil
+}
+
+// NewDnsProvider creates the DNS provider with given name.
+func NewDnsProvider(log *logging.Logger, name string) (DnsProvider, error) {
+	registryMutex.Lock()
+	r, ok := dnsProviders[name]
+	rp := flagSets["dns:"+name]
+	registryMutex.Unlock()
+	if !ok {
+		return nil, maskAny(errgo.WithCausef(nil, UnknownProviderError, "unknown DNS provider '%s'", name))
+	}
+	if err := rp.applyEnvDefaults(); err != nil {
+		return nil, maskAny(err)
+	}
+	p, err := r.New(log)
+	if err != nil {
+		return nil, maskAny(err)
+	}
+	return p, nil
+}
+
+// DefaultDnsProviderFor returns the name of the DNS provider to use with the given cloud provider
+// when no DNS provider is specified.
+func DefaultDnsProviderFor(cloudProvider, defaultName string) string {
+	registryMutex.Lock()
+	defer registryMutex.Unlock()
+	if r, ok := cloudProviders[cloudProvider]; ok && r.DnsProvider != "" {
+		return r.DnsProvider
+	}
+	return defaultName
+}
+
+// applyEnvDefaults sets all flags that have not been set on the command line to the value
+// of their environment variable (if any).
+// This is done lazily (instead of using the environment variable as flag default) to keep
+// secrets out of the usage output.
+func (rp registeredProvider) applyEnvDefaults() error {
+	for flagName, envName := range rp.envDefaults {
+		flag := rp.flagSet.Lookup(flagName)
+		if flag == nil || flag.Changed {
+			continue
+		}
+		if value := os.Getenv(envName); value != "" {
+			if err := rp.flagSet.Set(flagName, value); err != nil {
+				return maskAny(err)
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,142 @@
+// Copyright (c) 2016 Pulcy.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package providers_test
+
+import (
+	"os"
+	"testing"
+
+	"github.com/juju/errgo"
+	"github.com/op/go-logging"
+	"github.com/spf13/pflag"
+
+	"github.com/pulcy/quark/providers"
+)
+
+// registryTestProvider is a cloud provider that only records the token it was created with.
+type registryTestProvider struct {
+	providers.CloudProvider
+	Token string
+}
+
+var registryTestToken string
+
+func init() {
+	providers.RegisterCloudProvider(providers.CloudProviderRegistration{
+		Name: "registry-test",
+		SetupFlags: func(flagSet *pflag.FlagSet) {
+			flagSet.StringVar(&registryTestToken, "registry-test-token", "", "Token of the registry test provider")
+		},
+		EnvDefaults: map[string]string{"registry-test-token": "QUARK_REGISTRY_TEST_TOKEN"},
+		New: func(log *logging.Logger) (providers.CloudProvider, error) {
+			return registryTestProvider{Token: registryTestToken}, nil
+		},
+		DnsProvider: "registry-test-dns",
+	})
+	providers.RegisterDnsProvider(providers.DnsProviderRegistration{
+		Name: "registry-test-dns",
+		New: func(log *logging.Logger) (providers.DnsProvider, error) {
+			return nil, errgo.New("registry-test-dns failure")
+		},
+	})
+}
+
+func TestRegistryNames(t *testing.T) {
+	if !contains(providers.CloudProviderNames(), "registry-test") {
+		t.Errorf("Expected registry-test in cloud providers %v", providers.CloudProviderNames())
+	}
+	if !contains(providers.DnsProviderNames(), "registry-test-dns") {
+		t.Errorf("Expected registry-test-dns in DNS providers %v", providers.DnsProviderNames())
+	}
+	if contains(providers.CloudProviderNames(), "registry-test-dns") {
+		t.Errorf("Expected DNS providers not to be listed as cloud providers")
+	}
+	if providers.CloudProviderFlags("registry-test").Lookup("registry-test-token") == nil {
+		t.Errorf("Expected registry-test-token in the flags of registry-test")
+	}
+	if providers.ProviderFlags().Lookup("registry-test-token") == nil {
+		t.Errorf("Expected registry-test-token in the flags of all providers")
+	}
+}
+
+func TestRegistryNewCloudProvider(t *testing.T) {
+	log := logging.MustGetLogger("registry-test")
+	defer os.Unsetenv("QUARK_REGISTRY_TEST_TOKEN")
+
+	if _, err := providers.NewCloudProvider(log, "no-such-provider"); errgo.Cause(err) != providers.UnknownProviderError {
+		t.Errorf("Expected UnknownProviderError, got %v", err)
+	}
+	if _, err := providers.NewDnsProvider(log, "no-such-provider"); errgo.Cause(err) != providers.UnknownProviderError {
+		t.Errorf("Expected UnknownProviderError, got %v", err)
+	}
+	if _, err := providers.NewDnsProvider(log, "registry-test-dns"); err == nil {
+		t.Errorf("Expected the error of the DNS provider to be returned")
+	}
+
+	// Environment variable is used when the flag is not set
+	os.Setenv("QUARK_REGISTRY_TEST_TOKEN", "from-env")
+	p, err := providers.NewCloudProvider(log, "registry-test")
+	if err != nil {
+		t.Fatalf("NewCloudProvider failed: %v", err)
+	}
+	if token := p.(registryTestProvider).Token; token != "from-env" {
+		t.Errorf("Expected token from-env, got '%s'", token)
+	}
+
+	// Flag set on the command line wins
+	if err := providers.ProviderFlags().Parse([]string{"--registry-test-token=from-flag"}); err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	p, err = providers.NewCloudProvider(log, "registry-test")
+	if err != nil {
+		t.Fatalf("NewCloudProvider failed: %v", err)
+	}
+	if token := p.(registryTestProvider).Token; token != "from-flag" {
+		t.Errorf("Expected token from-flag, got '%s'", token)
+	}
+}
+
+func TestDefaultDnsProviderFor(t *testing.T) {
+	tests := []struct {
+		CloudProvider string
+		Expected      string
+	}{
+		{"registry-test", "registry-test-dns"},
+		{"no-such-provider", "default-dns"},
+	}
+	for _, test := range tests {
+		if actual := providers.DefaultDnsProviderFor(test.CloudProvider, "default-dns"); actual != test.Expected {
+			t.Errorf("DefaultDnsProviderFor(%s): expected %s, got %s", test.CloudProvider, test.Expected, actual)
+		}
+	}
+}
+
+func TestRegisterTwice(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Errorf("Expected registering a provider twice to panic")
+		}
+	}()
+	providers.RegisterCloudProvider(providers.CloudProviderRegistration{Name: "registry-test"})
+}
+
+func contains(list []string, value string) bool {
+	for _, x := range list {
+		if x == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,152 @@
+// Copyright (c) 2016 Pulcy.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package providers
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/juju/errgo"
+	"github.com/op/go-logging"
+	"golang.org/x/sync/errgroup"
+)
+
+// CheckRemoval checks whether the given instance can be removed from the cluster formed by the instances in the given list.
+// It returns a description of every problem found, or no problems when removal is safe.
+// Removal is not safe when the remaining etcd members would lose quorum or become an even number,
+// when the instance is the only instance with one of its roles (e.g. vault or lb) or when its roles are unknown.
+func (cil ClusterInstanceList) CheckRemoval(ctx context.Context, log *logging.Logger, toRemove ClusterInstance) ([]string, error) {
+	remaining := cil.Except(toRemove)
+	etcdProblems, err := remaining.checkEtcdRemoval(ctx, log, toRemove)
+	if err != nil {
+		return nil, maskAny(err)
+	}
+	roleProblems, err := remaining.checkRoleRemoval(ctx, log, toRemove)
+	if err != nil {
+		return nil, maskAny(err)
+	}
+	return append(etcdProblems, roleProblems...), nil
+}
+
+// checkEtcdRemoval checks that the voting etcd members that remain after removing the given instance
+// still have quorum and are an odd number.
+func (cil ClusterInstanceList) checkEtcdRemoval(ctx context.Context, log *logging.Logger, toRemove ClusterInstance) ([]string, error) {
+	if len(cil) == 0 {
+		return []string{fmt.Sprintf("%s is the last instance of the cluster", toRemove.Name)}, nil
+	}
+	var client EtcdClient
+	var lastErr error
+	for _, i := range cil {
+		c, err := i.EtcdClient(ctx, log)
+		if err == nil {
+			client = c
+			break
+		}
+		log.Warningf("cannot connect to etcd on '%s': %#v", i, err)
+		lastErr = err
+	}
+	if client == nil {
+		return nil, maskAny(lastErr)
+	}
+	defer client.Close()
+
+	members, err := client.Members()
+	if err != nil {
+		return nil, maskAny(err)
+	}
+	member, isMember := members.FindByPeerIP(toRemove.ClusterIP)
+	if !isMember {
+		// Etcd proxies do not vote
+		return nil, nil
+	}
+	total, healthyRemaining := 0, 0
+	for _, m := range members {
+		if m.ID == member.ID {
+			continue
+		}
+		total++
+		if healthy, err := client.IsHealthy(m); err == nil && healthy {
+			healthyRemaining++
+		}
+	}
+	return etcdRemovalProblems(toRemove.Name, total, healthyRemaining), nil
+}
+
+// etcdRemovalProblems returns the problems of removing the etcd member with given name,
+// when total voting members (of which healthyRemaining are healthy) remain.
+func etcdRemovalProblems(name string, total, healthyRemaining int) []string {
+	quorum := total/2 + 1
+	var problems []string
+	switch {
+	case total == 0:
+		problems = append(problems, fmt.Sprintf("%s is the last etcd member", name))
+	case healthyRemaining < quorum:
+		problems = append(problems, fmt.Sprintf("removing %s leaves %d healthy of %d etcd members, quorum needs %d", name, healthyRemaining, total, quorum))
+	}
+	if total > 0 && total%2 == 0 {
+		problems = append(problems, fmt.Sprintf("removing %s leaves an even number (%d) of etcd members", name, total))
+	}
+	return problems
+}
+
+// checkRoleRemoval checks that every role of the given instance is also played by one of the instances in the given list.
+// When the roles of the given instance are unknown, that is a problem in itself. Instances in the list with unknown
+// roles are not counted for any role.
+func (cil ClusterInstanceList) checkRoleRemoval(ctx context.Context, log *logging.Logger, toRemove ClusterInstance) ([]string, error) {
+	roles, err := toRemove.GetRoles(ctx, log)
+	if errgo.Cause(err) == UnknownRolesError {
+		return []string{fmt.Sprintf("the roles of %s are unknown, it may be the only instance with one of its roles", toRemove.Name)}, nil
+	} else if err != nil {
+		return nil, maskAny(err)
+	}
+	otherRoles := make([]string, len(cil))
+	g := errgroup.Group{}
+	for idx, i := range cil {
+		idx, i := idx, i
+		g.Go(func() error {
+			r, err := i.GetRoles(ctx, log)
+			if errgo.Cause(err) == UnknownRolesError {
+				log.Warningf("Roles of %s are unknown: %v", i.Name, err)
+				return nil
+			} else if err != nil {
+				return maskAny(err)
+			}
+			otherRoles[idx] = r
+			return nil
+		})
+	}
+	if err := g.Wait(); err != nil {
+		return nil, maskAny(err)
+	}
+
+	var problems []string
+	for _, role := range strings.Split(roles, ",") {
+		role = strings.TrimSpace(role)
+		found := false
+		for _, r := range otherRoles {
+			if hasRole(r, role) {
+				found = true
+				break
+			}
+		}
+		if role != "" && !found {
+			problems = append(problems, fmt.Sprintf("%s is the only instance with role '%s'", toRemove.Name, role))
+		}
+	}
+	sort.Strings(problems)
+	return problems, nil
+}
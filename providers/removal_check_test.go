@@ -0,0 +1,51 @@
+// Copyright (c) 2016 Pulcy.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package providers
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestEtcdRemovalProblems(t *testing.T) {
+	tests := []struct {
+		Total            int
+		HealthyRemaining int
+		Expected         []string
+	}{
+		// 5 -> 4 members
+		{4, 4, []string{"removing i1 leaves an even number (4) of etcd members"}},
+		{4, 3, []string{"removing i1 leaves an even number (4) of etcd members"}},
+		{4, 2, []string{"removing i1 leaves 2 healthy of 4 etcd members, quorum needs 3", "removing i1 leaves an even number (4) of etcd members"}},
+		// 4 -> 3 members
+		{3, 3, nil},
+		{3, 2, nil},
+		{3, 1, []string{"removing i1 leaves 1 healthy of 3 etcd members, quorum needs 2"}},
+		// 3 -> 2 members
+		{2, 2, []string{"removing i1 leaves an even number (2) of etcd members"}},
+		{2, 1, []string{"removing i1 leaves 1 healthy of 2 etcd members, quorum needs 2", "removing i1 leaves an even number (2) of etcd members"}},
+		// 2 -> 1 member
+		{1, 1, nil},
+		{1, 0, []string{"removing i1 leaves 0 healthy of 1 etcd members, quorum needs 1"}},
+		// Last member
+		{0, 0, []string{"i1 is the last etcd member"}},
+	}
+	for _, test := range tests {
+		problems := etcdRemovalProblems("i1", test.Total, test.HealthyRemaining)
+		if !reflect.DeepEqual(problems, test.Expected) {
+			t.Errorf("Expected problems %v with %d of %d healthy, got %v", test.Expected, test.HealthyRemaining, test.Total, problems)
+		}
+	}
+}
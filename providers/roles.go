@@ -0,0 +1,132 @@
+// Copyright (c) 2016 Pulcy.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package providers
+
+import (
+	"context"
+	"strings"
+
+	"github.com/juju/errgo"
+	"github.com/op/go-logging"
+)
+
+const (
+	// rolesPath is the file in which the roles of an instance are recorded (e.g. "core,lb").
+	rolesPath = "/etc/pulcy/roles"
+)
+
+var (
+	// knownRoles are all roles an instance can have, in the order used in /etc/pulcy/roles.
+	knownRoles = []string{"core", "lb", "vault", "worker"}
+)
+
+// GetRoles loads the roles of the instance (e.g. "core,lb") as recorded during its initial setup.
+// Instances created before roles were recorded have no /etc/pulcy/roles. Their roles are derived
+// from their fleet metadata, without changing the instance (see RecordRoles).
+// An UnknownRolesError is returned when the roles cannot be determined.
+func (i ClusterInstance) GetRoles(ctx context.Context, log *logging.Logger) (string, error) {
+	s, err := i.Connect()
+	if err != nil {
+		return "", maskAny(err)
+	}
+	defer s.Close()
+	roles, _, err := loadRoles(ctx, log, s, i)
+	if err != nil {
+		return "", maskAny(err)
+	}
+	return roles, nil
+}
+
+// RecordRoles loads the roles of the instance like GetRoles and records them in /etc/pulcy/roles
+// when they had to be derived, so they are found directly next time.
+func (i ClusterInstance) RecordRoles(ctx context.Context, log *logging.Logger) (string, error) {
+	s, err := i.Connect()
+	if err != nil {
+		return "", maskAny(err)
+	}
+	defer s.Close()
+	roles, recorded, err := loadRoles(ctx, log, s, i)
+	if err != nil {
+		return "", maskAny(err)
+	}
+	if !recorded {
+		log.Infof("Recording roles %s of %s", roles, i.Name)
+		if err := s.WriteFile(ctx, log, rolesPath, roles, 0644, ""); err != nil {
+			return "", maskAny(err)
+		}
+	}
+	return roles, nil
+}
+
+// loadRoles loads the recorded roles of the given instance, or derives them from the fleet
+// metadata in its gluon arguments or as reported by fleet.
+// The returned flag is set when the roles were loaded from /etc/pulcy/roles.
+func loadRoles(ctx context.Context, log *logging.Logger, s InstanceConnection, i ClusterInstance) (string, bool, error) {
+	roles, err := s.GetRoles(ctx, log)
+	if err != nil {
+		return "", false, maskAny(err)
+	}
+	if roles = strings.TrimSpace(roles); roles != "" {
+		return roles, true, nil
+	}
+
+	gluonArgs, err := s.GetGluonArgs(ctx, log)
+	if err != nil {
+		return "", false, maskAny(err)
+	}
+	roles = rolesFromFleetMetadata(parseFleetMetadata(gluonArgs))
+	if roles == "" {
+		metadata, err := fleetMetadata(ctx, log, s)
+		if err != nil {
+			return "", false, maskAny(errgo.WithCausef(err, UnknownRolesError, "%s has no recorded roles and fleet does not know its metadata", i.Name))
+		}
+		roles = rolesFromFleetMetadata(metadata)
+	}
+	if roles == "" {
+		return "", false, maskAny(errgo.WithCausef(nil, UnknownRolesError, "%s has no recorded roles and no roles in its fleet metadata", i.Name))
+	}
+	return roles, false, nil
+}
+
+// rolesFromFleetMetadata returns the roles (e.g. "core,lb") set to true in the given fleet metadata.
+func rolesFromFleetMetadata(metadata map[string]string) string {
+	var list []string
+	for _, role := range knownRoles {
+		if metadata[role] == "true" {
+			list = append(list, role)
+		}
+	}
+	return strings.Join(list, ",")
+}
+
+// fleetMetadata returns the metadata of the instance as reported by fleet.
+func fleetMetadata(ctx context.Context, log *logging.Logger, s InstanceConnection) (map[string]string, error) {
+	machineID, err := s.GetMachineID(ctx, log)
+	if err != nil {
+		return nil, maskAny(err)
+	}
+	out, err := s.Run(ctx, log, "fleetctl list-machines --full --no-legend --fields=machine,metadata", "", true)
+	if err != nil {
+		return nil, maskAny(err)
+	}
+	for _, line := range strings.Split(out, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) != 2 || fields[0] != machineID {
+			continue
+		}
+		return parseFleetMetadata([]string{fleetMetadataFlag + fields[1]}), nil
+	}
+	return nil, maskAny(errgo.WithCausef(nil, NotFoundError, "machine %s is not listed by fleet", machineID))
+}
@@ -0,0 +1,117 @@
+// Copyright (c) 2016 Pulcy.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package providers_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/juju/errgo"
+
+	"github.com/pulcy/quark/providers"
+	"github.com/pulcy/quark/providers/sshtest"
+)
+
+func TestGetRoles(t *testing.T) {
+	c := newTestCluster(t, 4)
+	defer c.Close()
+	// Roles recorded during initial setup
+	c.Servers[0].FS.WriteFile("/etc/pulcy/roles", "core,vault", 0644, "root")
+	// Older instance with recorded gluon arguments
+	c.Servers[1].FS.WriteFile("/etc/pulcy/gluon-args", "--gluon-image=pulcy/gluon:0.30.3\n--fleet-metadata=region=ams3,odd=true,core=true,lb=true", 0400, "root")
+	// Older instance of which only fleet knows the metadata
+	c.Servers[2].Handle("fleetctl", func(cmd sshtest.Command) sshtest.Result {
+		return sshtest.OK("0123456789abcdef\t-\n" + cmd.Server.MachineID() + "\tcore=true,even=true,region=ams3,worker=true\n")
+	})
+	// Older instance without fleet
+	c.Servers[3].Handle("fleetctl", func(cmd sshtest.Command) sshtest.Result {
+		return sshtest.Fail(1, "Error retrieving list of active machines\n")
+	})
+
+	expected := []string{"core,vault", "core,lb", "core,worker"}
+	for idx, roles := range expected {
+		actual, err := c.Instances[idx].GetRoles(context.Background(), testLog)
+		if err != nil {
+			t.Errorf("GetRoles of instance %d failed: %v", idx, err)
+			continue
+		}
+		if actual != roles {
+			t.Errorf("Expected roles %s of instance %d, got %s", roles, idx, actual)
+		}
+		// Derived roles are not recorded by GetRoles
+		if idx > 0 {
+			if _, ok := c.Servers[idx].FS.ReadFile("/etc/pulcy/roles"); ok {
+				t.Errorf("Expected GetRoles not to record the roles of instance %d", idx)
+			}
+		}
+	}
+
+	_, err := c.Instances[3].GetRoles(context.Background(), testLog)
+	if errgo.Cause(err) != providers.UnknownRolesError {
+		t.Errorf("Expected UnknownRolesError, got %v", err)
+	}
+
+	// RecordRoles records derived roles
+	for idx, roles := range expected {
+		actual, err := c.Instances[idx].RecordRoles(context.Background(), testLog)
+		if err != nil {
+			t.Errorf("RecordRoles of instance %d failed: %v", idx, err)
+			continue
+		}
+		if actual != roles {
+			t.Errorf("Expected roles %s of instance %d, got %s", roles, idx, actual)
+		}
+		expectFile(t, c.Servers[idx], "/etc/pulcy/roles", roles, 0644)
+	}
+	if _, err := c.Instances[3].RecordRoles(context.Background(), testLog); errgo.Cause(err) != providers.UnknownRolesError {
+		t.Errorf("Expected UnknownRolesError, got %v", err)
+	}
+	if _, ok := c.Servers[3].FS.ReadFile("/etc/pulcy/roles"); ok {
+		t.Errorf("Expected no roles to be recorded for an instance with unknown roles")
+	}
+}
+
+func TestCheckRemoval(t *testing.T) {
+	c := newTestCluster(t, 4)
+	defer c.Close()
+	c.AddEtcdMembers()
+	c.Servers[0].FS.WriteFile("/etc/pulcy/roles", "core,vault", 0644, "root")
+	c.Servers[1].FS.WriteFile("/etc/pulcy/roles", "core,lb", 0644, "root")
+	c.Servers[2].FS.WriteFile("/etc/pulcy/roles", "core,lb", 0644, "root")
+	// Roles of the last instance cannot be determined
+	c.Servers[3].Handle("fleetctl", func(cmd sshtest.Command) sshtest.Result {
+		return sshtest.Fail(1, "Error retrieving list of active machines\n")
+	})
+
+	tests := []struct {
+		Index    int
+		Expected []string
+	}{
+		{0, []string{"i1.c1.example.com is the only instance with role 'vault'"}},
+		{1, nil},
+		{3, []string{"the roles of i4.c1.example.com are unknown, it may be the only instance with one of its roles"}},
+	}
+	for _, test := range tests {
+		problems, err := c.Instances.CheckRemoval(context.Background(), testLog, c.Instances[test.Index])
+		if err != nil {
+			t.Errorf("CheckRemoval of instance %d failed: %v", test.Index, err)
+			continue
+		}
+		if strings.Join(problems, "\n") != strings.Join(test.Expected, "\n") {
+			t.Errorf("Expected problems %v when removing instance %d, got %v", test.Expected, test.Index, problems)
+		}
+	}
+}
@@ -15,6 +15,7 @@
 package scaleway
 
 import (
+	"context"
 	"fmt"
 	"os"
 	"sync"
@@ -42,26 +43,26 @@ const (
 )
 
 // CreateInstance creates one new machine instance.
-func (vp *scalewayProvider) CreateInstance(log *logging.Logger, options providers.CreateInstanceOptions, dnsProvider providers.DnsProvider) (providers.ClusterInstance, error) {
+func (vp *scalewayProvider) CreateInstance(ctx context.Context, log *logging.Logger, options providers.CreateInstanceOptions, dnsProvider providers.DnsProvider) (providers.ClusterInstance, error) {
 	// Fetch existing instances
-	existingInstances, err := vp.GetInstances(options.ClusterInfo)
+	existingInstances, err := vp.GetInstances(ctx, options.ClusterInfo)
 	if err != nil {
 		return providers.ClusterInstance{}, maskAny(err)
 	}
 
 	// Create server
-	instance, err := vp.createInstance(log, options, dnsProvider, existingInstances)
+	instance, err := vp.createInstance(ctx, log, options, dnsProvider, existingInstances)
 	if err != nil {
 		return providers.ClusterInstance{}, maskAny(err)
 	}
 
 	// Update tinc network config
-	instanceList, err := vp.GetInstances(options.ClusterInfo)
+	instanceList, err := vp.GetInstances(ctx, options.ClusterInfo)
 	if err != nil {
 		return providers.ClusterInstance{}, maskAny(err)
 	}
 	newInstances := providers.ClusterInstanceList{instance}
-	if instanceList.ReconfigureTincCluster(vp.Logger, newInstances); err != nil {
+	if instanceList.ReconfigureTincCluster(ctx, vp.Logger, newInstances); err != nil {
 		return providers.ClusterInstance{}, maskAny(err)
 	}
 
@@ -70,7 +71,7 @@ func (vp *scalewayProvider) CreateInstance(log *logging.Logger, options provider
 
 // createInstance creates a new instances, runs the bootstrap script and registers the instance
 // in DNS.
-func (vp *scalewayProvider) createInstance(log *logging.Logger, options providers.CreateInstanceOptions, dnsProvider providers.DnsProvider, existingInstances providers.ClusterInstanceList) (providers.ClusterInstance, error) {
+func (vp *scalewayProvider) createInstance(ctx context.Context, log *logging.Logger, options providers.CreateInstanceOptions, dnsProvider providers.DnsProvider, existingInstances providers.ClusterInstanceList) (providers.ClusterInstance, error) {
 	if options.RegionID != vp.Region {
 		return providers.ClusterInstance{}, maskAny(fmt.Errorf("Cannot create server on region '%s' with provider configured for region '%s", options.RegionID, vp.Region))
 	}
@@ -82,7 +83,7 @@ func (vp *scalewayProvider) createInstance(log *logging.Logger, options provider
 	log.Debugf("created machined-id: %s", machineID)
 
 	// Create server
-	instance, err := vp.createAndStartServer(options)
+	instance, err := vp.createAndStartServer(ctx, options)
 	if err != nil {
 		return providers.ClusterInstance{}, maskAny(err)
 	}
@@ -91,7 +92,7 @@ func (vp *scalewayProvider) createInstance(log *logging.Logger, options provider
 	// This ensures that the firewall of the existing instances allows our new instance
 	if len(existingInstances) > 0 {
 		rebootAfter := false
-		clusterMembers, err := existingInstances.AsClusterMemberList(log, nil)
+		clusterMembers, err := existingInstances.AsClusterMemberList(ctx, log, nil)
 		if err != nil {
 			return providers.ClusterInstance{}, maskAny(err)
 		}
@@ -103,18 +104,18 @@ func (vp *scalewayProvider) createInstance(log *logging.Logger, options provider
 			EtcdProxy:     options.EtcdProxy,
 		}
 		clusterMembers = append(clusterMembers, newMember)
-		if err := existingInstances.UpdateClusterMembers(log, clusterMembers, rebootAfter, vp); err != nil {
+		if err := existingInstances.UpdateClusterMembers(ctx, log, clusterMembers, rebootAfter, vp); err != nil {
 			log.Warningf("Failed to update cluster members: %#v", err)
 		}
 	}
 
 	// Bootstrap server
-	if err := vp.bootstrapServer(instance, options, machineID); err != nil {
+	if err := vp.bootstrapServer(ctx, instance, options, machineID, existingInstances); err != nil {
 		return providers.ClusterInstance{}, maskAny(err)
 	}
 
 	// Wait for the server to be active
-	server, err := vp.waitUntilServerActive(instance.ID, false)
+	server, err := vp.waitUntilServerActive(ctx, instance.ID, false, existingInstances)
 	if err != nil {
 		return providers.ClusterInstance{}, maskAny(err)
 	}
@@ -126,19 +127,19 @@ func (vp *scalewayProvider) createInstance(log *logging.Logger, options provider
 		if server.IPV6 != nil {
 			publicIpv6 = server.IPV6.Address
 		}
-		if err := providers.RegisterInstance(vp.Logger, dnsProvider, options, server.Name, options.RegisterInstance, options.RoleLoadBalancer, options.RoleLoadBalancer, publicIpv4, publicIpv6, privateIpv4); err != nil {
+		if err := providers.RegisterInstance(ctx, vp.Logger, dnsProvider, options, server.Name, options.RegisterInstance, options.RoleLoadBalancer, options.RoleLoadBalancer, publicIpv4, publicIpv6, privateIpv4); err != nil {
 			return providers.ClusterInstance{}, maskAny(err)
 		}
 	}
 
 	vp.Logger.Infof("Server '%s' is ready", server.Name)
 
-	return vp.clusterInstance(server, false), nil
+	return existingInstances.UseGateway(vp.clusterInstance(server, false)), nil
 }
 
 // createAndStartServer creates a new server and starts it.
 // It then waits until the instance is active.
-func (vp *scalewayProvider) createAndStartServer(options providers.CreateInstanceOptions) (providers.ClusterInstance, error) {
+func (vp *scalewayProvider) createAndStartServer(ctx context.Context, options providers.CreateInstanceOptions) (providers.ClusterInstance, error) {
 	zeroInstance := providers.ClusterInstance{}
 
 	// Validate input
@@ -236,6 +237,12 @@ func (vp *scalewayProvider) createAndStartServer(options providers.CreateInstanc
 				vp.Logger.Errorf("DeleteVolume failed: %#v", err)
 			}
 		}
+		// Release reserved IP
+		if publicIPIdentifier != "" {
+			if err := vp.client.DeleteIP(publicIPIdentifier); err != nil {
+				vp.Logger.Errorf("DeleteIP failed: %#v", err)
+			}
+		}
 		return zeroInstance, maskAny(err)
 	}
 
@@ -246,7 +253,7 @@ func (vp *scalewayProvider) createAndStartServer(options providers.CreateInstanc
 	}
 
 	// Wait until server starts
-	server, err := vp.waitUntilServerActive(id, true)
+	server, err := vp.waitUntilServerActive(ctx, id, true, nil)
 	if err != nil {
 		return zeroInstance, maskAny(err)
 	}
@@ -258,22 +265,25 @@ func (vp *scalewayProvider) createAndStartServer(options providers.CreateInstanc
 
 // bootstrapServer copies etcd & fleet into the instances and runs the scaleway bootstrap script.
 // It then reboots the instances and waits until it is active again.
-func (vp *scalewayProvider) bootstrapServer(instance providers.ClusterInstance, options providers.CreateInstanceOptions, machineID string) error {
+// The gateways are used to reach the instance when its public IP has been disconnected.
+func (vp *scalewayProvider) bootstrapServer(ctx context.Context, instance providers.ClusterInstance, options providers.CreateInstanceOptions, machineID string, gateways providers.ClusterInstanceList) error {
 	// Bootstrap
 	bootstrapOptions := struct {
 		ScalewayProviderConfig
 		providers.CreateInstanceOptions
 		MachineID string
+		NoProxy   string
 	}{
 		ScalewayProviderConfig: vp.ScalewayProviderConfig,
 		CreateInstanceOptions:  options,
 		MachineID:              machineID,
+		NoProxy:                options.NoProxy(),
 	}
 	bootstrap, err := templates.Render(bootstrapTemplate, bootstrapOptions)
 	if err != nil {
 		return maskAny(err)
 	}
-	if err := vp.runBootstrap(instance, bootstrap); err != nil {
+	if err := vp.runBootstrap(ctx, instance, bootstrap); err != nil {
 		return maskAny(err)
 	}
 
@@ -308,8 +318,10 @@ func (vp *scalewayProvider) bootstrapServer(instance providers.ClusterInstance,
 		vp.Logger.Errorf("poweroff failed: %#v", err)
 		return maskAny(err)
 	}
-	time.Sleep(time.Second * 5)
-	if _, err := vp.waitUntilServerActive(instance.ID, false); err != nil {
+	if err := providers.Sleep(ctx, time.Second*5); err != nil {
+		return maskAny(err)
+	}
+	if _, err := vp.waitUntilServerActive(ctx, instance.ID, false, gateways); err != nil {
 		return maskAny(err)
 	}
 
@@ -318,14 +330,14 @@ func (vp *scalewayProvider) bootstrapServer(instance providers.ClusterInstance,
 	return nil
 }
 
-func (vp *scalewayProvider) runBootstrap(instance providers.ClusterInstance, bootstrap string) error {
+func (vp *scalewayProvider) runBootstrap(ctx context.Context, instance providers.ClusterInstance, bootstrap string) error {
 	s, err := instance.Connect()
 	if err != nil {
 		return maskAny(err)
 	}
 	defer s.Close()
 	vp.Logger.Infof("Running bootstrap on %s. This may take a while...", instance.Name)
-	if err := s.RunScript(vp.Logger, bootstrap, "/root/pulcy-bootstrap.sh"); err != nil {
+	if err := s.RunScript(ctx, vp.Logger, bootstrap, "/root/pulcy-bootstrap.sh"); err != nil {
 		// Failed expected because of a reboot
 		vp.Logger.Debugf("bootstrap failed (expected): %#v", err)
 	}
@@ -340,7 +352,11 @@ func (vp *scalewayProvider) getFreeIP() (api.ScalewayIPDefinition, error) {
 	return ip.IP, nil
 }
 
-func (vp *scalewayProvider) waitUntilServerActive(id string, bootstrapNeeded bool) (api.ScalewayServer, error) {
+// waitUntilServerActive waits until the server with given ID is running and accepts SSH connections.
+// Servers without a public IP are connected to through one of the given gateways.
+func (vp *scalewayProvider) waitUntilServerActive(ctx context.Context, id string, bootstrapNeeded bool, gateways providers.ClusterInstanceList) (api.ScalewayServer, error) {
+	ctx, cancel := providers.ProvisionContext(ctx)
+	defer cancel()
 	currentState := ""
 	for {
 		server, err := vp.client.GetServer(id)
@@ -352,14 +368,14 @@ func (vp *scalewayProvider) waitUntilServerActive(id string, bootstrapNeeded boo
 			vp.Logger.Debugf("server state changed to '%s'", server.State)
 		}
 		if server.State == "running" {
-			instance := vp.clusterInstance(*server, bootstrapNeeded)
+			instance := gateways.UseGateway(vp.clusterInstance(*server, bootstrapNeeded))
 			// Check SSH port state
-			sshOpen, err := instance.IsSSHPortOpen(vp.Logger)
+			sshOpen, err := instance.IsSSHPortOpen(ctx, vp.Logger)
 			if err != nil {
 				vp.Logger.Errorf("Cannot check SSH port state: %#v", err)
 			} else if sshOpen {
 				// Attempt an SSH connection
-				if _, err := instance.GetMachineID(vp.Logger); err == nil {
+				if _, err := instance.GetMachineID(ctx, vp.Logger); err == nil {
 					// Success
 					return *server, nil
 				} else {
@@ -368,12 +384,14 @@ func (vp *scalewayProvider) waitUntilServerActive(id string, bootstrapNeeded boo
 			}
 		}
 		// Wait a while
-		time.Sleep(time.Second * 5)
+		if err := providers.Sleep(ctx, time.Second*5); err != nil {
+			return api.ScalewayServer{}, maskAny(errgo.Notef(err, "server %s is not active", id))
+		}
 	}
 }
 
 // Create an entire cluster
-func (vp *scalewayProvider) CreateCluster(log *logging.Logger, options providers.CreateClusterOptions, dnsProvider providers.DnsProvider) error {
+func (vp *scalewayProvider) CreateCluster(ctx context.Context, log *logging.Logger, options providers.CreateClusterOptions, dnsProvider providers.DnsProvider) error {
 	wg := sync.WaitGroup{}
 	errors := make(chan error, options.InstanceCount)
 	instanceDatas := make(chan instanceData, options.InstanceCount)
@@ -381,7 +399,10 @@ func (vp *scalewayProvider) CreateCluster(log *logging.Logger, options providers
 		wg.Add(1)
 		go func(i int) {
 			defer wg.Done()
-			time.Sleep(time.Duration((i - 1)) * time.Second * 10)
+			if err := providers.Sleep(ctx, time.Duration((i-1))*time.Second*10); err != nil {
+				errors <- maskAny(err)
+				return
+			}
 			isCore := (i <= 3)
 			isLB := (i <= 2)
 			instanceOptions, err := options.NewCreateInstanceOptions(isCore, isLB, i)
@@ -393,7 +414,7 @@ func (vp *scalewayProvider) CreateCluster(log *logging.Logger, options providers
 			if !isLB {
 				instanceOptions.NoPublicIPv4 = true
 			}
-			instance, err := vp.createInstance(log, instanceOptions, dnsProvider, nil)
+			instance, err := vp.createInstance(ctx, log, instanceOptions, dnsProvider, nil)
 			if err != nil {
 				errors <- maskAny(err)
 			} else {
@@ -420,17 +441,23 @@ func (vp *scalewayProvider) CreateCluster(log *logging.Logger, options providers
 		instanceList = append(instanceList, data.ClusterInstance)
 	}
 
-	clusterMembers, err := instanceList.AsClusterMemberList(log, nil)
+	// Instances without a public IP are reached through a gateway
+	for i, data := range instances {
+		instances[i].ClusterInstance = instanceList.UseGateway(data.ClusterInstance)
+	}
+	instanceList = instanceList.UseGateways()
+
+	clusterMembers, err := instanceList.AsClusterMemberList(ctx, log, nil)
 	if err != nil {
 		return maskAny(err)
 	}
 
 	// Create tinc network config
-	if instanceList.ReconfigureTincCluster(vp.Logger, instanceList); err != nil {
+	if instanceList.ReconfigureTincCluster(ctx, vp.Logger, instanceList); err != nil {
 		return maskAny(err)
 	}
 
-	if err := vp.setupInstances(log, instances, clusterMembers); err != nil {
+	if err := vp.setupInstances(ctx, log, instances, clusterMembers); err != nil {
 		return maskAny(err)
 	}
 
@@ -443,7 +470,7 @@ type instanceData struct {
 	FleetMetadata         string
 }
 
-func (vp *scalewayProvider) setupInstances(log *logging.Logger, instances []instanceData, clusterMembers providers.ClusterMemberList) error {
+func (vp *scalewayProvider) setupInstances(ctx context.Context, log *logging.Logger, instances []instanceData, clusterMembers providers.ClusterMemberList) error {
 	wg := sync.WaitGroup{}
 	errors := make(chan error, len(instances))
 	for _, instance := range instances {
@@ -455,7 +482,7 @@ func (vp *scalewayProvider) setupInstances(log *logging.Logger, instances []inst
 				FleetMetadata:  instance.FleetMetadata,
 			}
 
-			if err := instance.ClusterInstance.InitialSetup(log, instance.CreateInstanceOptions, iso, vp); err != nil {
+			if err := instance.ClusterInstance.InitialSetup(ctx, log, instance.CreateInstanceOptions, iso, vp); err != nil {
 				errors <- maskAny(err)
 				return
 			}
@@ -15,6 +15,8 @@
 package scaleway
 
 import (
+	"context"
+
 	"github.com/pulcy/quark/providers"
 )
 
@@ -44,7 +46,7 @@ func (vp *scalewayProvider) CreateInstanceDefaults(options providers.CreateInsta
 	options.ClusterInfo = vp.ClusterDefaults(options.ClusterInfo)
 	options.InstanceConfig = vp.instanceConfigDefaults(options.InstanceConfig)
 	if options.TincIpv4 == "" && options.TincCIDR != "" {
-		instances, err := vp.GetInstances(options.ClusterInfo)
+		instances, err := vp.GetInstances(context.Background(), options.ClusterInfo)
 		if err != nil {
 			vp.Logger.Warningf("Failed to load instances: %#v", err)
 		} else {
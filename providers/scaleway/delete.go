@@ -15,19 +15,21 @@
 package scaleway
 
 import (
+	"context"
+
 	"github.com/scaleway/scaleway-cli/pkg/api"
 
 	"github.com/pulcy/quark/providers"
 )
 
 // Remove all instances of a cluster
-func (vp *scalewayProvider) DeleteCluster(info providers.ClusterInfo, dnsProvider providers.DnsProvider) error {
+func (vp *scalewayProvider) DeleteCluster(ctx context.Context, info providers.ClusterInfo, dnsProvider providers.DnsProvider) error {
 	servers, err := vp.getServers(info)
 	if err != nil {
 		return err
 	}
 	for _, s := range servers {
-		if err := vp.deleteServer(s, dnsProvider, info.Domain); err != nil {
+		if err := vp.deleteServer(ctx, s, dnsProvider, info.Domain); err != nil {
 			return maskAny(err)
 		}
 	}
@@ -35,7 +37,7 @@ func (vp *scalewayProvider) DeleteCluster(info providers.ClusterInfo, dnsProvide
 	return nil
 }
 
-func (vp *scalewayProvider) DeleteInstance(info providers.ClusterInstanceInfo, dnsProvider providers.DnsProvider) error {
+func (vp *scalewayProvider) DeleteInstance(ctx context.Context, info providers.ClusterInstanceInfo, dnsProvider providers.DnsProvider) error {
 	fullName := info.String()
 	servers, err := vp.getServers(info.ClusterInfo)
 	if err != nil {
@@ -45,7 +47,7 @@ func (vp *scalewayProvider) DeleteInstance(info providers.ClusterInstanceInfo, d
 	found := false
 	for _, s := range servers {
 		if s.Name == fullName {
-			if err := vp.deleteServer(s, dnsProvider, info.Domain); err != nil {
+			if err := vp.deleteServer(ctx, s, dnsProvider, info.Domain); err != nil {
 				return maskAny(err)
 			}
 			found = true
@@ -58,20 +60,20 @@ func (vp *scalewayProvider) DeleteInstance(info providers.ClusterInstanceInfo, d
 	}
 
 	// Reconfigure tinc
-	instanceList, err := vp.GetInstances(info.ClusterInfo)
+	instanceList, err := vp.GetInstances(ctx, info.ClusterInfo)
 	if err != nil {
 		return maskAny(err)
 	}
 	// Create tinc network config
 	newInstances := providers.ClusterInstanceList{}
-	if instanceList.ReconfigureTincCluster(vp.Logger, newInstances); err != nil {
+	if instanceList.ReconfigureTincCluster(ctx, vp.Logger, newInstances); err != nil {
 		return maskAny(err)
 	}
 
 	return nil
 }
 
-func (vp *scalewayProvider) deleteServer(s api.ScalewayServer, dnsProvider providers.DnsProvider, domain string) error {
+func (vp *scalewayProvider) deleteServer(ctx context.Context, s api.ScalewayServer, dnsProvider providers.DnsProvider, domain string) error {
 	if s.State == "running" {
 		vp.Logger.Infof("Stopping server %s", s.Name)
 		if err := vp.client.PostServerAction(s.Identifier, "terminate"); err != nil {
@@ -85,7 +87,7 @@ func (vp *scalewayProvider) deleteServer(s api.ScalewayServer, dnsProvider provi
 	// Delete DNS instance records
 	vp.Logger.Infof("Unregistering DNS for %s", s.Name)
 	instance := vp.clusterInstance(s, false)
-	if err := providers.UnRegisterInstance(vp.Logger, dnsProvider, instance, domain); err != nil {
+	if err := providers.UnRegisterInstance(ctx, vp.Logger, dnsProvider, instance, domain); err != nil {
 		return maskAny(err)
 	}
 
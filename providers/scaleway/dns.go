@@ -14,6 +14,12 @@
 
 package scaleway
 
-func (vp *scalewayProvider) ShowDomainRecords(domain string) error {
-	return maskAny(NotImplementedError)
+import (
+	"context"
+
+	"github.com/pulcy/quark/providers"
+)
+
+func (vp *scalewayProvider) GetDomainRecords(ctx context.Context, domain string) (providers.DnsRecordList, error) {
+	return nil, maskAny(NotImplementedError)
 }
@@ -15,6 +15,7 @@
 package scaleway
 
 import (
+	"context"
 	"fmt"
 	"net"
 	"strings"
@@ -25,7 +26,7 @@ import (
 )
 
 // Get names of instances of a cluster
-func (vp *scalewayProvider) GetInstances(info providers.ClusterInfo) (providers.ClusterInstanceList, error) {
+func (vp *scalewayProvider) GetInstances(ctx context.Context, info providers.ClusterInfo) (providers.ClusterInstanceList, error) {
 	servers, err := vp.getServers(info)
 	if err != nil {
 		return nil, maskAny(err)
@@ -36,7 +37,8 @@ func (vp *scalewayProvider) GetInstances(info providers.ClusterInfo) (providers.
 		list = append(list, instance)
 
 	}
-	return list, nil
+	// Instances without a public IP are reached through a gateway
+	return list.UseGateways(), nil
 }
 
 func (vp *scalewayProvider) getServers(info providers.ClusterInfo) ([]api.ScalewayServer, error) {
@@ -15,31 +15,26 @@
 package scaleway
 
 import (
-	"fmt"
-	"sort"
+	"context"
 
-	"github.com/ryanuber/columnize"
+	"github.com/pulcy/quark/providers"
 )
 
-func (vp *scalewayProvider) ShowKeys() error {
+func (vp *scalewayProvider) GetKeys(ctx context.Context) (providers.SSHKeyList, error) {
 	user, err := vp.client.GetUser()
 	if err != nil {
-		return maskAny(err)
-	}
-	keys := user.SSHPublicKeys
-	lines := []string{
-		"Key | Fingerprint",
-	}
-	for _, r := range keys {
-		line := fmt.Sprintf("%v | %s", r.Key, r.Fingerprint)
-		lines = append(lines, line)
+		return nil, maskAny(err)
 	}
 
-	sort.Strings(lines[1:])
-	result := columnize.SimpleFormat(lines)
-	fmt.Println(result)
+	result := providers.SSHKeyList{}
+	for _, r := range user.SSHPublicKeys {
+		result = append(result, providers.SSHKey{
+			Fingerprint: r.Fingerprint,
+			PublicKey:   r.Key,
+		})
+	}
 
-	return nil
+	return result, nil
 }
 
 // Search for an SSH key with given name and return its ID
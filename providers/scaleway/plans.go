@@ -14,6 +14,12 @@
 
 package scaleway
 
-func (vp *scalewayProvider) ShowInstanceTypes() error {
-	return maskAny(NotImplementedError)
+import (
+	"context"
+
+	"github.com/pulcy/quark/providers"
+)
+
+func (vp *scalewayProvider) GetInstanceTypes(ctx context.Context) (providers.InstanceTypeList, error) {
+	return nil, maskAny(NotImplementedError)
 }
@@ -15,17 +15,19 @@
 package scaleway
 
 import (
+	"context"
+
 	"github.com/pulcy/quark/providers"
 )
 
 // Perform a reboot of the given instance
-func (vp *scalewayProvider) RebootInstance(instance providers.ClusterInstance) error {
+func (vp *scalewayProvider) RebootInstance(ctx context.Context, instance providers.ClusterInstance) error {
 	s, err := instance.Connect()
 	if err != nil {
 		return maskAny(err)
 	}
 	defer s.Close()
-	if err := s.Sync(vp.Logger); err != nil {
+	if err := s.Sync(ctx, vp.Logger); err != nil {
 		return maskAny(err)
 	}
 	if err := vp.client.PostServerAction(instance.ID, "reboot"); err != nil {
@@ -15,28 +15,14 @@
 package scaleway
 
 import (
-	"fmt"
-	"sort"
+	"context"
 
-	"github.com/ryanuber/columnize"
+	"github.com/pulcy/quark/providers"
 )
 
-func (vp *scalewayProvider) ShowRegions() error {
-	regions := map[string]string{
-		regionParis1:     "Paris 1",
-		regionAmsterdam1: "Amsterdam 1",
-	}
-	lines := []string{
-		"ID | Name",
-	}
-	for id, name := range regions {
-		line := fmt.Sprintf("%s | %s", id, name)
-		lines = append(lines, line)
-	}
-
-	sort.Strings(lines[1:])
-	result := columnize.SimpleFormat(lines)
-	fmt.Println(result)
-
-	return nil
+func (vp *scalewayProvider) GetRegions(ctx context.Context) (providers.RegionList, error) {
+	return providers.RegionList{
+		{ID: regionParis1, Name: "Paris 1"},
+		{ID: regionAmsterdam1, Name: "Amsterdam 1"},
+	}, nil
 }
@@ -0,0 +1,62 @@
+// Copyright (c) 2016 Pulcy.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package scaleway
+
+import (
+	"github.com/juju/errgo"
+	"github.com/op/go-logging"
+	"github.com/spf13/pflag"
+
+	"github.com/pulcy/quark/providers"
+)
+
+var (
+	config = NewConfig()
+)
+
+func init() {
+	providers.RegisterCloudProvider(providers.CloudProviderRegistration{
+		Name: "scaleway",
+		SetupFlags: func(flagSet *pflag.FlagSet) {
+			flagSet.StringVar(&config.Organization, "scaleway-organization", config.Organization, "Scaleway organization ID (defaults to .scwrc)")
+			flagSet.StringVar(&config.Token, "scaleway-token", config.Token, "Scaleway token (defaults to .scwrc)")
+			flagSet.StringVar(&config.Region, "scaleway-region", config.Region, "Scaleway region")
+			flagSet.BoolVar(&config.ReserveLoadBalancerIP, "scaleway-reserve-ip", config.ReserveLoadBalancerIP, "Use reserved IPv4 addresses for load-balancer instances")
+			flagSet.BoolVar(&config.EnableIPV6, "scaleway-ipv6", config.EnableIPV6, "Enabled IPv6 on all instances")
+			flagSet.BoolVar(&config.NoIPv4, "scaleway-no-ipv4", config.NoIPv4, "Do not add IPv4 addresses to new instances")
+		},
+		New: func(log *logging.Logger) (providers.CloudProvider, error) {
+			if config.Organization == "" || config.Token == "" {
+				rc, err := ReadRC()
+				if err != nil {
+					return nil, maskAny(errgo.Notef(err, "Cannot read .scwrc"))
+				}
+				config.Organization = rc.Organization
+				config.Token = rc.Token
+			}
+			if config.Organization == "" {
+				return nil, maskAny(errgo.WithCausef(nil, providers.InvalidArgumentError, "Please specify a scaleway-organization"))
+			}
+			if config.Token == "" {
+				return nil, maskAny(errgo.WithCausef(nil, providers.InvalidArgumentError, "Please specify a scaleway-token"))
+			}
+			provider, err := NewProvider(log, config)
+			if err != nil {
+				return nil, maskAny(err)
+			}
+			return provider, nil
+		},
+	})
+}
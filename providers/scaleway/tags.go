@@ -0,0 +1,72 @@
+// Copyright (c) 2016 Pulcy.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package scaleway
+
+import (
+	"context"
+
+	"github.com/scaleway/scaleway-cli/pkg/api"
+
+	"github.com/pulcy/quark/providers"
+)
+
+// Get all tags of the instances of a cluster
+func (vp *scalewayProvider) GetClusterTags(ctx context.Context, info providers.ClusterInfo) ([]string, error) {
+	servers, err := vp.getServers(info)
+	if err != nil {
+		return nil, maskAny(err)
+	}
+	var tags []string
+	for _, s := range servers {
+		tags = append(tags, s.Tags...)
+	}
+	return tags, nil
+}
+
+// Add a tag to all instances of a cluster
+func (vp *scalewayProvider) AddClusterTag(ctx context.Context, info providers.ClusterInfo, tag string) error {
+	return maskAny(vp.updateTags(info, func(tags []string) []string {
+		return append(tags, tag)
+	}))
+}
+
+// Remove a tag from all instances of a cluster
+func (vp *scalewayProvider) RemoveClusterTag(ctx context.Context, info providers.ClusterInfo, tag string) error {
+	return maskAny(vp.updateTags(info, func(tags []string) []string {
+		result := []string{}
+		for _, t := range tags {
+			if t != tag {
+				result = append(result, t)
+			}
+		}
+		return result
+	}))
+}
+
+// updateTags replaces the tags of all servers of the given cluster with the result of the given function.
+// The cluster IP tag (see clusterIPTagIndex) must be kept in place.
+func (vp *scalewayProvider) updateTags(info providers.ClusterInfo, update func(tags []string) []string) error {
+	servers, err := vp.getServers(info)
+	if err != nil {
+		return maskAny(err)
+	}
+	for _, s := range servers {
+		tags := update(append([]string{}, s.Tags...))
+		if err := vp.client.PatchServer(s.Identifier, api.ScalewayServerPatchDefinition{Tags: &tags}); err != nil {
+			return maskAny(err)
+		}
+	}
+	return nil
+}
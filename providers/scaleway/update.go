@@ -15,25 +15,27 @@
 package scaleway
 
 import (
+	"context"
+
 	"github.com/op/go-logging"
 
 	"github.com/pulcy/quark/providers"
 )
 
-func (p *scalewayProvider) UpdateCluster(log *logging.Logger, info providers.ClusterInfo, dnsProvider providers.DnsProvider) error {
-	instances, err := p.GetInstances(info)
+func (p *scalewayProvider) UpdateCluster(ctx context.Context, log *logging.Logger, info providers.ClusterInfo, dnsProvider providers.DnsProvider) error {
+	instances, err := p.GetInstances(ctx, info)
 	if err != nil {
 		return maskAny(err)
 	}
-	members, err := instances.AsClusterMemberList(log, nil)
+	members, err := instances.AsClusterMemberList(ctx, log, nil)
 	if err != nil {
 		return maskAny(err)
 	}
 	rebootAfter := false
-	if err := instances.UpdateClusterMembers(log, members, rebootAfter, p); err != nil {
+	if err := instances.UpdateClusterMembers(ctx, log, members, rebootAfter, p); err != nil {
 		return maskAny(err)
 	}
-	if err := instances.ReconfigureTincCluster(log, nil); err != nil {
+	if err := instances.ReconfigureTincCluster(ctx, log, nil); err != nil {
 		return maskAny(err)
 	}
 	return nil
@@ -0,0 +1,157 @@
+// Copyright (c) 2016 Pulcy.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package providers
+
+import (
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"sync"
+
+	"github.com/juju/errgo"
+	"github.com/mitchellh/go-homedir"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+	"golang.org/x/crypto/ssh/terminal"
+)
+
+// SSHSettings specifies how SSH connections to instances are made,
+// unless an instance specifies otherwise.
+type SSHSettings struct {
+	User     string   // Account name used to SSH into instances (empty defaults to 'core')
+	Port     int      // Port of the SSH server on instances (0 defaults to 22)
+	KeyFiles []string // Private key files used for authentication (in addition to the ssh-agent)
+}
+
+var (
+	sshSettings SSHSettings
+
+	// sshSigners caches the signers of loaded private key files, so passphrases are asked only once.
+	sshSigners      = make(map[string]ssh.Signer)
+	sshSignersMutex sync.Mutex
+)
+
+// SetSSHSettings configures the SSH settings used for all SSH connections to instances.
+func SetSSHSettings(settings SSHSettings) {
+	sshSettings = settings
+}
+
+// WithDefaults returns the settings, with all empty fields taken from the given defaults.
+func (s SSHSettings) WithDefaults(defaults SSHSettings) SSHSettings {
+	if s.User == "" {
+		s.User = defaults.User
+	}
+	if s.Port == 0 {
+		s.Port = defaults.Port
+	}
+	if len(s.KeyFiles) == 0 {
+		s.KeyFiles = defaults.KeyFiles
+	}
+	return s
+}
+
+// sshAuthMethod creates an authentication method that offers the keys of the ssh-agent (if running)
+// and the keys in the given private key files.
+func sshAuthMethod(keyFiles []string) (ssh.AuthMethod, error) {
+	var sshAgent agent.Agent
+	if sock := os.Getenv("SSH_AUTH_SOCK"); sock != "" {
+		if agentConn, err := net.Dial("unix", sock); err == nil {
+			sshAgent = agent.NewClient(agentConn)
+		}
+	}
+	var signers []ssh.Signer
+	for _, path := range keyFiles {
+		signer, err := loadSSHKeyFile(path)
+		if err != nil {
+			return nil, maskAny(err)
+		}
+		signers = append(signers, signer)
+	}
+	if sshAgent == nil && len(signers) == 0 {
+		return nil, maskAny(errgo.WithCausef(nil, NoSSHAuthError, "no ssh-agent is running (SSH_AUTH_SOCK) and no SSH key files are specified"))
+	}
+	return ssh.PublicKeysCallback(func() ([]ssh.Signer, error) {
+		result := signers
+		if sshAgent != nil {
+			agentSigners, err := sshAgent.Signers()
+			if err != nil {
+				return nil, maskAny(err)
+			}
+			result = append(agentSigners, signers...)
+		}
+		return result, nil
+	}), nil
+}
+
+// loadSSHKeyFile reads a private key file.
+// The passphrase of an encrypted key is asked for on the terminal.
+func loadSSHKeyFile(path string) (ssh.Signer, error) {
+	sshSignersMutex.Lock()
+	defer sshSignersMutex.Unlock()
+
+	if signer, ok := sshSigners[path]; ok {
+		return signer, nil
+	}
+	expandedPath, err := homedir.Expand(path)
+	if err != nil {
+		return nil, maskAny(err)
+	}
+	raw, err := ioutil.ReadFile(expandedPath)
+	if err != nil {
+		return nil, maskAny(err)
+	}
+	block, _ := pem.Decode(raw)
+	if block == nil {
+		return nil, maskAny(errgo.WithCausef(nil, InvalidArgumentError, "no private key found in %s", path))
+	}
+	if x509.IsEncryptedPEMBlock(block) {
+		passphrase, err := readPassphrase(fmt.Sprintf("Enter passphrase for key '%s': ", path))
+		if err != nil {
+			return nil, maskAny(err)
+		}
+		der, err := x509.DecryptPEMBlock(block, passphrase)
+		if err != nil {
+			return nil, maskAny(errgo.Notef(err, "cannot decrypt %s", path))
+		}
+		raw = pem.EncodeToMemory(&pem.Block{Type: block.Type, Bytes: der})
+	}
+	signer, err := ssh.ParsePrivateKey(raw)
+	if err != nil {
+		if block.Type == "OPENSSH PRIVATE KEY" {
+			return nil, maskAny(errgo.Notef(err, "cannot load %s; add it to ssh-agent or convert it with `ssh-keygen -p -m PEM -f %s`", path, path))
+		}
+		return nil, maskAny(errgo.Notef(err, "cannot load %s", path))
+	}
+	sshSigners[path] = signer
+	return signer, nil
+}
+
+// readPassphrase asks for a passphrase on the terminal.
+func readPassphrase(prompt string) ([]byte, error) {
+	fd := int(os.Stdin.Fd())
+	if !terminal.IsTerminal(fd) {
+		return nil, maskAny(errgo.WithCausef(nil, InvalidArgumentError, "cannot ask for a passphrase without a terminal"))
+	}
+	fmt.Fprint(os.Stderr, prompt)
+	passphrase, err := terminal.ReadPassword(fd)
+	fmt.Fprintln(os.Stderr)
+	if err != nil {
+		return nil, maskAny(err)
+	}
+	return passphrase, nil
+}
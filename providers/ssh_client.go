@@ -2,61 +2,200 @@ package providers
 
 import (
 	"bytes"
+	"context"
 	"io"
 	"net"
-	"os"
+	"net/http"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/juju/errgo"
 	logging "github.com/op/go-logging"
 
 	"golang.org/x/crypto/ssh"
-	"golang.org/x/crypto/ssh/agent"
 )
 
 type SSHClient interface {
 	io.Closer
-	Run(log *logging.Logger, command, stdin string, quiet bool) (string, error)
+	Run(ctx context.Context, log *logging.Logger, command, stdin string, quiet bool) (string, error)
 }
 
 type sshClient struct {
 	client *ssh.Client
+	jump   *ssh.Client // Connection to the jump host (if any)
 }
 
-// DialSSH creates a new SSH connection to the given user on the given host.
-func DialSSH(userName, host string) (SSHClient, error) {
+// SSHOptions specifies how to open an SSH connection.
+type SSHOptions struct {
+	UserName string   // Account name to login with
+	Host     string   // Address of the host to connect to
+	Port     int      // Port of the SSH server (0 defaults to 22)
+	JumpHost string   // Jump host ([user@]host[:port]) to connect through (empty for a direct connection)
+	KeyFiles []string // Private key files used for authentication (in addition to the ssh-agent)
+}
+
+// address returns the host:port address to dial.
+func (o SSHOptions) address() string {
+	port := o.Port
+	if port == 0 {
+		port = sshPort
+	}
+	return net.JoinHostPort(o.Host, strconv.Itoa(port))
+}
+
+// knownHostsHost returns the name used to verify the host key of the host.
+// Hosts behind a jump host are identified by both addresses, since their (private)
+// addresses are only unique behind that jump host.
+func (o SSHOptions) knownHostsHost() string {
+	if o.JumpHost == "" {
+		return o.address()
+	}
+	_, jumpAddr := parseJumpHost(o.JumpHost, o.UserName)
+	return o.address() + "@" + jumpAddr
+}
+
+// SSHDialer opens an SSH connection using the given options.
+type SSHDialer func(options SSHOptions) (SSHClient, error)
+
+var (
+	sshDialer SSHDialer = DialSSH
+)
+
+// SetSSHDialer replaces the function used to open SSH connections to instances.
+// It returns the previous dialer, so callers can restore it.
+// This is intended for tests that use a local SSH server instead of real instances.
+func SetSSHDialer(dialer SSHDialer) SSHDialer {
+	previous := sshDialer
+	sshDialer = dialer
+	// Existing connections were opened by the previous dialer
+	CloseSSHConnections()
+	return previous
+}
+
+// NewSSHClient wraps an existing SSH connection in an SSHClient.
+func NewSSHClient(client *ssh.Client) SSHClient {
+	return &sshClient{client: client}
+}
+
+// DialSSH creates a new SSH connection using the given options.
+// If a jump host is specified, the connection is tunneled through an SSH connection to that host.
+// Every connection & handshake must complete within sshDialTimeout.
+func DialSSH(options SSHOptions) (SSHClient, error) {
 	// To authenticate with the remote server you must pass at least one
 	// implementation of AuthMethod via the Auth field in ClientConfig.
-	config := &ssh.ClientConfig{
-		User: userName,
+	authMethod, err := sshAuthMethod(options.KeyFiles)
+	if err != nil {
+		return nil, maskAny(err)
+	}
+	auth := []ssh.AuthMethod{authMethod}
+
+	addr := options.address()
+	config := newSSHClientConfig(options.UserName, options.knownHostsHost(), auth)
+	if options.JumpHost == "" {
+		client, err := dialSSHTimeout(addr, config)
+		if err != nil {
+			return nil, maskAny(err)
+		}
+		return &sshClient{client: client}, nil
+	}
+
+	// Connect to the jump host first
+	jumpUser, jumpAddr := parseJumpHost(options.JumpHost, options.UserName)
+	jump, err := dialSSHTimeout(jumpAddr, newSSHClientConfig(jumpUser, jumpAddr, auth))
+	if err != nil {
+		return nil, maskAny(errgo.Notef(err, "cannot connect to jump host %s", jumpAddr))
+	}
+	// Open a connection to the host from the jump host and run SSH over it.
+	// Tunneled connections have no deadlines, so the jump connection is closed when the time is up.
+	timer := time.AfterFunc(sshDialTimeout, func() { jump.Close() })
+	defer timer.Stop()
+	conn, err := jump.Dial("tcp", addr)
+	if err != nil {
+		jump.Close()
+		return nil, maskAny(errgo.Notef(err, "cannot reach %s from jump host %s", addr, jumpAddr))
 	}
-	var sshAgent agent.Agent
-	if agentConn, err := net.Dial("unix", os.Getenv("SSH_AUTH_SOCK")); err == nil {
-		sshAgent = agent.NewClient(agentConn)
-		config.Auth = append(config.Auth, ssh.PublicKeysCallback(sshAgent.Signers))
-	} else {
+	c, chans, reqs, err := ssh.NewClientConn(conn, addr, config)
+	if err != nil {
+		conn.Close()
+		jump.Close()
 		return nil, maskAny(err)
 	}
+	if !timer.Stop() {
+		// The jump connection was closed while finishing the handshake
+		c.Close()
+		return nil, maskAny(errgo.Newf("timeout connecting to %s from jump host %s", addr, jumpAddr))
+	}
+	return &sshClient{client: ssh.NewClient(c, chans, reqs), jump: jump}, nil
+}
 
-	addr := net.JoinHostPort(host, "22")
-	client, err := ssh.Dial("tcp", addr, config)
+// dialSSHTimeout opens an SSH connection to the given address.
+// Both the TCP connection & the SSH handshake must complete within sshDialTimeout.
+func dialSSHTimeout(addr string, config *ssh.ClientConfig) (*ssh.Client, error) {
+	conn, err := net.DialTimeout("tcp", addr, sshDialTimeout)
 	if err != nil {
 		return nil, maskAny(err)
 	}
+	conn.SetDeadline(time.Now().Add(sshDialTimeout))
+	c, chans, reqs, err := ssh.NewClientConn(conn, addr, config)
+	if err != nil {
+		conn.Close()
+		return nil, maskAny(err)
+	}
+	conn.SetDeadline(time.Time{})
+	return ssh.NewClient(c, chans, reqs), nil
+}
 
-	return &sshClient{client}, nil
+// newSSHClientConfig creates the configuration used to connect to the given user on the given host
+// (see SSHOptions.knownHostsHost).
+func newSSHClientConfig(userName, host string, auth []ssh.AuthMethod) *ssh.ClientConfig {
+	config := &ssh.ClientConfig{
+		User: userName,
+		Auth: auth,
+	}
+	if knownHosts != nil {
+		config.HostKeyCallback = knownHosts.Callback(host)
+		config.HostKeyAlgorithms = knownHosts.hostKeyAlgorithms(host)
+	}
+	return config
 }
 
-func (s *sshClient) Close() error {
-	return maskAny(s.client.Close())
+// KeepAlive sends a keep-alive request to check that the connection is still alive.
+func (s *sshClient) KeepAlive() error {
+	if _, _, err := s.client.SendRequest("keepalive@openssh.com", true, nil); err != nil {
+		return maskAny(err)
+	}
+	return nil
 }
 
-func (s *sshClient) Run(log *logging.Logger, command, stdin string, quiet bool) (string, error) {
-	var stdOut, stdErr bytes.Buffer
+// RemoteTransport returns an HTTP transport that opens all connections from the remote host.
+func (s *sshClient) RemoteTransport() (http.RoundTripper, error) {
+	return &http.Transport{Dial: s.client.Dial}, nil
+}
 
+func (s *sshClient) Close() error {
+	err := s.client.Close()
+	if s.jump != nil {
+		s.jump.Close()
+	}
+	return maskAny(err)
+}
+
+// newSession opens a new session on the connection.
+func (s *sshClient) newSession() (*ssh.Session, error) {
 	// Each ClientConn can support multiple interactive sessions,
 	// represented by a Session.
 	session, err := s.client.NewSession()
+	if err != nil {
+		return nil, maskAny(errgo.WithCausef(err, SSHConnectionError, "cannot open SSH session"))
+	}
+	return session, nil
+}
+
+func (s *sshClient) Run(ctx context.Context, log *logging.Logger, command, stdin string, quiet bool) (string, error) {
+	var stdOut, stdErr bytes.Buffer
+
+	session, err := s.newSession()
 	if err != nil {
 		return "", maskAny(err)
 	}
@@ -69,11 +208,27 @@ func (s *sshClient) Run(log *logging.Logger, command, stdin string, quiet bool)
 		session.Stdin = strings.NewReader(stdin)
 	}
 
-	if err := session.Run(command); err != nil {
+	// Run the command in the background, so it can be stopped when the context is cancelled
+	done := make(chan error, 1)
+	go func() {
+		done <- session.Run(command)
+	}()
+	select {
+	case err := <-done:
+		if err != nil {
+			if !quiet {
+				log.Errorf("SSH failed: %s", RedactCommand(command))
+			}
+			return "", errgo.NoteMask(err, stdErr.String())
+		}
+	case <-ctx.Done():
 		if !quiet {
-			log.Errorf("SSH failed: %s", command)
+			log.Errorf("SSH cancelled: %s", RedactCommand(command))
 		}
-		return "", errgo.NoteMask(err, stdErr.String())
+		session.Signal(ssh.SIGTERM)
+		session.Close()
+		<-done
+		return "", maskAny(ctx.Err())
 	}
 
 	out := stdOut.String()
@@ -0,0 +1,168 @@
+// Copyright (c) 2016 Pulcy.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package providers
+
+import (
+	"bufio"
+	"io"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/mitchellh/go-homedir"
+)
+
+// SSHConfig contains the host specific settings of an OpenSSH client configuration file (~/.ssh/config).
+// Only the User, Port and IdentityFile keywords are used.
+type SSHConfig struct {
+	hosts []sshConfigHost
+}
+
+type sshConfigHost struct {
+	patterns      []string
+	user          string
+	port          int
+	identityFiles []string
+}
+
+var (
+	sshConfig = &SSHConfig{}
+)
+
+// SetSSHConfig sets the OpenSSH client configuration used for all SSH connections to instances.
+func SetSSHConfig(c *SSHConfig) {
+	if c == nil {
+		c = &SSHConfig{}
+	}
+	sshConfig = c
+}
+
+// LoadSSHConfig reads an OpenSSH client configuration file.
+// A missing file results in an empty configuration.
+func LoadSSHConfig(path string) (*SSHConfig, error) {
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return &SSHConfig{}, nil
+	} else if err != nil {
+		return nil, maskAny(err)
+	}
+	defer f.Close()
+	c, err := ParseSSHConfig(f)
+	if err != nil {
+		return nil, maskAny(err)
+	}
+	return c, nil
+}
+
+// ParseSSHConfig parses the content of an OpenSSH client configuration file.
+func ParseSSHConfig(r io.Reader) (*SSHConfig, error) {
+	c := &SSHConfig{}
+	// Settings before the first Host line apply to all hosts
+	current := &sshConfigHost{patterns: []string{"*"}}
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		key, value := parseSSHConfigLine(scanner.Text())
+		switch key {
+		case "":
+			// Empty line or comment
+		case "host":
+			c.hosts = append(c.hosts, *current)
+			current = &sshConfigHost{patterns: strings.Fields(value)}
+		case "match":
+			// Match conditions are not supported, ignore the settings of this block
+			c.hosts = append(c.hosts, *current)
+			current = &sshConfigHost{}
+		case "user":
+			current.user = value
+		case "port":
+			port, err := strconv.Atoi(value)
+			if err != nil {
+				return nil, maskAny(err)
+			}
+			current.port = port
+		case "identityfile":
+			if expanded, err := homedir.Expand(value); err == nil {
+				value = expanded
+			}
+			current.identityFiles = append(current.identityFiles, value)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, maskAny(err)
+	}
+	c.hosts = append(c.hosts, *current)
+	return c, nil
+}
+
+// parseSSHConfigLine splits a line into a lowercase keyword and its value.
+// Both `Keyword value` and `Keyword=value` are supported.
+func parseSSHConfigLine(line string) (string, string) {
+	line = strings.TrimSpace(line)
+	if line == "" || strings.HasPrefix(line, "#") {
+		return "", ""
+	}
+	idx := strings.IndexAny(line, " \t=")
+	if idx < 0 {
+		return strings.ToLower(line), ""
+	}
+	key := strings.ToLower(line[:idx])
+	value := strings.TrimSpace(line[idx:])
+	value = strings.TrimSpace(strings.TrimPrefix(value, "="))
+	value = strings.Trim(value, "\"")
+	return key, value
+}
+
+// Lookup returns the settings for a host known by any of the given names.
+// Like OpenSSH, the first value found for a setting is used, except for identity files
+// which are all collected.
+func (c *SSHConfig) Lookup(names ...string) SSHSettings {
+	result := SSHSettings{}
+	for _, h := range c.hosts {
+		if !h.matches(names) {
+			continue
+		}
+		if result.User == "" {
+			result.User = h.user
+		}
+		if result.Port == 0 {
+			result.Port = h.port
+		}
+		result.KeyFiles = append(result.KeyFiles, h.identityFiles...)
+	}
+	return result
+}
+
+// matches returns true if any of the given names matches the patterns of the host
+// and none of them matches a negated pattern.
+func (h sshConfigHost) matches(names []string) bool {
+	matched := false
+	for _, pattern := range h.patterns {
+		negate := strings.HasPrefix(pattern, "!")
+		pattern = strings.TrimPrefix(pattern, "!")
+		for _, name := range names {
+			if name == "" {
+				continue
+			}
+			if ok, _ := path.Match(pattern, name); ok {
+				if negate {
+					return false
+				}
+				matched = true
+			}
+		}
+	}
+	return matched
+}
@@ -0,0 +1,183 @@
+// Copyright (c) 2016 Pulcy.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package providers
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/juju/errgo"
+)
+
+const testSSHConfig = `
+# Global settings
+IdentityFile /keys/global
+
+Host *.c1.example.com !bastion.c1.example.com
+  User admin
+  Port 2222
+  IdentityFile /keys/c1
+
+Host 10.1.0.*
+	User=private
+	IdentityFile="/keys/private"
+
+Match host *.example.com
+  User matched
+
+Host *
+  User fallback
+  Port 22
+`
+
+func TestParseSSHConfig(t *testing.T) {
+	c, err := ParseSSHConfig(strings.NewReader(testSSHConfig))
+	if err != nil {
+		t.Fatalf("ParseSSHConfig failed: %v", err)
+	}
+	tests := []struct {
+		Names    []string
+		Expected SSHSettings
+	}{
+		{[]string{"i1.c1.example.com", "1.2.3.4"}, SSHSettings{User: "admin", Port: 2222, KeyFiles: []string{"/keys/global", "/keys/c1"}}},
+		{[]string{"i2.c1.example.com", "10.1.0.2"}, SSHSettings{User: "admin", Port: 2222, KeyFiles: []string{"/keys/global", "/keys/c1", "/keys/private"}}},
+		{[]string{"i3.c2.example.com", "10.1.0.3"}, SSHSettings{User: "private", Port: 22, KeyFiles: []string{"/keys/global", "/keys/private"}}},
+		{[]string{"bastion.c1.example.com"}, SSHSettings{User: "fallback", Port: 22, KeyFiles: []string{"/keys/global"}}},
+		{[]string{"", "other.example.org"}, SSHSettings{User: "fallback", Port: 22, KeyFiles: []string{"/keys/global"}}},
+	}
+	for _, test := range tests {
+		actual := c.Lookup(test.Names...)
+		if !reflect.DeepEqual(actual, test.Expected) {
+			t.Errorf("Lookup(%v): expected %+v, got %+v", test.Names, test.Expected, actual)
+		}
+	}
+
+	if _, err := ParseSSHConfig(strings.NewReader("Host x\n  Port twenty-two\n")); err == nil {
+		t.Errorf("Expected an invalid port to be an error")
+	}
+	if c, err := LoadSSHConfig(filepath.Join(os.TempDir(), "quark-no-such-ssh-config")); err != nil {
+		t.Errorf("Expected a missing config file to be ignored, got %v", err)
+	} else if s := c.Lookup("i1.c1.example.com"); !reflect.DeepEqual(s, SSHSettings{}) {
+		t.Errorf("Expected empty settings from a missing config file, got %+v", s)
+	}
+}
+
+func TestSSHOptions(t *testing.T) {
+	dir, err := ioutil.TempDir("", "quark-ssh")
+	if err != nil {
+		t.Fatalf("Cannot create temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	existing := filepath.Join(dir, "id_config")
+	if err := ioutil.WriteFile(existing, []byte("key"), 0600); err != nil {
+		t.Fatalf("Cannot write key file: %v", err)
+	}
+	c, err := ParseSSHConfig(strings.NewReader("Host *.example.com\n  User config\n  Port 2222\n  IdentityFile " + existing + "\n  IdentityFile " + filepath.Join(dir, "missing") + "\n"))
+	if err != nil {
+		t.Fatalf("ParseSSHConfig failed: %v", err)
+	}
+	SetSSHConfig(c)
+	defer SetSSHConfig(nil)
+	defer SetSSHSettings(SSHSettings{})
+
+	plain := ClusterInstance{Name: "i1.other.org", LoadBalancerIPv4: "1.2.3.4"}
+	configured := ClusterInstance{Name: "i2.example.com", LoadBalancerIPv4: "1.2.3.5"}
+	explicit := ClusterInstance{Name: "i3.example.com", LoadBalancerIPv4: "1.2.3.6", UserName: "instance", SSHPort: 2200, SSHKeyFile: "/keys/instance"}
+
+	tests := []struct {
+		Settings SSHSettings
+		Instance ClusterInstance
+		User     string
+		Port     int
+		KeyFiles []string
+	}{
+		// Defaults
+		{SSHSettings{}, plain, "core", 22, nil},
+		// SSH config file
+		{SSHSettings{}, configured, "config", 2222, []string{existing}},
+		// Settings take precedence over the config file, key files are combined
+		{SSHSettings{User: "settings", Port: 2022, KeyFiles: []string{"/keys/settings"}}, configured, "settings", 2022, []string{"/keys/settings", existing}},
+		// Instance takes precedence over everything
+		{SSHSettings{User: "settings", Port: 2022, KeyFiles: []string{"/keys/settings"}}, explicit, "instance", 2200, []string{"/keys/instance", "/keys/settings", existing}},
+	}
+	for _, test := range tests {
+		SetSSHSettings(test.Settings)
+		options := test.Instance.sshOptions()
+		if options.UserName != test.User || options.Port != test.Port || !reflect.DeepEqual(options.KeyFiles, test.KeyFiles) {
+			t.Errorf("%s with settings %+v: expected %s:%d with %v, got %s:%d with %v", test.Instance.Name, test.Settings, test.User, test.Port, test.KeyFiles, options.UserName, options.Port, options.KeyFiles)
+		}
+	}
+}
+
+func TestSSHAuthMethod(t *testing.T) {
+	if sock := os.Getenv("SSH_AUTH_SOCK"); sock != "" {
+		defer os.Setenv("SSH_AUTH_SOCK", sock)
+	}
+	os.Unsetenv("SSH_AUTH_SOCK")
+
+	dir, err := ioutil.TempDir("", "quark-ssh")
+	if err != nil {
+		t.Fatalf("Cannot create temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Cannot generate key: %v", err)
+	}
+	der, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatalf("Cannot marshal key: %v", err)
+	}
+	keyPath := filepath.Join(dir, "id_ecdsa")
+	if err := ioutil.WriteFile(keyPath, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: der}), 0600); err != nil {
+		t.Fatalf("Cannot write key file: %v", err)
+	}
+	notAKeyPath := filepath.Join(dir, "not-a-key")
+	if err := ioutil.WriteFile(notAKeyPath, []byte("hello"), 0600); err != nil {
+		t.Fatalf("Cannot write file: %v", err)
+	}
+
+	// Without agent & key files there is no way to authenticate
+	if _, err := sshAuthMethod(nil); errgo.Cause(err) != NoSSHAuthError {
+		t.Errorf("Expected NoSSHAuthError, got %v", err)
+	}
+	// Key files are loaded
+	if _, err := sshAuthMethod([]string{keyPath}); err != nil {
+		t.Errorf("Expected key file to be accepted, got %v", err)
+	}
+	signer, err := loadSSHKeyFile(keyPath)
+	if err != nil {
+		t.Fatalf("loadSSHKeyFile failed: %v", err)
+	}
+	if again, err := loadSSHKeyFile(keyPath); err != nil || again != signer {
+		t.Errorf("Expected loaded key to be cached")
+	}
+	// Invalid & missing key files are errors
+	if _, err := sshAuthMethod([]string{notAKeyPath}); errgo.Cause(err) != InvalidArgumentError {
+		t.Errorf("Expected InvalidArgumentError, got %v", err)
+	}
+	if _, err := sshAuthMethod([]string{filepath.Join(dir, "missing")}); err == nil {
+		t.Errorf("Expected a missing key file to be an error")
+	}
+}
@@ -0,0 +1,115 @@
+// Copyright (c) 2016 Pulcy.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package providers
+
+import (
+	"net"
+	"strconv"
+	"strings"
+)
+
+var (
+	// sshJumpHost is the jump host used to reach all instances (empty for direct connections)
+	sshJumpHost string
+	// sshTunnel is the jump host used to reach instances without a public address (empty if unknown)
+	sshTunnel string
+)
+
+// SetSSHJumpHost configures a jump host ([user@]host[:port]) through which all SSH connections
+// to instances are made.
+func SetSSHJumpHost(jumpHost string) {
+	sshJumpHost = jumpHost
+}
+
+// SetSSHTunnel configures a jump host ([user@]host[:port]) through which SSH connections to
+// instances without a public address are made (typically the tunnel of a cluster).
+func SetSSHTunnel(tunnel string) {
+	sshTunnel = tunnel
+}
+
+// HasPublicAddress returns true if the instance has a public IPv4 or IPv6 address.
+func (i ClusterInstance) HasPublicAddress() bool {
+	return i.LoadBalancerIPv4 != "" || i.LoadBalancerIPv6 != ""
+}
+
+// sshRoute returns the address used to SSH into the instance and the jump host to connect through.
+// An empty jump host means that the instance is connected to directly.
+func (i ClusterInstance) sshRoute() (string, string) {
+	if i.HasPublicAddress() {
+		return i.String(), sshJumpHost
+	}
+	address := i.PrivateIP
+	if address == "" {
+		address = i.ClusterIP
+	}
+	jumpHost := sshJumpHost
+	if jumpHost == "" {
+		jumpHost = sshTunnel
+	}
+	if jumpHost == "" {
+		jumpHost = i.JumpHost
+	}
+	if jumpHost == "" || address == "" {
+		return i.String(), ""
+	}
+	return address, jumpHost
+}
+
+// Gateway returns the first gateway instance in the list that has a public address.
+func (cil ClusterInstanceList) Gateway() (ClusterInstance, bool) {
+	for _, i := range cil {
+		if i.IsGateway && i.HasPublicAddress() {
+			return i, true
+		}
+	}
+	return ClusterInstance{}, false
+}
+
+// UseGateway returns the given instance, configured to be reached through a gateway instance
+// of the list, when it has no public address of its own.
+func (cil ClusterInstanceList) UseGateway(i ClusterInstance) ClusterInstance {
+	if i.HasPublicAddress() || i.JumpHost != "" {
+		return i
+	}
+	if gw, ok := cil.Gateway(); ok {
+		i.JumpHost = gw.User() + "@" + net.JoinHostPort(gw.String(), strconv.Itoa(gw.Port()))
+	}
+	return i
+}
+
+// UseGateways returns a copy of the list in which all instances without a public address
+// are reached through a gateway instance of the list.
+func (cil ClusterInstanceList) UseGateways() ClusterInstanceList {
+	result := make(ClusterInstanceList, 0, len(cil))
+	for _, i := range cil {
+		result = append(result, cil.UseGateway(i))
+	}
+	return result
+}
+
+// parseJumpHost splits a jump host specification ([user@]host[:port]) into a user name and
+// an address to dial. The given user name is used when the specification does not contain one.
+func parseJumpHost(jumpHost, defaultUser string) (string, string) {
+	userName := defaultUser
+	if idx := strings.LastIndex(jumpHost, "@"); idx >= 0 {
+		userName = jumpHost[:idx]
+		jumpHost = jumpHost[idx+1:]
+	}
+	if host, port, err := net.SplitHostPort(jumpHost); err == nil {
+		return userName, net.JoinHostPort(host, port)
+	}
+	host := strings.TrimSuffix(strings.TrimPrefix(jumpHost, "["), "]")
+	return userName, net.JoinHostPort(host, strconv.Itoa(sshPort))
+}
@@ -0,0 +1,47 @@
+// Copyright (c) 2016 Pulcy.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package providers
+
+import (
+	"testing"
+)
+
+func TestSSHRoute(t *testing.T) {
+	defer SetSSHJumpHost("")
+	defer SetSSHTunnel("")
+	public := ClusterInstance{Name: "i1", PrivateIP: "10.1.0.1", LoadBalancerIPv4: "1.2.3.4"}
+	private := ClusterInstance{Name: "i2", PrivateIP: "10.1.0.2", JumpHost: "core@gw.example.com"}
+
+	tests := []struct {
+		JumpHost, Tunnel string
+		Instance         ClusterInstance
+		Address, Via     string
+	}{
+		{"", "", public, "1.2.3.4", ""},
+		{"", "", private, "10.1.0.2", "core@gw.example.com"},
+		{"", "tunnel.example.com", public, "1.2.3.4", ""},
+		{"", "tunnel.example.com", private, "10.1.0.2", "tunnel.example.com"},
+		{"bastion.example.com", "tunnel.example.com", public, "1.2.3.4", "bastion.example.com"},
+		{"bastion.example.com", "tunnel.example.com", private, "10.1.0.2", "bastion.example.com"},
+	}
+	for _, test := range tests {
+		SetSSHJumpHost(test.JumpHost)
+		SetSSHTunnel(test.Tunnel)
+		address, via := test.Instance.sshRoute()
+		if address != test.Address || via != test.Via {
+			t.Errorf("%s with jump host '%s' and tunnel '%s': expected %s via '%s', got %s via '%s'", test.Instance.Name, test.JumpHost, test.Tunnel, test.Address, test.Via, address, via)
+		}
+	}
+}
@@ -0,0 +1,350 @@
+// Copyright (c) 2016 Pulcy.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package providers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/juju/errgo"
+	logging "github.com/op/go-logging"
+)
+
+const (
+	// maxSSHDials is the maximum number of SSH handshakes in progress at the same time.
+	// It is kept below the default MaxStartups of sshd.
+	maxSSHDials = 8
+	// sshDialTimeout is the time in which a connection to an SSH server & its handshake must complete.
+	sshDialTimeout = time.Second * 30
+	// maxSSHSessions is the maximum number of sessions (commands) per SSH connection at the same time.
+	// It is kept below the default MaxSessions of sshd.
+	maxSSHSessions = 8
+	// sshKeepAliveInterval is the time between keep-alive requests on idle connections.
+	sshKeepAliveInterval = time.Second * 30
+	// sshKeepAliveTimeout is the time to wait for an answer to a keep-alive request.
+	sshKeepAliveTimeout = time.Second * 15
+	// sshIdleTimeout is the time after which an unused connection is closed.
+	sshIdleTimeout = time.Minute * 5
+)
+
+// keepAliver is implemented by SSH clients that can check whether their connection is still alive.
+type keepAliver interface {
+	KeepAlive() error
+}
+
+// sshPool shares SSH connections between all users of the same instance.
+type sshPool struct {
+	mutex sync.Mutex
+	conns map[string]*pooledConnection
+	dials chan struct{}
+}
+
+// pooledConnection is a shared SSH connection to a single instance.
+// It is reconnected when it turns out to be broken.
+type pooledConnection struct {
+	pool     *sshPool
+	key      string
+	options  SSHOptions
+	sessions chan struct{}
+	stop     chan struct{}
+
+	mutex    sync.Mutex
+	client   SSHClient // nil when not connected
+	dial     *sshDial  // non-nil while connecting
+	refs     int
+	lastUsed time.Time
+}
+
+// sshDial is a connection attempt of a pooled connection, shared by all users waiting for it.
+type sshDial struct {
+	done   chan struct{} // closed when the attempt has finished
+	client SSHClient
+	err    error
+}
+
+// pooledClient is the SSHClient handed out to a single user of a pooled connection.
+type pooledClient struct {
+	conn   *pooledConnection
+	closed bool
+}
+
+var (
+	sshConnections = newSSHPool()
+)
+
+// newSSHPool creates an empty pool.
+func newSSHPool() *sshPool {
+	return &sshPool{
+		conns: make(map[string]*pooledConnection),
+		dials: make(chan struct{}, maxSSHDials),
+	}
+}
+
+// CloseSSHConnections closes all shared SSH connections.
+func CloseSSHConnections() {
+	sshConnections.closeAll()
+}
+
+// sshPoolKey returns the key of the shared connection for the given options.
+func sshPoolKey(options SSHOptions) string {
+	return fmt.Sprintf("%s@%s via %s", options.UserName, options.address(), options.JumpHost)
+}
+
+// get returns a client that uses the shared connection for the given options.
+// The connection is opened when needed. Close the client when done.
+func (p *sshPool) get(options SSHOptions) (*pooledClient, error) {
+	key := sshPoolKey(options)
+	p.mutex.Lock()
+	conn, ok := p.conns[key]
+	if !ok {
+		conn = &pooledConnection{
+			pool:     p,
+			key:      key,
+			options:  options,
+			sessions: make(chan struct{}, maxSSHSessions),
+			stop:     make(chan struct{}),
+		}
+		p.conns[key] = conn
+		go conn.keepAlive()
+	}
+	p.mutex.Unlock()
+	conn.mutex.Lock()
+	conn.refs++
+	conn.mutex.Unlock()
+
+	if _, err := conn.connect(context.Background()); err != nil {
+		conn.release()
+		return nil, maskAny(err)
+	}
+	return &pooledClient{conn: conn}, nil
+}
+
+// forget closes the shared connection for the given options (if any),
+// so the next user opens a new connection.
+func (p *sshPool) forget(options SSHOptions) {
+	p.mutex.Lock()
+	conn, ok := p.conns[sshPoolKey(options)]
+	p.mutex.Unlock()
+	if ok {
+		conn.reset(nil)
+	}
+}
+
+// closeAll closes all shared connections.
+func (p *sshPool) closeAll() {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	for key, conn := range p.conns {
+		// Stop first, so a connection that is being opened is not kept
+		close(conn.stop)
+		conn.reset(nil)
+		delete(p.conns, key)
+	}
+}
+
+// connect returns the SSH client of the connection, opening it when needed.
+// The connection is opened in the background, so waiting for it can be cancelled
+// and other users of the connection are not blocked meanwhile.
+func (c *pooledConnection) connect(ctx context.Context) (SSHClient, error) {
+	c.mutex.Lock()
+	c.lastUsed = time.Now()
+	if client := c.client; client != nil {
+		c.mutex.Unlock()
+		return client, nil
+	}
+	d := c.dial
+	if d == nil {
+		d = &sshDial{done: make(chan struct{})}
+		c.dial = d
+		go c.dialInBackground(d)
+	}
+	c.mutex.Unlock()
+
+	select {
+	case <-d.done:
+		if d.err != nil {
+			return nil, maskAny(d.err)
+		}
+		return d.client, nil
+	case <-ctx.Done():
+		return nil, maskAny(ctx.Err())
+	}
+}
+
+// dialInBackground opens the connection, limiting the number of handshakes in progress.
+func (c *pooledConnection) dialInBackground(d *sshDial) {
+	defer close(d.done)
+	select {
+	case c.pool.dials <- struct{}{}:
+	case <-c.stop:
+		d.err = maskAny(errgo.New("SSH connections are closed"))
+		c.mutex.Lock()
+		c.dial = nil
+		c.mutex.Unlock()
+		return
+	}
+	d.client, d.err = sshDialer(c.options)
+	<-c.pool.dials
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.dial = nil
+	if d.err != nil {
+		return
+	}
+	select {
+	case <-c.stop:
+		// The pool was closed while connecting
+		d.client.Close()
+		d.client, d.err = nil, maskAny(errgo.New("SSH connections are closed"))
+	default:
+		c.client = d.client
+	}
+}
+
+// reset closes the given client if it is still the client of the connection.
+// If client is nil, the current client is closed.
+func (c *pooledConnection) reset(client SSHClient) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if c.client != nil && (client == nil || c.client == client) {
+		c.client.Close()
+		c.client = nil
+	}
+}
+
+// release is called when a user of the connection is done with it.
+func (c *pooledConnection) release() {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.refs--
+	c.lastUsed = time.Now()
+}
+
+// keepAlive periodically checks the connection until the pool is closed.
+func (c *pooledConnection) keepAlive() {
+	ticker := time.NewTicker(sshKeepAliveInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-c.stop:
+			return
+		case <-ticker.C:
+			c.check()
+		}
+	}
+}
+
+// check closes the connection when it is broken or has not been used for sshIdleTimeout.
+func (c *pooledConnection) check() {
+	c.mutex.Lock()
+	client := c.client
+	idle := c.refs == 0 && time.Since(c.lastUsed) > sshIdleTimeout
+	c.mutex.Unlock()
+
+	if client == nil {
+		return
+	}
+	if idle {
+		c.reset(client)
+		return
+	}
+	if ka, ok := client.(keepAliver); ok {
+		result := make(chan error, 1)
+		go func() { result <- ka.KeepAlive() }()
+		var err error
+		select {
+		case err = <-result:
+		case <-time.After(sshKeepAliveTimeout):
+			err = errgo.New("keep-alive timeout")
+		}
+		if err != nil {
+			c.reset(client)
+		}
+	}
+}
+
+func (c *pooledClient) Close() error {
+	if !c.closed {
+		c.closed = true
+		c.conn.release()
+	}
+	return nil
+}
+
+// Run executes a command on the shared connection.
+// If the connection turns out to be broken before the command is started,
+// it is reconnected and the command is tried once more.
+func (c *pooledClient) Run(ctx context.Context, log *logging.Logger, command, stdin string, quiet bool) (string, error) {
+	var out string
+	err := c.withSession(ctx, log, func(client SSHClient) error {
+		var err error
+		out, err = client.Run(ctx, log, command, stdin, quiet)
+		return err
+	})
+	return out, maskAny(err)
+}
+
+// withSession calls f with the client of the shared connection, limiting the number
+// of sessions on the connection.
+// If the connection turns out to be broken before a session could be opened,
+// it is reconnected and f is called once more.
+func (c *pooledClient) withSession(ctx context.Context, log *logging.Logger, f func(client SSHClient) error) error {
+	select {
+	case c.conn.sessions <- struct{}{}:
+		defer func() { <-c.conn.sessions }()
+	case <-ctx.Done():
+		return maskAny(ctx.Err())
+	}
+
+	client, err := c.conn.connect(ctx)
+	if err != nil {
+		return maskAny(err)
+	}
+	err = f(client)
+	if err != nil && errgo.Cause(err) == SSHConnectionError {
+		log.Debugf("SSH connection to %s is broken, reconnecting", c.conn.options.Host)
+		c.conn.reset(client)
+		client, err = c.conn.connect(ctx)
+		if err != nil {
+			return maskAny(err)
+		}
+		err = f(client)
+	}
+	return maskAny(err)
+}
+
+// RemoteTransport returns an HTTP transport that opens all connections from the remote host
+// over the shared connection.
+func (c *pooledClient) RemoteTransport() (http.RoundTripper, error) {
+	client, err := c.conn.connect(context.Background())
+	if err != nil {
+		return nil, maskAny(err)
+	}
+	transporter, ok := client.(remoteTransporter)
+	if !ok {
+		return nil, maskAny(fmt.Errorf("cannot forward connections to %s", c.conn.options.Host))
+	}
+	transport, err := transporter.RemoteTransport()
+	if err != nil {
+		return nil, maskAny(err)
+	}
+	return transport, nil
+}
@@ -0,0 +1,315 @@
+// Copyright (c) 2016 Pulcy.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package providers
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/juju/errgo"
+	"github.com/op/go-logging"
+)
+
+// testSSHClient is an SSHClient that records the commands it runs.
+type testSSHClient struct {
+	mutex        sync.Mutex
+	broken       bool  // If set, Run fails with SSHConnectionError
+	keepAliveErr error // Returned by KeepAlive
+	closed       bool
+	commands     []string
+}
+
+func (c *testSSHClient) Run(ctx context.Context, log *logging.Logger, command, stdin string, quiet bool) (string, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if c.broken || c.closed {
+		return "", maskAny(errgo.WithCausef(nil, SSHConnectionError, "broken"))
+	}
+	c.commands = append(c.commands, command)
+	return "ok", nil
+}
+
+func (c *testSSHClient) KeepAlive() error {
+	return c.keepAliveErr
+}
+
+func (c *testSSHClient) Close() error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.closed = true
+	return nil
+}
+
+func (c *testSSHClient) isClosed() bool {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.closed
+}
+
+// testSSHDialer creates a testSSHClient for every dial.
+type testSSHDialer struct {
+	mutex   sync.Mutex
+	clients []*testSSHClient
+	block   chan struct{} // If not nil, dials wait until it is closed
+	active  int
+	maxSeen int
+}
+
+func (d *testSSHDialer) dial(options SSHOptions) (SSHClient, error) {
+	d.mutex.Lock()
+	d.active++
+	if d.active > d.maxSeen {
+		d.maxSeen = d.active
+	}
+	block := d.block
+	d.mutex.Unlock()
+	if block != nil {
+		<-block
+	}
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	d.active--
+	client := &testSSHClient{}
+	d.clients = append(d.clients, client)
+	return client, nil
+}
+
+func (d *testSSHDialer) dials() int {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	return len(d.clients)
+}
+
+func (d *testSSHDialer) client(index int) *testSSHClient {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	return d.clients[index]
+}
+
+func newTestSSHPool(block chan struct{}) (*sshPool, *testSSHDialer, func()) {
+	d := &testSSHDialer{block: block}
+	previous := SetSSHDialer(d.dial)
+	p := newSSHPool()
+	return p, d, func() {
+		p.closeAll()
+		SetSSHDialer(previous)
+	}
+}
+
+var poolTestLog = logging.MustGetLogger("ssh-pool-test")
+
+func TestSSHPoolShare(t *testing.T) {
+	p, d, cleanup := newTestSSHPool(nil)
+	defer cleanup()
+	options := SSHOptions{UserName: "core", Host: "10.0.0.1"}
+
+	c1, err := p.get(options)
+	if err != nil {
+		t.Fatalf("get failed: %v", err)
+	}
+	c2, err := p.get(options)
+	if err != nil {
+		t.Fatalf("get failed: %v", err)
+	}
+	if _, err := p.get(SSHOptions{UserName: "root", Host: "10.0.0.1"}); err != nil {
+		t.Fatalf("get failed: %v", err)
+	}
+	if dials := d.dials(); dials != 2 {
+		t.Errorf("Expected 2 connections (one per user), got %d", dials)
+	}
+	for _, c := range []SSHClient{c1, c2} {
+		if _, err := c.Run(context.Background(), poolTestLog, "true", "", false); err != nil {
+			t.Errorf("Run failed: %v", err)
+		}
+		c.Close()
+	}
+	if commands := d.client(0).commands; len(commands) != 2 {
+		t.Errorf("Expected both commands on the shared connection, got %v", commands)
+	}
+}
+
+func TestSSHPoolReconnect(t *testing.T) {
+	p, d, cleanup := newTestSSHPool(nil)
+	defer cleanup()
+
+	c, err := p.get(SSHOptions{UserName: "core", Host: "10.0.0.1"})
+	if err != nil {
+		t.Fatalf("get failed: %v", err)
+	}
+	defer c.Close()
+	d.client(0).broken = true
+	if out, err := c.Run(context.Background(), poolTestLog, "true", "", false); err != nil || out != "ok" {
+		t.Fatalf("Expected command to be retried on a new connection, got '%s', %v", out, err)
+	}
+	if dials := d.dials(); dials != 2 {
+		t.Fatalf("Expected 2 connections, got %d", dials)
+	}
+	if !d.client(0).isClosed() {
+		t.Errorf("Expected the broken connection to be closed")
+	}
+	if commands := d.client(1).commands; len(commands) != 1 {
+		t.Errorf("Expected the command on the new connection, got %v", commands)
+	}
+}
+
+func TestSSHPoolIdle(t *testing.T) {
+	p, d, cleanup := newTestSSHPool(nil)
+	defer cleanup()
+	options := SSHOptions{UserName: "core", Host: "10.0.0.1"}
+
+	c, err := p.get(options)
+	if err != nil {
+		t.Fatalf("get failed: %v", err)
+	}
+	conn := p.conns[sshPoolKey(options)]
+	setLastUsed := func(t time.Time) {
+		conn.mutex.Lock()
+		conn.lastUsed = t
+		conn.mutex.Unlock()
+	}
+
+	// Connections in use are kept, even when nothing has been run for a long time
+	setLastUsed(time.Now().Add(-2 * sshIdleTimeout))
+	conn.check()
+	if d.client(0).isClosed() {
+		t.Fatalf("Expected a connection in use to be kept")
+	}
+
+	// Unused connections are kept until the idle timeout
+	c.Close()
+	conn.check()
+	if d.client(0).isClosed() {
+		t.Fatalf("Expected a recently used connection to be kept")
+	}
+	setLastUsed(time.Now().Add(-2 * sshIdleTimeout))
+	conn.check()
+	if !d.client(0).isClosed() {
+		t.Fatalf("Expected an idle connection to be closed")
+	}
+
+	// The next user opens a new connection
+	c, err = p.get(options)
+	if err != nil {
+		t.Fatalf("get failed: %v", err)
+	}
+	defer c.Close()
+	if dials := d.dials(); dials != 2 {
+		t.Errorf("Expected a new connection, got %d connections", dials)
+	}
+}
+
+func TestSSHPoolKeepAliveFailure(t *testing.T) {
+	p, d, cleanup := newTestSSHPool(nil)
+	defer cleanup()
+	options := SSHOptions{UserName: "core", Host: "10.0.0.1"}
+
+	c, err := p.get(options)
+	if err != nil {
+		t.Fatalf("get failed: %v", err)
+	}
+	defer c.Close()
+	conn := p.conns[sshPoolKey(options)]
+	conn.check()
+	if d.client(0).isClosed() {
+		t.Fatalf("Expected a live connection to be kept")
+	}
+	d.client(0).keepAliveErr = errgo.New("connection reset")
+	conn.check()
+	if !d.client(0).isClosed() {
+		t.Fatalf("Expected a broken connection to be closed")
+	}
+}
+
+func TestSSHPoolCancelDial(t *testing.T) {
+	block := make(chan struct{})
+	p, d, cleanup := newTestSSHPool(block)
+	defer cleanup()
+	options := SSHOptions{UserName: "core", Host: "10.0.0.1"}
+
+	result := make(chan error, 1)
+	go func() {
+		c, err := p.get(options)
+		if err == nil {
+			c.Close()
+		}
+		result <- err
+	}()
+	// Wait until the dial has started
+	for {
+		p.mutex.Lock()
+		conn, ok := p.conns[sshPoolKey(options)]
+		p.mutex.Unlock()
+		if ok {
+			conn.mutex.Lock()
+			dialing := conn.dial != nil
+			conn.mutex.Unlock()
+			if dialing {
+				break
+			}
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	// Waiting for the connection can be cancelled, other users of the connection are not blocked
+	conn := p.conns[sshPoolKey(options)]
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*10)
+	defer cancel()
+	if _, err := conn.connect(ctx); errgo.Cause(err) != context.DeadlineExceeded {
+		t.Errorf("Expected DeadlineExceeded, got %v", err)
+	}
+	conn.check()
+
+	close(block)
+	if err := <-result; err != nil {
+		t.Fatalf("get failed: %v", err)
+	}
+	if dials := d.dials(); dials != 1 {
+		t.Errorf("Expected a single connection, got %d", dials)
+	}
+}
+
+func TestSSHPoolDialLimit(t *testing.T) {
+	block := make(chan struct{})
+	p, d, cleanup := newTestSSHPool(block)
+	defer cleanup()
+
+	count := maxSSHDials * 3
+	wg := sync.WaitGroup{}
+	for i := 0; i < count; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			c, err := p.get(SSHOptions{UserName: "core", Host: fmt.Sprintf("10.0.0.%d", i)})
+			if err != nil {
+				t.Errorf("get failed: %v", err)
+				return
+			}
+			c.Close()
+		}(i)
+	}
+	time.Sleep(time.Millisecond * 50)
+	close(block)
+	wg.Wait()
+
+	if dials := d.dials(); dials != count {
+		t.Errorf("Expected %d connections, got %d", count, dials)
+	}
+	if d.maxSeen > maxSSHDials {
+		t.Errorf("Expected at most %d dials at the same time, got %d", maxSSHDials, d.maxSeen)
+	}
+}
@@ -0,0 +1,193 @@
+// Copyright (c) 2016 Pulcy.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package providers
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+
+	logging "github.com/op/go-logging"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/terminal"
+)
+
+const (
+	defaultTerminalWidth  = 80
+	defaultTerminalHeight = 24
+	defaultTerminalType   = "xterm"
+)
+
+// sshStreamer is implemented by SSH clients that can attach a command to streams,
+// instead of collecting its output.
+type sshStreamer interface {
+	// Shell runs the given command (or the login shell when empty) attached to the standard input & output
+	// of this process. A pseudo terminal is requested when the standard input is a terminal.
+	Shell(ctx context.Context, command string) error
+	// Stream runs the given command, writing its output to the given writers.
+	Stream(ctx context.Context, command string, stdout, stderr io.Writer) error
+}
+
+// Shell opens an interactive session on the instance using the standard input & output of this process.
+// When a command is given, it is executed instead of the login shell.
+// It returns the exit status of the remote command or shell.
+func (i ClusterInstance) Shell(ctx context.Context, log *logging.Logger, command string) (int, error) {
+	client, err := i.pooledClient()
+	if err != nil {
+		return -1, maskAny(err)
+	}
+	defer client.Close()
+	return exitStatus(client.Shell(ctx, log, command))
+}
+
+// Stream runs a command on the instance, writing its output to the given writers.
+// It returns the exit status of the command.
+func (i ClusterInstance) Stream(ctx context.Context, log *logging.Logger, command string, stdout, stderr io.Writer) (int, error) {
+	client, err := i.pooledClient()
+	if err != nil {
+		return -1, maskAny(err)
+	}
+	defer client.Close()
+	return exitStatus(client.Stream(ctx, log, command, stdout, stderr))
+}
+
+// Shell runs an interactive session in a session of the shared connection.
+func (c *pooledClient) Shell(ctx context.Context, log *logging.Logger, command string) error {
+	return maskAny(c.withSession(ctx, log, func(client SSHClient) error {
+		streamer, ok := client.(sshStreamer)
+		if !ok {
+			return maskAny(fmt.Errorf("interactive sessions are not supported for %s", c.conn.options.Host))
+		}
+		return maskAny(streamer.Shell(ctx, command))
+	}))
+}
+
+// Stream runs a command in a session of the shared connection, writing its output to the given writers.
+func (c *pooledClient) Stream(ctx context.Context, log *logging.Logger, command string, stdout, stderr io.Writer) error {
+	return maskAny(c.withSession(ctx, log, func(client SSHClient) error {
+		streamer, ok := client.(sshStreamer)
+		if !ok {
+			// Fall back to collecting the output (e.g. in a dry run)
+			out, err := client.Run(ctx, log, command, "", true)
+			if out != "" {
+				fmt.Fprintln(stdout, out)
+			}
+			return maskAny(err)
+		}
+		return maskAny(streamer.Stream(ctx, command, stdout, stderr))
+	}))
+}
+
+// exitStatus converts the result of a remote command into its exit status.
+// Errors other than a non-zero exit status are returned as is.
+func exitStatus(err error) (int, error) {
+	if err == nil {
+		return 0, nil
+	}
+	for e := err; e != nil; {
+		if exitErr, ok := e.(*ssh.ExitError); ok {
+			return exitErr.ExitStatus(), nil
+		}
+		wrapper, ok := e.(interface {
+			Underlying() error
+		})
+		if !ok {
+			break
+		}
+		e = wrapper.Underlying()
+	}
+	return -1, maskAny(err)
+}
+
+func (s *sshClient) Shell(ctx context.Context, command string) error {
+	session, err := s.newSession()
+	if err != nil {
+		return maskAny(err)
+	}
+	defer session.Close()
+
+	session.Stdin = os.Stdin
+	session.Stdout = os.Stdout
+	session.Stderr = os.Stderr
+
+	fd := int(os.Stdin.Fd())
+	if terminal.IsTerminal(fd) {
+		width, height, err := terminal.GetSize(fd)
+		if err != nil {
+			width, height = defaultTerminalWidth, defaultTerminalHeight
+		}
+		term := os.Getenv("TERM")
+		if term == "" {
+			term = defaultTerminalType
+		}
+		modes := ssh.TerminalModes{
+			ssh.ECHO:          1,
+			ssh.TTY_OP_ISPEED: 14400,
+			ssh.TTY_OP_OSPEED: 14400,
+		}
+		if err := session.RequestPty(term, height, width, modes); err != nil {
+			return maskAny(err)
+		}
+		// Pass all keys (including Ctrl-C) to the remote terminal
+		state, err := terminal.MakeRaw(fd)
+		if err != nil {
+			return maskAny(err)
+		}
+		defer terminal.Restore(fd, state)
+	}
+
+	return maskAny(runSession(ctx, session, func() error {
+		if command != "" {
+			return session.Run(command)
+		}
+		if err := session.Shell(); err != nil {
+			return err
+		}
+		return session.Wait()
+	}))
+}
+
+func (s *sshClient) Stream(ctx context.Context, command string, stdout, stderr io.Writer) error {
+	session, err := s.newSession()
+	if err != nil {
+		return maskAny(err)
+	}
+	defer session.Close()
+
+	session.Stdout = stdout
+	session.Stderr = stderr
+	return maskAny(runSession(ctx, session, func() error {
+		return session.Run(command)
+	}))
+}
+
+// runSession calls run (which runs the session) in the background,
+// so the session can be stopped when the context is cancelled.
+func runSession(ctx context.Context, session *ssh.Session, run func() error) error {
+	done := make(chan error, 1)
+	go func() {
+		done <- run()
+	}()
+	select {
+	case err := <-done:
+		return maskAny(err)
+	case <-ctx.Done():
+		session.Signal(ssh.SIGTERM)
+		session.Close()
+		<-done
+		return maskAny(ctx.Err())
+	}
+}
@@ -0,0 +1,99 @@
+// Copyright (c) 2016 Pulcy.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sshtest
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"fmt"
+	"net"
+	"sync"
+
+	"golang.org/x/crypto/ssh"
+
+	"github.com/pulcy/quark/providers"
+)
+
+var (
+	clientSignerOnce sync.Once
+	clientSigner     ssh.Signer
+	clientSignerErr  error
+)
+
+// getClientSigner returns a key used by all clients connecting to test servers.
+func getClientSigner() (ssh.Signer, error) {
+	clientSignerOnce.Do(func() {
+		key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		if err != nil {
+			clientSignerErr = maskAny(err)
+			return
+		}
+		clientSigner, clientSignerErr = ssh.NewSignerFromKey(key)
+	})
+	return clientSigner, clientSignerErr
+}
+
+// Dial opens an SSH connection to the server for the given user.
+// The host key of the server is verified.
+func (s *Server) Dial(userName string) (*ssh.Client, error) {
+	signer, err := getClientSigner()
+	if err != nil {
+		return nil, maskAny(err)
+	}
+	hostKey := s.HostKey().Marshal()
+	config := &ssh.ClientConfig{
+		User: userName,
+		Auth: []ssh.AuthMethod{ssh.PublicKeys(signer)},
+		HostKeyCallback: func(hostname string, remote net.Addr, key ssh.PublicKey) error {
+			if !bytes.Equal(key.Marshal(), hostKey) {
+				return maskAny(fmt.Errorf("unexpected host key for %s", hostname))
+			}
+			return nil
+		},
+	}
+	client, err := ssh.Dial("tcp", s.Addr(), config)
+	if err != nil {
+		return nil, maskAny(err)
+	}
+	return client, nil
+}
+
+// Dialer returns an SSH dialer that connects to the server registered for the requested host.
+// Ports, jump hosts & key files are ignored, the server is always connected to directly.
+func Dialer(servers map[string]*Server) providers.SSHDialer {
+	return func(options providers.SSHOptions) (providers.SSHClient, error) {
+		s, ok := servers[options.Host]
+		if !ok {
+			return nil, maskAny(fmt.Errorf("no test server for host '%s'", options.Host))
+		}
+		client, err := s.Dial(options.UserName)
+		if err != nil {
+			return nil, maskAny(err)
+		}
+		return providers.NewSSHClient(client), nil
+	}
+}
+
+// Install routes all SSH connections made by the providers package to the given servers,
+// keyed by host address (see providers.ClusterInstance.String).
+// Call the returned function to restore the original dialer.
+func Install(servers map[string]*Server) func() {
+	previous := providers.SetSSHDialer(Dialer(servers))
+	return func() {
+		providers.SetSSHDialer(previous)
+	}
+}
@@ -0,0 +1,256 @@
+// Copyright (c) 2016 Pulcy.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sshtest
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"golang.org/x/crypto/ssh"
+)
+
+const (
+	etcdClientPort     = 2379
+	defaultEtcdVersion = "2.3.7"
+)
+
+// etcdAPIMember is a member in the format of the etcd v2 members API.
+type etcdAPIMember struct {
+	ID         string   `json:"id"`
+	Name       string   `json:"name"`
+	PeerURLs   []string `json:"peerURLs"`
+	ClientURLs []string `json:"clientURLs"`
+}
+
+// etcdV3Member is a member in the format of the etcd v3 JSON gateway (with a decimal ID).
+type etcdV3Member struct {
+	ID         string   `json:"ID"`
+	Name       string   `json:"name,omitempty"`
+	PeerURLs   []string `json:"peerURLs"`
+	ClientURLs []string `json:"clientURLs,omitempty"`
+}
+
+func (m EtcdMember) v2() etcdAPIMember {
+	result := etcdAPIMember{ID: m.ID, PeerURLs: []string{m.PeerURL}, ClientURLs: []string{}}
+	if !m.Unstarted {
+		result.Name = m.Name
+		result.ClientURLs = []string{m.ClientURL}
+	}
+	return result
+}
+
+func (m EtcdMember) v3() etcdV3Member {
+	v2 := m.v2()
+	id, _ := strconv.ParseUint(m.ID, 16, 64)
+	return etcdV3Member{ID: strconv.FormatUint(id, 10), Name: v2.Name, PeerURLs: v2.PeerURLs, ClientURLs: v2.ClientURLs}
+}
+
+// SetVersion sets the etcd version reported by the emulated etcd API (defaults to 2.3.7).
+// Clients use the v3 API for versions 3.x and up.
+func (e *Etcd) SetVersion(version string) {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	e.version = version
+}
+
+func (e *Etcd) getVersion() string {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	if e.version == "" {
+		return defaultEtcdVersion
+	}
+	return e.version
+}
+
+// serveForward handles a direct-tcpip (port forwarding) channel.
+// Only connections to the etcd client port are supported, they are answered by the emulated etcd API.
+func (s *Server) serveForward(user string, newChannel ssh.NewChannel) {
+	var payload struct {
+		Host     string
+		Port     uint32
+		OrigHost string
+		OrigPort uint32
+	}
+	if err := ssh.Unmarshal(newChannel.ExtraData(), &payload); err != nil {
+		newChannel.Reject(ssh.ConnectionFailed, "invalid payload")
+		return
+	}
+	if payload.Port != etcdClientPort {
+		newChannel.Reject(ssh.ConnectionFailed, fmt.Sprintf("connection refused on port %d", payload.Port))
+		return
+	}
+	channel, requests, err := newChannel.Accept()
+	if err != nil {
+		return
+	}
+	go ssh.DiscardRequests(requests)
+	defer channel.Close()
+
+	target := net.JoinHostPort(payload.Host, strconv.Itoa(int(payload.Port)))
+	reader := bufio.NewReader(channel)
+	for {
+		req, err := http.ReadRequest(reader)
+		if err != nil {
+			return
+		}
+		body, _ := ioutil.ReadAll(req.Body)
+		req.Body.Close()
+		rec := httptest.NewRecorder()
+		s.serveEtcdAPI(rec, req.Method, payload.Host, req.URL, body)
+		s.addRecord(Record{
+			User:    user,
+			Command: fmt.Sprintf("%s http://%s%s", req.Method, target, req.URL.Path),
+			Stdin:   string(body),
+			Stdout:  rec.Body.String(),
+		})
+		resp := rec.Result()
+		resp.ContentLength = int64(rec.Body.Len())
+		if err := resp.Write(channel); err != nil {
+			return
+		}
+	}
+}
+
+// serveEtcdAPI answers a single request to the etcd client API on the given host.
+func (s *Server) serveEtcdAPI(w http.ResponseWriter, method, host string, u *url.URL, body []byte) {
+	if s.serveEtcdKeys(w, method, u, body) {
+		return
+	}
+	path := u.Path
+	etcd := s.Etcd
+	reply := func(statusCode int, result interface{}) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(statusCode)
+		if result != nil {
+			json.NewEncoder(w).Encode(result)
+		}
+	}
+	fail := func(statusCode int, message string) {
+		reply(statusCode, map[string]string{"message": message, "error": message})
+	}
+	var request struct {
+		PeerURLs []string `json:"peerURLs"`
+		ID       string   `json:"ID"`
+	}
+	if len(body) > 0 {
+		if err := json.Unmarshal(body, &request); err != nil {
+			fail(http.StatusBadRequest, err.Error())
+			return
+		}
+	}
+
+	// Strip the prefix of the v3 JSON gateway
+	v3 := false
+	for _, prefix := range []string{"/v3alpha/", "/v3beta/", "/v3/"} {
+		if strings.HasPrefix(path, prefix) {
+			path, v3 = "/"+strings.TrimPrefix(path, prefix), true
+			break
+		}
+	}
+
+	switch {
+	case method == "GET" && path == "/version":
+		version := etcd.getVersion()
+		parts := strings.SplitN(version, ".", 3)
+		reply(http.StatusOK, map[string]string{"etcdserver": version, "etcdcluster": parts[0] + "." + parts[1] + ".0"})
+	case method == "GET" && path == "/health":
+		healthy := false
+		for _, m := range etcd.Members() {
+			if !m.Unstarted && (host == "127.0.0.1" || strings.Contains(m.ClientURL, "//"+host+":")) {
+				healthy = true
+			}
+		}
+		reply(http.StatusOK, map[string]string{"health": strconv.FormatBool(healthy)})
+	case !v3 && method == "GET" && path == "/v2/members":
+		members := []etcdAPIMember{}
+		for _, m := range etcd.Members() {
+			members = append(members, m.v2())
+		}
+		reply(http.StatusOK, map[string]interface{}{"members": members})
+	case !v3 && method == "GET" && path == "/v2/members/leader":
+		for _, m := range etcd.Members() {
+			if m.IsLeader {
+				reply(http.StatusOK, m.v2())
+				return
+			}
+		}
+		fail(http.StatusServiceUnavailable, "During election")
+	case !v3 && method == "POST" && path == "/v2/members":
+		m, ok := s.addEtcdMember(request.PeerURLs)
+		if !ok {
+			fail(http.StatusConflict, "membership: peerURL exists")
+			return
+		}
+		reply(http.StatusCreated, m.v2())
+	case !v3 && method == "DELETE" && strings.HasPrefix(path, "/v2/members/"):
+		if !etcd.remove(strings.TrimPrefix(path, "/v2/members/")) {
+			fail(http.StatusNotFound, "No such member")
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	case v3 && method == "POST" && path == "/cluster/member/list":
+		members := []etcdV3Member{}
+		for _, m := range etcd.Members() {
+			members = append(members, m.v3())
+		}
+		reply(http.StatusOK, map[string]interface{}{"header": map[string]string{}, "members": members})
+	case v3 && method == "POST" && path == "/cluster/member/add":
+		m, ok := s.addEtcdMember(request.PeerURLs)
+		if !ok {
+			fail(http.StatusInternalServerError, "etcdserver: peerURL exists")
+			return
+		}
+		reply(http.StatusOK, map[string]interface{}{"header": map[string]string{}, "member": m.v3()})
+	case v3 && method == "POST" && path == "/cluster/member/remove":
+		id, _ := strconv.ParseUint(request.ID, 10, 64)
+		if !etcd.remove(strconv.FormatUint(id, 16)) {
+			fail(http.StatusInternalServerError, "etcdserver: member not found")
+			return
+		}
+		reply(http.StatusOK, map[string]interface{}{"header": map[string]string{}})
+	case v3 && method == "POST" && path == "/maintenance/status":
+		status := map[string]interface{}{"header": map[string]string{}, "version": etcd.getVersion()}
+		for _, m := range etcd.Members() {
+			if m.IsLeader {
+				status["leader"] = m.v3().ID
+			}
+		}
+		reply(http.StatusOK, status)
+	default:
+		fail(http.StatusNotFound, fmt.Sprintf("%s %s is not supported", method, path))
+	}
+}
+
+// addEtcdMember adds an unstarted member with the given peer URL.
+// It returns false if there already is a member with that peer URL.
+func (s *Server) addEtcdMember(peerURLs []string) (EtcdMember, bool) {
+	if len(peerURLs) != 1 {
+		return EtcdMember{}, false
+	}
+	for _, m := range s.Etcd.Members() {
+		if m.PeerURL == peerURLs[0] {
+			return EtcdMember{}, false
+		}
+	}
+	return s.Etcd.add("", peerURLs[0]), true
+}
@@ -0,0 +1,234 @@
+// Copyright (c) 2016 Pulcy.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sshtest
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// etcdKey is a single key of the emulated etcd key space.
+type etcdKey struct {
+	Value   string
+	Expires time.Time // Zero for keys without TTL
+}
+
+func (k etcdKey) isExpired() bool {
+	return !k.Expires.IsZero() && time.Now().After(k.Expires)
+}
+
+// Key returns the value of the given key.
+func (e *Etcd) Key(key string) (string, bool) {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	k, ok := e.getKey(key)
+	return k.Value, ok
+}
+
+// SetKey sets the value of the given key. A TTL of 0 means that the key does not expire.
+func (e *Etcd) SetKey(key, value string, ttl time.Duration) {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	e.setKey(key, value, ttl)
+}
+
+func (e *Etcd) getKey(key string) (etcdKey, bool) {
+	k, ok := e.keys[normalizeKey(key)]
+	if !ok || k.isExpired() {
+		return etcdKey{}, false
+	}
+	return k, true
+}
+
+func (e *Etcd) setKey(key, value string, ttl time.Duration) {
+	if e.keys == nil {
+		e.keys = make(map[string]etcdKey)
+	}
+	k := etcdKey{Value: value}
+	if ttl > 0 {
+		k.Expires = time.Now().Add(ttl)
+	}
+	e.keys[normalizeKey(key)] = k
+}
+
+func normalizeKey(key string) string {
+	return "/" + strings.TrimPrefix(key, "/")
+}
+
+// serveEtcdKeys answers requests to the v2 keys API and the v3 KV & lease API.
+// It returns false if the request is not such a request.
+func (s *Server) serveEtcdKeys(w http.ResponseWriter, method string, u *url.URL, body []byte) bool {
+	e := s.Etcd
+	reply := func(statusCode int, result interface{}) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(statusCode)
+		json.NewEncoder(w).Encode(result)
+	}
+	fail := func(statusCode, errorCode int, message string) {
+		reply(statusCode, map[string]interface{}{"errorCode": errorCode, "message": message, "error": message})
+	}
+
+	path := u.Path
+	if strings.HasPrefix(path, "/v2/keys/") {
+		key := strings.TrimPrefix(path, "/v2/keys")
+		query := u.Query()
+		form, _ := url.ParseQuery(string(body))
+		e.mutex.Lock()
+		defer e.mutex.Unlock()
+		current, exists := e.getKey(key)
+		node := func(value string) map[string]interface{} {
+			return map[string]interface{}{"node": map[string]string{"key": key, "value": value}}
+		}
+		switch method {
+		case "GET":
+			if !exists {
+				fail(http.StatusNotFound, 100, "Key not found")
+				return true
+			}
+			reply(http.StatusOK, node(current.Value))
+		case "PUT":
+			if exists && query.Get("prevExist") == "false" {
+				fail(http.StatusPreconditionFailed, 105, "Key already exists")
+				return true
+			}
+			if prevValue := query.Get("prevValue"); prevValue != "" {
+				if !exists {
+					fail(http.StatusNotFound, 100, "Key not found")
+					return true
+				}
+				if prevValue != current.Value {
+					fail(http.StatusPreconditionFailed, 101, "Compare failed")
+					return true
+				}
+			}
+			ttl, _ := strconv.Atoi(form.Get("ttl"))
+			e.setKey(key, form.Get("value"), time.Duration(ttl)*time.Second)
+			reply(http.StatusCreated, node(form.Get("value")))
+		case "DELETE":
+			if !exists {
+				fail(http.StatusNotFound, 100, "Key not found")
+				return true
+			}
+			if prevValue := query.Get("prevValue"); prevValue != "" && prevValue != current.Value {
+				fail(http.StatusPreconditionFailed, 101, "Compare failed")
+				return true
+			}
+			delete(e.keys, normalizeKey(key))
+			reply(http.StatusOK, node(""))
+		default:
+			return false
+		}
+		return true
+	}
+
+	// Strip the prefix of the v3 JSON gateway
+	for _, prefix := range []string{"/v3alpha/", "/v3beta/", "/v3/"} {
+		if strings.HasPrefix(path, prefix) {
+			path = "/" + strings.TrimPrefix(path, prefix)
+			break
+		}
+	}
+	type keyValue struct {
+		Key   string `json:"key"`
+		Value string `json:"value,omitempty"`
+		Lease string `json:"lease,omitempty"`
+	}
+	var request struct {
+		keyValue
+		TTL     string `json:"TTL"`
+		ID      string `json:"ID"`
+		Compare []struct {
+			Key            string `json:"key"`
+			Target         string `json:"target"`
+			Result         string `json:"result"`
+			CreateRevision string `json:"create_revision"`
+			Value          string `json:"value"`
+		} `json:"compare"`
+		Success []struct {
+			Put         *keyValue `json:"request_put"`
+			DeleteRange *keyValue `json:"request_delete_range"`
+		} `json:"success"`
+	}
+	decode := func(encoded string) string {
+		raw, _ := base64.StdEncoding.DecodeString(encoded)
+		return string(raw)
+	}
+	switch path {
+	case "/lease/grant", "/lease/revoke", "/kv/put", "/kv/range", "/kv/txn":
+		if err := json.Unmarshal(body, &request); err != nil {
+			fail(http.StatusBadRequest, 0, err.Error())
+			return true
+		}
+	default:
+		return false
+	}
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	switch path {
+	case "/lease/grant":
+		// The lease ID is its TTL, so keys put with the lease get that TTL
+		reply(http.StatusOK, map[string]interface{}{"header": map[string]string{}, "ID": request.TTL, "TTL": request.TTL})
+	case "/lease/revoke":
+		reply(http.StatusOK, map[string]interface{}{"header": map[string]string{}})
+	case "/kv/put":
+		e.setKey(decode(request.Key), decode(request.Value), 0)
+		reply(http.StatusOK, map[string]interface{}{"header": map[string]string{}})
+	case "/kv/range":
+		result := map[string]interface{}{"header": map[string]string{}}
+		if k, ok := e.getKey(decode(request.Key)); ok {
+			result["kvs"] = []keyValue{{Key: request.Key, Value: base64.StdEncoding.EncodeToString([]byte(k.Value))}}
+			result["count"] = "1"
+		}
+		reply(http.StatusOK, result)
+	case "/kv/txn":
+		succeeded := true
+		for _, c := range request.Compare {
+			k, exists := e.getKey(decode(c.Key))
+			switch {
+			case c.Target == "CREATE" && c.Result == "EQUAL":
+				succeeded = succeeded && !exists
+			case c.Target == "CREATE" && c.Result == "NOT_EQUAL":
+				succeeded = succeeded && exists
+			case c.Target == "VALUE" && c.Result == "EQUAL":
+				succeeded = succeeded && exists && k.Value == decode(c.Value)
+			default:
+				fail(http.StatusBadRequest, 0, "unsupported comparison")
+				return true
+			}
+		}
+		if succeeded {
+			for _, op := range request.Success {
+				if op.Put != nil {
+					ttl, _ := strconv.Atoi(op.Put.Lease)
+					e.setKey(decode(op.Put.Key), decode(op.Put.Value), time.Duration(ttl)*time.Second)
+				}
+				if op.DeleteRange != nil {
+					delete(e.keys, normalizeKey(decode(op.DeleteRange.Key)))
+				}
+			}
+		}
+		result := map[string]interface{}{"header": map[string]string{}}
+		if succeeded {
+			result["succeeded"] = true
+		}
+		reply(http.StatusOK, result)
+	}
+	return true
+}
@@ -0,0 +1,116 @@
+// Copyright (c) 2016 Pulcy.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sshtest
+
+import (
+	"sort"
+	"sync"
+
+	"github.com/pulcy/quark/providers"
+)
+
+// Farm starts a test server for every host that is connected to, so instances
+// created during a test (e.g. by the fake provider) can be reached without
+// registering them up front. All servers of a farm share a single etcd cluster.
+type Farm struct {
+	// Setup, if set, is called for every new server before it is first dialed.
+	Setup func(host string, s *Server)
+
+	mutex   sync.Mutex
+	etcd    *Etcd
+	servers map[string]*Server
+}
+
+// NewFarm creates an empty farm.
+func NewFarm() *Farm {
+	return &Farm{
+		etcd:    &Etcd{},
+		servers: make(map[string]*Server),
+	}
+}
+
+// Server returns the server for the given host, starting it when needed.
+func (f *Farm) Server(host string) (*Server, error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	if s, ok := f.servers[host]; ok {
+		return s, nil
+	}
+	s, err := NewServer("")
+	if err != nil {
+		return nil, maskAny(err)
+	}
+	s.Etcd = f.etcd
+	if f.Setup != nil {
+		f.Setup(host, s)
+	}
+	f.servers[host] = s
+	return s, nil
+}
+
+// Hosts returns the sorted hosts for which a server has been started.
+func (f *Farm) Hosts() []string {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	hosts := []string{}
+	for host := range f.servers {
+		hosts = append(hosts, host)
+	}
+	sort.Strings(hosts)
+	return hosts
+}
+
+// Etcd returns the etcd cluster shared by all servers.
+func (f *Farm) Etcd() *Etcd {
+	return f.etcd
+}
+
+// Dialer returns an SSH dialer that connects to the server of the requested host.
+// Ports, jump hosts & key files are ignored, like in Dialer.
+func (f *Farm) Dialer() providers.SSHDialer {
+	return func(options providers.SSHOptions) (providers.SSHClient, error) {
+		s, err := f.Server(options.Host)
+		if err != nil {
+			return nil, maskAny(err)
+		}
+		client, err := s.Dial(options.UserName)
+		if err != nil {
+			return nil, maskAny(err)
+		}
+		return providers.NewSSHClient(client), nil
+	}
+}
+
+// Install routes all SSH connections made by the providers package to the servers of the farm.
+// Call the returned function to restore the original dialer.
+func (f *Farm) Install() func() {
+	previous := providers.SetSSHDialer(f.Dialer())
+	return func() {
+		providers.SetSSHDialer(previous)
+	}
+}
+
+// Close stops all servers of the farm.
+func (f *Farm) Close() {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	for _, s := range f.servers {
+		s.Close()
+	}
+	f.servers = make(map[string]*Server)
+}
@@ -0,0 +1,172 @@
+// Copyright (c) 2016 Pulcy.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sshtest
+
+import (
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// File is a single file in a FileSystem.
+type File struct {
+	Content string
+	Mode    os.FileMode
+	Owner   string
+}
+
+// FileSystem is an in-memory filesystem of an emulated instance.
+type FileSystem struct {
+	mutex sync.Mutex
+	files map[string]File
+	dirs  map[string]struct{}
+}
+
+// NewFileSystem creates an empty filesystem containing only the root directory.
+func NewFileSystem() *FileSystem {
+	return &FileSystem{
+		files: make(map[string]File),
+		dirs:  map[string]struct{}{"/": struct{}{}},
+	}
+}
+
+// ReadFile returns the file with the given path.
+func (fs *FileSystem) ReadFile(p string) (File, bool) {
+	fs.mutex.Lock()
+	defer fs.mutex.Unlock()
+	f, ok := fs.files[path.Clean(p)]
+	return f, ok
+}
+
+// WriteFile creates or replaces the file with the given path.
+// All parent directories are created when needed.
+func (fs *FileSystem) WriteFile(p, content string, mode os.FileMode, owner string) {
+	fs.mutex.Lock()
+	defer fs.mutex.Unlock()
+	p = path.Clean(p)
+	fs.mkdirAll(path.Dir(p))
+	fs.files[p] = File{Content: content, Mode: mode, Owner: owner}
+}
+
+// Chmod changes the mode of the file with the given path.
+func (fs *FileSystem) Chmod(p string, mode os.FileMode) bool {
+	fs.mutex.Lock()
+	defer fs.mutex.Unlock()
+	p = path.Clean(p)
+	f, ok := fs.files[p]
+	if !ok {
+		return false
+	}
+	f.Mode = mode
+	fs.files[p] = f
+	return true
+}
+
+// Chown changes the owner of the file with the given path.
+func (fs *FileSystem) Chown(p, owner string) bool {
+	fs.mutex.Lock()
+	defer fs.mutex.Unlock()
+	p = path.Clean(p)
+	f, ok := fs.files[p]
+	if !ok {
+		return false
+	}
+	f.Owner = owner
+	fs.files[p] = f
+	return true
+}
+
+// Rename moves a file from one path to another.
+func (fs *FileSystem) Rename(from, to string) bool {
+	fs.mutex.Lock()
+	defer fs.mutex.Unlock()
+	from, to = path.Clean(from), path.Clean(to)
+	f, ok := fs.files[from]
+	if !ok {
+		return false
+	}
+	delete(fs.files, from)
+	fs.mkdirAll(path.Dir(to))
+	fs.files[to] = f
+	return true
+}
+
+// Remove deletes the file with the given path.
+func (fs *FileSystem) Remove(p string) bool {
+	fs.mutex.Lock()
+	defer fs.mutex.Unlock()
+	p = path.Clean(p)
+	if _, ok := fs.files[p]; !ok {
+		return false
+	}
+	delete(fs.files, p)
+	return true
+}
+
+// MkdirAll creates a directory and all of its parents.
+func (fs *FileSystem) MkdirAll(p string) {
+	fs.mutex.Lock()
+	defer fs.mutex.Unlock()
+	fs.mkdirAll(path.Clean(p))
+}
+
+func (fs *FileSystem) mkdirAll(p string) {
+	for {
+		fs.dirs[p] = struct{}{}
+		if p == "/" || p == "." {
+			return
+		}
+		p = path.Dir(p)
+	}
+}
+
+// IsDir returns true if the given path is an existing directory.
+func (fs *FileSystem) IsDir(p string) bool {
+	fs.mutex.Lock()
+	defer fs.mutex.Unlock()
+	_, ok := fs.dirs[path.Clean(p)]
+	return ok
+}
+
+// Glob returns the sorted paths of all files matching the given pattern.
+func (fs *FileSystem) Glob(pattern string) []string {
+	fs.mutex.Lock()
+	defer fs.mutex.Unlock()
+	var result []string
+	for p := range fs.files {
+		if ok, _ := path.Match(pattern, p); ok {
+			result = append(result, p)
+		}
+	}
+	sort.Strings(result)
+	return result
+}
+
+// Paths returns the sorted paths of all files in the given directory (recursive).
+func (fs *FileSystem) Paths(dir string) []string {
+	fs.mutex.Lock()
+	defer fs.mutex.Unlock()
+	prefix := strings.TrimSuffix(path.Clean(dir), "/") + "/"
+	var result []string
+	for p := range fs.files {
+		if strings.HasPrefix(p, prefix) {
+			result = append(result, p)
+		}
+	}
+	sort.Strings(result)
+	return result
+}
@@ -0,0 +1,430 @@
+// Copyright (c) 2016 Pulcy.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package sshtest provides an in-process SSH server that emulates a Pulcy instance.
+// It is used to test code that manages instances over SSH (see providers.InstanceConnection)
+// without real machines.
+//
+// The server has an in-memory filesystem and scriptable command handlers.
+// Default handlers emulate common shell tools as well as etcdctl, fleetctl, systemctl, docker and gluon.
+// Forwarded connections to port 2379 are answered by an emulation of the etcd client API (v2 & v3).
+// Every command that is executed is recorded, including its stdin payload.
+package sshtest
+
+import (
+	"bufio"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+
+	"golang.org/x/crypto/ssh"
+)
+
+const (
+	defaultAddress = "127.0.0.1:0"
+	bootIDPath     = "/proc/sys/kernel/random/boot_id"
+)
+
+// Result is the outcome of a command executed on the server.
+type Result struct {
+	Stdout     string
+	Stderr     string
+	ExitStatus int
+}
+
+// OK returns a successful result with the given output.
+func OK(stdout string) Result {
+	return Result{Stdout: stdout}
+}
+
+// Fail returns a failed result with the given exit status & error output.
+func Fail(exitStatus int, stderr string) Result {
+	return Result{Stderr: stderr, ExitStatus: exitStatus}
+}
+
+// Command is a single command invocation passed to a HandlerFunc.
+type Command struct {
+	Server *Server
+	User   string   // Name of the SSH user
+	Root   bool     // Set when the command is run through sudo
+	Args   []string // Arguments, including the program name
+
+	stdin *input
+}
+
+// Stdin returns the complete stdin of the command.
+// It blocks until the client closes its stdin, so only handlers that need their input should call it.
+func (c Command) Stdin() string {
+	return c.stdin.String()
+}
+
+// HandlerFunc implements a single program on the server.
+type HandlerFunc func(cmd Command) Result
+
+// Record describes a single command line executed through SSH.
+type Record struct {
+	User       string
+	Command    string
+	Stdin      string // Part of stdin that was read by the command
+	Stdout     string
+	Stderr     string
+	ExitStatus int
+}
+
+// Server is an in-process SSH server emulating a single instance.
+type Server struct {
+	FS      *FileSystem
+	Etcd    *Etcd
+	Systemd *Systemd
+	Docker  *Docker
+	Gluon   *Gluon
+
+	listener net.Listener
+	config   *ssh.ServerConfig
+	hostKey  ssh.Signer
+
+	mutex    sync.Mutex
+	handlers map[string]HandlerFunc
+	records  []Record
+	reboots  int
+	conns    map[net.Conn]struct{}
+	closed   bool
+}
+
+// NewServer starts a new SSH server listening on the given address.
+// If address is empty, a random port on 127.0.0.1 is used.
+func NewServer(address string) (*Server, error) {
+	if address == "" {
+		address = defaultAddress
+	}
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, maskAny(err)
+	}
+	hostKey, err := ssh.NewSignerFromKey(key)
+	if err != nil {
+		return nil, maskAny(err)
+	}
+	listener, err := net.Listen("tcp", address)
+	if err != nil {
+		return nil, maskAny(err)
+	}
+	s := &Server{
+		FS:       NewFileSystem(),
+		Etcd:     &Etcd{},
+		Systemd:  &Systemd{},
+		Docker:   &Docker{},
+		Gluon:    &Gluon{},
+		listener: listener,
+		hostKey:  hostKey,
+		handlers: make(map[string]HandlerFunc),
+		conns:    make(map[net.Conn]struct{}),
+	}
+	s.config = &ssh.ServerConfig{
+		// Any key is accepted, the server is only reachable by the test process.
+		PublicKeyCallback: func(conn ssh.ConnMetadata, key ssh.PublicKey) (*ssh.Permissions, error) {
+			return nil, nil
+		},
+	}
+	s.config.AddHostKey(hostKey)
+	s.seed()
+
+	go s.serve()
+	return s, nil
+}
+
+// seed creates the files every instance is expected to have.
+func (s *Server) seed() {
+	machineID := make([]byte, 16)
+	rand.Read(machineID)
+	s.FS.WriteFile("/etc/machine-id", fmt.Sprintf("%x\n", machineID), 0444, "root")
+	s.FS.WriteFile("/etc/lsb-release", "DISTRIB_ID=CoreOS\nDISTRIB_RELEASE=1122.2.0\n", 0444, "root")
+	s.newBootID()
+	s.FS.MkdirAll("/home/core")
+	s.FS.MkdirAll("/tmp")
+}
+
+// Addr returns the address the server is listening on.
+func (s *Server) Addr() string {
+	return s.listener.Addr().String()
+}
+
+// Host returns the IP address the server is listening on.
+func (s *Server) Host() string {
+	host, _, _ := net.SplitHostPort(s.Addr())
+	return host
+}
+
+// Port returns the port the server is listening on.
+func (s *Server) Port() int {
+	_, port, _ := net.SplitHostPort(s.Addr())
+	result, _ := strconv.Atoi(port)
+	return result
+}
+
+// HostKey returns the public host key of the server.
+func (s *Server) HostKey() ssh.PublicKey {
+	return s.hostKey.PublicKey()
+}
+
+// MachineID returns the content of /etc/machine-id without trailing newline.
+func (s *Server) MachineID() string {
+	f, _ := s.FS.ReadFile("/etc/machine-id")
+	return trimNewline(f.Content)
+}
+
+// Close stops the server and closes all open connections.
+func (s *Server) Close() error {
+	s.mutex.Lock()
+	s.closed = true
+	for c := range s.conns {
+		c.Close()
+	}
+	s.mutex.Unlock()
+	return maskAny(s.listener.Close())
+}
+
+// Handle registers a handler for the program with the given name.
+// The name is matched against the base name of the first argument, so "gluon"
+// also handles "/home/core/bin/gluon".
+// Registered handlers take precedence over the default handlers.
+func (s *Server) Handle(name string, handler HandlerFunc) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.handlers[name] = handler
+}
+
+func (s *Server) handler(name string) (HandlerFunc, bool) {
+	s.mutex.Lock()
+	h, ok := s.handlers[name]
+	s.mutex.Unlock()
+	if ok {
+		return h, true
+	}
+	h, ok = defaultHandlers[name]
+	return h, ok
+}
+
+// Reboot simulates a reboot of the server, which gives it a new boot ID.
+func (s *Server) Reboot() {
+	s.mutex.Lock()
+	s.reboots++
+	s.mutex.Unlock()
+	s.newBootID()
+}
+
+// newBootID writes a random /proc/sys/kernel/random/boot_id.
+func (s *Server) newBootID() {
+	id := make([]byte, 16)
+	rand.Read(id)
+	s.FS.WriteFile(bootIDPath, fmt.Sprintf("%x-%x-%x-%x-%x\n", id[0:4], id[4:6], id[6:8], id[8:10], id[10:]), 0444, "root")
+}
+
+// Reboots returns the number of times the server has been asked to reboot.
+func (s *Server) Reboots() int {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.reboots
+}
+
+// Records returns a copy of all command lines executed so far.
+func (s *Server) Records() []Record {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return append([]Record(nil), s.records...)
+}
+
+// Commands returns all command lines executed so far.
+func (s *Server) Commands() []string {
+	var result []string
+	for _, r := range s.Records() {
+		result = append(result, r.Command)
+	}
+	return result
+}
+
+// ClearRecords removes all recorded commands.
+func (s *Server) ClearRecords() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.records = nil
+}
+
+func (s *Server) addRecord(r Record) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.records = append(s.records, r)
+}
+
+func (s *Server) serve() {
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+		s.mutex.Lock()
+		if s.closed {
+			s.mutex.Unlock()
+			conn.Close()
+			return
+		}
+		s.conns[conn] = struct{}{}
+		s.mutex.Unlock()
+		go s.serveConn(conn)
+	}
+}
+
+func (s *Server) serveConn(conn net.Conn) {
+	defer func() {
+		conn.Close()
+		s.mutex.Lock()
+		delete(s.conns, conn)
+		s.mutex.Unlock()
+	}()
+	sconn, channels, requests, err := ssh.NewServerConn(conn, s.config)
+	if err != nil {
+		return
+	}
+	go ssh.DiscardRequests(requests)
+	for newChannel := range channels {
+		if newChannel.ChannelType() == "direct-tcpip" {
+			go s.serveForward(sconn.User(), newChannel)
+			continue
+		}
+		if newChannel.ChannelType() != "session" {
+			newChannel.Reject(ssh.UnknownChannelType, "unknown channel type")
+			continue
+		}
+		channel, requests, err := newChannel.Accept()
+		if err != nil {
+			continue
+		}
+		go s.serveSession(sconn.User(), channel, requests)
+	}
+}
+
+func (s *Server) serveSession(user string, channel ssh.Channel, requests <-chan *ssh.Request) {
+	defer channel.Close()
+	for req := range requests {
+		switch req.Type {
+		case "env", "pty-req", "window-change":
+			req.Reply(true, nil)
+		case "exec":
+			var payload struct {
+				Command string
+			}
+			if err := ssh.Unmarshal(req.Payload, &payload); err != nil {
+				req.Reply(false, nil)
+				continue
+			}
+			req.Reply(true, nil)
+			// Stdin is streamed to the command, so clients that keep their stdin open
+			// (e.g. interactive sessions) do not block commands that never read it.
+			result := s.exec(user, payload.Command, newInput(channel), channel)
+			sendExitStatus(channel, result.ExitStatus)
+			return
+		case "shell":
+			req.Reply(true, nil)
+			sendExitStatus(channel, s.shell(user, channel))
+			return
+		default:
+			req.Reply(false, nil)
+		}
+	}
+}
+
+// exec runs a single command line, writes its output to the channel and records it.
+func (s *Server) exec(user, line string, stdin *input, channel ssh.Channel) Result {
+	result := s.runLine(user, false, line, stdin)
+	s.addRecord(Record{
+		User:       user,
+		Command:    line,
+		Stdin:      stdin.consumed(),
+		Stdout:     result.Stdout,
+		Stderr:     result.Stderr,
+		ExitStatus: result.ExitStatus,
+	})
+	channel.Write([]byte(result.Stdout))
+	channel.Stderr().Write([]byte(result.Stderr))
+	return result
+}
+
+// shell runs the command lines read from the channel one at a time, until the client
+// closes its stdin or sends `exit`. It returns the exit status of the last command.
+func (s *Server) shell(user string, channel ssh.Channel) int {
+	status := 0
+	scanner := bufio.NewScanner(channel)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		switch {
+		case line == "":
+			continue
+		case line == "exit":
+			return status
+		}
+		status = s.exec(user, line, stringInput(""), channel).ExitStatus
+	}
+	return status
+}
+
+func sendExitStatus(channel ssh.Channel, exitStatus int) {
+	status := struct {
+		Status uint32
+	}{uint32(exitStatus)}
+	channel.SendRequest("exit-status", false, ssh.Marshal(&status))
+}
+
+// input is the stdin of a command. It is only read when a handler asks for it.
+type input struct {
+	mutex  sync.Mutex
+	reader io.Reader
+	data   string
+	read   bool
+}
+
+// newInput creates an input that reads from the given reader on first use.
+func newInput(reader io.Reader) *input {
+	return &input{reader: reader}
+}
+
+// stringInput creates an input with the given content.
+func stringInput(data string) *input {
+	return &input{data: data, read: true}
+}
+
+// String reads the complete input.
+func (i *input) String() string {
+	i.mutex.Lock()
+	defer i.mutex.Unlock()
+	if !i.read {
+		raw, _ := ioutil.ReadAll(i.reader)
+		i.data = string(raw)
+		i.read = true
+	}
+	return i.data
+}
+
+// consumed returns the input as far as it has been read by a handler.
+func (i *input) consumed() string {
+	i.mutex.Lock()
+	defer i.mutex.Unlock()
+	return i.data
+}
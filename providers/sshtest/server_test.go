@@ -0,0 +1,145 @@
+// Copyright (c) 2016 Pulcy.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sshtest
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newTestServer(t *testing.T) *Server {
+	s, err := NewServer("")
+	if err != nil {
+		t.Fatalf("NewServer failed: %v", err)
+	}
+	return s
+}
+
+// runWithTimeout runs the given function and fails the test when it does not return in time.
+func runWithTimeout(t *testing.T, name string, f func() error) {
+	done := make(chan error, 1)
+	go func() { done <- f() }()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("%s failed: %v", name, err)
+		}
+	case <-time.After(time.Second * 10):
+		t.Fatalf("%s did not return", name)
+	}
+}
+
+func TestCommandIgnoringOpenStdin(t *testing.T) {
+	s := newTestServer(t)
+	defer s.Close()
+	client, err := s.Dial("core")
+	if err != nil {
+		t.Fatalf("Dial failed: %v", err)
+	}
+	defer client.Close()
+	session, err := client.NewSession()
+	if err != nil {
+		t.Fatalf("NewSession failed: %v", err)
+	}
+	defer session.Close()
+
+	// The stdin of the session is never closed
+	stdin, stdinWriter := io.Pipe()
+	defer stdinWriter.Close()
+	session.Stdin = stdin
+	var stdout bytes.Buffer
+	session.Stdout = &stdout
+	runWithTimeout(t, "echo", func() error { return session.Run("echo hello") })
+	if stdout.String() != "hello\n" {
+		t.Errorf("Expected 'hello', got '%s'", stdout.String())
+	}
+}
+
+func TestCommandReadingStdin(t *testing.T) {
+	s := newTestServer(t)
+	defer s.Close()
+	client, err := s.Dial("core")
+	if err != nil {
+		t.Fatalf("Dial failed: %v", err)
+	}
+	defer client.Close()
+	session, err := client.NewSession()
+	if err != nil {
+		t.Fatalf("NewSession failed: %v", err)
+	}
+	defer session.Close()
+
+	session.Stdin = strings.NewReader("some content")
+	runWithTimeout(t, "tee", func() error { return session.Run("sudo tee /etc/test") })
+	if f, ok := s.FS.ReadFile("/etc/test"); !ok || f.Content != "some content" {
+		t.Errorf("Expected /etc/test to contain the stdin of tee, got '%s'", f.Content)
+	}
+	records := s.Records()
+	if len(records) != 1 || records[0].Stdin != "some content" {
+		t.Errorf("Expected the stdin of tee to be recorded, got %#v", records)
+	}
+}
+
+func TestInteractiveShell(t *testing.T) {
+	s := newTestServer(t)
+	defer s.Close()
+	client, err := s.Dial("core")
+	if err != nil {
+		t.Fatalf("Dial failed: %v", err)
+	}
+	defer client.Close()
+	session, err := client.NewSession()
+	if err != nil {
+		t.Fatalf("NewSession failed: %v", err)
+	}
+	defer session.Close()
+
+	stdin, err := session.StdinPipe()
+	if err != nil {
+		t.Fatalf("StdinPipe failed: %v", err)
+	}
+	stdout, err := session.StdoutPipe()
+	if err != nil {
+		t.Fatalf("StdoutPipe failed: %v", err)
+	}
+	if err := session.RequestPty("xterm", 25, 80, nil); err != nil {
+		t.Fatalf("RequestPty failed: %v", err)
+	}
+	if err := session.Shell(); err != nil {
+		t.Fatalf("Shell failed: %v", err)
+	}
+
+	// Output must arrive before the session ends
+	io.WriteString(stdin, "echo first\r\n")
+	line := make([]byte, len("first\n"))
+	runWithTimeout(t, "reading output", func() error {
+		_, err := io.ReadFull(stdout, line)
+		return err
+	})
+	if string(line) != "first\n" {
+		t.Errorf("Expected 'first', got '%s'", string(line))
+	}
+	io.WriteString(stdin, "false\nexit\n")
+	err = session.Wait()
+	if err == nil {
+		t.Errorf("Expected the exit status of the last command")
+	}
+	if commands := s.Commands(); len(commands) != 2 || commands[0] != "echo first" || commands[1] != "false" {
+		t.Errorf("Unexpected commands %#v", commands)
+	}
+}
@@ -0,0 +1,414 @@
+// Copyright (c) 2016 Pulcy.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sshtest
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"fmt"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// EtcdMember is a single member of the emulated ETCD cluster.
+type EtcdMember struct {
+	ID        string
+	Name      string
+	PeerURL   string
+	ClientURL string
+	IsLeader  bool
+	Unstarted bool
+}
+
+// Etcd emulates the ETCD cluster membership as seen by `etcdctl member` and the etcd client API,
+// as well as the etcd key space (v2 keys API and v3 KV API).
+type Etcd struct {
+	mutex   sync.Mutex
+	members []EtcdMember
+	version string
+	keys    map[string]etcdKey
+}
+
+// Members returns a copy of the current members.
+func (e *Etcd) Members() []EtcdMember {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	return append([]EtcdMember(nil), e.members...)
+}
+
+// AddMember adds a started member with the given name and cluster IP.
+// The first member becomes the leader.
+func (e *Etcd) AddMember(name, clusterIP string) EtcdMember {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	m := EtcdMember{
+		ID:        newEtcdMemberID(),
+		Name:      name,
+		PeerURL:   fmt.Sprintf("http://%s:2380", clusterIP),
+		ClientURL: fmt.Sprintf("http://%s:2379", clusterIP),
+		IsLeader:  len(e.members) == 0,
+	}
+	e.members = append(e.members, m)
+	return m
+}
+
+func (e *Etcd) add(name, peerURL string) EtcdMember {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	m := EtcdMember{
+		ID:        newEtcdMemberID(),
+		Name:      name,
+		PeerURL:   peerURL,
+		Unstarted: true,
+	}
+	e.members = append(e.members, m)
+	return m
+}
+
+func (e *Etcd) remove(id string) bool {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	for i, m := range e.members {
+		if m.ID == id {
+			e.members = append(e.members[:i], e.members[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// newEtcdMemberID returns a random member ID, formatted like etcd does (hexadecimal without leading zeros).
+func newEtcdMemberID() string {
+	raw := make([]byte, 8)
+	rand.Read(raw)
+	return strconv.FormatUint(binary.BigEndian.Uint64(raw), 16)
+}
+
+// handleEtcdctl supports `etcdctl cluster-health` and `etcdctl member list|add|remove`
+// using the etcd2 output format.
+func handleEtcdctl(cmd Command) Result {
+	_, args := splitFlags(cmd.Args[1:])
+	etcd := cmd.Server.Etcd
+	if len(args) == 1 && args[0] == "cluster-health" {
+		return etcdClusterHealth(etcd.Members())
+	}
+	if len(args) < 2 || args[0] != "member" {
+		return Fail(1, "etcdctl: unsupported command\n")
+	}
+	switch args[1] {
+	case "list":
+		var lines []string
+		for _, m := range etcd.Members() {
+			if m.Unstarted {
+				lines = append(lines, fmt.Sprintf("%s[unstarted]: peerURLs=%s", m.ID, m.PeerURL))
+			} else {
+				lines = append(lines, fmt.Sprintf("%s: name=%s peerURLs=%s clientURLs=%s isLeader=%v", m.ID, m.Name, m.PeerURL, m.ClientURL, m.IsLeader))
+			}
+		}
+		if len(lines) == 0 {
+			return OK("")
+		}
+		return OK(strings.Join(lines, "\n") + "\n")
+	case "add":
+		if len(args) != 4 {
+			return Fail(1, "etcdctl: member add requires a name and a peer URL\n")
+		}
+		m := etcd.add(args[2], args[3])
+		return OK(fmt.Sprintf("Added member named %s with ID %s to cluster\n", m.Name, m.ID))
+	case "remove":
+		if len(args) != 3 {
+			return Fail(1, "etcdctl: member remove requires an ID\n")
+		}
+		id := strings.TrimSpace(args[2])
+		if !etcd.remove(id) {
+			return Fail(1, fmt.Sprintf("client: etcd cluster is unavailable or misconfigured; member %s not found\n", id))
+		}
+		return OK(fmt.Sprintf("Removed member %s from cluster\n", id))
+	default:
+		return Fail(1, fmt.Sprintf("etcdctl: unknown member command '%s'\n", args[1]))
+	}
+}
+
+// etcdClusterHealth reports the cluster as healthy when a majority of the members is started.
+func etcdClusterHealth(members []EtcdMember) Result {
+	var lines []string
+	started := 0
+	for _, m := range members {
+		if m.Unstarted {
+			lines = append(lines, fmt.Sprintf("member %s is unreachable: no available published client urls", m.ID))
+		} else {
+			started++
+			lines = append(lines, fmt.Sprintf("member %s is healthy: got healthy result from %s", m.ID, m.ClientURL))
+		}
+	}
+	if len(members) == 0 || started <= len(members)/2 {
+		lines = append(lines, "cluster is unhealthy")
+		return Fail(5, strings.Join(lines, "\n")+"\n")
+	}
+	lines = append(lines, "cluster is healthy")
+	return OK(strings.Join(lines, "\n") + "\n")
+}
+
+// handleFleetctl supports `fleetctl list-machines`, listing all started etcd members as machines,
+// and `fleetctl list-units`, listing no units.
+func handleFleetctl(cmd Command) Result {
+	var flags, args []string
+	for _, a := range cmd.Args[1:] {
+		if strings.HasPrefix(a, "-") {
+			flags = append(flags, a)
+		} else {
+			args = append(args, a)
+		}
+	}
+	if len(args) == 1 && args[0] == "list-units" {
+		if hasFlag(flags, "--no-legend") {
+			return OK("")
+		}
+		return OK("UNIT\tMACHINE\n")
+	}
+	if len(args) != 1 || args[0] != "list-machines" {
+		return Fail(1, "fleetctl: unsupported command\n")
+	}
+	var lines []string
+	if !hasFlag(flags, "--no-legend") {
+		lines = append(lines, "MACHINE\t\tIP\t\tMETADATA")
+	}
+	for _, m := range cmd.Server.Etcd.Members() {
+		if m.Unstarted {
+			continue
+		}
+		ip := strings.TrimSuffix(strings.TrimPrefix(m.ClientURL, "http://"), ":2379")
+		lines = append(lines, fmt.Sprintf("%s...\t%s\t-", m.ID[:8], ip))
+	}
+	if len(lines) == 0 {
+		return OK("")
+	}
+	return OK(strings.Join(lines, "\n") + "\n")
+}
+
+// Systemd emulates the state of systemd units.
+// Unit files are read from /etc/systemd/system in the server filesystem.
+type Systemd struct {
+	mutex    sync.Mutex
+	enabled  map[string]bool
+	restarts map[string]int
+	stopped  map[string]bool
+	reloads  int
+}
+
+// IsEnabled returns true if `systemctl enable` has been called for the given unit.
+func (s *Systemd) IsEnabled(unit string) bool {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.enabled[unit]
+}
+
+// Restarts returns the number of times the given unit has been (re)started.
+func (s *Systemd) Restarts(unit string) int {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.restarts[unit]
+}
+
+// IsActive returns true if the given unit has been started and not stopped since.
+func (s *Systemd) IsActive(unit string) bool {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.restarts[unit] > 0 && !s.stopped[unit]
+}
+
+// SetActive marks the given unit as started (or stopped).
+func (s *Systemd) SetActive(unit string, active bool) {
+	s.update(func() {
+		if active && s.restarts[unit] == 0 {
+			s.restarts[unit] = 1
+		}
+		s.stopped[unit] = !active
+	})
+}
+
+// Reloads returns the number of times `systemctl daemon-reload` has been called.
+func (s *Systemd) Reloads() int {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.reloads
+}
+
+func (s *Systemd) update(cb func()) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.enabled == nil {
+		s.enabled = make(map[string]bool)
+		s.restarts = make(map[string]int)
+		s.stopped = make(map[string]bool)
+	}
+	cb()
+}
+
+// normalizeUnit adds the .service suffix to unit names without a suffix.
+func normalizeUnit(name string) string {
+	if path.Ext(name) == "" {
+		return name + ".service"
+	}
+	return name
+}
+
+// handleSystemctl supports `systemctl cat|is-active|enable|disable|start|restart|stop|daemon-reload`.
+func handleSystemctl(cmd Command) Result {
+	_, args := splitFlags(cmd.Args[1:])
+	if len(args) == 0 {
+		return Fail(1, "systemctl: missing command\n")
+	}
+	systemd := cmd.Server.Systemd
+	if args[0] == "cat" {
+		var out string
+		for _, unit := range args[1:] {
+			p := path.Join("/etc/systemd/system", normalizeUnit(unit))
+			f, ok := cmd.Server.FS.ReadFile(p)
+			if !ok {
+				return Fail(1, fmt.Sprintf("No files found for %s.\n", normalizeUnit(unit)))
+			}
+			out += "# " + p + "\n" + f.Content
+		}
+		return OK(out)
+	}
+	if args[0] == "is-active" {
+		for _, unit := range args[1:] {
+			if !systemd.IsActive(normalizeUnit(unit)) {
+				return Result{Stdout: "inactive\n", ExitStatus: 3}
+			}
+		}
+		return OK("active\n")
+	}
+	if !cmd.Root {
+		return Fail(1, "Failed to execute operation: Access denied\n")
+	}
+	units := args[1:]
+	switch args[0] {
+	case "daemon-reload":
+		systemd.update(func() { systemd.reloads++ })
+	case "enable", "disable":
+		systemd.update(func() {
+			for _, unit := range units {
+				systemd.enabled[normalizeUnit(unit)] = args[0] == "enable"
+			}
+		})
+	case "start", "restart":
+		systemd.update(func() {
+			for _, unit := range units {
+				systemd.restarts[normalizeUnit(unit)]++
+				systemd.stopped[normalizeUnit(unit)] = false
+			}
+		})
+	case "stop":
+		systemd.update(func() {
+			for _, unit := range units {
+				systemd.stopped[normalizeUnit(unit)] = true
+			}
+		})
+	default:
+		return Fail(1, fmt.Sprintf("Unknown operation '%s'.\n", args[0]))
+	}
+	return OK("")
+}
+
+// Docker records the images that are run on the server.
+type Docker struct {
+	mutex  sync.Mutex
+	images []string
+}
+
+// Images returns the images run so far, in order.
+func (d *Docker) Images() []string {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	return append([]string(nil), d.images...)
+}
+
+// handleDocker supports `docker run`. When a volume is mounted on /destination/,
+// a gluon binary is written into the host side of that volume, the way the gluon image does.
+func handleDocker(cmd Command) Result {
+	if len(cmd.Args) < 2 || cmd.Args[1] != "run" {
+		return Fail(1, "docker: unsupported command\n")
+	}
+	var destination, image string
+	args := cmd.Args[2:]
+	for i := 0; i < len(args); i++ {
+		a := args[i]
+		switch {
+		case a == "-v" && i+1 < len(args):
+			i++
+			parts := strings.SplitN(args[i], ":", 2)
+			if len(parts) == 2 && strings.TrimSuffix(parts[1], "/") == "/destination" {
+				destination = parts[0]
+			}
+		case strings.HasPrefix(a, "-"):
+		default:
+			if image == "" {
+				image = a
+			}
+		}
+	}
+	if image == "" {
+		return Fail(1, "docker: image name required\n")
+	}
+	cmd.Server.Docker.mutex.Lock()
+	cmd.Server.Docker.images = append(cmd.Server.Docker.images, image)
+	cmd.Server.Docker.mutex.Unlock()
+	if destination != "" {
+		cmd.Server.FS.WriteFile(path.Join(destination, "gluon"), "#!gluon "+image+"\n", os.FileMode(0755), "root")
+	}
+	return OK("")
+}
+
+// Gluon records the arguments passed to `gluon setup`.
+type Gluon struct {
+	mutex sync.Mutex
+	setup [][]string
+}
+
+// SetupArgs returns the arguments of all `gluon setup` invocations, in order.
+func (g *Gluon) SetupArgs() [][]string {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+	return append([][]string(nil), g.setup...)
+}
+
+// handleGluon supports `gluon setup`. The gluon binary must have been installed
+// (see handleDocker) and executable.
+func handleGluon(cmd Command) Result {
+	if strings.Contains(cmd.Args[0], "/") {
+		f, ok := cmd.Server.FS.ReadFile(cmd.Args[0])
+		if !ok {
+			return Fail(127, fmt.Sprintf("sh: %s: No such file or directory\n", cmd.Args[0]))
+		}
+		if f.Mode&0111 == 0 {
+			return Fail(126, fmt.Sprintf("sh: %s: Permission denied\n", cmd.Args[0]))
+		}
+	}
+	if len(cmd.Args) < 2 || cmd.Args[1] != "setup" {
+		return Fail(1, "gluon: unsupported command\n")
+	}
+	if !cmd.Root {
+		return Fail(1, "gluon: setup must be run as root\n")
+	}
+	cmd.Server.Gluon.mutex.Lock()
+	cmd.Server.Gluon.setup = append(cmd.Server.Gluon.setup, append([]string(nil), cmd.Args[2:]...))
+	cmd.Server.Gluon.mutex.Unlock()
+	return OK("")
+}
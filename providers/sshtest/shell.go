@@ -0,0 +1,190 @@
+// Copyright (c) 2016 Pulcy.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sshtest
+
+import (
+	"fmt"
+	"path"
+	"strings"
+)
+
+// word is a single element of a tokenized command line.
+type word struct {
+	Value string
+	Op    bool // Set for the operators `|`, `||`, `&&` and `;`
+	Glob  bool // Set when the word contains an unquoted `*`
+}
+
+// tokenize splits a command line into words & operators, the way `sh` would.
+// It supports single & double quotes and backslash escapes.
+func tokenize(line string) ([]word, error) {
+	var result []word
+	var current word
+	inWord := false
+	flush := func() {
+		if inWord {
+			result = append(result, current)
+		}
+		current = word{}
+		inWord = false
+	}
+	for i := 0; i < len(line); i++ {
+		c := line[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n':
+			flush()
+		case c == '|' || c == '&' || c == ';':
+			flush()
+			op := string(c)
+			if c != ';' && i+1 < len(line) && line[i+1] == c {
+				op += string(c)
+				i++
+			}
+			if op == "&" {
+				return nil, fmt.Errorf("background jobs are not supported")
+			}
+			result = append(result, word{Value: op, Op: true})
+		case c == '\'':
+			end := strings.IndexByte(line[i+1:], '\'')
+			if end < 0 {
+				return nil, fmt.Errorf("unterminated single quote")
+			}
+			current.Value += line[i+1 : i+1+end]
+			inWord = true
+			i += end + 1
+		case c == '"':
+			inWord = true
+			i++
+			for ; i < len(line) && line[i] != '"'; i++ {
+				if line[i] == '\\' && i+1 < len(line) && strings.IndexByte("\"\\$`", line[i+1]) >= 0 {
+					i++
+				}
+				current.Value += string(line[i])
+			}
+			if i >= len(line) {
+				return nil, fmt.Errorf("unterminated double quote")
+			}
+		case c == '\\' && i+1 < len(line):
+			i++
+			current.Value += string(line[i])
+			inWord = true
+		default:
+			if c == '*' {
+				current.Glob = true
+			}
+			current.Value += string(c)
+			inWord = true
+		}
+	}
+	flush()
+	return result, nil
+}
+
+// runLine executes a command line and returns its combined result.
+func (s *Server) runLine(user string, root bool, line string, stdin *input) Result {
+	words, err := tokenize(line)
+	if err != nil {
+		return Fail(2, fmt.Sprintf("sh: %v\n", err))
+	}
+
+	var result Result
+	connector := ""
+	var pipeline [][]word
+	var cmd []word
+	runPipeline := func() {
+		if len(cmd) > 0 {
+			pipeline = append(pipeline, cmd)
+		}
+		skip := (connector == "||" && result.ExitStatus == 0) || (connector == "&&" && result.ExitStatus != 0)
+		if !skip && len(pipeline) > 0 {
+			r := s.runPipeline(user, root, pipeline, stdin)
+			result.Stdout += r.Stdout
+			result.Stderr += r.Stderr
+			result.ExitStatus = r.ExitStatus
+		}
+		pipeline = nil
+		cmd = nil
+	}
+	for _, w := range words {
+		if !w.Op {
+			cmd = append(cmd, w)
+			continue
+		}
+		if w.Value == "|" {
+			pipeline = append(pipeline, cmd)
+			cmd = nil
+			continue
+		}
+		runPipeline()
+		connector = w.Value
+	}
+	runPipeline()
+	return result
+}
+
+// runPipeline executes a series of commands, passing the stdout of each command
+// to the stdin of the next.
+func (s *Server) runPipeline(user string, root bool, pipeline [][]word, stdin *input) Result {
+	var result Result
+	for _, cmd := range pipeline {
+		r := s.runCommand(user, root, s.expand(cmd), stdin)
+		stdin = stringInput(r.Stdout)
+		result.Stderr += r.Stderr
+		result.Stdout = r.Stdout
+		result.ExitStatus = r.ExitStatus
+	}
+	return result
+}
+
+// expand replaces words containing unquoted globs with the matching paths.
+func (s *Server) expand(cmd []word) []string {
+	var args []string
+	for _, w := range cmd {
+		if w.Glob {
+			if matches := s.FS.Glob(w.Value); len(matches) > 0 {
+				args = append(args, matches...)
+				continue
+			}
+		}
+		args = append(args, w.Value)
+	}
+	return args
+}
+
+// runCommand executes a single command using the registered handlers.
+func (s *Server) runCommand(user string, root bool, args []string, stdin *input) Result {
+	for len(args) > 0 && args[0] == "sudo" {
+		root = true
+		args = args[1:]
+	}
+	if len(args) == 0 {
+		return Result{}
+	}
+	if args[0] == "sh" && len(args) >= 3 && args[1] == "-c" {
+		return s.runLine(user, root, args[2], stdin)
+	}
+	name := path.Base(args[0])
+	handler, ok := s.handler(name)
+	if !ok {
+		return Fail(127, fmt.Sprintf("sh: %s: command not found\n", args[0]))
+	}
+	return handler(Command{
+		Server: s,
+		User:   user,
+		Root:   root,
+		Args:   args,
+		stdin:  stdin,
+	})
+}
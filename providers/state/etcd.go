@@ -0,0 +1,118 @@
+// Copyright (c) 2016 Pulcy.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package state
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/juju/errgo"
+	"github.com/op/go-logging"
+
+	"github.com/pulcy/quark/providers"
+)
+
+const (
+	// stateKey is the etcd key that holds the state of a cluster.
+	stateKey = "/pulcy/quark/state"
+)
+
+// etcdStore keeps the state of a cluster in the etcd of that cluster.
+// The etcd API is reached through the first instance of the cluster on which it is available.
+type etcdStore struct {
+	log      *logging.Logger
+	provider providers.CloudProvider
+}
+
+// NewEtcdStore creates a store that keeps the state of a cluster in the etcd of that cluster.
+func NewEtcdStore(log *logging.Logger, provider providers.CloudProvider) Store {
+	return &etcdStore{
+		log:      log,
+		provider: provider,
+	}
+}
+
+func (s *etcdStore) String() string {
+	return "etcd " + stateKey
+}
+
+// open returns a client for the etcd of the given cluster.
+// It returns NotFoundError if the cluster has no instances.
+func (s *etcdStore) open(ctx context.Context, info providers.ClusterInfo) (providers.EtcdClient, error) {
+	instances, err := s.provider.GetInstances(ctx, info)
+	if err != nil {
+		return nil, maskAny(err)
+	}
+	if len(instances) == 0 {
+		return nil, maskAny(errgo.WithCausef(nil, NotFoundError, "%s has no instances", info))
+	}
+	client, _, err := instances.EtcdClient(ctx, s.log)
+	if err != nil {
+		return nil, maskAny(err)
+	}
+	return client, nil
+}
+
+func (s *etcdStore) Load(ctx context.Context, info providers.ClusterInfo) (*Cluster, error) {
+	client, err := s.open(ctx, info)
+	if err != nil {
+		return nil, maskAny(err)
+	}
+	defer client.Close()
+	raw, err := client.GetKey(stateKey)
+	if errgo.Cause(err) == providers.NotFoundError {
+		return nil, maskAny(errgo.WithCausef(nil, NotFoundError, "%s has not been recorded", info))
+	} else if err != nil {
+		return nil, maskAny(err)
+	}
+	c := &Cluster{}
+	if err := json.Unmarshal([]byte(raw), c); err != nil {
+		return nil, maskAny(errgo.Notef(err, "invalid state in %s", stateKey))
+	}
+	return c, nil
+}
+
+func (s *etcdStore) Save(ctx context.Context, c *Cluster) error {
+	client, err := s.open(ctx, c.Cluster)
+	if err != nil {
+		return maskAny(err)
+	}
+	defer client.Close()
+	c.Updated = time.Now().UTC().Truncate(time.Second)
+	raw, err := json.Marshal(c)
+	if err != nil {
+		return maskAny(err)
+	}
+	if err := client.SetKey(stateKey, string(raw)); err != nil {
+		return maskAny(err)
+	}
+	return nil
+}
+
+func (s *etcdStore) Remove(ctx context.Context, info providers.ClusterInfo) error {
+	client, err := s.open(ctx, info)
+	if errgo.Cause(err) == NotFoundError {
+		// The state was removed together with the cluster
+		return nil
+	} else if err != nil {
+		return maskAny(err)
+	}
+	defer client.Close()
+	if err := client.DeleteKey(stateKey, ""); err != nil && errgo.Cause(err) != providers.NotFoundError {
+		return maskAny(err)
+	}
+	return nil
+}
@@ -0,0 +1,268 @@
+// Copyright (c) 2016 Pulcy.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package state records the clusters managed by quark: the options a cluster was created with
+// and the instances it consists of. The state is kept in a local file or in the etcd of the cluster
+// and is used as the source of defaults for later commands on the cluster.
+package state
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pulcy/quark/providers"
+)
+
+// Cluster is the recorded state of a single cluster.
+type Cluster struct {
+	Cluster   providers.ClusterInfo `json:"cluster"`
+	Provider  string                `json:"provider,omitempty"` // Name of the cloud provider that hosts the cluster
+	Created   time.Time             `json:"created"`
+	Updated   time.Time             `json:"updated"`
+	Options   Options               `json:"options"`
+	Instances []Instance            `json:"instances,omitempty"`
+}
+
+// Options are the options a cluster was created with.
+// Secrets (passwords, vault keys) are never recorded.
+type Options struct {
+	ImageID                 string   `json:"image,omitempty"`
+	RegionID                string   `json:"region,omitempty"`
+	TypeID                  string   `json:"type,omitempty"`
+	MinOSVersion            string   `json:"min-os-version,omitempty"`
+	GluonImage              string   `json:"gluon-image,omitempty"`
+	RebootStrategy          string   `json:"reboot-strategy,omitempty"`
+	PrivateRegistryUrl      string   `json:"private-registry-url,omitempty"`
+	PrivateRegistryUserName string   `json:"private-registry-username,omitempty"`
+	SSHKeyNames             []string `json:"ssh-key,omitempty"`
+	SSHKeyGithubAccount     string   `json:"ssh-key-github-account,omitempty"`
+	TincCIDR                string   `json:"tinc-cidr,omitempty"`
+	HttpProxy               string   `json:"http-proxy,omitempty"`
+	RegisterInstance        bool     `json:"register-instance,omitempty"`
+}
+
+// Instance is the recorded state of a single instance of a cluster.
+type Instance struct {
+	Name       string    `json:"name"`
+	ID         string    `json:"id,omitempty"` // Provider specific ID of the server
+	ClusterIP  string    `json:"cluster-ip,omitempty"`
+	PrivateIP  string    `json:"private-ip,omitempty"`
+	PublicIPv4 string    `json:"public-ipv4,omitempty"`
+	PublicIPv6 string    `json:"public-ipv6,omitempty"`
+	Roles      string    `json:"roles,omitempty"` // Roles of the instance (e.g. "core,lb")
+	EtcdProxy  bool      `json:"etcd-proxy,omitempty"`
+	Created    time.Time `json:"created"`
+}
+
+// New creates an empty state for the given cluster.
+func New(info providers.ClusterInfo, provider string) *Cluster {
+	now := time.Now().UTC().Truncate(time.Second)
+	return &Cluster{
+		Cluster:  info,
+		Provider: provider,
+		Created:  now,
+		Updated:  now,
+	}
+}
+
+// OptionsFromCluster returns the options to record for a cluster created with the given options.
+func OptionsFromCluster(o providers.CreateClusterOptions) Options {
+	return Options{
+		ImageID:                 o.ImageID,
+		RegionID:                o.RegionID,
+		TypeID:                  o.TypeID,
+		MinOSVersion:            o.MinOSVersion,
+		GluonImage:              o.GluonImage,
+		RebootStrategy:          o.RebootStrategy,
+		PrivateRegistryUrl:      o.PrivateRegistryUrl,
+		PrivateRegistryUserName: o.PrivateRegistryUserName,
+		SSHKeyNames:             nonEmpty(o.SSHKeyNames),
+		SSHKeyGithubAccount:     o.SSHKeyGithubAccount,
+		TincCIDR:                o.TincCIDR,
+		HttpProxy:               o.HttpProxy,
+		RegisterInstance:        o.RegisterInstance,
+	}
+}
+
+// OptionsFromInstance returns the options to record for a cluster of which an instance was created with the given options.
+func OptionsFromInstance(o providers.CreateInstanceOptions) Options {
+	return Options{
+		ImageID:                 o.ImageID,
+		RegionID:                o.RegionID,
+		TypeID:                  o.TypeID,
+		MinOSVersion:            o.MinOSVersion,
+		GluonImage:              o.GluonImage,
+		RebootStrategy:          o.RebootStrategy,
+		PrivateRegistryUrl:      o.PrivateRegistryUrl,
+		PrivateRegistryUserName: o.PrivateRegistryUserName,
+		SSHKeyNames:             nonEmpty(o.SSHKeyNames),
+		SSHKeyGithubAccount:     o.SSHKeyGithubAccount,
+		TincCIDR:                o.TincCIDR,
+		HttpProxy:               o.HttpProxy,
+		RegisterInstance:        o.RegisterInstance,
+	}
+}
+
+// IsEmpty returns true if no options have been recorded.
+func (o Options) IsEmpty() bool {
+	return o.ImageID == "" && o.RegionID == "" && o.TypeID == "" && o.GluonImage == "" && o.TincCIDR == ""
+}
+
+// Values returns all recorded options, keyed by the name of their command line option.
+// Options that have not been recorded are left out.
+func (o Options) Values() map[string]string {
+	values := map[string]string{
+		"image":                     o.ImageID,
+		"region":                    o.RegionID,
+		"type":                      o.TypeID,
+		"min-os-version":            o.MinOSVersion,
+		"gluon-image":               o.GluonImage,
+		"reboot-strategy":           o.RebootStrategy,
+		"private-registry-url":      o.PrivateRegistryUrl,
+		"private-registry-username": o.PrivateRegistryUserName,
+		"ssh-key":                   strings.Join(o.SSHKeyNames, ","),
+		"ssh-key-github-account":    o.SSHKeyGithubAccount,
+		"tinc-cidr":                 o.TincCIDR,
+		"http-proxy":                o.HttpProxy,
+	}
+	for k, v := range values {
+		if v == "" {
+			delete(values, k)
+		}
+	}
+	if !o.IsEmpty() {
+		values["register-instance"] = strconv.FormatBool(o.RegisterInstance)
+	}
+	return values
+}
+
+// NewInstance returns the state of the given instance, with its addresses as reported by the provider.
+func NewInstance(i providers.ClusterInstance) Instance {
+	result := Instance{
+		Name:    i.Name,
+		Created: time.Now().UTC().Truncate(time.Second),
+	}
+	result.setAddresses(i)
+	if i.EtcdProxy != nil {
+		result.EtcdProxy = *i.EtcdProxy
+	}
+	return result
+}
+
+// setAddresses copies the ID & addresses of the given instance.
+func (i *Instance) setAddresses(ci providers.ClusterInstance) {
+	i.ID = ci.ID
+	i.ClusterIP = ci.ClusterIP
+	i.PrivateIP = ci.PrivateIP
+	i.PublicIPv4 = ci.LoadBalancerIPv4
+	i.PublicIPv6 = ci.LoadBalancerIPv6
+}
+
+// Instance returns the recorded instance with given name.
+func (c *Cluster) Instance(name string) (Instance, bool) {
+	for _, i := range c.Instances {
+		if i.Name == name {
+			return i, true
+		}
+	}
+	return Instance{}, false
+}
+
+// SetInstance records the given instance, replacing an earlier record of the same instance.
+func (c *Cluster) SetInstance(i Instance) {
+	for index, existing := range c.Instances {
+		if existing.Name == i.Name {
+			if i.Created.IsZero() {
+				i.Created = existing.Created
+			}
+			c.Instances[index] = i
+			return
+		}
+	}
+	c.Instances = append(c.Instances, i)
+	sort.Sort(instancesByName(c.Instances))
+}
+
+// RemoveInstance removes the record of the instance with given name.
+func (c *Cluster) RemoveInstance(name string) {
+	for index, existing := range c.Instances {
+		if existing.Name == name {
+			c.Instances = append(c.Instances[:index], c.Instances[index+1:]...)
+			return
+		}
+	}
+}
+
+// Sync updates the recorded instances to match the given instances as reported by the provider.
+// The addresses of recorded instances are updated, instances that no longer exist are removed.
+// Instances that have not been recorded are described by the given function.
+func (c *Cluster) Sync(instances providers.ClusterInstanceList, describe func(providers.ClusterInstance) Instance) {
+	var result []Instance
+	for _, ci := range instances {
+		i, ok := c.Instance(ci.Name)
+		if ok {
+			i.setAddresses(ci)
+		} else {
+			i = describe(ci)
+		}
+		result = append(result, i)
+	}
+	sort.Sort(instancesByName(result))
+	c.Instances = result
+}
+
+// Drift returns a description of all differences between the recorded instances and the given
+// instances as reported by the provider.
+func (c *Cluster) Drift(instances providers.ClusterInstanceList) []string {
+	var drift []string
+	for _, ci := range instances {
+		i, ok := c.Instance(ci.Name)
+		if !ok {
+			drift = append(drift, fmt.Sprintf("instance %s exists but has not been recorded", ci.Name))
+			continue
+		}
+		if i.ClusterIP != ci.ClusterIP {
+			drift = append(drift, fmt.Sprintf("cluster IP of %s is %s, recorded %s", ci.Name, ci.ClusterIP, i.ClusterIP))
+		}
+		if i.PublicIPv4 != ci.LoadBalancerIPv4 {
+			drift = append(drift, fmt.Sprintf("public IPv4 address of %s is %s, recorded %s", ci.Name, ci.LoadBalancerIPv4, i.PublicIPv4))
+		}
+	}
+	for _, i := range c.Instances {
+		if _, err := instances.InstanceByName(i.Name); err != nil {
+			drift = append(drift, fmt.Sprintf("instance %s has been recorded but no longer exists", i.Name))
+		}
+	}
+	return drift
+}
+
+// nonEmpty returns the given list without empty elements.
+func nonEmpty(list []string) []string {
+	var result []string
+	for _, s := range list {
+		if s != "" {
+			result = append(result, s)
+		}
+	}
+	return result
+}
+
+type instancesByName []Instance
+
+func (l instancesByName) Len() int           { return len(l) }
+func (l instancesByName) Less(i, j int) bool { return l[i].Name < l[j].Name }
+func (l instancesByName) Swap(i, j int)      { l[i], l[j] = l[j], l[i] }
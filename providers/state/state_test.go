@@ -0,0 +1,162 @@
+// Copyright (c) 2016 Pulcy.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package state_test
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pulcy/quark/providers"
+	"github.com/pulcy/quark/providers/state"
+)
+
+var (
+	testInfo = providers.ClusterInfo{Name: "c1", Domain: "example.com", ID: "cid"}
+)
+
+func testInstances() providers.ClusterInstanceList {
+	return providers.ClusterInstanceList{
+		{ID: "1", Name: "i1.c1.example.com", ClusterIP: "192.168.35.1", PrivateIP: "10.1.0.1", LoadBalancerIPv4: "1.1.1.1"},
+		{ID: "2", Name: "i2.c1.example.com", ClusterIP: "192.168.35.2", PrivateIP: "10.1.0.2", LoadBalancerIPv4: "1.1.1.2"},
+	}
+}
+
+// recordedCluster returns a state in which the given instances are recorded.
+func recordedCluster(instances providers.ClusterInstanceList) *state.Cluster {
+	c := state.New(testInfo, "fake")
+	for _, i := range instances {
+		c.SetInstance(state.NewInstance(i))
+	}
+	return c
+}
+
+func TestDrift(t *testing.T) {
+	instances := testInstances()
+	c := recordedCluster(instances)
+	if drift := c.Drift(instances); len(drift) != 0 {
+		t.Errorf("Expected no drift, got %v", drift)
+	}
+
+	// Changed addresses, an instance created & one destroyed outside of quark
+	changed := providers.ClusterInstanceList{instances[0], instances[1]}
+	changed[0].ClusterIP = "192.168.35.11"
+	changed[0].LoadBalancerIPv4 = "1.1.1.11"
+	changed[1] = providers.ClusterInstance{ID: "3", Name: "i3.c1.example.com", ClusterIP: "192.168.35.3"}
+	drift := c.Drift(changed)
+	expected := []string{
+		"cluster IP of i1.c1.example.com is 192.168.35.11, recorded 192.168.35.1",
+		"public IPv4 address of i1.c1.example.com is 1.1.1.11, recorded 1.1.1.1",
+		"instance i3.c1.example.com exists but has not been recorded",
+		"instance i2.c1.example.com has been recorded but no longer exists",
+	}
+	if !reflect.DeepEqual(drift, expected) {
+		t.Errorf("Expected drift\n%s\ngot\n%s", strings.Join(expected, "\n"), strings.Join(drift, "\n"))
+	}
+
+	// A cluster without instances drifts from all its records
+	if drift := c.Drift(nil); len(drift) != 2 {
+		t.Errorf("Expected 2 removed instances, got %v", drift)
+	}
+}
+
+func TestSync(t *testing.T) {
+	instances := testInstances()
+	c := recordedCluster(instances)
+	i1, _ := c.Instance(instances[0].Name)
+	i1.Roles = "core,lb"
+	i1.Created = time.Date(2016, 11, 1, 0, 0, 0, 0, time.UTC)
+	c.SetInstance(i1)
+
+	changed := providers.ClusterInstanceList{
+		{ID: "3", Name: "i0.c1.example.com", ClusterIP: "192.168.35.3"},
+		instances[0],
+	}
+	changed[1].LoadBalancerIPv4 = "1.1.1.11"
+	var described []string
+	c.Sync(changed, func(i providers.ClusterInstance) state.Instance {
+		described = append(described, i.Name)
+		result := state.NewInstance(i)
+		result.Roles = "worker"
+		return result
+	})
+
+	if !reflect.DeepEqual(described, []string{"i0.c1.example.com"}) {
+		t.Errorf("Expected only the new instance to be described, got %v", described)
+	}
+	if len(c.Instances) != 2 || c.Instances[0].Name != "i0.c1.example.com" || c.Instances[1].Name != "i1.c1.example.com" {
+		t.Fatalf("Expected i0 & i1 sorted by name, got %#v", c.Instances)
+	}
+	if i := c.Instances[1]; i.PublicIPv4 != "1.1.1.11" || i.Roles != "core,lb" || !i.Created.Equal(i1.Created) {
+		t.Errorf("Expected updated address with recorded roles & creation time, got %#v", i)
+	}
+	if i := c.Instances[0]; i.Roles != "worker" || i.ClusterIP != "192.168.35.3" {
+		t.Errorf("Expected the described instance, got %#v", i)
+	}
+	if drift := c.Drift(changed); len(drift) != 0 {
+		t.Errorf("Expected no drift after sync, got %v", drift)
+	}
+}
+
+func TestSetInstance(t *testing.T) {
+	c := state.New(testInfo, "fake")
+	created := time.Date(2016, 11, 1, 0, 0, 0, 0, time.UTC)
+	c.SetInstance(state.Instance{Name: "i2", Created: created})
+	c.SetInstance(state.Instance{Name: "i1"})
+	c.SetInstance(state.Instance{Name: "i2", Roles: "core"})
+	if len(c.Instances) != 2 || c.Instances[0].Name != "i1" || c.Instances[1].Name != "i2" {
+		t.Fatalf("Expected i1 & i2, got %#v", c.Instances)
+	}
+	if i, ok := c.Instance("i2"); !ok || i.Roles != "core" || !i.Created.Equal(created) {
+		t.Errorf("Expected the replaced i2 with its creation time, got %#v", i)
+	}
+	c.RemoveInstance("i1")
+	c.RemoveInstance("unknown")
+	if _, ok := c.Instance("i1"); ok || len(c.Instances) != 1 {
+		t.Errorf("Expected i1 to be removed, got %#v", c.Instances)
+	}
+}
+
+func TestOptionsValues(t *testing.T) {
+	o := state.OptionsFromCluster(providers.CreateClusterOptions{
+		InstanceConfig:          providers.InstanceConfig{ImageID: "coreos-stable", RegionID: "ams3", TypeID: "2gb", MinOSVersion: "1122.2.0"},
+		GluonImage:              "pulcy/gluon:test",
+		PrivateRegistryUrl:      "registry.example.com",
+		PrivateRegistryUserName: "admin",
+		PrivateRegistryPassword: "s3cret",
+		SSHKeyNames:             []string{"", "alice"},
+		WeavePassword:           "weave-s3cret",
+	})
+	expected := map[string]string{
+		"image":                     "coreos-stable",
+		"region":                    "ams3",
+		"type":                      "2gb",
+		"min-os-version":            "1122.2.0",
+		"gluon-image":               "pulcy/gluon:test",
+		"private-registry-url":      "registry.example.com",
+		"private-registry-username": "admin",
+		"ssh-key":                   "alice",
+		"register-instance":         "false",
+	}
+	if values := o.Values(); !reflect.DeepEqual(values, expected) {
+		t.Errorf("Expected %v, got %v", expected, values)
+	}
+
+	// Nothing recorded, nothing to use as default
+	if values := (state.Options{}).Values(); len(values) != 0 {
+		t.Errorf("Expected no values, got %v", values)
+	}
+}
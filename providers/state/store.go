@@ -0,0 +1,107 @@
+// Copyright (c) 2016 Pulcy.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package state
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/juju/errgo"
+
+	"github.com/pulcy/quark/providers"
+)
+
+const (
+	stateFileMode = os.FileMode(0600)
+)
+
+// Store keeps the recorded state of clusters.
+type Store interface {
+	fmt.Stringer
+	// Load returns the recorded state of the given cluster.
+	// It returns NotFoundError if the cluster has not been recorded.
+	Load(ctx context.Context, info providers.ClusterInfo) (*Cluster, error)
+	// Save records the given state.
+	Save(ctx context.Context, c *Cluster) error
+	// Remove removes the recorded state of the given cluster (if any).
+	Remove(ctx context.Context, info providers.ClusterInfo) error
+}
+
+// fileStore keeps the state of every cluster in a JSON file in a local directory.
+type fileStore struct {
+	dir string
+}
+
+// NewFileStore creates a store that keeps the state of every cluster in a file in the given directory.
+func NewFileStore(dir string) Store {
+	return &fileStore{dir: dir}
+}
+
+func (s *fileStore) String() string {
+	return s.dir
+}
+
+// path returns the path of the state file of the given cluster.
+func (s *fileStore) path(info providers.ClusterInfo) string {
+	return filepath.Join(s.dir, info.String()+".json")
+}
+
+func (s *fileStore) Load(ctx context.Context, info providers.ClusterInfo) (*Cluster, error) {
+	path := s.path(info)
+	raw, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, maskAny(errgo.WithCausef(nil, NotFoundError, "%s has not been recorded", info))
+	} else if err != nil {
+		return nil, maskAny(err)
+	}
+	c := &Cluster{}
+	if err := json.Unmarshal(raw, c); err != nil {
+		return nil, maskAny(errgo.Notef(err, "invalid state in %s", path))
+	}
+	return c, nil
+}
+
+func (s *fileStore) Save(ctx context.Context, c *Cluster) error {
+	c.Updated = time.Now().UTC().Truncate(time.Second)
+	raw, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return maskAny(err)
+	}
+	if err := os.MkdirAll(s.dir, 0755); err != nil {
+		return maskAny(err)
+	}
+	// Write to a temporary file first, so an interrupted write never leaves a corrupt state behind
+	path := s.path(c.Cluster)
+	tmpPath := path + ".tmp"
+	if err := ioutil.WriteFile(tmpPath, raw, stateFileMode); err != nil {
+		return maskAny(err)
+	}
+	if err := os.Rename(tmpPath, path); err != nil {
+		return maskAny(err)
+	}
+	return nil
+}
+
+func (s *fileStore) Remove(ctx context.Context, info providers.ClusterInfo) error {
+	if err := os.Remove(s.path(info)); err != nil && !os.IsNotExist(err) {
+		return maskAny(err)
+	}
+	return nil
+}
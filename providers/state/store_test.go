@@ -0,0 +1,126 @@
+// Copyright (c) 2016 Pulcy.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package state_test
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/juju/errgo"
+	"github.com/op/go-logging"
+
+	"github.com/pulcy/quark/providers"
+	"github.com/pulcy/quark/providers/sshtest"
+	"github.com/pulcy/quark/providers/state"
+)
+
+var (
+	testLog = logging.MustGetLogger("state-test")
+)
+
+// testStore saves, loads & removes the state of a cluster in the given store.
+func testStore(t *testing.T, store state.Store) {
+	ctx := context.Background()
+	if _, err := store.Load(ctx, testInfo); errgo.Cause(err) != state.NotFoundError {
+		t.Fatalf("Expected NotFoundError before saving, got %v", err)
+	}
+	c := recordedCluster(testInstances())
+	c.Options.GluonImage = "pulcy/gluon:test"
+	if err := store.Save(ctx, c); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+	loaded, err := store.Load(ctx, testInfo)
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if loaded.Cluster != testInfo || loaded.Provider != "fake" || loaded.Options.GluonImage != "pulcy/gluon:test" || len(loaded.Instances) != 2 {
+		t.Errorf("Expected the saved state, got %#v", loaded)
+	}
+	if drift := loaded.Drift(testInstances()); len(drift) != 0 {
+		t.Errorf("Expected no drift of the loaded state, got %v", drift)
+	}
+	if err := store.Remove(ctx, testInfo); err != nil {
+		t.Fatalf("Remove failed: %v", err)
+	}
+	if _, err := store.Load(ctx, testInfo); errgo.Cause(err) != state.NotFoundError {
+		t.Errorf("Expected NotFoundError after removal, got %v", err)
+	}
+	if err := store.Remove(ctx, testInfo); err != nil {
+		t.Errorf("Expected removing a removed state to succeed, got %v", err)
+	}
+}
+
+func TestFileStore(t *testing.T) {
+	dir, err := ioutil.TempDir("", "quark-state")
+	if err != nil {
+		t.Fatalf("Cannot create temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	stateDir := filepath.Join(dir, "state")
+	testStore(t, state.NewFileStore(stateDir))
+
+	// The state is only readable by its owner
+	store := state.NewFileStore(stateDir)
+	if err := store.Save(context.Background(), recordedCluster(testInstances())); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+	path := filepath.Join(stateDir, testInfo.String()+".json")
+	if fi, err := os.Stat(path); err != nil || fi.Mode().Perm() != 0600 {
+		t.Errorf("Expected state file with mode 0600, got %v", err)
+	}
+
+	// A corrupt state is an error, not a missing state
+	if err := ioutil.WriteFile(path, []byte("{"), 0600); err != nil {
+		t.Fatalf("Cannot write state: %v", err)
+	}
+	if _, err := store.Load(context.Background(), testInfo); err == nil || errgo.Cause(err) == state.NotFoundError {
+		t.Errorf("Expected an invalid state error, got %v", err)
+	}
+}
+
+// instancesProvider is a cloud provider that reports a fixed list of instances.
+type instancesProvider struct {
+	providers.CloudProvider
+	instances providers.ClusterInstanceList
+}
+
+func (p instancesProvider) GetInstances(ctx context.Context, info providers.ClusterInfo) (providers.ClusterInstanceList, error) {
+	return p.instances, nil
+}
+
+func TestEtcdStore(t *testing.T) {
+	s, err := sshtest.NewServer("")
+	if err != nil {
+		t.Fatalf("NewServer failed: %v", err)
+	}
+	defer s.Close()
+	instances := testInstances()[:1]
+	defer sshtest.Install(map[string]*sshtest.Server{instances[0].String(): s})()
+	defer providers.CloseSSHConnections()
+
+	testStore(t, state.NewEtcdStore(testLog, instancesProvider{instances: instances}))
+
+	// Without instances, there is no etcd to keep the state in
+	store := state.NewEtcdStore(testLog, instancesProvider{})
+	if _, err := store.Load(context.Background(), testInfo); errgo.Cause(err) != state.NotFoundError {
+		t.Errorf("Expected NotFoundError without instances, got %v", err)
+	}
+	if err := store.Remove(context.Background(), testInfo); err != nil {
+		t.Errorf("Expected removal without instances to succeed, got %v", err)
+	}
+}
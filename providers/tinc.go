@@ -15,6 +15,7 @@
 package providers
 
 import (
+	"context"
 	"fmt"
 	"path"
 	"strings"
@@ -26,7 +27,7 @@ import (
 )
 
 // ReconfigureTincCluster creates the tinc configuration on all given instances.
-func (instances ClusterInstanceList) ReconfigureTincCluster(log *logging.Logger, newInstances ClusterInstanceList) error {
+func (instances ClusterInstanceList) ReconfigureTincCluster(ctx context.Context, log *logging.Logger, newInstances ClusterInstanceList) error {
 	// Now update all members in parallel
 	vpnName := "pulcy"
 	wg := sync.WaitGroup{}
@@ -36,11 +37,11 @@ func (instances ClusterInstanceList) ReconfigureTincCluster(log *logging.Logger,
 		go func(i ClusterInstance) {
 			defer wg.Done()
 			if newInstances.Contains(i) {
-				if err := configureTincHost(log, i, vpnName, instances); err != nil {
+				if err := configureTincHost(ctx, log, i, vpnName, instances); err != nil {
 					errorChannel <- maskAny(err)
 				}
 			} else {
-				if err := reconfigureTincConf(log, i, vpnName, instances); err != nil {
+				if err := reconfigureTincConf(ctx, log, i, vpnName, instances); err != nil {
 					errorChannel <- maskAny(err)
 				}
 			}
@@ -56,7 +57,7 @@ func (instances ClusterInstanceList) ReconfigureTincCluster(log *logging.Logger,
 	for _, i := range instances {
 		i := i
 		g.Go(func() error {
-			if err := cleanupHostsFolder(log, i, vpnName); err != nil {
+			if err := cleanupHostsFolder(ctx, log, i, vpnName); err != nil {
 				return maskAny(err)
 			}
 			return nil
@@ -70,7 +71,7 @@ func (instances ClusterInstanceList) ReconfigureTincCluster(log *logging.Logger,
 	for _, i := range instances {
 		i := i
 		g.Go(func() error {
-			if err := distributeTincHosts(log, i, vpnName, instances); err != nil {
+			if err := distributeTincHosts(ctx, log, i, vpnName, instances); err != nil {
 				return maskAny(err)
 			}
 			return nil
@@ -83,21 +84,23 @@ func (instances ClusterInstanceList) ReconfigureTincCluster(log *logging.Logger,
 	for _, i := range instances {
 		// Restart tinc one after another
 		if !newInstances.Contains(i) {
-			if err := reloadTinc(log, i); err != nil {
+			if err := reloadTinc(ctx, log, i); err != nil {
 				return maskAny(err)
 			}
 		} else {
-			if err := restartTinc(log, i); err != nil {
+			if err := restartTinc(ctx, log, i); err != nil {
+				return maskAny(err)
+			}
+			if err := Sleep(ctx, time.Second*5); err != nil {
 				return maskAny(err)
 			}
-			time.Sleep(time.Second * 5)
 		}
 	}
 
 	return nil
 }
 
-func cleanupHostsFolder(log *logging.Logger, i ClusterInstance, vpnName string) error {
+func cleanupHostsFolder(ctx context.Context, log *logging.Logger, i ClusterInstance, vpnName string) error {
 	s, err := i.Connect()
 	if err != nil {
 		return maskAny(err)
@@ -105,29 +108,29 @@ func cleanupHostsFolder(log *logging.Logger, i ClusterInstance, vpnName string)
 	defer s.Close()
 
 	confDir := path.Join("/etc/tinc", vpnName)
-	if _, err := s.Run(log, fmt.Sprintf("sudo cp -f %s/hosts/%s %s/self", confDir, tincName(i), confDir), "", false); err != nil {
+	if _, err := s.Run(ctx, log, fmt.Sprintf("sudo cp -f %s/hosts/%s %s/self", confDir, tincName(i), confDir), "", false); err != nil {
 		return maskAny(err)
 	}
-	if _, err := s.Run(log, fmt.Sprintf("sudo rm -f %s/hosts/*", confDir), "", false); err != nil {
+	if _, err := s.Run(ctx, log, fmt.Sprintf("sudo rm -f %s/hosts/*", confDir), "", false); err != nil {
 		return maskAny(err)
 	}
-	if _, err := s.Run(log, fmt.Sprintf("sudo cp -f %s/self %s/hosts/%s", confDir, confDir, tincName(i)), "", false); err != nil {
+	if _, err := s.Run(ctx, log, fmt.Sprintf("sudo cp -f %s/self %s/hosts/%s", confDir, confDir, tincName(i)), "", false); err != nil {
 		return maskAny(err)
 	}
 	return nil
 }
 
-func configureTincHost(log *logging.Logger, i ClusterInstance, vpnName string, instances ClusterInstanceList) error {
-	if err := reconfigureTincConf(log, i, vpnName, instances); err != nil {
+func configureTincHost(ctx context.Context, log *logging.Logger, i ClusterInstance, vpnName string, instances ClusterInstanceList) error {
+	if err := reconfigureTincConf(ctx, log, i, vpnName, instances); err != nil {
 		return maskAny(err)
 	}
-	if err := createTincHostsConf(log, i, vpnName); err != nil {
+	if err := createTincHostsConf(ctx, log, i, vpnName); err != nil {
 		return maskAny(err)
 	}
-	if err := createTincScripts(log, i, vpnName); err != nil {
+	if err := createTincScripts(ctx, log, i, vpnName); err != nil {
 		return maskAny(err)
 	}
-	if err := createTincService(log, i, vpnName); err != nil {
+	if err := createTincService(ctx, log, i, vpnName); err != nil {
 		return maskAny(err)
 	}
 	//Create key
@@ -136,13 +139,13 @@ func configureTincHost(log *logging.Logger, i ClusterInstance, vpnName string, i
 		return maskAny(err)
 	}
 	defer s.Close()
-	if _, err := s.Run(log, fmt.Sprintf("sudo tincd -n %s -K", vpnName), "", false); err != nil {
+	if _, err := s.Run(ctx, log, fmt.Sprintf("sudo tincd -n %s -K", vpnName), "", false); err != nil {
 		return maskAny(err)
 	}
 	return nil
 }
 
-func reconfigureTincConf(log *logging.Logger, i ClusterInstance, vpnName string, instances ClusterInstanceList) error {
+func reconfigureTincConf(ctx context.Context, log *logging.Logger, i ClusterInstance, vpnName string, instances ClusterInstanceList) error {
 	log.Debugf("reconfigure tinc on %s", i)
 	connectTo := []string{}
 	for _, x := range instances {
@@ -150,36 +153,36 @@ func reconfigureTincConf(log *logging.Logger, i ClusterInstance, vpnName string,
 			connectTo = append(connectTo, tincName(x))
 		}
 	}
-	if err := createTincService(log, i, vpnName); err != nil {
+	if err := createTincService(ctx, log, i, vpnName); err != nil {
 		return maskAny(err)
 	}
-	if err := createTincConf(log, i, vpnName, connectTo); err != nil {
+	if err := createTincConf(ctx, log, i, vpnName, connectTo); err != nil {
 		return maskAny(err)
 	}
 	return nil
 }
 
-func reloadTinc(log *logging.Logger, i ClusterInstance) error {
+func reloadTinc(ctx context.Context, log *logging.Logger, i ClusterInstance) error {
 	// Reload config
 	s, err := i.Connect()
 	if err != nil {
 		return maskAny(err)
 	}
 	defer s.Close()
-	if _, err := s.Run(log, "sudo pkill -HUP tincd", "", false); err != nil {
+	if _, err := s.Run(ctx, log, "sudo pkill -HUP tincd", "", false); err != nil {
 		return maskAny(err)
 	}
 	return nil
 }
 
-func restartTinc(log *logging.Logger, i ClusterInstance) error {
+func restartTinc(ctx context.Context, log *logging.Logger, i ClusterInstance) error {
 	s, err := i.Connect()
 	if err != nil {
 		return maskAny(err)
 	}
 	defer s.Close()
 	log.Infof("Starting tinc on %s", i)
-	if _, err := s.Run(log, "sudo systemctl restart tinc.service", "", false); err != nil {
+	if _, err := s.Run(ctx, log, "sudo systemctl restart tinc.service", "", false); err != nil {
 		return maskAny(err)
 	}
 	return nil
@@ -190,15 +193,15 @@ func tincName(i ClusterInstance) string {
 	return strings.Replace(strings.Replace(i.Name, ".", "_", -1), "-", "_", -1)
 }
 
-func distributeTincHosts(log *logging.Logger, i ClusterInstance, vpnName string, instances ClusterInstanceList) error {
-	conf, err := getTincHostsConf(log, i, vpnName)
+func distributeTincHosts(ctx context.Context, log *logging.Logger, i ClusterInstance, vpnName string, instances ClusterInstanceList) error {
+	conf, err := getTincHostsConf(ctx, log, i, vpnName)
 	if err != nil {
 		return maskAny(err)
 	}
 	tincName := tincName(i)
 	for _, x := range instances {
 		if x.Name != i.Name {
-			err := setTincHostsConf(log, x, vpnName, tincName, conf)
+			err := setTincHostsConf(ctx, log, x, vpnName, tincName, conf)
 			if err != nil {
 				return maskAny(err)
 			}
@@ -208,7 +211,7 @@ func distributeTincHosts(log *logging.Logger, i ClusterInstance, vpnName string,
 }
 
 // createTincConf creates a tinc.conf for the host of the given instance
-func createTincConf(log *logging.Logger, i ClusterInstance, vpnName string, connectTo []string) error {
+func createTincConf(ctx context.Context, log *logging.Logger, i ClusterInstance, vpnName string, connectTo []string) error {
 	lines := []string{
 		fmt.Sprintf("Name = %s", tincName(i)),
 		"AddressFamily = ipv4",
@@ -226,19 +229,15 @@ func createTincConf(log *logging.Logger, i ClusterInstance, vpnName string, conn
 	}
 	defer s.Close()
 
-	confDir := path.Join("/etc/tinc", vpnName)
-	confPath := path.Join(confDir, "tinc.conf")
-	if _, err := s.Run(log, fmt.Sprintf("sudo mkdir -p %s", confDir), "", false); err != nil {
-		return maskAny(err)
-	}
-	if _, err := s.Run(log, fmt.Sprintf("sudo tee %s", confPath), strings.Join(lines, "\n"), false); err != nil {
+	confPath := path.Join("/etc/tinc", vpnName, "tinc.conf")
+	if err := s.WriteFile(ctx, log, confPath, strings.Join(lines, "\n"), 0644, ""); err != nil {
 		return maskAny(err)
 	}
 	return nil
 }
 
 // createTincHostsConf creates a /etc/tinc/<vpnName>/hosts/<hostName> for the host of the given instance
-func createTincHostsConf(log *logging.Logger, i ClusterInstance, vpnName string) error {
+func createTincHostsConf(ctx context.Context, log *logging.Logger, i ClusterInstance, vpnName string) error {
 	address := i.PrivateIP
 	lines := []string{
 		fmt.Sprintf("Address = %s", address),
@@ -254,19 +253,15 @@ func createTincHostsConf(log *logging.Logger, i ClusterInstance, vpnName string)
 	}
 	defer s.Close()
 
-	confDir := path.Join("/etc/tinc", vpnName, "hosts")
-	confPath := path.Join(confDir, tincName(i))
-	if _, err := s.Run(log, fmt.Sprintf("sudo mkdir -p %s", confDir), "", false); err != nil {
-		return maskAny(err)
-	}
-	if _, err := s.Run(log, fmt.Sprintf("sudo tee %s", confPath), strings.Join(lines, "\n"), false); err != nil {
+	confPath := path.Join("/etc/tinc", vpnName, "hosts", tincName(i))
+	if err := s.WriteFile(ctx, log, confPath, strings.Join(lines, "\n"), 0644, ""); err != nil {
 		return maskAny(err)
 	}
 	return nil
 }
 
 // createTincScripts creates a /etc/tinc/<vpnName>/tinc-up|down for the host of the given instance
-func createTincScripts(log *logging.Logger, i ClusterInstance, vpnName string) error {
+func createTincScripts(ctx context.Context, log *logging.Logger, i ClusterInstance, vpnName string) error {
 	upLines := []string{
 		"#!/bin/sh",
 		fmt.Sprintf("ifconfig $INTERFACE %s netmask 255.255.255.0", i.ClusterIP),
@@ -294,23 +289,17 @@ func createTincScripts(log *logging.Logger, i ClusterInstance, vpnName string) e
 	confDir := path.Join("/etc/tinc", vpnName)
 	upPath := path.Join(confDir, "tinc-up")
 	downPath := path.Join(confDir, "tinc-down")
-	if _, err := s.Run(log, fmt.Sprintf("sudo mkdir -p %s", confDir), "", false); err != nil {
-		return maskAny(err)
-	}
-	if _, err := s.Run(log, fmt.Sprintf("sudo tee %s", upPath), strings.Join(upLines, "\n"), false); err != nil {
-		return maskAny(err)
-	}
-	if _, err := s.Run(log, fmt.Sprintf("sudo tee %s", downPath), strings.Join(downLines, "\n"), false); err != nil {
+	if err := s.WriteFile(ctx, log, upPath, strings.Join(upLines, "\n"), 0755, ""); err != nil {
 		return maskAny(err)
 	}
-	if _, err := s.Run(log, fmt.Sprintf("sudo chmod 755 %s %s", upPath, downPath), "", false); err != nil {
+	if err := s.WriteFile(ctx, log, downPath, strings.Join(downLines, "\n"), 0755, ""); err != nil {
 		return maskAny(err)
 	}
 	return nil
 }
 
 // getTincHostsConf reads a /etc/tinc/<vpnName>/hosts/<hostName> for the host of the given instance
-func getTincHostsConf(log *logging.Logger, i ClusterInstance, vpnName string) (string, error) {
+func getTincHostsConf(ctx context.Context, log *logging.Logger, i ClusterInstance, vpnName string) (string, error) {
 	s, err := i.Connect()
 	if err != nil {
 		return "", maskAny(err)
@@ -319,7 +308,7 @@ func getTincHostsConf(log *logging.Logger, i ClusterInstance, vpnName string) (s
 
 	confDir := path.Join("/etc/tinc", vpnName, "hosts")
 	confPath := path.Join(confDir, tincName(i))
-	content, err := s.Run(log, "cat "+confPath, "", false)
+	content, err := s.Run(ctx, log, "cat "+confPath, "", false)
 	if err != nil {
 		return "", maskAny(err)
 	}
@@ -327,26 +316,22 @@ func getTincHostsConf(log *logging.Logger, i ClusterInstance, vpnName string) (s
 }
 
 // setTincHostsConf creates a /etc/tinc/<vpnName>/hosts/<hostName> from the given content
-func setTincHostsConf(log *logging.Logger, i ClusterInstance, vpnName, tincName, content string) error {
+func setTincHostsConf(ctx context.Context, log *logging.Logger, i ClusterInstance, vpnName, tincName, content string) error {
 	s, err := i.Connect()
 	if err != nil {
 		return maskAny(err)
 	}
 	defer s.Close()
 
-	confDir := path.Join("/etc/tinc", vpnName, "hosts")
-	confPath := path.Join(confDir, tincName)
-	if _, err := s.Run(log, fmt.Sprintf("sudo mkdir -p %s", confDir), "", false); err != nil {
-		return maskAny(err)
-	}
-	if _, err := s.Run(log, fmt.Sprintf("sudo tee %s", confPath), content, false); err != nil {
+	confPath := path.Join("/etc/tinc", vpnName, "hosts", tincName)
+	if err := s.WriteFile(ctx, log, confPath, content, 0644, ""); err != nil {
 		return maskAny(err)
 	}
 	return nil
 }
 
 // createTincService creates /etc/systemd/system/tinc.service on the given instance
-func createTincService(log *logging.Logger, i ClusterInstance, vpnName string) error {
+func createTincService(ctx context.Context, log *logging.Logger, i ClusterInstance, vpnName string) error {
 	lines := []string{
 		"[Unit]",
 		fmt.Sprintf("Description=tinc for network %s", vpnName),
@@ -373,13 +358,13 @@ func createTincService(log *logging.Logger, i ClusterInstance, vpnName string) e
 	defer s.Close()
 
 	confPath := "/etc/systemd/system/tinc.service"
-	if _, err := s.Run(log, fmt.Sprintf("sudo tee %s", confPath), strings.Join(lines, "\n"), false); err != nil {
+	if err := s.WriteFile(ctx, log, confPath, strings.Join(lines, "\n"), 0644, ""); err != nil {
 		return maskAny(err)
 	}
-	if _, err := s.Run(log, "sudo systemctl daemon-reload", "", false); err != nil {
+	if _, err := s.Run(ctx, log, "sudo systemctl daemon-reload", "", false); err != nil {
 		return maskAny(err)
 	}
-	if _, err := s.Run(log, "sudo systemctl enable tinc.service", "", false); err != nil {
+	if _, err := s.Run(ctx, log, "sudo systemctl enable tinc.service", "", false); err != nil {
 		return maskAny(err)
 	}
 	return nil
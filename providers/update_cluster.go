@@ -15,27 +15,28 @@
 package providers
 
 import (
+	"context"
 	"sync"
 
 	"github.com/op/go-logging"
 )
 
 // UpdateClusterMembers updates /etc/cluster-members on all instances of the cluster
-func UpdateClusterMembers(log *logging.Logger, info ClusterInfo, rebootAfter bool, isEtcdProxy func(ClusterInstance) (bool, error), provider CloudProvider) error {
+func UpdateClusterMembers(ctx context.Context, log *logging.Logger, info ClusterInfo, rebootAfter bool, isEtcdProxy func(ClusterInstance) (bool, error), provider CloudProvider) error {
 	// Load all instances
-	instances, err := provider.GetInstances(info)
+	instances, err := provider.GetInstances(ctx, info)
 	if err != nil {
 		return maskAny(err)
 	}
 
 	// Load cluster-members data
-	clusterMembers, err := instances.AsClusterMemberList(log, isEtcdProxy)
+	clusterMembers, err := instances.AsClusterMemberList(ctx, log, isEtcdProxy)
 	if err != nil {
 		return maskAny(err)
 	}
 
 	// Call update-member on all instances
-	if instances.UpdateClusterMembers(log, clusterMembers, rebootAfter, provider); err != nil {
+	if err := instances.UpdateClusterMembers(ctx, log, clusterMembers, rebootAfter, provider); err != nil {
 		return maskAny(err)
 	}
 
@@ -43,7 +44,7 @@ func UpdateClusterMembers(log *logging.Logger, info ClusterInfo, rebootAfter boo
 }
 
 // UpdateClusterMembers updates /etc/cluster-members on all instances of the cluster
-func (instances ClusterInstanceList) UpdateClusterMembers(log *logging.Logger, clusterMembers ClusterMemberList, rebootAfter bool, provider CloudProvider) error {
+func (instances ClusterInstanceList) UpdateClusterMembers(ctx context.Context, log *logging.Logger, clusterMembers ClusterMemberList, rebootAfter bool, provider CloudProvider) error {
 	// Now update all members in parallel
 	wg := sync.WaitGroup{}
 	errorChannel := make(chan error, len(instances))
@@ -51,11 +52,11 @@ func (instances ClusterInstanceList) UpdateClusterMembers(log *logging.Logger, c
 		wg.Add(1)
 		go func(i ClusterInstance) {
 			defer wg.Done()
-			if err := i.UpdateClusterMembers(log, clusterMembers); err != nil {
+			if err := i.UpdateClusterMembers(ctx, log, clusterMembers); err != nil {
 				errorChannel <- maskAny(err)
 			}
 			if rebootAfter {
-				if err := provider.RebootInstance(i); err != nil {
+				if err := provider.RebootInstance(ctx, i); err != nil {
 					errorChannel <- maskAny(err)
 				}
 			}
@@ -15,6 +15,7 @@
 package vagrant
 
 import (
+	"context"
 	"fmt"
 	"io/ioutil"
 	"os"
@@ -60,30 +61,33 @@ func NewProvider(logger *logging.Logger, folder string) providers.CloudProvider
 	}
 }
 
-func (vp *vagrantProvider) ShowInstanceTypes() error {
-	return maskAny(NotImplementedError)
+func (vp *vagrantProvider) GetInstanceTypes(ctx context.Context) (providers.InstanceTypeList, error) {
+	return nil, maskAny(NotImplementedError)
 }
 
-func (vp *vagrantProvider) ShowRegions() error {
-	return maskAny(NotImplementedError)
+func (vp *vagrantProvider) GetRegions(ctx context.Context) (providers.RegionList, error) {
+	return nil, maskAny(NotImplementedError)
 }
 
-func (vp *vagrantProvider) ShowImages() error {
-	fmt.Printf("Images\n%s\n", strings.Join(images, "\n"))
-	return nil
+func (vp *vagrantProvider) GetImages(ctx context.Context) (providers.ImageList, error) {
+	result := providers.ImageList{}
+	for _, id := range images {
+		result = append(result, providers.Image{ID: id, Name: id})
+	}
+	return result, nil
 }
 
-func (vp *vagrantProvider) ShowKeys() error {
-	return maskAny(NotImplementedError)
+func (vp *vagrantProvider) GetKeys(ctx context.Context) (providers.SSHKeyList, error) {
+	return nil, maskAny(NotImplementedError)
 }
 
 // Create a machine instance
-func (vp *vagrantProvider) CreateInstance(log *logging.Logger, options providers.CreateInstanceOptions, dnsProvider providers.DnsProvider) (providers.ClusterInstance, error) {
+func (vp *vagrantProvider) CreateInstance(ctx context.Context, log *logging.Logger, options providers.CreateInstanceOptions, dnsProvider providers.DnsProvider) (providers.ClusterInstance, error) {
 	return providers.ClusterInstance{}, maskAny(NotImplementedError)
 }
 
 // Create an entire cluster
-func (vp *vagrantProvider) CreateCluster(log *logging.Logger, options providers.CreateClusterOptions, dnsProvider providers.DnsProvider) error {
+func (vp *vagrantProvider) CreateCluster(ctx context.Context, log *logging.Logger, options providers.CreateClusterOptions, dnsProvider providers.DnsProvider) error {
 	// Ensure folder exists
 	if err := os.MkdirAll(vp.folder, fileMode|os.ModeDir); err != nil {
 		return maskAny(err)
@@ -168,11 +172,11 @@ func (vp *vagrantProvider) CreateCluster(log *logging.Logger, options providers.
 	}
 
 	// Run initial setup
-	instances, err := vp.GetInstances(providers.ClusterInfo{})
+	instances, err := vp.GetInstances(ctx, providers.ClusterInfo{})
 	if err != nil {
 		return maskAny(err)
 	}
-	clusterMembers, err := instances.AsClusterMemberList(log, nil)
+	clusterMembers, err := instances.AsClusterMemberList(ctx, log, nil)
 	if err != nil {
 		return maskAny(err)
 	}
@@ -181,7 +185,7 @@ func (vp *vagrantProvider) CreateCluster(log *logging.Logger, options providers.
 			ClusterMembers: clusterMembers,
 			FleetMetadata:  instanceOptions.CreateFleetMetadata(index),
 		}
-		if err := instance.InitialSetup(log, instanceOptions, iso, vp); err != nil {
+		if err := instance.InitialSetup(ctx, log, instanceOptions, iso, vp); err != nil {
 			return maskAny(err)
 		}
 	}
@@ -190,12 +194,14 @@ func (vp *vagrantProvider) CreateCluster(log *logging.Logger, options providers.
 }
 
 // Get names of instances of a cluster
-func (vp *vagrantProvider) GetInstances(info_ providers.ClusterInfo) (providers.ClusterInstanceList, error) {
+func (vp *vagrantProvider) GetInstances(ctx context.Context, info_ providers.ClusterInfo) (providers.ClusterInstanceList, error) {
 	if _, err := os.Stat(filepath.Join(vp.folder, ".vagrant")); os.IsNotExist(err) {
 		// Cluster does not exist
 		return nil, nil
 	}
 
+	// The insecure key is added to all instances (see CreateCluster)
+	keyFile := vagrantInsecureSSHKeyFile()
 	instances := providers.ClusterInstanceList{}
 	for i := 1; i <= vp.instanceCount; i++ {
 		instances = append(instances, providers.ClusterInstance{
@@ -206,13 +212,14 @@ func (vp *vagrantProvider) GetInstances(info_ providers.ClusterInfo) (providers.
 			LoadBalancerIPv6: "",
 			ClusterDevice:    privateClusterDevice,
 			OS:               providers.OSNameCoreOS,
+			SSHKeyFile:       keyFile,
 		})
 	}
 	return instances, nil
 }
 
 // Remove all instances of a cluster
-func (vp *vagrantProvider) DeleteCluster(info providers.ClusterInfo, dnsProvider providers.DnsProvider) error {
+func (vp *vagrantProvider) DeleteCluster(ctx context.Context, info providers.ClusterInfo, dnsProvider providers.DnsProvider) error {
 	// Start
 	cmd := exec.Command("vagrant", "destroy", "-f")
 	cmd.Dir = vp.folder
@@ -228,23 +235,35 @@ func (vp *vagrantProvider) DeleteCluster(info providers.ClusterInfo, dnsProvider
 	return nil
 }
 
-func (vp *vagrantProvider) DeleteInstance(info providers.ClusterInstanceInfo, dnsProvider providers.DnsProvider) error {
+func (vp *vagrantProvider) DeleteInstance(ctx context.Context, info providers.ClusterInstanceInfo, dnsProvider providers.DnsProvider) error {
 	return maskAny(NotImplementedError)
 }
 
-func (vp *vagrantProvider) ShowDomainRecords(domain string) error {
-	return maskAny(NotImplementedError)
+func (vp *vagrantProvider) GetDomainRecords(ctx context.Context, domain string) (providers.DnsRecordList, error) {
+	return nil, maskAny(NotImplementedError)
 }
 
 // Perform a reboot of the given instance
-func (vp *vagrantProvider) RebootInstance(instance providers.ClusterInstance) error {
+func (vp *vagrantProvider) RebootInstance(ctx context.Context, instance providers.ClusterInstance) error {
 	s, err := instance.Connect()
 	if err != nil {
 		return maskAny(err)
 	}
 	defer s.Close()
-	if _, err := s.Exec(vp.Logger, "sudo shutdown -r now"); err != nil {
+	if _, err := s.Exec(ctx, vp.Logger, "sudo shutdown -r now"); err != nil {
 		return maskAny(err)
 	}
 	return nil
 }
+
+func (vp *vagrantProvider) GetClusterTags(ctx context.Context, info providers.ClusterInfo) ([]string, error) {
+	return nil, maskAny(providers.TagsNotSupportedError)
+}
+
+func (vp *vagrantProvider) AddClusterTag(ctx context.Context, info providers.ClusterInfo, tag string) error {
+	return maskAny(providers.TagsNotSupportedError)
+}
+
+func (vp *vagrantProvider) RemoveClusterTag(ctx context.Context, info providers.ClusterInfo, tag string) error {
+	return maskAny(providers.TagsNotSupportedError)
+}
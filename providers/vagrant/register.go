@@ -0,0 +1,44 @@
+// Copyright (c) 2016 Pulcy.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package vagrant
+
+import (
+	"os"
+
+	"github.com/juju/errgo"
+	"github.com/op/go-logging"
+	"github.com/spf13/pflag"
+
+	"github.com/pulcy/quark/providers"
+)
+
+var (
+	folder string
+)
+
+func init() {
+	providers.RegisterCloudProvider(providers.CloudProviderRegistration{
+		Name: "vagrant",
+		SetupFlags: func(flagSet *pflag.FlagSet) {
+			flagSet.StringVarP(&folder, "vagrant-folder", "f", os.Getenv("QUARK_VAGRANT_FOLDER"), "Directory containing vagrant files")
+		},
+		New: func(log *logging.Logger) (providers.CloudProvider, error) {
+			if folder == "" {
+				return nil, maskAny(errgo.WithCausef(nil, providers.InvalidArgumentError, "Please specify a vagrant-folder"))
+			}
+			return NewProvider(log, folder), nil
+		},
+	})
+}
@@ -27,6 +27,19 @@ const (
 	insecurePrivateKeyPathTmpl = "~/.vagrant.d/insecure_private_key"
 )
 
+// vagrantInsecureSSHKeyFile returns the path of the vagrant insecure private key,
+// or an empty string if it does not exist.
+func vagrantInsecureSSHKeyFile() string {
+	insecurePrivateKeyPath, err := homedir.Expand(insecurePrivateKeyPathTmpl)
+	if err != nil {
+		return ""
+	}
+	if _, err := os.Stat(insecurePrivateKeyPath); err != nil {
+		return ""
+	}
+	return insecurePrivateKeyPath
+}
+
 func fetchVagrantInsecureSSHKey() (string, error) {
 	insecurePrivateKeyPath, err := homedir.Expand(insecurePrivateKeyPathTmpl)
 	if err != nil {
@@ -15,11 +15,13 @@
 package vagrant
 
 import (
+	"context"
+
 	"github.com/op/go-logging"
 
 	"github.com/pulcy/quark/providers"
 )
 
-func (p *vagrantProvider) UpdateCluster(log *logging.Logger, info providers.ClusterInfo, dnsProvider providers.DnsProvider) error {
+func (p *vagrantProvider) UpdateCluster(ctx context.Context, log *logging.Logger, info providers.ClusterInfo, dnsProvider providers.DnsProvider) error {
 	return maskAny(NotImplementedError)
 }
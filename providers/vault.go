@@ -15,6 +15,8 @@
 package providers
 
 import (
+	"context"
+
 	"github.com/op/go-logging"
 	"github.com/pulcy/vault-monkey/service"
 )
@@ -29,8 +31,8 @@ type VaultProviderConfig struct {
 }
 
 type VaultProvider interface {
-	AddMachine(clusterId, machineId string) error
-	RemoveMachine(machineId string) error
+	AddMachine(ctx context.Context, clusterId, machineId string) error
+	RemoveMachine(ctx context.Context, machineId string) error
 }
 
 type vaultService struct {
@@ -58,7 +60,7 @@ func NewVaultProvider(log *logging.Logger, config VaultProviderConfig) (VaultPro
 	}, nil
 }
 
-func (vs *vaultService) AddMachine(clusterId, machineId string) error {
+func (vs *vaultService) AddMachine(ctx context.Context, clusterId, machineId string) error {
 	vs.log.Debug("attempting vault login")
 	if err := vs.login(); err != nil {
 		return maskAny(err)
@@ -71,7 +73,7 @@ func (vs *vaultService) AddMachine(clusterId, machineId string) error {
 	return nil
 }
 
-func (vs *vaultService) RemoveMachine(machineId string) error {
+func (vs *vaultService) RemoveMachine(ctx context.Context, machineId string) error {
 	vs.log.Debug("attempting vault login")
 	if err := vs.login(); err != nil {
 		return maskAny(err)
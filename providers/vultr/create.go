@@ -15,12 +15,14 @@
 package vultr
 
 import (
+	"context"
 	"os"
 	"strconv"
 	"sync"
 	"time"
 
 	"github.com/JamesClonk/vultr/lib"
+	"github.com/juju/errgo"
 	"github.com/op/go-logging"
 
 	"github.com/pulcy/quark/providers"
@@ -33,7 +35,7 @@ const (
 )
 
 // Create a machine instance
-func (vp *vultrProvider) CreateInstance(log *logging.Logger, options providers.CreateInstanceOptions, dnsProvider providers.DnsProvider) (providers.ClusterInstance, error) {
+func (vp *vultrProvider) CreateInstance(ctx context.Context, log *logging.Logger, options providers.CreateInstanceOptions, dnsProvider providers.DnsProvider) (providers.ClusterInstance, error) {
 	// Create server
 	id, err := vp.createServer(options)
 	if err != nil {
@@ -41,7 +43,7 @@ func (vp *vultrProvider) CreateInstance(log *logging.Logger, options providers.C
 	}
 
 	// Wait for the server to be active
-	server, err := vp.waitUntilServerActive(id)
+	server, err := vp.waitUntilServerActive(ctx, id)
 	if err != nil {
 		return providers.ClusterInstance{}, maskAny(err)
 	}
@@ -52,7 +54,7 @@ func (vp *vultrProvider) CreateInstance(log *logging.Logger, options providers.C
 	if len(server.V6Networks) > 0 {
 		publicIpv6 = server.V6Networks[0].MainIP
 	}
-	if err := providers.RegisterInstance(vp.Logger, dnsProvider, options, server.Name, options.RegisterInstance, options.RoleLoadBalancer, options.RoleLoadBalancer, publicIpv4, publicIpv6, privateIpv4); err != nil {
+	if err := providers.RegisterInstance(ctx, vp.Logger, dnsProvider, options, server.Name, options.RegisterInstance, options.RoleLoadBalancer, options.RoleLoadBalancer, publicIpv4, publicIpv6, privateIpv4); err != nil {
 		return providers.ClusterInstance{}, maskAny(err)
 	}
 
@@ -117,7 +119,9 @@ func (vp *vultrProvider) createServer(options providers.CreateInstanceOptions) (
 	return server.ID, nil
 }
 
-func (vp *vultrProvider) waitUntilServerActive(id string) (lib.Server, error) {
+func (vp *vultrProvider) waitUntilServerActive(ctx context.Context, id string) (lib.Server, error) {
+	ctx, cancel := providers.ProvisionContext(ctx)
+	defer cancel()
 	for {
 		server, err := vp.client.GetServer(id)
 		if err != nil {
@@ -127,7 +131,7 @@ func (vp *vultrProvider) waitUntilServerActive(id string) (lib.Server, error) {
 			// Attempt an SSH connection
 			instance := vp.clusterInstance(server)
 			if s, err := instance.Connect(); err == nil {
-				if _, err := s.GetMachineID(vp.Logger); err == nil {
+				if _, err := s.GetMachineID(ctx, vp.Logger); err == nil {
 					s.Close()
 					// Success
 					return server, nil
@@ -137,12 +141,14 @@ func (vp *vultrProvider) waitUntilServerActive(id string) (lib.Server, error) {
 			}
 		}
 		// Wait a while
-		time.Sleep(time.Second * 5)
+		if err := providers.Sleep(ctx, time.Second*5); err != nil {
+			return lib.Server{}, maskAny(errgo.Notef(err, "server %s is not active", id))
+		}
 	}
 }
 
 // Create an entire cluster
-func (vp *vultrProvider) CreateCluster(log *logging.Logger, options providers.CreateClusterOptions, dnsProvider providers.DnsProvider) error {
+func (vp *vultrProvider) CreateCluster(ctx context.Context, log *logging.Logger, options providers.CreateClusterOptions, dnsProvider providers.DnsProvider) error {
 	wg := sync.WaitGroup{}
 	errors := make(chan error, options.InstanceCount)
 	instanceDatas := make(chan instanceData, options.InstanceCount)
@@ -150,7 +156,10 @@ func (vp *vultrProvider) CreateCluster(log *logging.Logger, options providers.Cr
 		wg.Add(1)
 		go func(i int) {
 			defer wg.Done()
-			time.Sleep(time.Duration((i - 1)) * time.Second * 10)
+			if err := providers.Sleep(ctx, time.Duration((i-1))*time.Second*10); err != nil {
+				errors <- maskAny(err)
+				return
+			}
 			isCore := true
 			isLB := true
 			instanceOptions, err := options.NewCreateInstanceOptions(isCore, isLB, i)
@@ -158,7 +167,7 @@ func (vp *vultrProvider) CreateCluster(log *logging.Logger, options providers.Cr
 				errors <- maskAny(err)
 				return
 			}
-			instance, err := vp.CreateInstance(log, instanceOptions, dnsProvider)
+			instance, err := vp.CreateInstance(ctx, log, instanceOptions, dnsProvider)
 			if err != nil {
 				errors <- maskAny(err)
 			} else {
@@ -185,12 +194,12 @@ func (vp *vultrProvider) CreateCluster(log *logging.Logger, options providers.Cr
 		instanceList = append(instanceList, data.ClusterInstance)
 	}
 
-	clusterMembers, err := instanceList.AsClusterMemberList(log, nil)
+	clusterMembers, err := instanceList.AsClusterMemberList(ctx, log, nil)
 	if err != nil {
 		return maskAny(err)
 	}
 
-	if err := vp.setupInstances(log, instances, clusterMembers); err != nil {
+	if err := vp.setupInstances(ctx, log, instances, clusterMembers); err != nil {
 		return maskAny(err)
 	}
 
@@ -203,7 +212,7 @@ type instanceData struct {
 	FleetMetadata         string
 }
 
-func (vp *vultrProvider) setupInstances(log *logging.Logger, instances []instanceData, clusterMembers providers.ClusterMemberList) error {
+func (vp *vultrProvider) setupInstances(ctx context.Context, log *logging.Logger, instances []instanceData, clusterMembers providers.ClusterMemberList) error {
 	wg := sync.WaitGroup{}
 	errors := make(chan error, len(instances))
 	for _, instance := range instances {
@@ -214,7 +223,7 @@ func (vp *vultrProvider) setupInstances(log *logging.Logger, instances []instanc
 				ClusterMembers: clusterMembers,
 				FleetMetadata:  instance.FleetMetadata,
 			}
-			if err := instance.ClusterInstance.InitialSetup(log, instance.CreateInstanceOptions, iso, vp); err != nil {
+			if err := instance.ClusterInstance.InitialSetup(ctx, log, instance.CreateInstanceOptions, iso, vp); err != nil {
 				errors <- maskAny(err)
 				return
 			}
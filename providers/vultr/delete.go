@@ -15,11 +15,13 @@
 package vultr
 
 import (
+	"context"
+
 	"github.com/pulcy/quark/providers"
 )
 
 // Remove all instances of a cluster
-func (vp *vultrProvider) DeleteCluster(info providers.ClusterInfo, dnsProvider providers.DnsProvider) error {
+func (vp *vultrProvider) DeleteCluster(ctx context.Context, info providers.ClusterInfo, dnsProvider providers.DnsProvider) error {
 	servers, err := vp.getInstances(info)
 	if err != nil {
 		return err
@@ -27,7 +29,7 @@ func (vp *vultrProvider) DeleteCluster(info providers.ClusterInfo, dnsProvider p
 	for _, s := range servers {
 		// Delete DNS instance records
 		instance := vp.clusterInstance(s)
-		if err := providers.UnRegisterInstance(vp.Logger, dnsProvider, instance, info.Domain); err != nil {
+		if err := providers.UnRegisterInstance(ctx, vp.Logger, dnsProvider, instance, info.Domain); err != nil {
 			return maskAny(err)
 		}
 
@@ -41,7 +43,7 @@ func (vp *vultrProvider) DeleteCluster(info providers.ClusterInfo, dnsProvider p
 	return nil
 }
 
-func (vp *vultrProvider) DeleteInstance(info providers.ClusterInstanceInfo, dnsProvider providers.DnsProvider) error {
+func (vp *vultrProvider) DeleteInstance(ctx context.Context, info providers.ClusterInstanceInfo, dnsProvider providers.DnsProvider) error {
 	fullName := info.String()
 	servers, err := vp.getInstances(info.ClusterInfo)
 	if err != nil {
@@ -51,7 +53,7 @@ func (vp *vultrProvider) DeleteInstance(info providers.ClusterInstanceInfo, dnsP
 		if s.Name == fullName {
 			// Delete DNS instance records
 			instance := vp.clusterInstance(s)
-			if err := providers.UnRegisterInstance(vp.Logger, dnsProvider, instance, info.Domain); err != nil {
+			if err := providers.UnRegisterInstance(ctx, vp.Logger, dnsProvider, instance, info.Domain); err != nil {
 				return maskAny(err)
 			}
 
@@ -14,6 +14,12 @@
 
 package vultr
 
-func (vp *vultrProvider) ShowDomainRecords(domain string) error {
-	return maskAny(NotImplementedError)
+import (
+	"context"
+
+	"github.com/pulcy/quark/providers"
+)
+
+func (vp *vultrProvider) GetDomainRecords(ctx context.Context, domain string) (providers.DnsRecordList, error) {
+	return nil, maskAny(NotImplementedError)
 }
@@ -15,30 +15,28 @@
 package vultr
 
 import (
+	"context"
 	"fmt"
-	"sort"
 
-	"github.com/ryanuber/columnize"
+	"github.com/pulcy/quark/providers"
 )
 
-func (vp *vultrProvider) ShowImages() error {
+func (vp *vultrProvider) GetImages(ctx context.Context) (providers.ImageList, error) {
 	// Load OS's
 	os, err := vp.client.GetOS()
 	if err != nil {
-		return maskAny(err)
+		return nil, maskAny(err)
 	}
 
-	lines := []string{
-		"ID | Name | Arch | Family",
-	}
+	result := providers.ImageList{}
 	for _, r := range os {
-		line := fmt.Sprintf("%d | %s | %s | %s", r.ID, r.Name, r.Arch, r.Family)
-		lines = append(lines, line)
+		result = append(result, providers.Image{
+			ID:     fmt.Sprintf("%d", r.ID),
+			Name:   r.Name,
+			Arch:   r.Arch,
+			Family: r.Family,
+		})
 	}
 
-	sort.Strings(lines[1:])
-	result := columnize.SimpleFormat(lines)
-	fmt.Println(result)
-
-	return nil
+	return result, nil
 }
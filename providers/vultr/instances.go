@@ -15,6 +15,7 @@
 package vultr
 
 import (
+	"context"
 	"fmt"
 	"strings"
 
@@ -24,7 +25,7 @@ import (
 )
 
 // Get names of instances of a cluster
-func (vp *vultrProvider) GetInstances(info providers.ClusterInfo) (providers.ClusterInstanceList, error) {
+func (vp *vultrProvider) GetInstances(ctx context.Context, info providers.ClusterInfo) (providers.ClusterInstanceList, error) {
 	servers, err := vp.getInstances(info)
 	if err != nil {
 		return nil, maskAny(err)
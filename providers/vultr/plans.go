@@ -15,29 +15,30 @@
 package vultr
 
 import (
+	"context"
 	"fmt"
-	"sort"
 
-	"github.com/ryanuber/columnize"
+	"github.com/pulcy/quark/providers"
 )
 
-func (vp *vultrProvider) ShowInstanceTypes() error {
+func (vp *vultrProvider) GetInstanceTypes(ctx context.Context) (providers.InstanceTypeList, error) {
 	plans, err := vp.client.GetPlans()
 	if err != nil {
-		return maskAny(err)
+		return nil, maskAny(err)
 	}
 
-	lines := []string{
-		"ID | Name | VCpu | RAM | Disk | Bandwidth | Price",
-	}
+	result := providers.InstanceTypeList{}
 	for _, p := range plans {
-		line := fmt.Sprintf("%03d | %s | %d | %s | %s | %s | %s", p.ID, p.Name, p.VCpus, p.RAM, p.Disk, p.Bandwidth, p.Price)
-		lines = append(lines, line)
+		result = append(result, providers.InstanceType{
+			ID:        fmt.Sprintf("%03d", p.ID),
+			Name:      p.Name,
+			VCpus:     p.VCpus,
+			RAM:       p.RAM,
+			Disk:      p.Disk,
+			Bandwidth: p.Bandwidth,
+			Price:     p.Price,
+		})
 	}
 
-	sort.Strings(lines[1:])
-	result := columnize.SimpleFormat(lines)
-	fmt.Println(result)
-
-	return nil
+	return result, nil
 }
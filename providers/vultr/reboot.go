@@ -15,17 +15,19 @@
 package vultr
 
 import (
+	"context"
+
 	"github.com/pulcy/quark/providers"
 )
 
 // Perform a reboot of the given instance
-func (vp *vultrProvider) RebootInstance(instance providers.ClusterInstance) error {
+func (vp *vultrProvider) RebootInstance(ctx context.Context, instance providers.ClusterInstance) error {
 	s, err := instance.Connect()
 	if err != nil {
 		return maskAny(err)
 	}
 	defer s.Close()
-	if _, err := s.Exec(vp.Logger, "sudo shutdown -r now"); err != nil {
+	if _, err := s.Exec(ctx, vp.Logger, "sudo shutdown -r now"); err != nil {
 		return maskAny(err)
 	}
 	return nil
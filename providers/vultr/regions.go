@@ -15,29 +15,28 @@
 package vultr
 
 import (
+	"context"
 	"fmt"
-	"sort"
 
-	"github.com/ryanuber/columnize"
+	"github.com/pulcy/quark/providers"
 )
 
-func (vp *vultrProvider) ShowRegions() error {
+func (vp *vultrProvider) GetRegions(ctx context.Context) (providers.RegionList, error) {
 	regions, err := vp.client.GetRegions()
 	if err != nil {
-		return maskAny(err)
+		return nil, maskAny(err)
 	}
 
-	lines := []string{
-		"ID | Name | State | Country | Continent",
-	}
+	result := providers.RegionList{}
 	for _, r := range regions {
-		line := fmt.Sprintf("%02d | %s | %s | %s | %s", r.ID, r.Name, r.State, r.Country, r.Continent)
-		lines = append(lines, line)
+		result = append(result, providers.Region{
+			ID:        fmt.Sprintf("%02d", r.ID),
+			Name:      r.Name,
+			State:     r.State,
+			Country:   r.Country,
+			Continent: r.Continent,
+		})
 	}
 
-	sort.Strings(lines[1:])
-	result := columnize.SimpleFormat(lines)
-	fmt.Println(result)
-
-	return nil
+	return result, nil
 }
@@ -0,0 +1,36 @@
+// Copyright (c) 2016 Pulcy.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package vultr
+
+import (
+	"context"
+
+	"github.com/pulcy/quark/providers"
+)
+
+// Get all tags of the instances of a cluster
+func (vp *vultrProvider) GetClusterTags(ctx context.Context, info providers.ClusterInfo) ([]string, error) {
+	return nil, maskAny(providers.TagsNotSupportedError)
+}
+
+// Add a tag to all instances of a cluster
+func (vp *vultrProvider) AddClusterTag(ctx context.Context, info providers.ClusterInfo, tag string) error {
+	return maskAny(providers.TagsNotSupportedError)
+}
+
+// Remove a tag from all instances of a cluster
+func (vp *vultrProvider) RemoveClusterTag(ctx context.Context, info providers.ClusterInfo, tag string) error {
+	return maskAny(providers.TagsNotSupportedError)
+}
@@ -15,11 +15,13 @@
 package vultr
 
 import (
+	"context"
+
 	"github.com/op/go-logging"
 
 	"github.com/pulcy/quark/providers"
 )
 
-func (p *vultrProvider) UpdateCluster(log *logging.Logger, info providers.ClusterInfo, dnsProvider providers.DnsProvider) error {
+func (p *vultrProvider) UpdateCluster(ctx context.Context, log *logging.Logger, info providers.ClusterInfo, dnsProvider providers.DnsProvider) error {
 	return maskAny(NotImplementedError)
 }
@@ -0,0 +1,111 @@
+// Copyright (c) 2016 Pulcy.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package providers
+
+import (
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"os"
+	"path"
+	"strings"
+
+	logging "github.com/op/go-logging"
+)
+
+const (
+	// writeFileTmpSuffix is appended to the path of a file to get the temporary file used while writing it.
+	writeFileTmpSuffix = ".quark-tmp"
+	// defaultFileOwner is the owner of written files when no owner is specified.
+	defaultFileOwner = "root"
+)
+
+// WriteFile writes the given content to the file with given path on the instance.
+// The content is written to a temporary file (only readable by root) first, which is verified,
+// given the requested mode & owner (user[:group], defaults to root) and then moved into place.
+// Nothing is written when the file already has the same content, mode & owner.
+// The content is passed to `sudo tee` on stdin instead of using SFTP, since SFTP runs as the
+// login user, which cannot write to the root owned directories used by quark.
+func (s *instanceConnection) WriteFile(ctx context.Context, log *logging.Logger, filePath, content string, mode os.FileMode, owner string) error {
+	if owner == "" {
+		owner = defaultFileOwner
+	}
+	checksum := fmt.Sprintf("%x", sha256.Sum256([]byte(content)))
+	perm := fmt.Sprintf("%o", mode.Perm())
+
+	// Skip unchanged files.
+	// The file does not have to exists, so ignore errors by the `|| echo ""` parts.
+	quotedPath := shellQuote(filePath)
+	statCmd := fmt.Sprintf("sudo stat -c \"%%a %%U:%%G\" %s && sudo sha256sum %s || echo \"\"", quotedPath, quotedPath)
+	current, err := s.Run(ctx, log, "sh -c "+shellQuote(statCmd), "", true)
+	if err != nil {
+		return maskAny(err)
+	}
+	if isFileUpToDate(current, checksum, perm, owner) {
+		log.Debugf("%s is up to date on %s", filePath, s.host)
+		return nil
+	}
+
+	log.Debugf("Writing %s on %s", filePath, s.host)
+	tmpPath := shellQuote(filePath + writeFileTmpSuffix)
+	cmds := []string{
+		fmt.Sprintf("sudo mkdir -p %s", shellQuote(path.Dir(filePath))),
+		fmt.Sprintf("sudo rm -f %s", tmpPath),
+		// Restrict access before any content is written
+		fmt.Sprintf("sudo touch %s", tmpPath),
+		fmt.Sprintf("sudo chmod 0600 %s", tmpPath),
+		fmt.Sprintf("sudo tee %s", tmpPath),
+		// Make sure all content has arrived before the file is replaced
+		fmt.Sprintf("echo %s | sudo sha256sum -c --status", shellQuote(checksum+"  "+filePath+writeFileTmpSuffix)),
+		fmt.Sprintf("sudo chown %s %s", shellQuote(owner), tmpPath),
+		fmt.Sprintf("sudo chmod %s %s", perm, tmpPath),
+		fmt.Sprintf("sudo mv -f %s %s", tmpPath, quotedPath),
+	}
+	if _, err := s.Run(ctx, log, "sh -c "+shellQuote(strings.Join(cmds, " && ")), content, false); err != nil {
+		return maskAny(err)
+	}
+	return nil
+}
+
+// isFileUpToDate returns true if the given output of `stat -c "%a %U:%G" && sha256sum` shows
+// that a file has the given checksum, mode & owner.
+func isFileUpToDate(statOutput, checksum, perm, owner string) bool {
+	lines := strings.Split(strings.TrimSpace(statOutput), "\n")
+	if len(lines) != 2 {
+		return false
+	}
+	stat := strings.Fields(lines[0])
+	sum := strings.Fields(lines[1])
+	if len(stat) != 2 || len(sum) == 0 {
+		return false
+	}
+	currentOwner := stat[1]
+	if !strings.Contains(owner, ":") {
+		// Only the user is specified, ignore the group
+		currentOwner = strings.SplitN(currentOwner, ":", 2)[0]
+	}
+	return stat[0] == perm && currentOwner == owner && sum[0] == checksum
+}
+
+// shellQuote quotes the given value for use as a single word in a `sh` command line.
+// Values that only contain safe characters are returned as is.
+func shellQuote(value string) string {
+	if value != "" && strings.IndexFunc(value, func(r rune) bool {
+		return !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || strings.ContainsRune("@%+=:,./_-", r))
+	}) < 0 {
+		return value
+	}
+	return "'" + strings.Replace(value, "'", `'\''`, -1) + "'"
+}
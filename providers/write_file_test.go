@@ -0,0 +1,65 @@
+// Copyright (c) 2016 Pulcy.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package providers
+
+import (
+	"testing"
+)
+
+func TestIsFileUpToDate(t *testing.T) {
+	const checksum = "9f86d081884c7d659a2feaa0c55ad015a3bf4f1b2b0b822cd15d6c15b0f00a08"
+	tests := []struct {
+		Output   string
+		Perm     string
+		Owner    string
+		Expected bool
+	}{
+		{"400 root:root\n" + checksum + "  /etc/pulcy/gluon-args\n", "400", "root", true},
+		{"400 root:root\n" + checksum + "  /etc/pulcy/gluon-args\n", "400", "root:root", true},
+		{"400 root:core\n" + checksum + "  /etc/pulcy/gluon-args\n", "400", "root", true},
+		{"400 root:core\n" + checksum + "  /etc/pulcy/gluon-args\n", "400", "root:root", false},
+		{"644 root:root\n" + checksum + "  /etc/pulcy/gluon-args\n", "400", "root", false},
+		{"400 core:core\n" + checksum + "  /etc/pulcy/gluon-args\n", "400", "root", false},
+		{"400 root:root\n" + checksum[1:] + "0  /etc/pulcy/gluon-args\n", "400", "root", false},
+		// File does not exist
+		{"\n", "400", "root", false},
+		{"", "400", "root", false},
+		// Unexpected output
+		{"400 root:root\n", "400", "root", false},
+		{"400\n" + checksum + "  /etc/pulcy/gluon-args\n", "400", "root", false},
+		{"400 root:root\n\n", "400", "root", false},
+	}
+	for _, test := range tests {
+		if result := isFileUpToDate(test.Output, checksum, test.Perm, test.Owner); result != test.Expected {
+			t.Errorf("Expected isFileUpToDate(%q, %s, %s) to return %v, got %v", test.Output, test.Perm, test.Owner, test.Expected, result)
+		}
+	}
+}
+
+func TestShellQuote(t *testing.T) {
+	tests := map[string]string{
+		"/etc/pulcy/gluon-args": "/etc/pulcy/gluon-args",
+		"root:core":             "root:core",
+		"":                      "''",
+		"/etc/my file":          "'/etc/my file'",
+		"/etc/$(reboot)":        "'/etc/$(reboot)'",
+		"/etc/it's":             `'/etc/it'\''s'`,
+	}
+	for value, expected := range tests {
+		if result := shellQuote(value); result != expected {
+			t.Errorf("Expected shellQuote(%q) to return %s, got %s", value, expected, result)
+		}
+	}
+}
@@ -0,0 +1,191 @@
+// Copyright (c) 2016 Pulcy.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"strings"
+
+	"github.com/juju/errgo"
+	"github.com/spf13/pflag"
+
+	"github.com/pulcy/quark/providers"
+	"github.com/pulcy/quark/providers/dryrun"
+	"github.com/pulcy/quark/providers/state"
+)
+
+const (
+	stateStoreFile = "file" // State is kept in a local file
+	stateStoreEtcd = "etcd" // State is kept in the etcd of the cluster
+)
+
+var (
+	stateStores = []string{stateStoreFile, stateStoreEtcd}
+
+	// clusterStates holds the recorded state of all clusters loaded by this command (nil if not recorded).
+	clusterStates = make(map[string]*state.Cluster)
+)
+
+// validateStateStore exits when the --state-store flag has an unknown value.
+func validateStateStore() {
+	for _, s := range stateStores {
+		if s == stateStore {
+			return
+		}
+	}
+	Exitf("Invalid state store '%s', expected one of %s\n", stateStore, strings.Join(stateStores, "|"))
+}
+
+// newStateStore returns the store selected with --state-store.
+func newStateStore(cloudProvider providers.CloudProvider) state.Store {
+	if stateStore == stateStoreEtcd {
+		return state.NewEtcdStore(log, cloudProvider)
+	}
+	return state.NewFileStore(stateDir)
+}
+
+// newClusterState creates an empty state for the given cluster, hosted by the selected provider.
+func newClusterState(info providers.ClusterInfo) *state.Cluster {
+	return state.New(info, provider)
+}
+
+// loadClusterState returns the recorded state of the given cluster, or nil if it has not been recorded.
+// All differences between the recorded instances and the instances reported by the provider are logged as warnings.
+func loadClusterState(cloudProvider providers.CloudProvider, info providers.ClusterInfo) *state.Cluster {
+	key := info.String()
+	if c, ok := clusterStates[key]; ok {
+		return c
+	}
+	store := newStateStore(cloudProvider)
+	c, err := store.Load(ctx, info)
+	if errgo.Cause(err) == state.NotFoundError {
+		log.Debugf("No state of %s recorded in %s", info, store)
+		clusterStates[key] = nil
+		return nil
+	} else if err != nil {
+		log.Warningf("Failed to load the recorded state of %s from %s: %v", info, store, err)
+		return nil
+	}
+	instances, err := cloudProvider.GetInstances(ctx, info)
+	if err != nil {
+		log.Warningf("Failed to list instances: %v", err)
+	} else {
+		for _, d := range c.Drift(instances) {
+			log.Warningf("%s differs from its recorded state: %s", info, d)
+		}
+	}
+	clusterStates[key] = c
+	return c
+}
+
+// applyClusterStateDefaults sets all flags in the given flagset that have not been changed (on the command line
+// or by the cluster file) to the recorded options of the given cluster.
+func applyClusterStateDefaults(flagSet *pflag.FlagSet, cloudProvider providers.CloudProvider, info providers.ClusterInfo) {
+	c := loadClusterState(cloudProvider, info)
+	if c == nil {
+		return
+	}
+	values := make(map[string]interface{})
+	for k, v := range c.Options.Values() {
+		values[k] = v
+	}
+	setFlagsFromValues(flagSet, values)
+}
+
+// updateClusterState calls the given function to change the recorded state of the given cluster and
+// records the instances of the cluster as reported by the provider.
+// A cluster that has not been recorded yet is recorded now.
+func updateClusterState(cloudProvider providers.CloudProvider, info providers.ClusterInfo, update func(c *state.Cluster)) {
+	if dryRun {
+		dryRunPlan.Add(dryrun.KindState, info.String(), "Update recorded state")
+		return
+	}
+	store := newStateStore(cloudProvider)
+	c, err := store.Load(ctx, info)
+	if errgo.Cause(err) == state.NotFoundError {
+		c = newClusterState(info)
+	} else if err != nil {
+		log.Warningf("Failed to load the recorded state of %s from %s: %v", info, store, err)
+		return
+	}
+	if c.Cluster.ID == "" {
+		c.Cluster.ID = info.ID
+	}
+	if update != nil {
+		update(c)
+	}
+	syncClusterState(cloudProvider, c)
+	saveClusterState(cloudProvider, c)
+}
+
+// syncClusterState records the instances of the given cluster as reported by the provider.
+func syncClusterState(cloudProvider providers.CloudProvider, c *state.Cluster) {
+	instances, err := cloudProvider.GetInstances(ctx, c.Cluster)
+	if err != nil {
+		log.Warningf("Failed to list instances: %v", err)
+		return
+	}
+	if c.Cluster.ID == "" && len(instances) > 0 {
+		if id, err := instances.GetClusterID(ctx, log); err == nil {
+			c.Cluster.ID = id
+		}
+	}
+	c.Sync(instances, describeInstance)
+}
+
+// saveClusterState records the given state in the selected store.
+func saveClusterState(cloudProvider providers.CloudProvider, c *state.Cluster) {
+	if dryRun {
+		dryRunPlan.Add(dryrun.KindState, c.Cluster.String(), "Update recorded state")
+		return
+	}
+	store := newStateStore(cloudProvider)
+	if err := store.Save(ctx, c); err != nil {
+		log.Warningf("Failed to record the state of %s in %s: %v (run `quark cluster update` to record it later)", c.Cluster, store, err)
+		return
+	}
+	log.Debugf("Recorded the state of %s in %s", c.Cluster, store)
+	clusterStates[c.Cluster.String()] = c
+}
+
+// removeClusterState removes the recorded state of the given cluster.
+func removeClusterState(cloudProvider providers.CloudProvider, info providers.ClusterInfo) {
+	if dryRun {
+		dryRunPlan.Add(dryrun.KindState, info.String(), "Remove recorded state")
+		return
+	}
+	store := newStateStore(cloudProvider)
+	if err := store.Remove(ctx, info); err != nil {
+		log.Warningf("Failed to remove the recorded state of %s from %s: %v", info, store, err)
+		return
+	}
+	delete(clusterStates, info.String())
+}
+
+// describeInstance returns the state of an instance that has not been recorded yet.
+// Its roles & etcd mode are loaded from the instance, when it can be reached.
+func describeInstance(i providers.ClusterInstance) state.Instance {
+	result := state.NewInstance(i)
+	if roles, err := i.GetRoles(ctx, log); err != nil {
+		log.Debugf("Cannot load roles of %s: %v", i.Name, err)
+	} else {
+		result.Roles = roles
+	}
+	if etcdProxy, err := i.IsEtcdProxy(ctx, log); err != nil {
+		log.Debugf("Cannot load etcd mode of %s: %v", i.Name, err)
+	} else {
+		result.EtcdProxy = etcdProxy
+	}
+	return result
+}
@@ -115,7 +115,7 @@ func templatesBootstrapShTmpl() (*asset, error) {
 	return a, nil
 }
 
-var _templatesCloudConfigTmpl = []byte("\x1f\x8b\x08\x00\x00\x09\x6e\x88\x00\xff\x54\xce\xc1\x4e\xb4\x40\x0c\x07\xf0\xfb\x3c\x45\xc3\xf7\x5d\x59\x38\x78\x9a\xc4\x93\x5e\x8c\x37\xf7\x01\x08\x0e\x05\x26\x8b\x53\xd2\x96\x6c\xc6\x3a\xef\x6e\x00\x63\xe2\xa9\xed\x2f\xe9\xbf\xfd\x17\x16\xda\x86\x3a\x50\x1a\xe3\xe4\x5c\x20\x46\x12\xef\x00\xb6\x75\xe8\x15\xf7\x0e\x80\xf1\x9d\x48\x6b\x51\xee\x15\xa7\xec\xc1\xec\xf2\x76\xd8\xf5\x87\x4a\x71\xee\xce\x51\xb1\x1b\xe3\x82\x47\x40\x0d\x6b\xaf\xb3\x87\xaa\x41\x0d\xcd\xba\x2d\x21\x37\x61\xd9\x44\x91\xeb\x38\x54\x47\xf0\x8a\xfc\x11\x45\x22\x25\xf1\x50\xb5\x0f\x6d\x7b\x3a\xdd\x13\xb2\x87\x8a\x89\xf4\x94\x40\x49\x31\xa9\x87\xaf\x63\x84\xfd\x85\xa7\x33\xed\xe5\x79\xbf\x6e\x06\x71\x84\xcb\x55\xe6\x57\xcc\x02\xa5\x38\x91\xb9\xeb\x37\x9d\x89\xe3\x27\x0e\xdd\x0d\xb3\x78\x33\xe0\x3e\x4d\x08\xff\x6f\x98\xc1\x3f\xfe\x59\xa8\xc1\x6c\xf7\x52\xcc\x30\x0d\xbf\xc5\x7d\x07\x00\x00\xff\xff\x55\x0f\xf7\xe4\x25\x01\x00\x00")
+var _templatesCloudConfigTmpl = []byte("\x1f\x8b\x08\x00\x00\x00\x00\x00\x02\x03\xc5\x92\xc1\x4e\xc3\x30\x0c\x86\xef\x7d\x0a\x6b\x70\x4d\xdb\xc3\xe0\x50\x69\x07\x04\x48\x43\x4c\xdb\xb4\xee\x00\x42\xa8\x2a\xa9\xd7\x46\xeb\x92\x2a\x49\x37\x4a\xe9\xbb\xe3\xb6\x63\x13\x68\x08\x6e\x9c\x12\x7f\x76\xfe\xfc\x8e\x73\xc6\x73\x55\x26\x8c\x2b\xb9\x12\xa9\xe3\x70\xa5\x51\x99\xc0\x01\x28\x8b\x24\xb6\xd8\xee\x00\x34\xbe\x28\x65\x99\xb1\x9a\x50\x5a\x05\x50\xd7\xee\xa2\x63\xe1\x1e\x35\x8d\xe3\xec\xb4\xb0\x18\xad\x44\x8e\x9d\x00\x83\x22\xb6\x59\x00\x03\x0f\x2d\xf7\x8a\x32\xe7\x95\xc7\xf3\xd2\x58\xd4\x4c\x24\x83\x4e\xb8\x40\xbd\x11\xc6\x08\x25\x0d\x15\xfa\x43\xdf\xef\xb9\xda\x49\xd4\x44\x34\x5d\xd1\x13\x32\x68\x51\xda\x00\xde\xbb\x10\x5a\x0b\xd7\xbd\xda\xdd\x0d\xdd\x5e\xd7\x20\x56\xe0\x8e\xad\x2d\xe6\x5a\xbd\x56\x40\xec\xbb\x07\x53\x51\xf9\x26\xd9\xaf\x5e\xa2\xf8\x1a\xb5\x6b\x50\x6f\x05\x47\x37\xf1\x2e\x7c\x96\xd1\x79\x56\xb4\x02\x6e\xfb\x24\x27\x5d\x5e\x0e\x87\x7f\x76\xf9\x14\xf6\xea\xcf\xfb\xf8\x56\x6e\x85\x56\x72\x43\x45\xa3\xc1\x78\xb9\x9c\x47\xf3\xc5\xec\xe1\x71\x44\xdd\x1c\xac\x37\xcd\x00\xba\x5c\xf8\x53\x72\x3a\x3b\x66\xa6\xea\x93\x3b\xbf\x35\xdc\x8f\x94\xa1\x4c\x85\xc4\x7f\xec\xfb\x6a\x32\x39\xdd\x59\x3b\x45\x94\x09\x1c\xe7\x19\x9a\xec\x1e\x2b\xd3\x12\x63\xb2\x28\x2e\x6d\xa6\xb4\x78\xc3\x24\x5a\x13\x0e\xa8\x4a\xc7\x32\x45\x38\xa7\x10\x82\xd1\x97\x03\x8c\xfe\x48\xcb\x9b\xa6\xae\x49\xf5\xb0\x38\x1f\xce\xde\x79\x84\xf4\x02\x00\x00")
 
 func templatesCloudConfigTmplBytes() ([]byte, error) {
 	return bindataRead(
@@ -130,7 +130,7 @@ func templatesCloudConfigTmpl() (*asset, error) {
 		return nil, err
 	}
 
-	info := bindataFileInfo{name: "templates/cloud-config.tmpl", size: 293, mode: os.FileMode(420), modTime: time.Unix(1456146790, 0)}
+	info := bindataFileInfo{name: "templates/cloud-config.tmpl", size: 756, mode: os.FileMode(420), modTime: time.Unix(1456146790, 0)}
 	a := &asset{bytes: bytes, info: info}
 	return a, nil
 }
@@ -175,7 +175,7 @@ func templatesRenderGo() (*asset, error) {
 	return a, nil
 }
 
-var _templatesScalewayBootstrapTmpl = []byte("\x1f\x8b\x08\x00\x00\x09\x6e\x88\x00\xff\x94\x56\x61\x6f\xe2\x46\x10\xfd\xee\x5f\x31\x25\xe8\x92\x54\x5d\x1b\x10\x97\x2a\xb4\x44\xe2\xc0\xb9\x5a\x22\x80\x80\xe4\x54\x55\x15\xb7\xb1\x07\xd8\xc3\xde\x75\x76\xd7\x24\x88\xf0\xdf\xab\xb5\x4d\x70\x48\x02\xd7\x2f\xb1\xf2\xe6\xbd\x37\x9e\xd9\xd9\x31\x27\xbf\x38\xf7\x8c\x3b\xf7\x54\xcd\x2d\xeb\x04\xae\x51\xfb\x73\x40\xbe\x64\x52\xf0\x08\xb9\xb6\xac\x51\xfb\x9b\x37\x68\x96\xcf\xe6\x42\x69\x4e\x23\x04\x20\x1e\x3c\x03\x7d\x5c\xc0\xe9\x3a\x96\x8c\x6b\x28\x57\x37\xa7\xe7\xd6\x7a\x0d\x6c\x0a\x5c\x68\xb0\x7b\xc2\x1b\x2c\xeb\xb0\xd9\x18\xf5\xe0\xf6\x4b\xd7\x6b\x37\xcb\x67\x7e\x22\x43\x98\x6b\x1d\x37\x1c\x67\x59\xb7\xa3\x15\x8b\x6d\xce\xf8\x0f\x9a\x6a\x91\x07\x46\x70\xe3\x8e\x5b\x9d\xd6\xb8\xd5\xfc\x5e\xa4\x57\x2f\x2e\xed\xda\xe7\xba\x5d\xaf\xd9\xf5\x9a\xe3\x0b\x3e\xfd\x6e\xdd\xf4\x3b\x6e\xb7\x59\x3e\x43\x7f\x2e\xa0\x54\xde\x0a\x4b\xf0\x0c\x38\x93\x18\x43\xbb\x7f\x73\xe3\x0e\xdb\x5e\xab\x3b\x19\xff\x3d\x70\x9b\xf0\x0c\x0a\x03\x38\x55\xce\x7e\xc4\x71\x66\xa7\xe7\x56\xbb\x7b\x3b\x1a\xbb\x43\xaf\x73\xc8\x74\xdc\xfa\x3a\x9a\x54\x0a\x5e\x39\x90\x59\x8c\xbd\x5e\x3b\xed\xd6\x61\x7d\x75\x5f\x5f\xcd\xf5\xc3\x7e\xd7\x1d\x1d\x95\xd7\xf6\xe5\xb5\x5c\xee\x0d\xee\x2e\x26\xad\x4e\x67\xe8\x8e\x0e\xba\xbc\xe2\xed\xbc\x5e\xc1\x99\x63\x66\xf1\x57\x7f\x34\x9e\x0c\x86\xde\x5d\x6b\xec\x4e\xbc\xc1\x5d\xbd\x59\x2a\xa7\x73\x01\x57\x57\x0e\x6a\xdf\x29\x4e\x4c\x41\x62\x0c\x9b\xa5\x72\xd1\xf7\x63\x45\xbb\x3f\x74\xfb\xa3\xfd\x34\x59\x47\x8f\xab\xd2\x21\x2b\xbc\x5b\x06\x7c\xac\xcb\xa6\xa7\x54\x4e\x9f\xef\xd1\xa2\x45\xc0\x24\x90\x18\xd2\x48\x9c\x84\xfe\x2a\x93\x96\x5f\xe6\x04\xae\x76\x31\xc7\x0f\x13\xa5\x51\x12\x16\x58\xfe\x3c\x12\x01\x54\xea\x95\x0a\xbc\x4f\xc8\x7c\xb6\xa5\x15\x38\x9a\x71\x9f\xb0\x38\x27\xa4\xd3\xf0\x2a\x2e\x45\x88\xca\x5c\xd4\xb6\x44\xaa\x11\x22\xea\xcf\x19\x47\xe3\x29\x23\x20\xd3\x2c\x9f\x2d\x71\x86\x9c\x14\x82\x59\xcd\xeb\x35\xd8\x37\x19\xe8\x75\x60\xb3\x29\xc1\x55\x26\x28\x30\x77\xde\xbe\x90\x08\x89\x42\x69\x99\x3f\x34\x08\x80\x04\xe0\xcc\x45\x84\x4e\x1a\x22\x5f\x21\x10\xfe\x02\xe5\x6f\x6a\xa5\x34\x46\x01\xf9\x21\x12\xc9\x69\x08\x24\x02\x72\x0b\x24\x81\xcf\x95\x0a\x10\x05\x2f\x2b\xc6\xb4\xb3\x7c\x96\x24\x2c\x98\x21\x3f\x4f\x33\x14\xfa\xfc\x62\xed\xd8\x4a\xcd\x2d\x3f\x06\x22\xc1\x91\x42\xe8\x14\x70\x7e\xdd\xa7\x38\x96\x3f\x17\x8f\x1c\xc8\x30\xb5\xb2\xd3\xd7\x7a\x63\x93\x1e\x06\x19\x82\x98\x11\xf9\xf8\xf4\x26\x9e\xb5\x26\x95\xb6\xba\xdd\xe6\x59\xab\xdb\x3d\x87\x5e\x7f\xd0\x1a\x8d\xbe\x75\x1a\x06\x2b\xc1\x55\xde\x27\x95\x04\x02\x65\x7a\x00\x5d\xc6\x17\x90\x68\x16\x32\xcd\x50\x59\x7e\x00\x4e\xa2\xa4\xa9\x14\x3e\x7d\x82\x90\xbf\xd4\x9d\xd5\xf7\x1a\x93\xd1\x5b\x2c\x5d\xc1\x5b\x1b\xf5\xda\xc7\xfc\xeb\x84\xca\x14\xb2\x07\x46\x22\x88\xa5\xb8\xc7\x83\x4a\x16\x6b\x7a\x1f\xa2\x22\x8a\x2e\xf1\xc3\xa0\x44\xa5\x4d\x17\x3e\x88\x1f\xc9\x70\x71\x30\xc5\xc5\xb1\x1c\x39\x21\xfd\x06\xb1\x27\x30\x1f\x1b\x65\x99\x1d\x52\xf8\xf2\x6c\xb7\x51\xb5\xf6\xbb\x5d\xb1\x2b\x76\x15\xca\x6b\x43\xd9\xec\x0e\x28\xd3\x59\x27\xe0\x71\xa5\x69\x18\x42\x4c\xfd\x05\x9d\xa1\xb2\xf0\x29\x16\x52\x43\xc7\xfd\xe2\xb5\x7a\x93\xeb\x61\xbf\x37\x76\x7b\x9d\x26\x17\x9c\x71\x8d\x92\xfa\x9a\x2d\xd1\xa2\xb1\x26\x33\xd4\x40\x1e\x20\x89\x03\xaa\x0b\x08\x99\x0a\xe9\x23\x59\xa1\x02\xb2\x02\xf2\xf0\x00\x44\x40\x27\x5e\xcc\x1a\x8d\x7e\xac\x99\xe0\xaa\xd1\x68\x96\xb6\x34\xf3\x89\x0a\x70\x5a\x82\x24\x9e\x49\x1a\x20\xfc\x09\x4e\x80\x4b\x87\x27\x61\x78\xc8\x73\x4b\xff\x29\xef\xec\xfa\x11\xe4\x33\xc6\x8f\x67\x60\x79\x4b\xd2\x4c\x3f\x97\x80\x71\xd4\x66\xc6\x15\xd1\x92\xfa\x28\x45\xa2\x11\x34\x95\x60\xf6\x14\xec\x5d\x7b\x22\x31\x12\xfa\xfd\xf7\xf0\x43\xa4\xdc\x9c\xcb\x80\x9a\x1f\x18\xe6\x4e\x4f\xd5\xde\x8a\xcd\xed\xf2\xa7\x93\x15\x67\x2b\x94\x4b\xe6\xa3\xbd\xdd\x61\xff\x8c\x32\xe0\xdf\x97\xe5\x75\x44\xe7\x5c\x5e\x92\x1c\x33\x75\xe5\x36\xee\x6e\xd3\x5f\xb3\x10\x9b\xfb\xeb\xbf\x70\xe7\xff\x9f\x7d\x46\xf3\x75\x08\x01\x53\x66\xa6\xf3\x53\xfa\x03\x76\x11\xe4\x85\x80\xf5\x06\xdf\xef\xeb\x8c\x6a\x7c\xa4\xab\xc0\x56\x46\xa0\xd3\x36\x4a\x8c\xa9\x44\x98\x0a\x09\x12\xef\x85\xd0\x96\x5a\x71\xdf\xfa\x2f\x00\x00\xff\xff\x80\xe2\x34\x80\xcf\x09\x00\x00")
+var _templatesScalewayBootstrapTmpl = []byte("\x1f\x8b\x08\x00\x00\x00\x00\x00\x02\x03\x95\x56\x61\x6f\xda\x48\x10\xfd\xee\x5f\x31\x47\x51\x93\x54\xb7\x76\x88\x68\x4e\xe1\x8e\x48\x14\x9c\x16\x89\x00\x02\xd2\x5e\x75\x3a\x51\xc7\x5e\x60\x8b\xbd\xeb\xee\xae\x49\x10\x97\xff\x7e\xb3\xb6\x01\x43\x12\x68\x95\x28\x88\x99\xf7\xde\xec\xcc\xce\xcc\xe6\xcd\x6f\xce\x3d\xe3\xce\xbd\xa7\x66\x96\xb5\x5a\x01\x9b\x80\xfd\x49\xeb\xb8\x2f\xc5\xe3\x12\x9e\x9e\xac\x37\x70\xa7\x28\x7c\x1a\x8d\xfa\x10\x1b\x9b\x45\x1f\x63\x21\x35\x70\x31\x4e\xbf\xd7\x57\x2b\xbb\x2b\x52\x38\xa2\x73\xe7\x0c\x15\xb6\xee\x8d\xde\x2e\x40\xbd\x82\xf0\x67\x02\x4a\x26\xe0\xb8\x3f\xe8\xfd\xfd\x75\xcf\x5f\x82\xeb\x6b\x87\x6a\xdf\xa1\x7c\xc1\xa4\xe0\x11\xe5\xba\xc0\x19\xfe\x2a\xa9\xdb\xdb\x32\x36\x79\xbc\x88\xc7\xea\x50\x1e\x64\x35\xb9\x41\xe7\x0c\x8a\x5e\x6b\xd8\xfc\xd2\xee\xd7\xcb\xa7\x33\xa1\x34\xf7\x22\x0a\x40\xda\xf0\x1f\x78\x0f\x73\x38\x59\xc5\x92\x71\x0d\xe5\xca\xd3\xc9\x59\x5e\x65\x2e\x34\x60\xc0\x76\x7f\x51\x35\x92\xc8\xee\xdf\x7d\xe8\xb4\x9b\xa8\xe0\x27\x32\x4c\x2b\x54\x73\x9c\x45\xd5\x8e\x96\x2c\xb6\x39\xe3\xdf\xbd\xb3\xc2\x19\x6e\xdd\x51\xa3\xd5\x18\x35\xea\xdf\x52\x38\x21\x5c\xa4\xe5\x84\x93\x77\x27\x6b\x72\xe5\xf2\xca\xbe\x78\x5f\xb5\xab\x17\xf8\xeb\xf8\x82\x4f\xbe\x59\xb7\xbd\x96\xdb\xc1\x20\x59\xf6\xe5\xb5\x4c\x09\x8f\x4a\xa7\x92\xc6\xd0\xec\xdd\xde\xba\x83\x66\xbb\xd1\x19\x8f\xbe\xf6\xdd\x3a\x3a\x14\x0d\xe0\x44\x39\xfb\x1e\xc7\x99\x62\x3a\xcd\xce\xdd\x70\xe4\x0e\xda\xad\x43\xa2\xa3\xc6\xc7\xe1\xf8\xbc\xa0\x95\x1b\x32\x89\x51\xbb\xdb\x4c\x6b\x77\x98\x5f\xd9\xe7\x57\x72\xfe\xa0\xd7\x71\x87\x47\xe9\x17\xfb\xf4\x8b\x9c\xde\xee\x7f\xbe\x1c\x37\x5a\xad\x81\x3b\x3c\xa8\xb2\x83\xdb\x6a\xed\x98\x33\xc5\xbc\x1f\x7b\xc3\x11\x36\x57\xfb\x73\x63\xe4\x8e\x11\x55\xad\x97\xca\x69\x97\x1c\x68\x61\x43\x31\x82\x08\x2d\xea\xbe\xce\x68\xf6\x06\x6e\x6f\xb8\x1f\x26\xab\xe8\x71\x56\xda\x72\x85\xb3\x65\x86\xd7\x79\x59\xf7\x60\x71\xcc\xe7\x4b\xb0\x68\x1e\x30\x09\x24\x86\xd4\x13\x27\xa1\xbf\xcc\xa8\xe5\x4d\x9f\xc0\xf5\xd6\xe7\xf8\x61\xa2\x34\x95\x84\x05\x96\x3f\x8b\x44\x00\xe7\xd5\xf3\x73\x78\x19\x90\xe9\xac\x53\x2b\x60\x34\xe3\x3e\x61\x71\x0e\x48\xbb\x61\xc7\x2f\x45\x48\x95\x85\x63\xdb\x94\xd4\xd3\x14\x22\xcf\x9f\x31\x4e\x8d\xa6\x8c\x80\x4c\xb2\x78\xb6\xa4\x53\xca\x49\xc1\x99\xe5\x8c\x33\x67\xdf\x66\x46\x3c\x7c\xba\x1c\x32\x42\x01\xb9\xd5\xf6\x85\xa4\x90\x28\x2a\x2d\xf3\xc7\x0b\x02\x20\x01\x38\x33\x11\x51\x27\x75\x91\x8f\x10\x08\x7f\x4e\xe5\xef\x6a\x89\x89\x45\x01\xf9\x2e\x12\xc9\x3d\x1c\x61\x3c\xca\x1d\x90\x04\xde\x63\x05\x88\x82\xcd\x62\x36\xe5\x2c\x9f\x26\x09\x0b\xf0\x7c\x67\x69\x84\x42\x9d\x37\xd2\x8e\xad\x70\x89\xfb\x31\x10\x09\x98\xb2\xd0\xa9\xc1\x79\xb7\x0f\x71\xb0\xd2\xe2\x81\x03\x19\xa4\x52\x76\x7a\xac\x67\x32\xe9\x65\x20\x44\x4c\x89\x7c\x78\x7c\xe6\xcf\x4a\x93\x52\x1b\x9d\x4e\xfd\x14\xff\x9c\x41\xb7\xd7\x6f\x0c\x87\x5f\x5a\x35\x63\x33\x3b\x34\xab\x93\x4a\x02\x41\x65\x7a\x01\x1d\xc6\xe7\x90\x68\x16\x32\xcd\xf0\x4a\x7c\x2c\x4d\xa2\xa4\xc9\x14\xde\xbe\x85\x90\x6f\xf2\xce\xf2\xdb\xb5\xc9\xe8\xb9\x2d\x7d\xb8\xd6\x32\x6a\x57\xc7\x7c\x75\x42\x65\x12\xd9\x33\xa2\x09\x97\xe5\x3d\x3d\xc8\x64\xb1\xf6\xee\xb1\x71\x88\xf2\x16\xf4\x55\xa7\xa4\x4a\x9b\x2a\xbc\xe2\x3f\x12\xe1\xf2\x60\x88\xcb\x63\x31\x72\x80\xa9\xec\x0d\x7b\x04\xf3\xf4\x28\xcb\xec\x90\xc2\x3b\xb4\xde\x46\x95\x8b\x3f\xec\x73\xfc\xa9\x40\x79\x65\x20\x4f\xdb\x0b\xca\x78\x28\xd2\xe6\x4a\x7b\x61\x08\xb1\xe7\xcf\xbd\x29\x0a\xe7\xcf\x75\xcb\xfd\xd0\x6e\x74\xc7\x37\x83\x5e\x77\xe4\x76\x5b\x75\x2e\xf0\x3d\xc2\xa9\xf4\x7c\xcd\x16\xd4\xf2\x62\x4d\xa6\x54\x03\xf9\x01\x49\x1c\xe0\x14\x6c\x2d\x64\x22\xa4\x4f\xc9\x92\x2a\x20\x4b\x04\xfc\x00\x22\xa0\x15\xcf\xa7\xb5\x5a\x2f\xd6\x4c\x70\x55\xab\xd5\x4b\x6b\x98\x79\xa2\x02\x3a\x29\xa1\xcc\x14\x67\x87\xc2\x5f\xe0\x04\x74\xe1\xf0\x24\x0c\x0f\x69\xae\xe1\x3f\xa5\x9d\x8d\x1f\xa1\x7c\x8a\xc3\x7b\x34\x02\xcb\x4b\x92\x46\xfa\xb9\x00\x28\xab\x4d\x8f\x2b\xa2\xb1\x42\x54\x8a\x04\xf7\x82\xf6\x24\x98\x3d\x05\x7b\x63\x8f\x97\x1b\x09\xfd\xf2\x39\xfc\x90\x7a\xdc\xdc\x4b\xdf\x33\xff\x6e\x98\x99\x9e\xa8\xbd\x15\x9b\xcb\xe5\x9f\x4e\x96\x9c\x8d\xab\x67\xc1\x7c\x6a\xaf\x77\xd8\x3f\xc3\xcc\xf0\xef\x66\x79\x1d\xe1\x39\x57\x57\x24\xb7\x99\xbc\x72\x19\x77\xbb\xe9\x6f\x58\x48\xeb\xfb\xeb\xbf\x30\xf3\xbf\x26\x9f\xc1\x7c\x1d\x42\xc0\x94\xe9\xe9\xfc\x96\xfe\x84\xad\x87\xf2\x82\xc3\x7a\x66\xdf\xaf\xeb\x14\xfb\xf0\xc1\x5b\x06\xb6\x32\x04\x9d\x96\x11\x5f\x71\x0f\xe7\x08\xef\x0b\x24\xbd\xc7\x72\xa2\x0c\xf7\xad\xff\x01\xaa\xce\x74\xe8\x05\x0b\x00\x00")
 
 func templatesScalewayBootstrapTmplBytes() ([]byte, error) {
 	return bindataRead(
@@ -190,7 +190,7 @@ func templatesScalewayBootstrapTmpl() (*asset, error) {
 		return nil, err
 	}
 
-	info := bindataFileInfo{name: "templates/scaleway-bootstrap.tmpl", size: 2511, mode: os.FileMode(420), modTime: time.Unix(1484490255, 0)}
+	info := bindataFileInfo{name: "templates/scaleway-bootstrap.tmpl", size: 2821, mode: os.FileMode(420), modTime: time.Unix(1484490255, 0)}
 	a := &asset{bytes: bytes, info: info}
 	return a, nil
 }
@@ -0,0 +1,192 @@
+// Copyright (c) 2016 Pulcy.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var (
+	yamlPlainPattern = regexp.MustCompile(`^[A-Za-z_/.][A-Za-z0-9_/.@()+=,-]*( [A-Za-z0-9_/.@()+=,-]+)*$`)
+	yamlReserved     = []string{"true", "false", "yes", "no", "on", "off", "null", "y", "n", ".inf", ".nan"}
+)
+
+// yamlMapEntry is a single key/value pair of a YAML mapping.
+type yamlMapEntry struct {
+	key   string
+	value interface{}
+}
+
+// yamlMap is a YAML mapping that preserves the order of its keys.
+type yamlMap []yamlMapEntry
+
+// marshalYAML encodes the given value as a YAML document.
+// The value is first encoded as JSON, so `json` struct tags (including `omitempty`)
+// and the order of fields are respected.
+func marshalYAML(v interface{}) ([]byte, error) {
+	raw, err := json.Marshal(v)
+	if err != nil {
+		return nil, maskAny(err)
+	}
+	decoder := json.NewDecoder(bytes.NewReader(raw))
+	decoder.UseNumber()
+	value, err := decodeYAMLValue(decoder)
+	if err != nil {
+		return nil, maskAny(err)
+	}
+	lines := []string{}
+	switch value := value.(type) {
+	case yamlMap:
+		if len(value) == 0 {
+			lines = append(lines, "{}")
+		} else {
+			lines = appendYAMLMap(lines, value, "")
+		}
+	case []interface{}:
+		if len(value) == 0 {
+			lines = append(lines, "[]")
+		} else {
+			lines = appendYAMLList(lines, value, "")
+		}
+	default:
+		lines = append(lines, formatYAMLScalar(value))
+	}
+	return []byte(strings.Join(lines, "\n") + "\n"), nil
+}
+
+// decodeYAMLValue reads the next JSON value from the given decoder.
+// Objects are returned as yamlMap, arrays as []interface{}.
+func decodeYAMLValue(decoder *json.Decoder) (interface{}, error) {
+	token, err := decoder.Token()
+	if err != nil {
+		return nil, maskAny(err)
+	}
+	switch token {
+	case json.Delim('{'):
+		result := yamlMap{}
+		for decoder.More() {
+			key, err := decoder.Token()
+			if err != nil {
+				return nil, maskAny(err)
+			}
+			value, err := decodeYAMLValue(decoder)
+			if err != nil {
+				return nil, maskAny(err)
+			}
+			result = append(result, yamlMapEntry{key: key.(string), value: value})
+		}
+		if _, err := decoder.Token(); err != nil {
+			return nil, maskAny(err)
+		}
+		return result, nil
+	case json.Delim('['):
+		result := []interface{}{}
+		for decoder.More() {
+			value, err := decodeYAMLValue(decoder)
+			if err != nil {
+				return nil, maskAny(err)
+			}
+			result = append(result, value)
+		}
+		if _, err := decoder.Token(); err != nil {
+			return nil, maskAny(err)
+		}
+		return result, nil
+	default:
+		return token, nil
+	}
+}
+
+func appendYAMLMap(lines []string, m yamlMap, indent string) []string {
+	for _, entry := range m {
+		key := formatYAMLScalar(entry.key)
+		switch value := entry.value.(type) {
+		case yamlMap:
+			if len(value) == 0 {
+				lines = append(lines, indent+key+": {}")
+			} else {
+				lines = append(lines, indent+key+":")
+				lines = appendYAMLMap(lines, value, indent+"  ")
+			}
+		case []interface{}:
+			if len(value) == 0 {
+				lines = append(lines, indent+key+": []")
+			} else {
+				lines = append(lines, indent+key+":")
+				lines = appendYAMLList(lines, value, indent+"  ")
+			}
+		default:
+			lines = append(lines, indent+key+": "+formatYAMLScalar(value))
+		}
+	}
+	return lines
+}
+
+func appendYAMLList(lines []string, list []interface{}, indent string) []string {
+	for _, item := range list {
+		switch value := item.(type) {
+		case yamlMap:
+			if len(value) == 0 {
+				lines = append(lines, indent+"- {}")
+			} else {
+				// Put the first key on the same line as the dash
+				itemLines := appendYAMLMap(nil, value, indent+"  ")
+				itemLines[0] = indent + "- " + strings.TrimPrefix(itemLines[0], indent+"  ")
+				lines = append(lines, itemLines...)
+			}
+		case []interface{}:
+			if len(value) == 0 {
+				lines = append(lines, indent+"- []")
+			} else {
+				lines = append(lines, indent+"-")
+				lines = appendYAMLList(lines, value, indent+"  ")
+			}
+		default:
+			lines = append(lines, indent+"- "+formatYAMLScalar(value))
+		}
+	}
+	return lines
+}
+
+func formatYAMLScalar(value interface{}) string {
+	switch value := value.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return strconv.FormatBool(value)
+	case json.Number:
+		return value.String()
+	case string:
+		if !yamlPlainPattern.MatchString(value) {
+			return strconv.Quote(value)
+		}
+		for _, r := range yamlReserved {
+			if strings.EqualFold(value, r) {
+				return strconv.Quote(value)
+			}
+		}
+		if _, err := strconv.ParseFloat(value, 64); err == nil {
+			return strconv.Quote(value)
+		}
+		return value
+	default:
+		return strconv.Quote(fmt.Sprintf("%v", value))
+	}
+}
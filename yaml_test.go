@@ -0,0 +1,94 @@
+// Copyright (c) 2016 Pulcy.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"testing"
+)
+
+func TestMarshalYAMLScalars(t *testing.T) {
+	tests := []struct {
+		Value    interface{}
+		Expected string
+	}{
+		{"plain", "plain\n"},
+		{"two words", "two words\n"},
+		{"ns1.example.com", "ns1.example.com\n"},
+		{"yes", "\"yes\"\n"},
+		{"No", "\"No\"\n"},
+		{"null", "\"null\"\n"},
+		{".inf", "\".inf\"\n"},
+		{"a: b", "\"a: b\"\n"},
+		{"key:", "\"key:\"\n"},
+		{"#comment", "\"#comment\"\n"},
+		{"a #b", "\"a #b\"\n"},
+		{"-leading", "\"-leading\"\n"},
+		{"- item", "\"- item\"\n"},
+		{"123", "\"123\"\n"},
+		{"1.5", "\"1.5\"\n"},
+		{"", "\"\"\n"},
+		{" padded", "\" padded\"\n"},
+		{"line\nbreak", "\"line\\nbreak\"\n"},
+		{"*alias", "\"*alias\"\n"},
+		{"{}", "\"{}\"\n"},
+		{42, "42\n"},
+		{1.5, "1.5\n"},
+		{true, "true\n"},
+		{nil, "null\n"},
+	}
+	for _, test := range tests {
+		raw, err := marshalYAML(test.Value)
+		if err != nil {
+			t.Errorf("marshalYAML(%#v) failed: %v", test.Value, err)
+		} else if string(raw) != test.Expected {
+			t.Errorf("marshalYAML(%#v): expected %q, got %q", test.Value, test.Expected, string(raw))
+		}
+	}
+}
+
+func TestMarshalYAMLCollections(t *testing.T) {
+	type member struct {
+		Name  string   `json:"name"`
+		Roles []string `json:"roles,omitempty"`
+		Empty struct{} `json:"empty"`
+	}
+	tests := []struct {
+		Value    interface{}
+		Expected string
+	}{
+		{map[string]interface{}{}, "{}\n"},
+		{[]string{}, "[]\n"},
+		{[]string{"a", "yes"}, "- a\n- \"yes\"\n"},
+		{[][]string{{"a", "b"}, {}, {"c"}}, "-\n  - a\n  - b\n- []\n-\n  - c\n"},
+		{map[string]interface{}{"empty": map[string]interface{}{}, "list": []int{}}, "empty: {}\nlist: []\n"},
+		{map[string]interface{}{"yes": "no", "a:b": 1}, "\"a:b\": 1\n\"yes\": \"no\"\n"},
+		{
+			[]member{{Name: "m1", Roles: []string{"core", "lb"}}, {Name: "-m2"}},
+			"- name: m1\n  roles:\n    - core\n    - lb\n  empty: {}\n- name: \"-m2\"\n  empty: {}\n",
+		},
+		{
+			map[string]interface{}{"members": []interface{}{map[string]interface{}{}, []interface{}{[]string{"x"}}}},
+			"members:\n  - {}\n  -\n    -\n      - x\n",
+		},
+	}
+	for _, test := range tests {
+		raw, err := marshalYAML(test.Value)
+		if err != nil {
+			t.Errorf("marshalYAML(%#v) failed: %v", test.Value, err)
+		} else if string(raw) != test.Expected {
+			t.Errorf("marshalYAML(%#v): expected %q, got %q", test.Value, test.Expected, string(raw))
+		}
+	}
+}